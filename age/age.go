@@ -0,0 +1,173 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package age implements the age v1 file encryption format
+// (https://age-encryption.org/v1) with its post-quantum
+// mlkem768x25519 recipient type. Files produced here decrypt with
+// other age implementations that support mlkem768x25519 recipients,
+// without needing a plugin binary.
+//
+// The package provides the recipient and identity types with their
+// Bech32 encodings, a reader and writer for the age header, and the
+// STREAM payload encryption, built on KemEncrypt768 and KemDecrypt768.
+package age
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	// ErrMalformedHeader is returned when the age header cannot be parsed
+	// or a recipient stanza is malformed.
+	ErrMalformedHeader = errors.New("age: malformed header")
+
+	// ErrHeaderMAC is returned when the header MAC does not verify.
+	ErrHeaderMAC = errors.New("age: header MAC mismatch")
+
+	// ErrNoIdentityMatch is returned when none of the identities can
+	// unwrap the file key from any recipient stanza.
+	ErrNoIdentityMatch = errors.New("age: no identity matched any of the recipients")
+
+	// ErrIncorrectIdentity is returned by an Identity when a stanza is not
+	// addressed to it. Decrypt then moves on to the next identity.
+	ErrIncorrectIdentity = errors.New("age: incorrect identity for recipient block")
+
+	// ErrMalformedPayload is returned when the payload is truncated or a
+	// payload chunk fails to authenticate.
+	ErrMalformedPayload = errors.New("age: malformed or tampered payload")
+
+	// ErrTrailingData is returned when data follows the final payload chunk.
+	ErrTrailingData = errors.New("age: trailing data after end of encrypted file")
+
+	// ErrInvalidRecipient is returned for a malformed recipient encoding.
+	ErrInvalidRecipient = errors.New("age: invalid recipient")
+
+	// ErrInvalidIdentity is returned for a malformed identity encoding.
+	ErrInvalidIdentity = errors.New("age: invalid identity")
+)
+
+// Recipient wraps a file key into one or more header stanzas.
+type Recipient interface {
+	Wrap(fileKey []byte) ([]*Stanza, error)
+}
+
+// Identity unwraps a file key from the header stanzas. It returns
+// ErrIncorrectIdentity if none of the stanzas is addressed to it.
+type Identity interface {
+	Unwrap(stanzas []*Stanza) ([]byte, error)
+}
+
+// Encrypt writes an age header for the recipients to dst and returns a
+// WriteCloser that encrypts the payload. Close must be called to
+// write the final chunk.
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, ErrInvalidRecipient
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(fileKey)
+	h := new(Header)
+	for _, r := range recipients {
+		stanzas, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		h.Recipients = append(h.Recipients, stanzas...)
+	}
+	mac, err := headerMAC(fileKey, h)
+	if err != nil {
+		return nil, err
+	}
+	h.MAC = mac
+	if err := h.Marshal(dst); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(nonce); err != nil {
+		return nil, err
+	}
+	key := payloadKey(fileKey, nonce)
+	defer mlkem.ZeroBytes(key)
+	return NewStreamWriter(key, dst)
+}
+
+// Decrypt parses the age header from src, unwraps the file key with
+// the first matching identity, verifies the header MAC and returns a
+// Reader of the decrypted payload. Payload errors, including
+// truncation, are returned by the Reader.
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	h, payload, err := ParseHeader(src)
+	if err != nil {
+		return nil, err
+	}
+	var fileKey []byte
+	for _, id := range identities {
+		fileKey, err = id.Unwrap(h.Recipients)
+		if err == ErrIncorrectIdentity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if fileKey == nil {
+		return nil, ErrNoIdentityMatch
+	}
+	defer mlkem.ZeroBytes(fileKey)
+	if len(fileKey) != fileKeySize {
+		return nil, ErrMalformedHeader
+	}
+	mac, err := headerMAC(fileKey, h)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, h.MAC) {
+		return nil, ErrHeaderMAC
+	}
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(payload, nonce); err != nil {
+		return nil, ErrMalformedPayload
+	}
+	key := payloadKey(fileKey, nonce)
+	defer mlkem.ZeroBytes(key)
+	return NewStreamReader(key, payload)
+}
+
+// headerMAC computes HMAC-SHA256 over the header up to the footer
+// prefix, keyed with HKDF-SHA256(fileKey, "header").
+func headerMAC(fileKey []byte, h *Header) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, nil, []byte("header")), key); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(key)
+	var buf bytes.Buffer
+	if err := h.MarshalWithoutMAC(&buf); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(buf.Bytes())
+	return mac.Sum(nil), nil
+}
+
+// payloadKey derives the STREAM key as HKDF-SHA256(fileKey, nonce, "payload").
+func payloadKey(fileKey, nonce []byte) []byte {
+	key := make([]byte, 32)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, fileKey, nonce, []byte("payload")), key)
+	return key
+}
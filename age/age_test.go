@@ -0,0 +1,251 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"bytes"
+	"crypto/hpke"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func encryptTestFile(t *testing.T, plaintext []byte, recipients ...Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	id, err := GenerateHybridIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17} {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+		file := encryptTestFile(t, plaintext, id.Recipient())
+		r, err := Decrypt(bytes.NewReader(file), id)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: plaintext mismatch", size)
+		}
+	}
+}
+
+func TestMultipleRecipients(t *testing.T) {
+	a, _ := GenerateHybridIdentity()
+	b, _ := GenerateHybridIdentity()
+	c, _ := GenerateHybridIdentity()
+	file := encryptTestFile(t, []byte("backup"), a.Recipient(), b.Recipient())
+	for _, id := range []*HybridIdentity{a, b} {
+		r, err := Decrypt(bytes.NewReader(file), c, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != "backup" {
+			t.Fatal("plaintext mismatch")
+		}
+	}
+	if _, err := Decrypt(bytes.NewReader(file), c); err != ErrNoIdentityMatch {
+		t.Fatalf("expected ErrNoIdentityMatch, got %v", err)
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	id, _ := GenerateHybridIdentity()
+	s := id.String()
+	if !strings.HasPrefix(s, "AGE-SECRET-KEY-PQ-1") || strings.ToUpper(s) != s {
+		t.Fatalf("unexpected identity encoding %q", s)
+	}
+	parsed, err := ParseHybridIdentity(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != s || parsed.Recipient().String() != id.Recipient().String() {
+		t.Fatal("identity round trip mismatch")
+	}
+	rs := id.Recipient().String()
+	if !strings.HasPrefix(rs, "age1pq1") {
+		t.Fatalf("unexpected recipient encoding %q", rs)
+	}
+	r, err := ParseHybridRecipient(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != rs {
+		t.Fatal("recipient round trip mismatch")
+	}
+	if _, err := ParseHybridRecipient(rs[:len(rs)-1] + "q"); err != ErrInvalidRecipient {
+		t.Fatalf("expected ErrInvalidRecipient for bad checksum, got %v", err)
+	}
+	if _, err := ParseHybridRecipient(strings.ToUpper(rs[:8]) + rs[8:]); err != ErrInvalidRecipient {
+		t.Fatalf("expected ErrInvalidRecipient for mixed case, got %v", err)
+	}
+	if _, err := ParseHybridIdentity(rs); err != ErrInvalidIdentity {
+		t.Fatalf("expected ErrInvalidIdentity, got %v", err)
+	}
+}
+
+func TestBech32Vectors(t *testing.T) {
+	// Valid strings from BIP 173.
+	for _, s := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		hrp, data, err := bech32Decode(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		again, err := bech32Encode(hrp, data)
+		if err != nil || again != strings.ToLower(s) {
+			t.Fatalf("%s: re-encoded as %q", s, again)
+		}
+	}
+	for _, s := range []string{"x1b4n0q5v", "A1G7SGD8", "li1dgmt3", "de1lg7wt\xff"} {
+		if _, _, err := bech32Decode(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+// TestHPKEInterop checks the stanza against the standard library's
+// HPKE implementation of MLKEM768-X25519 in both directions.
+func TestHPKEInterop(t *testing.T) {
+	id, _ := GenerateHybridIdentity()
+	kem := hpke.MLKEM768X25519()
+	sk, err := kem.NewPrivateKey(id.seed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sk.PublicKey().Bytes(), append(id.recipient.ek[:], id.recipient.x[:]...)) {
+		t.Fatal("public key mismatch")
+	}
+	fileKey := make([]byte, fileKeySize)
+	_, _ = rand.Read(fileKey)
+
+	stanzas, err := id.Recipient().Wrap(fileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := b64.DecodeString(stanzas[0].Args[0])
+	r, err := hpke.NewRecipient(enc, sk, hpke.HKDFSHA256(), hpke.ChaCha20Poly1305(), []byte(hybridHPKEInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Open(nil, stanzas[0].Body)
+	if err != nil || !bytes.Equal(got, fileKey) {
+		t.Fatalf("HPKE could not open the stanza: %v", err)
+	}
+
+	enc, s, err := hpke.NewSender(sk.PublicKey(), hpke.HKDFSHA256(), hpke.ChaCha20Poly1305(), []byte(hybridHPKEInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := s.Seal(nil, fileKey)
+	got, err = id.Unwrap([]*Stanza{{Type: hybridStanzaType, Args: []string{b64.EncodeToString(enc)}, Body: body}})
+	if err != nil || !bytes.Equal(got, fileKey) {
+		t.Fatalf("could not unwrap an HPKE stanza: %v", err)
+	}
+}
+
+func TestHeaderEncoding(t *testing.T) {
+	h := &Header{
+		Recipients: []*Stanza{
+			{Type: "X", Args: []string{"a", "b"}, Body: make([]byte, 2*bytesPerLine)},
+			{Type: "Y", Body: []byte("short")},
+		},
+		MAC: make([]byte, headerMACSize),
+	}
+	var buf bytes.Buffer
+	if err := h.Marshal(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	// A body that fills whole lines ends with an empty line.
+	if lines[1] != "-> X a b" || len(lines[2]) != columnsPerLine || lines[4] != "" {
+		t.Fatalf("unexpected header encoding:\n%s", buf.String())
+	}
+	buf.WriteString("payload")
+	parsed, payload, err := ParseHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Recipients) != 2 || !bytes.Equal(parsed.Recipients[0].Body, h.Recipients[0].Body) ||
+		!bytes.Equal(parsed.Recipients[1].Body, h.Recipients[1].Body) {
+		t.Fatal("header round trip mismatch")
+	}
+	if rest, _ := io.ReadAll(payload); string(rest) != "payload" {
+		t.Fatalf("unexpected payload %q", rest)
+	}
+	for _, bad := range []string{
+		"age-encryption.org/v2\n--- AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\n",
+		"age-encryption.org/v1\r\n--- AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\n",
+		"age-encryption.org/v1\n-> X\nAAAA=\n--- AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\n",
+		"age-encryption.org/v1\n-> X  a\n\n--- AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA\n",
+		"age-encryption.org/v1\n-> X\n\n--- AAAA\n",
+	} {
+		if _, _, err := ParseHeader(strings.NewReader(bad)); err != ErrMalformedHeader {
+			t.Fatalf("%q: expected ErrMalformedHeader, got %v", bad, err)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	id, _ := GenerateHybridIdentity()
+	plaintext := make([]byte, 2*ChunkSize+5)
+	file := encryptTestFile(t, plaintext, id.Recipient())
+	headerLen := bytes.Index(file, []byte("\n---")) + 1
+	headerLen += bytes.IndexByte(file[headerLen:], '\n') + 1
+
+	mac := bytes.Clone(file)
+	mac[headerLen-2] ^= 0x01
+	if _, err := Decrypt(bytes.NewReader(mac), id); err == nil {
+		t.Fatal("expected header MAC failure")
+	}
+
+	readAll := func(file []byte) error {
+		r, err := Decrypt(bytes.NewReader(file), id)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+	flipped := bytes.Clone(file)
+	flipped[len(flipped)-1] ^= 0x01
+	if err := readAll(flipped); err != ErrMalformedPayload {
+		t.Fatalf("expected ErrMalformedPayload for flipped byte, got %v", err)
+	}
+	// Truncating at a chunk boundary drops the final chunk.
+	truncated := file[:headerLen+16+2*encryptedChunkSize]
+	if err := readAll(truncated); err != ErrMalformedPayload {
+		t.Fatalf("expected ErrMalformedPayload for truncation, got %v", err)
+	}
+	// A full final chunk followed by more data is trailing data.
+	full := encryptTestFile(t, make([]byte, ChunkSize), id.Recipient())
+	if err := readAll(append(full, 0)); !errors.Is(err, ErrTrailingData) {
+		t.Fatalf("expected ErrTrailingData, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"errors"
+	"strings"
+)
+
+// bech32Charset is the data alphabet from BIP 173.
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// errBech32 is returned for any malformed Bech32 string.
+var errBech32 = errors.New("age: malformed bech32 string")
+
+// bech32Polymod computes the BIP 173 checksum polynomial.
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand expands the human-readable part for checksumming.
+func bech32HRPExpand(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// bech32ConvertBits regroups a byte slice from fromBits-bit groups
+// to toBits-bit groups.
+func bech32ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	maxv := uint32(1)<<toBits - 1
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, errBech32
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errBech32
+	}
+	return out, nil
+}
+
+// bech32Encode encodes data as a lowercase Bech32 string. Unlike
+// BIP 173, and as in age, the string length is not limited.
+func bech32Encode(hrp string, data []byte) (string, error) {
+	hrp = strings.ToLower(hrp)
+	values, err := bech32ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	polymodInput := append(bech32HRPExpand(hrp), values...)
+	polymodInput = append(polymodInput, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(polymodInput) ^ 1
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range values {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// bech32Decode decodes a single-case Bech32 string, returning its
+// human-readable part in the case it was given in.
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errBech32
+	}
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errBech32
+	}
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, errBech32
+		}
+	}
+	lower := strings.ToLower(s)
+	values := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(lower); i++ {
+		d := strings.IndexByte(bech32Charset, lower[i])
+		if d < 0 {
+			return "", nil, errBech32
+		}
+		values = append(values, byte(d))
+	}
+	if bech32Polymod(append(bech32HRPExpand(lower[:pos]), values...)) != 1 {
+		return "", nil, errBech32
+	}
+	data, err := bech32ConvertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
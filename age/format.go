@@ -0,0 +1,185 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+const (
+	headerIntro    = "age-encryption.org/v1\n"
+	stanzaPrefix   = "->"
+	footerPrefix   = "---"
+	columnsPerLine = 64
+	bytesPerLine   = columnsPerLine / 4 * 3
+	headerMACSize  = 32
+)
+
+// b64 is the canonical, unpadded base64 encoding used throughout
+// the age header.
+var b64 = base64.RawStdEncoding.Strict()
+
+// Stanza is a recipient stanza of the age header: a type, zero or
+// more arguments, and a binary body.
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Header is a parsed age v1 header.
+type Header struct {
+	Recipients []*Stanza
+	MAC        []byte
+}
+
+// Marshal writes the stanza in its wrapped base64 header encoding.
+func (s *Stanza) Marshal(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(stanzaPrefix)
+	for _, arg := range append([]string{s.Type}, s.Args...) {
+		buf.WriteByte(' ')
+		buf.WriteString(arg)
+	}
+	buf.WriteByte('\n')
+	body := b64.EncodeToString(s.Body)
+	for len(body) >= columnsPerLine {
+		buf.WriteString(body[:columnsPerLine])
+		buf.WriteByte('\n')
+		body = body[columnsPerLine:]
+	}
+	// The body always ends with a line shorter than a full
+	// line, which may be empty.
+	buf.WriteString(body)
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// MarshalWithoutMAC writes the header up to and including the "---"
+// footer prefix; these are the bytes covered by the header MAC.
+func (h *Header) MarshalWithoutMAC(w io.Writer) error {
+	if _, err := io.WriteString(w, headerIntro); err != nil {
+		return err
+	}
+	for _, s := range h.Recipients {
+		if err := s.Marshal(w); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, footerPrefix)
+	return err
+}
+
+// Marshal writes the complete header, including the MAC line.
+func (h *Header) Marshal(w io.Writer) error {
+	if err := h.MarshalWithoutMAC(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, " "+b64.EncodeToString(h.MAC)+"\n")
+	return err
+}
+
+// ParseHeader reads an age v1 header from r. It returns the header and
+// a reader positioned at the start of the payload.
+func ParseHeader(r io.Reader) (*Header, io.Reader, error) {
+	br := bufio.NewReader(r)
+	intro, err := br.ReadString('\n')
+	if err != nil || intro != headerIntro {
+		return nil, nil, ErrMalformedHeader
+	}
+	h := new(Header)
+	for {
+		peek, err := br.Peek(len(footerPrefix))
+		if err != nil {
+			return nil, nil, ErrMalformedHeader
+		}
+		if string(peek) == footerPrefix {
+			break
+		}
+		s, err := readStanza(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.Recipients = append(h.Recipients, s)
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, nil, ErrMalformedHeader
+	}
+	args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(args) != 2 || args[0] != footerPrefix {
+		return nil, nil, ErrMalformedHeader
+	}
+	h.MAC, err = decodeBase64(args[1])
+	if err != nil || len(h.MAC) != headerMACSize {
+		return nil, nil, ErrMalformedHeader
+	}
+	if br == r {
+		return h, br, nil
+	}
+	// Hand back the bytes bufio read ahead of the payload.
+	buffered, _ := br.Peek(br.Buffered())
+	return h, io.MultiReader(bytes.NewReader(buffered), r), nil
+}
+
+// readStanza reads one stanza: an argument line followed by base64
+// body lines, the last of which is shorter than a full line.
+func readStanza(br *bufio.Reader) (*Stanza, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, ErrMalformedHeader
+	}
+	args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(args) < 2 || args[0] != stanzaPrefix {
+		return nil, ErrMalformedHeader
+	}
+	for _, arg := range args[1:] {
+		if !isValidArgument(arg) {
+			return nil, ErrMalformedHeader
+		}
+	}
+	s := &Stanza{Type: args[1], Args: args[2:]}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, ErrMalformedHeader
+		}
+		b, err := decodeBase64(strings.TrimSuffix(line, "\n"))
+		if err != nil || len(b) > bytesPerLine {
+			return nil, ErrMalformedHeader
+		}
+		s.Body = append(s.Body, b...)
+		if len(b) < bytesPerLine {
+			return s, nil
+		}
+	}
+}
+
+// decodeBase64 decodes canonical unpadded base64, rejecting the
+// newlines that the standard decoder would otherwise skip.
+func decodeBase64(s string) ([]byte, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, ErrMalformedHeader
+	}
+	return b64.DecodeString(s)
+}
+
+// isValidArgument reports whether s is a non-empty string of
+// printable, non-space ASCII characters.
+func isValidArgument(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,288 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	hybridStanzaType   = "mlkem768x25519"
+	hybridHPKEInfo     = "age-encryption.org/mlkem768x25519"
+	hybridRecipientHRP = "age1pq"
+	hybridIdentityHRP  = "AGE-SECRET-KEY-PQ-"
+	hybridSeedSize     = 32
+	hybridPublicSize   = kyberk2so.Kyber768PKBytes + curve25519.PointSize
+	hybridEncSize      = kyberk2so.Kyber768CTBytes + curve25519.PointSize
+	fileKeySize        = 16
+)
+
+// xwingLabel is the MLKEM768-X25519 (X-Wing) combiner label, `\.//^\`.
+const xwingLabel = "\\.//^\\"
+
+// hpkeSuiteID is the HPKE suite identifier for the MLKEM768-X25519 KEM
+// (0x647a), HKDF-SHA256 (0x0001) and ChaCha20-Poly1305 (0x0003).
+var hpkeSuiteID = []byte{'H', 'P', 'K', 'E', 0x64, 0x7a, 0x00, 0x01, 0x00, 0x03}
+
+// HybridRecipient is an mlkem768x25519 recipient: an ML-KEM-768
+// encapsulation key and an X25519 public key. File keys wrapped to it
+// are sealed with HPKE over the MLKEM768-X25519 hybrid KEM.
+type HybridRecipient struct {
+	ek [kyberk2so.Kyber768PKBytes]byte
+	x  [curve25519.PointSize]byte
+}
+
+// HybridIdentity is the mlkem768x25519 identity matching a
+// HybridRecipient. It is encoded as the 32-byte seed from which both
+// the ML-KEM-768 and the X25519 keys are expanded.
+type HybridIdentity struct {
+	seed      [hybridSeedSize]byte
+	dk        [kyberk2so.Kyber768SKBytes]byte
+	scalar    [curve25519.ScalarSize]byte
+	recipient HybridRecipient
+}
+
+// GenerateHybridIdentity returns a new random HybridIdentity.
+func GenerateHybridIdentity() (*HybridIdentity, error) {
+	var seed [hybridSeedSize]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	return newHybridIdentity(seed)
+}
+
+// newHybridIdentity expands seed with SHAKE256 into the 64-byte
+// ML-KEM-768 seed (d || z) followed by the X25519 scalar.
+func newHybridIdentity(seed [hybridSeedSize]byte) (*HybridIdentity, error) {
+	i := &HybridIdentity{seed: seed}
+	xof := sha3.NewShake256()
+	_, _ = xof.Write(seed[:])
+	var coins [64]byte
+	_, _ = xof.Read(coins[:])
+	_, _ = xof.Read(i.scalar[:])
+	dk, ek, err := kyberk2so.KemKeypairDerand768(coins)
+	mlkem.ZeroBytes(coins[:])
+	if err != nil {
+		return nil, err
+	}
+	i.dk, i.recipient.ek = dk, ek
+	x, err := curve25519.X25519(i.scalar[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	copy(i.recipient.x[:], x)
+	return i, nil
+}
+
+// ParseHybridIdentity parses an identity in its Bech32 encoding with
+// the "AGE-SECRET-KEY-PQ-1" prefix.
+func ParseHybridIdentity(s string) (*HybridIdentity, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil || !strings.EqualFold(hrp, hybridIdentityHRP) || len(data) != hybridSeedSize {
+		return nil, ErrInvalidIdentity
+	}
+	return newHybridIdentity([hybridSeedSize]byte(data))
+}
+
+// String returns the Bech32 encoding of the identity.
+func (i *HybridIdentity) String() string {
+	s, _ := bech32Encode(hybridIdentityHRP, i.seed[:])
+	return strings.ToUpper(s)
+}
+
+// Recipient returns the HybridRecipient matching the identity.
+func (i *HybridIdentity) Recipient() *HybridRecipient {
+	r := i.recipient
+	return &r
+}
+
+// ParseHybridRecipient parses a recipient in its Bech32 encoding with
+// the "age1pq1" prefix.
+func ParseHybridRecipient(s string) (*HybridRecipient, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil || hrp != hybridRecipientHRP || len(data) != hybridPublicSize {
+		return nil, ErrInvalidRecipient
+	}
+	r := new(HybridRecipient)
+	copy(r.ek[:], data[:kyberk2so.Kyber768PKBytes])
+	copy(r.x[:], data[kyberk2so.Kyber768PKBytes:])
+	return r, nil
+}
+
+// String returns the Bech32 encoding of the recipient.
+func (r *HybridRecipient) String() string {
+	data := make([]byte, 0, hybridPublicSize)
+	data = append(data, r.ek[:]...)
+	data = append(data, r.x[:]...)
+	s, _ := bech32Encode(hybridRecipientHRP, data)
+	return s
+}
+
+// Wrap encapsulates to the recipient and seals fileKey into a single
+// mlkem768x25519 stanza.
+func (r *HybridRecipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	enc, ss, err := r.encapsulate()
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	aead, nonce, err := hpkeContext(ss)
+	if err != nil {
+		return nil, err
+	}
+	return []*Stanza{{
+		Type: hybridStanzaType,
+		Args: []string{b64.EncodeToString(enc)},
+		Body: aead.Seal(nil, nonce, fileKey, nil),
+	}}, nil
+}
+
+// Unwrap returns the file key from the first mlkem768x25519 stanza that
+// opens under the identity, or ErrIncorrectIdentity if none does.
+func (i *HybridIdentity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		fileKey, err := i.unwrap(s)
+		if err == ErrIncorrectIdentity {
+			continue
+		}
+		return fileKey, err
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+// unwrap opens a single stanza.
+func (i *HybridIdentity) unwrap(s *Stanza) ([]byte, error) {
+	if s.Type != hybridStanzaType {
+		return nil, ErrIncorrectIdentity
+	}
+	if len(s.Args) != 1 {
+		return nil, ErrMalformedHeader
+	}
+	enc, err := b64.DecodeString(s.Args[0])
+	if err != nil || len(enc) != hybridEncSize {
+		return nil, ErrMalformedHeader
+	}
+	if len(s.Body) != fileKeySize+chacha20poly1305.Overhead {
+		return nil, ErrMalformedHeader
+	}
+	ss, err := i.decapsulate(enc)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	aead, nonce, err := hpkeContext(ss)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, nonce, s.Body, nil)
+	if err != nil {
+		// ML-KEM decapsulation rejects implicitly, so a stanza for
+		// another recipient only shows up as an AEAD failure.
+		return nil, ErrIncorrectIdentity
+	}
+	return fileKey, nil
+}
+
+// encapsulate runs MLKEM768-X25519 encapsulation, returning the
+// encapsulated key ctPQ || ctT and the combined shared secret.
+func (r *HybridRecipient) encapsulate() ([]byte, []byte, error) {
+	var ephemeral [curve25519.ScalarSize]byte
+	if _, err := rand.Read(ephemeral[:]); err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ephemeral[:])
+	ctT, err := curve25519.X25519(ephemeral[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	ssT, err := curve25519.X25519(ephemeral[:], r.x[:])
+	if err != nil {
+		return nil, nil, ErrInvalidRecipient
+	}
+	defer mlkem.ZeroBytes(ssT)
+	ctPQ, ssPQ, err := kyberk2so.KemEncrypt768(r.ek)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc := make([]byte, 0, hybridEncSize)
+	enc = append(enc, ctPQ[:]...)
+	enc = append(enc, ctT...)
+	return enc, xwingCombine(ssPQ[:], ssT, ctT, r.x[:]), nil
+}
+
+// decapsulate runs MLKEM768-X25519 decapsulation of enc.
+func (i *HybridIdentity) decapsulate(enc []byte) ([]byte, error) {
+	ctT := enc[kyberk2so.Kyber768CTBytes:]
+	ssPQ, err := kyberk2so.KemDecrypt768([kyberk2so.Kyber768CTBytes]byte(enc[:kyberk2so.Kyber768CTBytes]), i.dk)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ssPQ[:])
+	ssT, err := curve25519.X25519(i.scalar[:], ctT)
+	if err != nil {
+		// A low-order X25519 share yields the all-zero secret.
+		return nil, ErrMalformedHeader
+	}
+	defer mlkem.ZeroBytes(ssT)
+	return xwingCombine(ssPQ[:], ssT, ctT, i.recipient.x[:]), nil
+}
+
+// xwingCombine is the X-Wing combiner
+// SHA3-256(ssPQ || ssT || ctT || ekT || label).
+func xwingCombine(ssPQ, ssT, ctT, ekT []byte) []byte {
+	h := sha3.New256()
+	_, _ = h.Write(ssPQ)
+	_, _ = h.Write(ssT)
+	_, _ = h.Write(ctT)
+	_, _ = h.Write(ekT)
+	_, _ = h.Write([]byte(xwingLabel))
+	return h.Sum(nil)
+}
+
+// hpkeContext runs the RFC 9180 base-mode key schedule for the age
+// info string and returns the AEAD and the nonce of its first message.
+func hpkeContext(ss []byte) (cipher.AEAD, []byte, error) {
+	pskIDHash := hpkeLabeledExtract(nil, "psk_id_hash", nil)
+	infoHash := hpkeLabeledExtract(nil, "info_hash", []byte(hybridHPKEInfo))
+	context := append([]byte{0x00}, pskIDHash...)
+	context = append(context, infoHash...)
+	secret := hpkeLabeledExtract(ss, "secret", nil)
+	defer mlkem.ZeroBytes(secret)
+	key := hpkeLabeledExpand(secret, "key", context, chacha20poly1305.KeySize)
+	defer mlkem.ZeroBytes(key)
+	nonce := hpkeLabeledExpand(secret, "base_nonce", context, chacha20poly1305.NonceSize)
+	aead, err := chacha20poly1305.New(key)
+	return aead, nonce, err
+}
+
+// hpkeLabeledExtract implements LabeledExtract from RFC 9180 §4.
+func hpkeLabeledExtract(salt []byte, label string, ikm []byte) []byte {
+	labeled := append([]byte("HPKE-v1"), hpkeSuiteID...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, ikm...)
+	return hkdf.Extract(sha256.New, labeled, salt)
+}
+
+// hpkeLabeledExpand implements LabeledExpand from RFC 9180 §4.
+func hpkeLabeledExpand(prk []byte, label string, info []byte, length int) []byte {
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(labeled, "HPKE-v1"...)
+	labeled = append(labeled, hpkeSuiteID...)
+	labeled = append(labeled, label...)
+	labeled = append(labeled, info...)
+	out := make([]byte, length)
+	_, _ = hkdf.Expand(sha256.New, prk, labeled).Read(out)
+	return out
+}
@@ -0,0 +1,201 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ChunkSize is the plaintext size of every STREAM chunk but the last.
+const ChunkSize = 64 * 1024
+
+const (
+	encryptedChunkSize = ChunkSize + chacha20poly1305.Overhead
+	lastChunkFlag      = 0x01
+)
+
+// streamWriter encrypts a payload as a sequence of ChaCha20-Poly1305
+// chunks, each sealed under a nonce made of an 11-byte big-endian
+// chunk counter and a final-chunk flag byte.
+type streamWriter struct {
+	aead  cipher.AEAD
+	dst   io.Writer
+	buf   []byte
+	nonce [chacha20poly1305.NonceSize]byte
+	err   error
+}
+
+// NewStreamWriter returns a WriteCloser that encrypts to dst with the
+// STREAM construction keyed by the 32-byte payload key. Close must be
+// called to write the final chunk.
+func NewStreamWriter(key []byte, dst io.Writer) (io.WriteCloser, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamWriter{aead: aead, dst: dst, buf: make([]byte, 0, encryptedChunkSize)}, nil
+}
+
+// Write buffers p, flushing full chunks only once more data follows
+// them, so that the final chunk is never empty unless the whole
+// payload is.
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	total := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == ChunkSize {
+			if err := w.flushChunk(false); err != nil {
+				w.err = err
+				return total - len(p), err
+			}
+		}
+		n := min(ChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// Close writes the final chunk.
+func (w *streamWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.flushChunk(true)
+	w.err = errors.New("age: stream writer is closed")
+	return err
+}
+
+// flushChunk seals and writes the buffered chunk.
+func (w *streamWriter) flushChunk(last bool) error {
+	if last {
+		w.nonce[len(w.nonce)-1] = lastChunkFlag
+	}
+	w.buf = w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+	_, err := w.dst.Write(w.buf)
+	w.buf = w.buf[:0]
+	return errors.Join(err, incrementNonce(&w.nonce))
+}
+
+// streamReader decrypts a payload produced by streamWriter.
+type streamReader struct {
+	aead   cipher.AEAD
+	src    io.Reader
+	buf    []byte
+	plain  []byte
+	unread []byte
+	nonce  [chacha20poly1305.NonceSize]byte
+	err    error
+}
+
+// NewStreamReader returns a Reader that decrypts the STREAM payload
+// read from src with the 32-byte payload key. Truncation, reordering,
+// missing final chunks and trailing data are reported as errors.
+func NewStreamReader(key []byte, src io.Reader) (io.Reader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		aead:  aead,
+		src:   src,
+		buf:   make([]byte, encryptedChunkSize),
+		plain: make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// Read implements io.Reader.
+func (r *streamReader) Read(p []byte) (int, error) {
+	if len(r.unread) > 0 {
+		n := copy(p, r.unread)
+		r.unread = r.unread[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	last, err := r.readChunk()
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+	if last {
+		var extra [1]byte
+		switch _, err := io.ReadFull(r.src, extra[:]); err {
+		case io.EOF:
+			r.err = io.EOF
+		case nil:
+			r.err = ErrTrailingData
+		default:
+			r.err = err
+		}
+	}
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk. A short chunk must be
+// the last one; a full chunk is tried as a regular chunk first and
+// as the last chunk otherwise.
+func (r *streamReader) readChunk() (bool, error) {
+	in := r.buf
+	n, err := io.ReadFull(r.src, in)
+	last := false
+	switch err {
+	case nil:
+	case io.EOF:
+		return false, ErrMalformedPayload
+	case io.ErrUnexpectedEOF:
+		if n == chacha20poly1305.Overhead && !nonceIsZero(&r.nonce) {
+			// Only an empty payload may end with an empty chunk.
+			return false, ErrMalformedPayload
+		}
+		in, last = in[:n], true
+		r.nonce[len(r.nonce)-1] = lastChunkFlag
+	default:
+		return false, err
+	}
+	out, err := r.aead.Open(r.plain[:0], r.nonce[:], in, nil)
+	if err != nil && !last {
+		last = true
+		r.nonce[len(r.nonce)-1] = lastChunkFlag
+		out, err = r.aead.Open(r.plain[:0], r.nonce[:], in, nil)
+	}
+	if err != nil {
+		return false, ErrMalformedPayload
+	}
+	r.unread = out
+	return last, incrementNonce(&r.nonce)
+}
+
+// incrementNonce increments the 11-byte chunk counter of nonce.
+func incrementNonce(nonce *[chacha20poly1305.NonceSize]byte) error {
+	for i := len(nonce) - 2; i >= 0; i-- {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return nil
+		}
+	}
+	return errors.New("age: stream chunk counter overflow")
+}
+
+// nonceIsZero reports whether no chunk has been read yet.
+func nonceIsZero(nonce *[chacha20poly1305.NonceSize]byte) bool {
+	for _, b := range nonce {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The vectors in testdata/testkit are from the C2SP CCTV age test suite
+// (https://github.com/C2SP/CCTV/tree/main/age), under the 0BSD license.
+// Vectors whose identities are not mlkem768x25519 exercise only the
+// header and payload, so they are decrypted with the vector's file key.
+
+type testkitVector struct {
+	expect     string
+	payload    []byte
+	fileKey    []byte
+	identities []string
+	file       []byte
+}
+
+func parseTestkitVector(t *testing.T, contents []byte) *testkitVector {
+	t.Helper()
+	v := new(testkitVector)
+	r := bufio.NewReader(bytes.NewReader(contents))
+	compressed := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("truncated vector header")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "expect":
+			v.expect = value
+		case "payload":
+			v.payload, _ = hex.DecodeString(value)
+		case "file key":
+			v.fileKey, _ = hex.DecodeString(value)
+		case "identity":
+			v.identities = append(v.identities, value)
+		case "compressed":
+			compressed = true
+		case "armored":
+			t.Skip("armor is not implemented")
+		case "comment":
+		default:
+			t.Fatalf("unknown vector header %q", key)
+		}
+	}
+	v.file, _ = io.ReadAll(r)
+	if compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(v.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.file, err = io.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return v
+}
+
+// fileKeyIdentity returns a fixed file key for any header.
+type fileKeyIdentity []byte
+
+func (i fileKeyIdentity) Unwrap([]*Stanza) ([]byte, error) {
+	return bytes.Clone(i), nil
+}
+
+func TestTestkit(t *testing.T) {
+	files, err := filepath.Glob("testdata/testkit/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			contents, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := parseTestkitVector(t, contents)
+			var identities []Identity
+			for _, s := range v.identities {
+				if !strings.HasPrefix(s, hybridIdentityHRP) {
+					identities = nil
+					break
+				}
+				id, err := ParseHybridIdentity(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				identities = append(identities, id)
+			}
+			if identities == nil {
+				if strings.HasPrefix(filepath.Base(file), "hybrid") {
+					t.Skip("X25519 identities are not implemented")
+				}
+				identities = []Identity{fileKeyIdentity(v.fileKey)}
+			}
+			testkitDecrypt(t, v, identities)
+		})
+	}
+}
+
+func testkitDecrypt(t *testing.T, v *testkitVector, identities []Identity) {
+	r, err := Decrypt(bytes.NewReader(v.file), identities...)
+	switch {
+	case errors.Is(err, ErrNoIdentityMatch):
+		if v.expect != "no match" {
+			t.Fatalf("expected %s, got %v", v.expect, err)
+		}
+		return
+	case errors.Is(err, ErrHeaderMAC):
+		if v.expect != "HMAC failure" {
+			t.Fatalf("expected %s, got %v", v.expect, err)
+		}
+		return
+	case err != nil:
+		if v.expect != "header failure" {
+			t.Fatalf("expected %s, got %v", v.expect, err)
+		}
+		return
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		if v.expect != "payload failure" {
+			t.Fatalf("expected %s, got %v", v.expect, err)
+		}
+		return
+	}
+	if v.expect != "success" {
+		t.Fatalf("expected %s, got success", v.expect)
+	}
+	if sum := sha256.Sum256(out); !bytes.Equal(sum[:], v.payload) {
+		t.Fatal("payload hash mismatch")
+	}
+}
@@ -0,0 +1,184 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package ake implements the KEM-based key exchanges Kyber.UAKE and
+// Kyber.AKE from the Kyber paper (Bos et al., "CRYSTALS-Kyber: a
+// CCA-secure module-lattice-based KEM", EuroS&P 2018), over any ML-KEM
+// parameter set.
+//
+// In Kyber.UAKE the initiator knows the responder's static public key.
+// It sends a fresh ephemeral public key together with an encapsulation
+// to the responder's static key, and the responder answers with an
+// encapsulation to the ephemeral key; only the responder is
+// authenticated. Kyber.AKE adds an encapsulation to the initiator's
+// static key, known to the responder in advance, which authenticates
+// both parties. The ephemeral key provides forward secrecy.
+//
+// Beyond the paper, every message is bound into a SHA-256 transcript
+// hash, the session key and two key confirmation keys are derived with
+// HKDF-SHA256 from the shared secrets and the transcript hash, and each
+// party proves knowledge of the session key with an HMAC-SHA256 tag
+// over the transcript hash. A party only outputs the session key after
+// verifying its peer's tag.
+package ake
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+var (
+	// ErrInvalidConfig is returned when a Config lacks a key required by
+	// its mode or names an unknown KEM.
+	ErrInvalidConfig = errors.New("ake: invalid configuration")
+
+	// ErrMalformedMessage is returned when a message has the wrong length
+	// or carries an invalid ephemeral public key.
+	ErrMalformedMessage = errors.New("ake: malformed message")
+
+	// ErrAuthenticationFailed is returned when a key confirmation tag
+	// does not verify.
+	ErrAuthenticationFailed = errors.New("ake: authentication failed")
+
+	// ErrFinished is returned when a handshake state is used again after
+	// it has completed or failed.
+	ErrFinished = errors.New("ake: handshake already finished")
+)
+
+// SessionKeySize is the size of the session key.
+const SessionKeySize = 32
+
+// TagSize is the size of a key confirmation tag.
+const TagSize = sha256.Size
+
+// KEM identifies the ML-KEM parameter set used for all keys.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// KeyPair is an ML-KEM key pair.
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// GenerateKeyPair returns a new ML-KEM key pair of the given parameter set.
+func GenerateKeyPair(kem KEM) (KeyPair, error) {
+	sk, pk, err := kem.params().Keypair()
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return KeyPair{}, ErrInvalidConfig
+	}
+	return KeyPair{sk, pk}, err
+}
+
+// Config describes one party of a key exchange.
+type Config struct {
+	KEM KEM
+
+	// Mutual selects Kyber.AKE; otherwise Kyber.UAKE is run.
+	Mutual bool
+
+	// StaticKeypair is the local static key pair. It is required for
+	// the responder, and for the initiator in Kyber.AKE.
+	StaticKeypair KeyPair
+
+	// PeerStatic is the peer's static public key. It is required for
+	// the initiator, and for the responder in Kyber.AKE.
+	PeerStatic []byte
+}
+
+// check validates the configuration of the initiator or responder.
+func (c *Config) check(initiator bool) error {
+	pkSize, skSize, _ := c.KEM.params().Sizes()
+	if pkSize == 0 {
+		return ErrInvalidConfig
+	}
+	needStatic := !initiator || c.Mutual
+	needPeer := initiator || c.Mutual
+	if needStatic && (len(c.StaticKeypair.Private) != skSize || len(c.StaticKeypair.Public) != pkSize) {
+		return ErrInvalidConfig
+	}
+	if needPeer && len(c.PeerStatic) != pkSize {
+		return ErrInvalidConfig
+	}
+	return nil
+}
+
+// protocolName returns the name hashed into the transcript.
+func (c *Config) protocolName() string {
+	name := "kyberk2so Kyber.UAKE v1 "
+	if c.Mutual {
+		name = "kyberk2so Kyber.AKE v1 "
+	}
+	return name + c.KEM.params().String()
+}
+
+// keys holds the keys derived at the end of a key exchange.
+type keys struct {
+	session   []byte
+	initiator []byte
+	responder []byte
+}
+
+// deriveKeys extracts a pseudorandom key from the concatenated shared
+// secrets with the transcript hash as salt, and expands it into the
+// session key and the two key confirmation keys.
+func deriveKeys(secret, transcript []byte) (*keys, error) {
+	prk, err := hkdf.Extract(sha256.New, secret, transcript)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(prk)
+	k := new(keys)
+	if k.session, err = hkdf.Expand(sha256.New, prk, "session key", SessionKeySize); err != nil {
+		return nil, err
+	}
+	if k.initiator, err = hkdf.Expand(sha256.New, prk, "initiator confirmation", sha256.Size); err != nil {
+		return nil, err
+	}
+	if k.responder, err = hkdf.Expand(sha256.New, prk, "responder confirmation", sha256.Size); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// tag returns HMAC-SHA256(key, transcript).
+func tag(key, transcript []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(transcript)
+	return mac.Sum(nil)
+}
+
+// wipe clears the confirmation keys, and the session key unless it is
+// being returned.
+func (k *keys) wipe(keepSession bool) {
+	if !keepSession {
+		mlkem.ZeroBytes(k.session)
+	}
+	mlkem.ZeroBytes(k.initiator)
+	mlkem.ZeroBytes(k.responder)
+}
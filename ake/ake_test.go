@@ -0,0 +1,162 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package ake
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type parties struct {
+	initiator, responder Config
+}
+
+func newParties(t *testing.T, kem KEM, mutual bool) parties {
+	t.Helper()
+	initiatorStatic, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responderStatic, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parties{
+		initiator: Config{KEM: kem, Mutual: mutual, PeerStatic: responderStatic.Public},
+		responder: Config{KEM: kem, Mutual: mutual, StaticKeypair: responderStatic},
+	}
+	if mutual {
+		p.initiator.StaticKeypair = initiatorStatic
+		p.responder.PeerStatic = initiatorStatic.Public
+	}
+	return p
+}
+
+// run performs a key exchange, applying tamper to each message in turn.
+func run(p parties, tamper func(step int, msg []byte)) ([]byte, []byte, error) {
+	initiator, msg1, err := NewInitiator(p.initiator)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(1, msg1)
+	responder, msg2, err := NewResponder(p.responder, msg1)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(2, msg2)
+	msg3, keyA, err := initiator.Finish(msg2)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(3, msg3)
+	keyB, err := responder.Confirm(msg3)
+	return keyA, keyB, err
+}
+
+func TestAgreement(t *testing.T) {
+	for _, kem := range []KEM{MLKEM512, MLKEM768, MLKEM1024} {
+		for _, mutual := range []bool{false, true} {
+			p := newParties(t, kem, mutual)
+			keyA, keyB, err := run(p, func(int, []byte) {})
+			if err != nil {
+				t.Fatalf("KEM %d, mutual %v: %v", kem, mutual, err)
+			}
+			if len(keyA) != SessionKeySize || !bytes.Equal(keyA, keyB) {
+				t.Fatalf("KEM %d, mutual %v: session keys differ", kem, mutual)
+			}
+			keyC, _, _ := run(p, func(int, []byte) {})
+			if bytes.Equal(keyA, keyC) {
+				t.Fatalf("KEM %d, mutual %v: session key repeats", kem, mutual)
+			}
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	for _, mutual := range []bool{false, true} {
+		p := newParties(t, MLKEM768, mutual)
+		for step := 1; step <= 3; step++ {
+			// Flip a bit in each region of the message: the ephemeral key
+			// and ciphertext of the first message, the ciphertexts and tag
+			// of the answer, and the confirmation tag.
+			for _, offset := range []int{0, 1200, -1} {
+				_, _, err := run(p, func(s int, msg []byte) {
+					if s != step {
+						return
+					}
+					i := offset
+					if i < 0 || i >= len(msg) {
+						i = len(msg) - 1
+					}
+					msg[i] ^= 1
+				})
+				if !errors.Is(err, ErrAuthenticationFailed) && !errors.Is(err, ErrMalformedMessage) {
+					t.Fatalf("mutual %v, step %d, offset %d: got %v", mutual, step, offset, err)
+				}
+			}
+		}
+	}
+}
+
+func TestWrongKeys(t *testing.T) {
+	// A responder without the expected static key cannot answer
+	// acceptably.
+	p := newParties(t, MLKEM768, false)
+	impostor, _ := GenerateKeyPair(MLKEM768)
+	p.responder.StaticKeypair = impostor
+	if _, _, err := run(p, func(int, []byte) {}); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+	// In Kyber.AKE an initiator without the expected static key is
+	// rejected.
+	p = newParties(t, MLKEM768, true)
+	impostor, _ = GenerateKeyPair(MLKEM768)
+	p.initiator.StaticKeypair = impostor
+	if _, _, err := run(p, func(int, []byte) {}); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+	// Mixing the modes fails.
+	p = newParties(t, MLKEM768, true)
+	p.initiator.Mutual = false
+	if _, _, err := run(p, func(int, []byte) {}); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("got %v, want ErrMalformedMessage", err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	p := newParties(t, MLKEM512, true)
+	for _, cfg := range []Config{
+		{KEM: 0, PeerStatic: p.initiator.PeerStatic},
+		{KEM: MLKEM512},
+		{KEM: MLKEM768, PeerStatic: p.initiator.PeerStatic},
+		{KEM: MLKEM512, Mutual: true, PeerStatic: p.initiator.PeerStatic},
+	} {
+		if _, _, err := NewInitiator(cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("got %v, want ErrInvalidConfig", err)
+		}
+	}
+	if _, _, err := NewResponder(Config{KEM: MLKEM512}, nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want ErrInvalidConfig", err)
+	}
+	if _, _, err := NewResponder(p.responder, make([]byte, 10)); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("got %v, want ErrMalformedMessage", err)
+	}
+
+	initiator, msg1, _ := NewInitiator(p.initiator)
+	responder, msg2, _ := NewResponder(p.responder, msg1)
+	msg3, _, err := initiator.Finish(msg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := initiator.Finish(msg2); !errors.Is(err, ErrFinished) {
+		t.Fatalf("got %v, want ErrFinished", err)
+	}
+	if _, err := responder.Confirm(msg3[:TagSize-1]); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+	if _, err := responder.Confirm(msg3); !errors.Is(err, ErrFinished) {
+		t.Fatalf("got %v, want ErrFinished", err)
+	}
+}
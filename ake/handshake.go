@@ -0,0 +1,178 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package ake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+// Initiator is the state of the initiator between sending its first
+// message and receiving the responder's answer.
+type Initiator struct {
+	cfg       Config
+	ephemeral KeyPair
+	ssStatic  []byte
+	msg       []byte
+	done      bool
+}
+
+// NewInitiator starts a key exchange and returns the initiator state
+// and the first message: a fresh ephemeral public key followed by an
+// encapsulation to the responder's static key.
+func NewInitiator(cfg Config) (*Initiator, []byte, error) {
+	if err := cfg.check(true); err != nil {
+		return nil, nil, err
+	}
+	ephemeral, err := GenerateKeyPair(cfg.KEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	ct, ss, err := cfg.KEM.params().Encapsulate(cfg.PeerStatic)
+	if err != nil {
+		mlkem.ZeroBytes(ephemeral.Private)
+		return nil, nil, err
+	}
+	msg := append(append([]byte(nil), ephemeral.Public...), ct...)
+	return &Initiator{cfg: cfg, ephemeral: ephemeral, ssStatic: ss, msg: msg}, msg, nil
+}
+
+// Finish processes the responder's answer. It returns the key
+// confirmation message to send back and the session key. The state
+// cannot be used again, whether or not Finish succeeds.
+func (i *Initiator) Finish(response []byte) ([]byte, []byte, error) {
+	if i.done {
+		return nil, nil, ErrFinished
+	}
+	i.done = true
+	defer mlkem.ZeroBytes(i.ephemeral.Private)
+	defer mlkem.ZeroBytes(i.ssStatic)
+	_, _, ctSize := i.cfg.KEM.params().Sizes()
+	bodySize := ctSize
+	if i.cfg.Mutual {
+		bodySize += ctSize
+	}
+	if len(response) != bodySize+TagSize {
+		return nil, nil, ErrMalformedMessage
+	}
+	body := response[:bodySize]
+	ssEphemeral, err := i.cfg.KEM.params().Decapsulate(body[:ctSize], i.ephemeral.Private)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ssEphemeral)
+	// secret has room for the initiator's shared secret of Kyber.AKE, so
+	// that appending it does not reallocate past the deferred wipe.
+	secret := make([]byte, 0, 3*kyberk2so.KyberSSBytes)
+	secret = append(append(secret, ssEphemeral...), i.ssStatic...)
+	defer mlkem.ZeroBytes(secret)
+	if i.cfg.Mutual {
+		ssInitiator, err := i.cfg.KEM.params().Decapsulate(body[ctSize:], i.cfg.StaticKeypair.Private)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = append(secret, ssInitiator...)
+		mlkem.ZeroBytes(ssInitiator)
+	}
+	th := transcriptHash(&i.cfg, i.cfg.StaticKeypair.Public, i.cfg.PeerStatic, i.msg, body)
+	k, err := deriveKeys(secret, th)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hmac.Equal(tag(k.responder, th), response[bodySize:]) {
+		k.wipe(false)
+		return nil, nil, ErrAuthenticationFailed
+	}
+	confirmation := tag(k.initiator, th)
+	k.wipe(true)
+	return confirmation, k.session, nil
+}
+
+// Responder is the state of the responder between answering the
+// initiator and receiving its key confirmation.
+type Responder struct {
+	keys       *keys
+	transcript []byte
+	done       bool
+}
+
+// NewResponder processes the initiator's first message and returns the
+// responder state and the answer: an encapsulation to the initiator's
+// ephemeral key, followed in Kyber.AKE by an encapsulation to the
+// initiator's static key, and the responder's key confirmation tag.
+func NewResponder(cfg Config, msg []byte) (*Responder, []byte, error) {
+	if err := cfg.check(false); err != nil {
+		return nil, nil, err
+	}
+	pkSize, _, ctSize := cfg.KEM.params().Sizes()
+	if len(msg) != pkSize+ctSize {
+		return nil, nil, ErrMalformedMessage
+	}
+	ssStatic, err := cfg.KEM.params().Decapsulate(msg[pkSize:], cfg.StaticKeypair.Private)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ssStatic)
+	ctEphemeral, ssEphemeral, err := cfg.KEM.params().Encapsulate(msg[:pkSize])
+	if err != nil {
+		return nil, nil, ErrMalformedMessage
+	}
+	defer mlkem.ZeroBytes(ssEphemeral)
+	secret := make([]byte, 0, 3*kyberk2so.KyberSSBytes)
+	secret = append(append(secret, ssEphemeral...), ssStatic...)
+	defer mlkem.ZeroBytes(secret)
+	body := ctEphemeral
+	if cfg.Mutual {
+		ctInitiator, ssInitiator, err := cfg.KEM.params().Encapsulate(cfg.PeerStatic)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = append(secret, ssInitiator...)
+		mlkem.ZeroBytes(ssInitiator)
+		body = append(body, ctInitiator...)
+	}
+	th := transcriptHash(&cfg, cfg.PeerStatic, cfg.StaticKeypair.Public, msg, body)
+	k, err := deriveKeys(secret, th)
+	if err != nil {
+		return nil, nil, err
+	}
+	response := append(body, tag(k.responder, th)...)
+	return &Responder{keys: k, transcript: th}, response, nil
+}
+
+// Confirm verifies the initiator's key confirmation message and returns
+// the session key. The state cannot be used again, whether or not
+// Confirm succeeds.
+func (r *Responder) Confirm(confirmation []byte) ([]byte, error) {
+	if r.done {
+		return nil, ErrFinished
+	}
+	r.done = true
+	if !hmac.Equal(tag(r.keys.initiator, r.transcript), confirmation) {
+		r.keys.wipe(false)
+		return nil, ErrAuthenticationFailed
+	}
+	r.keys.wipe(true)
+	return r.keys.session, nil
+}
+
+// transcriptHash returns SHA-256 over the length-prefixed protocol name,
+// the initiator's static public key in Kyber.AKE, the responder's static
+// public key, the first message and the answer without its tag.
+func transcriptHash(cfg *Config, initiatorStatic, responderStatic, msg, body []byte) []byte {
+	name := cfg.protocolName()
+	h := sha256.New()
+	_, _ = h.Write([]byte{byte(len(name))})
+	_, _ = h.Write([]byte(name))
+	if cfg.Mutual {
+		_, _ = h.Write(initiatorStatic)
+	}
+	_, _ = h.Write(responderStatic)
+	_, _ = h.Write(msg)
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,230 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package authkem implements sender-authenticated sealing from ML-KEM
+// alone, in a stored-key setting.
+//
+// ML-KEM offers no Diffie-Hellman operation, so the static-static
+// secret that authenticates the sender in HPKE's auth mode cannot be
+// computed non-interactively. Instead, once per sender and recipient,
+// the recipient encapsulates to the sender's static key and both store
+// the resulting link secret: the recipient calls Establish and sends
+// the link message to the sender, who calls Accept. Seal then
+// encapsulates to the recipient's static key and derives the message
+// key from the fresh KEM shared secret, the link secret, the KEM
+// ciphertext and both identities, the SHA3-256 hashes of the static
+// public keys. Open repeats the derivation with the recipient's
+// decapsulation key and the link of the expected sender.
+//
+// Analysis notes:
+//
+//   - Confidentiality rests on the IND-CCA security of the encapsulation
+//     to the recipient: without the recipient's decapsulation key the
+//     fresh shared secret, and hence the message key, is unknown, even
+//     to someone who knows the link secret.
+//   - Authenticity rests on the link secret, which is the shared secret
+//     of an encapsulation to the sender's static key made by the
+//     recipient. Only the sender, holding the decapsulation key, and the
+//     recipient, who made the encapsulation, know it, so a third party,
+//     including another sender with its own link to the recipient,
+//     cannot produce an envelope that opens under this sender's link.
+//     The link message itself needs no authentication: a forged link
+//     only changes the secret the sender uses, and the recipient only
+//     accepts envelopes under links it created.
+//   - Since the recipient knows the link secret, it can produce
+//     envelopes that appear to come from the sender to itself. As with
+//     the Diffie-Hellman authenticated KEM of HPKE, authentication is
+//     deniable and only holds towards the recipient.
+//   - An attacker that learns the sender's decapsulation key and has
+//     recorded the link message can impersonate the sender, and one
+//     that reads the stored link of either party can impersonate the
+//     sender to the recipient; links should be replaced when keys
+//     rotate. Compromise of the recipient's decapsulation key alone
+//     breaks confidentiality but not authenticity.
+//   - Each envelope uses a fresh key, so the AEAD nonce is zero. Open
+//     does not detect replays, which applications must handle.
+package authkem
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidKey is returned when a key has the wrong size for its
+	// KEM, or the KEM is unknown.
+	ErrInvalidKey = errors.New("authkem: invalid key")
+
+	// ErrInvalidLink is returned when a serialized link is malformed, or
+	// a link does not belong to the keys it is used with.
+	ErrInvalidLink = errors.New("authkem: invalid link")
+
+	// ErrAuthenticationFailed is returned when an envelope does not open
+	// under the given link and key.
+	ErrAuthenticationFailed = errors.New("authkem: authentication failed")
+)
+
+// KEM identifies the ML-KEM parameter set of all keys.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// KeyPair is a static ML-KEM key pair.
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// GenerateKeyPair returns a new ML-KEM key pair of the given parameter set.
+func GenerateKeyPair(kem KEM) (KeyPair, error) {
+	sk, pk, err := kem.params().Keypair()
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return KeyPair{}, ErrInvalidKey
+	}
+	return KeyPair{sk, pk}, err
+}
+
+// ID returns the identity of a static public key, its SHA3-256 hash.
+func ID(publicKey []byte) [32]byte {
+	return sha3.Sum256(publicKey)
+}
+
+// linkVersion is the version byte of serialized links.
+const linkVersion = 1
+
+// linkSize is the size of a serialized link.
+const linkSize = 2 + 3*32
+
+// Link is the secret a sender and a recipient share for sealing from
+// the sender to the recipient.
+type Link struct {
+	kem         KEM
+	secret      [32]byte
+	senderID    [32]byte
+	recipientID [32]byte
+}
+
+// KEM returns the parameter set of the link.
+func (l *Link) KEM() KEM {
+	return l.kem
+}
+
+// SenderID returns the identity of the sender.
+func (l *Link) SenderID() [32]byte {
+	return l.senderID
+}
+
+// RecipientID returns the identity of the recipient.
+func (l *Link) RecipientID() [32]byte {
+	return l.recipientID
+}
+
+// MarshalBinary serializes the link, including its secret. Callers must
+// store it as securely as a private key.
+func (l *Link) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, linkSize)
+	b = append(b, linkVersion, byte(l.kem))
+	b = append(b, l.secret[:]...)
+	b = append(b, l.senderID[:]...)
+	b = append(b, l.recipientID[:]...)
+	return b, nil
+}
+
+// UnmarshalBinary restores a link serialized by MarshalBinary.
+func (l *Link) UnmarshalBinary(data []byte) error {
+	if len(data) != linkSize || data[0] != linkVersion {
+		return ErrInvalidLink
+	}
+	kem := KEM(data[1])
+	if pk, _, _ := kem.params().Sizes(); pk == 0 {
+		return ErrInvalidLink
+	}
+	l.kem = kem
+	copy(l.secret[:], data[2:34])
+	copy(l.senderID[:], data[34:66])
+	copy(l.recipientID[:], data[66:98])
+	return nil
+}
+
+// Establish is run by the recipient to create a link with a sender. It
+// encapsulates to the sender's static public key and returns the link
+// message to send to the sender, and the recipient's link.
+func Establish(kem KEM, recipientPublic, senderPublic []byte) ([]byte, *Link, error) {
+	pkSize, _, _ := kem.params().Sizes()
+	if pkSize == 0 || len(recipientPublic) != pkSize || len(senderPublic) != pkSize {
+		return nil, nil, ErrInvalidKey
+	}
+	ct, ss, err := kem.params().Encapsulate(senderPublic)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	l := &Link{kem: kem, senderID: ID(senderPublic), recipientID: ID(recipientPublic)}
+	if err := l.derive(ss, ct); err != nil {
+		return nil, nil, err
+	}
+	return ct, l, nil
+}
+
+// Accept is run by the sender on a link message from Establish. It
+// decapsulates with the sender's static key pair and returns the
+// sender's link to the recipient.
+func Accept(kem KEM, sender KeyPair, recipientPublic, msg []byte) (*Link, error) {
+	pkSize, skSize, ctSize := kem.params().Sizes()
+	if pkSize == 0 || len(sender.Private) != skSize || len(sender.Public) != pkSize || len(recipientPublic) != pkSize {
+		return nil, ErrInvalidKey
+	}
+	if len(msg) != ctSize {
+		return nil, ErrInvalidLink
+	}
+	ss, err := kem.params().Decapsulate(msg, sender.Private)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	l := &Link{kem: kem, senderID: ID(sender.Public), recipientID: ID(recipientPublic)}
+	if err := l.derive(ss, msg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// derive sets the link secret from the shared secret of the link
+// message, bound to both identities.
+func (l *Link) derive(ss, ct []byte) error {
+	info := append([]byte("kyberk2so authkem link v1"), byte(l.kem))
+	info = append(info, l.senderID[:]...)
+	info = append(info, l.recipientID[:]...)
+	info = append(info, ct...)
+	secret, err := hkdf.Key(sha256.New, ss, nil, string(info), len(l.secret))
+	if err != nil {
+		return err
+	}
+	copy(l.secret[:], secret)
+	mlkem.ZeroBytes(secret)
+	return nil
+}
@@ -0,0 +1,164 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package authkem
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// link establishes a link from sender to recipient and returns both
+// sides of it.
+func link(t *testing.T, kem KEM, sender, recipient KeyPair) (*Link, *Link) {
+	t.Helper()
+	msg, recipientLink, err := Establish(kem, recipient.Public, sender.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderLink, err := Accept(kem, sender, recipient.Public, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return senderLink, recipientLink
+}
+
+func keyPair(t *testing.T, kem KEM) KeyPair {
+	t.Helper()
+	kp, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kp
+}
+
+func TestSealOpen(t *testing.T) {
+	for _, kem := range []KEM{MLKEM512, MLKEM768, MLKEM1024} {
+		sender, recipient := keyPair(t, kem), keyPair(t, kem)
+		senderLink, recipientLink := link(t, kem, sender, recipient)
+		if senderLink.secret != recipientLink.secret {
+			t.Fatalf("KEM %d: link secrets differ", kem)
+		}
+		if senderLink.SenderID() != ID(sender.Public) || senderLink.RecipientID() != ID(recipient.Public) {
+			t.Fatalf("KEM %d: link identities differ", kem)
+		}
+		plaintext := []byte("firmware update approved")
+		envelope, err := Seal(senderLink, recipient.Public, plaintext, []byte("aad"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Open(recipientLink, recipient, envelope, []byte("aad"))
+		if err != nil {
+			t.Fatalf("KEM %d: %v", kem, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("KEM %d: plaintexts differ", kem)
+		}
+		if _, err := Open(recipientLink, recipient, envelope, []byte("other")); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("KEM %d: got %v, want ErrAuthenticationFailed", kem, err)
+		}
+		for _, i := range []int{0, len(envelope) - 20, len(envelope) - 1} {
+			tampered := append([]byte(nil), envelope...)
+			tampered[i] ^= 1
+			if _, err := Open(recipientLink, recipient, tampered, []byte("aad")); !errors.Is(err, ErrAuthenticationFailed) {
+				t.Fatalf("KEM %d: tampered byte %d: got %v", kem, i, err)
+			}
+		}
+	}
+}
+
+func TestForgedSender(t *testing.T) {
+	alice, mallory, bob := keyPair(t, MLKEM768), keyPair(t, MLKEM768), keyPair(t, MLKEM768)
+	_, bobFromAlice := link(t, MLKEM768, alice, bob)
+	malloryToBob, _ := link(t, MLKEM768, mallory, bob)
+
+	// Mallory has her own link to Bob but cannot seal as Alice.
+	envelope, err := Seal(malloryToBob, bob.Public, []byte("from alice"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(bobFromAlice, bob, envelope, nil); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+
+	// A link that Mallory sends to Alice in Bob's name gives Alice a
+	// secret Bob does not share, so Bob rejects what Alice seals with it.
+	msg, _, err := Establish(MLKEM768, bob.Public, alice.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedLink, err := Accept(MLKEM768, alice, bob.Public, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, _ = Seal(forgedLink, bob.Public, []byte("hello"), nil)
+	if _, err := Open(bobFromAlice, bob, envelope, nil); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+
+	// A link message decapsulated with the wrong sender key is useless.
+	msg, bobLink, _ := Establish(MLKEM768, bob.Public, alice.Public)
+	wrongLink, err := Accept(MLKEM768, KeyPair{mallory.Private, alice.Public}, bob.Public, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, _ = Seal(wrongLink, bob.Public, []byte("hello"), nil)
+	if _, err := Open(bobLink, bob, envelope, nil); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+}
+
+func TestWrongRecipient(t *testing.T) {
+	alice, bob, carol := keyPair(t, MLKEM768), keyPair(t, MLKEM768), keyPair(t, MLKEM768)
+	aliceToBob, bobFromAlice := link(t, MLKEM768, alice, bob)
+	if _, err := Seal(aliceToBob, carol.Public, []byte("x"), nil); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("got %v, want ErrInvalidLink", err)
+	}
+	envelope, _ := Seal(aliceToBob, bob.Public, []byte("x"), nil)
+	if _, err := Open(bobFromAlice, carol, envelope, nil); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("got %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestLinkMarshal(t *testing.T) {
+	alice, bob := keyPair(t, MLKEM1024), keyPair(t, MLKEM1024)
+	aliceToBob, bobFromAlice := link(t, MLKEM1024, alice, bob)
+	data, err := bobFromAlice.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Link
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored != *bobFromAlice || restored.KEM() != MLKEM1024 {
+		t.Fatal("link does not round-trip")
+	}
+	envelope, _ := Seal(aliceToBob, bob.Public, []byte("stored"), nil)
+	if got, err := Open(&restored, bob, envelope, nil); err != nil || string(got) != "stored" {
+		t.Fatalf("restored link failed to open: %v", err)
+	}
+	for _, bad := range [][]byte{data[:10], append([]byte{2}, data[1:]...), append([]byte{1, 9}, data[2:]...)} {
+		if err := restored.UnmarshalBinary(bad); !errors.Is(err, ErrInvalidLink) {
+			t.Fatalf("got %v, want ErrInvalidLink", err)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	alice, bob := keyPair(t, MLKEM512), keyPair(t, MLKEM512)
+	if _, _, err := Establish(0, bob.Public, alice.Public); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("got %v, want ErrInvalidKey", err)
+	}
+	if _, _, err := Establish(MLKEM768, bob.Public, alice.Public); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("got %v, want ErrInvalidKey", err)
+	}
+	msg, bobLink, _ := Establish(MLKEM512, bob.Public, alice.Public)
+	if _, err := Accept(MLKEM512, alice, bob.Public, msg[1:]); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("got %v, want ErrInvalidLink", err)
+	}
+	if _, err := Open(bobLink, bob, make([]byte, 10), nil); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("got %v, want ErrAuthenticationFailed", err)
+	}
+}
@@ -0,0 +1,118 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package authkem
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/subtle"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encapsulate is the authenticated encapsulation of the link's sender
+// to the recipient with the given static public key. It returns a KEM
+// ciphertext and a 32-byte shared secret that only the recipient, with
+// its link to the sender, can recompute.
+func Encapsulate(link *Link, recipientPublic []byte) ([]byte, []byte, error) {
+	pkSize, _, _ := link.kem.params().Sizes()
+	if len(recipientPublic) != pkSize {
+		return nil, nil, ErrInvalidKey
+	}
+	recipientID := ID(recipientPublic)
+	if subtle.ConstantTimeCompare(recipientID[:], link.recipientID[:]) != 1 {
+		return nil, nil, ErrInvalidLink
+	}
+	ct, ss, err := link.kem.params().Encapsulate(recipientPublic)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	key, err := link.messageKey(ss, ct)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ct, key, nil
+}
+
+// Decapsulate is the authenticated decapsulation of a ciphertext from
+// Encapsulate by the recipient with the given static key pair, using
+// its link to the expected sender. Like ML-KEM decapsulation it rejects
+// implicitly: a ciphertext from another sender yields an unrelated
+// secret.
+func Decapsulate(link *Link, recipient KeyPair, ct []byte) ([]byte, error) {
+	pkSize, skSize, ctSize := link.kem.params().Sizes()
+	if len(recipient.Private) != skSize || len(recipient.Public) != pkSize {
+		return nil, ErrInvalidKey
+	}
+	recipientID := ID(recipient.Public)
+	if subtle.ConstantTimeCompare(recipientID[:], link.recipientID[:]) != 1 {
+		return nil, ErrInvalidLink
+	}
+	if len(ct) != ctSize {
+		return nil, ErrAuthenticationFailed
+	}
+	ss, err := link.kem.params().Decapsulate(ct, recipient.Private)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	return link.messageKey(ss, ct)
+}
+
+// Seal encrypts plaintext from the sender of link to the recipient with
+// the given static public key, authenticating aad. The envelope is the
+// KEM ciphertext of Encapsulate followed by the AEAD ciphertext under
+// its shared secret.
+func Seal(link *Link, recipientPublic, plaintext, aad []byte) ([]byte, error) {
+	ct, key, err := Encapsulate(link, recipientPublic)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(key)
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	return aead.Seal(ct, nonce, plaintext, aad), nil
+}
+
+// Open decrypts an envelope sealed by the sender of link to the
+// recipient with the given static key pair, authenticating aad. It
+// returns ErrAuthenticationFailed unless the envelope was sealed with
+// the same link.
+func Open(link *Link, recipient KeyPair, envelope, aad []byte) ([]byte, error) {
+	_, _, ctSize := link.kem.params().Sizes()
+	ctSize = min(ctSize, len(envelope))
+	key, err := Decapsulate(link, recipient, envelope[:ctSize])
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(key)
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	plaintext, err := aead.Open(nil, nonce, envelope[ctSize:], aad)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+	return plaintext, nil
+}
+
+// messageKey derives the AEAD key of an envelope from the KEM shared
+// secret and the link secret, bound to both identities and the KEM
+// ciphertext.
+func (l *Link) messageKey(ss, ct []byte) ([]byte, error) {
+	ikm := append(append([]byte(nil), ss...), l.secret[:]...)
+	defer mlkem.ZeroBytes(ikm)
+	info := append([]byte("kyberk2so authkem seal v1"), byte(l.kem))
+	info = append(info, l.senderID[:]...)
+	info = append(info, l.recipientID[:]...)
+	info = append(info, ct...)
+	return hkdf.Key(sha256.New, ikm, nil, string(info), chacha20poly1305.KeySize)
+}
@@ -0,0 +1,158 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ML-KEM shared secrets are derived from the encrypted message and
+// H(ek) only, so they are not bound to the ciphertext, and with an
+// implicit rejection secret z chosen by the key owner they need not be
+// bound to the encapsulation key either (Schmieg, "Unbindable Kemmy
+// Schmidt", 2024). The binding KEM below derives its output as
+//
+//	K = SHA3-256(len(label) || label || ss || H(ek) || ct)
+//
+// where len(label) is a 64-bit big-endian length. Since SHA3-256 is
+// collision resistant and every other input has a fixed length, equal
+// outputs imply equal labels, equal ciphertexts and equal H(ek), hence
+// equal encapsulation keys: the wrapper achieves MAL-BIND-K-CT and
+// MAL-BIND-K-PK, which imply the weaker LEAK and HON variants, even for
+// adversarially generated decapsulation keys. Decapsulation reads H(ek)
+// from the decapsulation key, where it is checked against ek before use.
+// Outputs for different labels are independent, so protocols should use
+// a label that names them.
+
+// kemBindingKey computes the binding shared secret from the ML-KEM
+// shared secret ss, H(ek) and the ciphertext.
+func kemBindingKey(label, ss, pkh, ciphertext []byte) [KyberSSBytes]byte {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(label)))
+	h := sha3.New256()
+	_, _ = h.Write(length[:])
+	_, _ = h.Write(label)
+	_, _ = h.Write(ss)
+	_, _ = h.Write(pkh)
+	_, _ = h.Write(ciphertext)
+	var key [KyberSSBytes]byte
+	h.Sum(key[:0])
+	return key
+}
+
+// KemEncryptBinding512 takes a public key (from KemKeypair512) and an
+// application label as input and returns a ciphertext and a 32-byte shared
+// secret that is bound to the public key, the ciphertext and the label.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptBinding512(publicKey [Kyber512PKBytes]byte, label []byte) (
+	[Kyber512CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	ciphertext, ss, err := KemEncrypt512(publicKey)
+	if err != nil {
+		return ciphertext, ss, err
+	}
+	pkh := sha3.Sum256(publicKey[:])
+	sharedSecret := kemBindingKey(label, ss[:], pkh[:], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return ciphertext, sharedSecret, nil
+}
+
+// KemDecryptBinding512 takes a ciphertext (from KemEncryptBinding512), a
+// private key (from KemKeypair512) and the application label used for
+// encryption, and returns a 32-byte shared secret. H(ek) is read from
+// the private key. An accompanying error is returned if the private key
+// is invalid.
+func KemDecryptBinding512(
+	ciphertext [Kyber512CTBytes]byte,
+	privateKey [Kyber512SKBytes]byte,
+	label []byte,
+) ([KyberSSBytes]byte, error) {
+	ss, err := KemDecrypt512(ciphertext, privateKey)
+	if err != nil {
+		return ss, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK512 + paramsIndcpaPublicKeyBytesK512
+	sharedSecret := kemBindingKey(label, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return sharedSecret, nil
+}
+
+// KemEncryptBinding768 takes a public key (from KemKeypair768) and an
+// application label as input and returns a ciphertext and a 32-byte shared
+// secret that is bound to the public key, the ciphertext and the label.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptBinding768(publicKey [Kyber768PKBytes]byte, label []byte) (
+	[Kyber768CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	ciphertext, ss, err := KemEncrypt768(publicKey)
+	if err != nil {
+		return ciphertext, ss, err
+	}
+	pkh := sha3.Sum256(publicKey[:])
+	sharedSecret := kemBindingKey(label, ss[:], pkh[:], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return ciphertext, sharedSecret, nil
+}
+
+// KemDecryptBinding768 takes a ciphertext (from KemEncryptBinding768), a
+// private key (from KemKeypair768) and the application label used for
+// encryption, and returns a 32-byte shared secret. H(ek) is read from
+// the private key. An accompanying error is returned if the private key
+// is invalid.
+func KemDecryptBinding768(
+	ciphertext [Kyber768CTBytes]byte,
+	privateKey [Kyber768SKBytes]byte,
+	label []byte,
+) ([KyberSSBytes]byte, error) {
+	ss, err := KemDecrypt768(ciphertext, privateKey)
+	if err != nil {
+		return ss, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK768 + paramsIndcpaPublicKeyBytesK768
+	sharedSecret := kemBindingKey(label, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return sharedSecret, nil
+}
+
+// KemEncryptBinding1024 takes a public key (from KemKeypair1024) and an
+// application label as input and returns a ciphertext and a 32-byte shared
+// secret that is bound to the public key, the ciphertext and the label.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptBinding1024(publicKey [Kyber1024PKBytes]byte, label []byte) (
+	[Kyber1024CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	ciphertext, ss, err := KemEncrypt1024(publicKey)
+	if err != nil {
+		return ciphertext, ss, err
+	}
+	pkh := sha3.Sum256(publicKey[:])
+	sharedSecret := kemBindingKey(label, ss[:], pkh[:], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return ciphertext, sharedSecret, nil
+}
+
+// KemDecryptBinding1024 takes a ciphertext (from KemEncryptBinding1024), a
+// private key (from KemKeypair1024) and the application label used for
+// encryption, and returns a 32-byte shared secret. H(ek) is read from
+// the private key. An accompanying error is returned if the private key
+// is invalid.
+func KemDecryptBinding1024(
+	ciphertext [Kyber1024CTBytes]byte,
+	privateKey [Kyber1024SKBytes]byte,
+	label []byte,
+) ([KyberSSBytes]byte, error) {
+	ss, err := KemDecrypt1024(ciphertext, privateKey)
+	if err != nil {
+		return ss, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK1024 + paramsIndcpaPublicKeyBytesK1024
+	sharedSecret := kemBindingKey(label, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:])
+	byteopsZeroBytes(ss[:])
+	return sharedSecret, nil
+}
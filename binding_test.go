@@ -0,0 +1,110 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestBinding512(t *testing.T) {
+	privateKey, publicKey, _ := KemKeypair512()
+	label := []byte("binding test")
+	ciphertext, ssA, err := KemEncryptBinding512(publicKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemDecryptBinding512(ciphertext, privateKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	if ssC, _ := KemDecryptBinding512(ciphertext, privateKey, []byte("other label")); ssC == ssA {
+		t.Fatal("label does not separate shared secrets")
+	}
+}
+
+func TestBinding768(t *testing.T) {
+	privateKey, publicKey, _ := KemKeypair768()
+	label := []byte("binding test")
+	ciphertext, ssA, err := KemEncryptBinding768(publicKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemDecryptBinding768(ciphertext, privateKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	// The output is the documented KDF over the plain shared secret.
+	ss, _ := KemDecrypt768(ciphertext, privateKey)
+	pkh := sha3.Sum256(publicKey[:])
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(label)))
+	input := append(append(append(append(length[:], label...), ss[:]...), pkh[:]...), ciphertext[:]...)
+	if sha3.Sum256(input) != ssA {
+		t.Fatal("shared secret does not match the KDF")
+	}
+	if ssC, _ := KemDecryptBinding768(ciphertext, privateKey, nil); ssC == ssA {
+		t.Fatal("label does not separate shared secrets")
+	}
+	// Label boundaries are unambiguous.
+	if kemBindingKey([]byte("a"), []byte("bc"), nil, nil) == kemBindingKey([]byte("ab"), []byte("c"), nil, nil) {
+		t.Fatal("label and shared secret are not separated")
+	}
+	privateKey[Kyber768SKBytes-2*paramsSymBytes] ^= 1
+	if _, err := KemDecryptBinding768(ciphertext, privateKey, label); !errors.Is(err, ErrInvalidDecapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidDecapsulationKey", err)
+	}
+}
+
+func TestBinding1024(t *testing.T) {
+	privateKey, publicKey, _ := KemKeypair1024()
+	label := []byte("binding test")
+	ciphertext, ssA, err := KemEncryptBinding1024(publicKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemDecryptBinding1024(ciphertext, privateKey, label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	// An implicitly rejected ciphertext yields a different binding secret.
+	ciphertext[0] ^= 1
+	if ssC, _ := KemDecryptBinding1024(ciphertext, privateKey, label); ssC == ssA {
+		t.Fatal("modified ciphertext was accepted")
+	}
+}
+
+func TestBindingPublicKey(t *testing.T) {
+	// Two decapsulation keys that share the secret vector and z but embed
+	// different encapsulation keys derive different binding secrets for
+	// the same ciphertext, even though ML-KEM alone may not separate them.
+	privateKey, publicKey, _ := KemKeypair768()
+	ciphertext, _, _ := KemEncryptBinding768(publicKey, nil)
+	_, otherPublicKey, _ := KemKeypair768()
+	other := privateKey
+	pki := paramsIndcpaSecretKeyBytesK768
+	copy(other[pki:], otherPublicKey[:])
+	pkh := sha3.Sum256(otherPublicKey[:])
+	copy(other[pki+Kyber768PKBytes:], pkh[:])
+	ssA, _ := KemDecryptBinding768(ciphertext, privateKey, nil)
+	ssB, err := KemDecryptBinding768(ciphertext, other, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA == ssB {
+		t.Fatal("shared secret is not bound to the encapsulation key")
+	}
+}
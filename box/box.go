@@ -0,0 +1,256 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package box implements NaCl-box-style public-key encryption with
+// ML-KEM-768, with an anonymous mode in the manner of sealed boxes and
+// an authenticated mode in the manner of crypto_box.
+//
+// A box is a three-byte header, an ML-KEM-768 ciphertext, a 32-byte key
+// commitment and an AEAD ciphertext:
+//
+//	version (0x01) || mode || AEAD || KEM ciphertext || commitment || payload
+//
+// The mode byte is 0x00 for anonymous boxes, whose KEM ciphertext comes
+// from KemEncrypt768, and 0x01 for authenticated boxes, whose KEM
+// ciphertext comes from an authkem link between the sender and the
+// recipient. The AEAD byte selects XChaCha20-Poly1305 (0x01) or
+// AES-256-GCM (0x02). The AEAD key and the commitment are derived with
+// HKDF-SHA256 from the 32-byte shared secret, with the header, H(ek)
+// and the KEM ciphertext as info. Since every box has a fresh key, the
+// nonce is zero and is not transmitted. Open checks the commitment
+// before decrypting, so a box opens under at most one key even though
+// neither AEAD is key-committing by itself.
+package box
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/authkem"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrOpen is returned when a box is truncated, malformed, tampered
+	// with, or sealed to another key.
+	ErrOpen = errors.New("box: cannot open box")
+
+	// ErrUnsupported is returned when a box has an unknown version, mode
+	// or AEAD, or a sealing option names an unknown AEAD.
+	ErrUnsupported = errors.New("box: unsupported version, mode or AEAD")
+
+	// ErrInvalidLink is returned when an authkem link is not an
+	// ML-KEM-768 link for the given recipient.
+	ErrInvalidLink = errors.New("box: invalid link")
+)
+
+// Version is the version byte of boxes.
+const Version = 0x01
+
+// Box modes.
+const (
+	modeAnonymous     = 0x00
+	modeAuthenticated = 0x01
+)
+
+// AEAD identifies the payload encryption of a box.
+type AEAD byte
+
+// Supported AEADs.
+const (
+	XChaCha20Poly1305 AEAD = 0x01
+	AES256GCM         AEAD = 0x02
+)
+
+// headerSize is the size of the version, mode and AEAD bytes.
+const headerSize = 3
+
+// commitmentSize is the size of the key commitment.
+const commitmentSize = 32
+
+// Overhead is the number of bytes a box adds to its message.
+const Overhead = headerSize + kyberk2so.Kyber768CTBytes + commitmentSize + 16
+
+// Options are the optional parameters of sealing.
+type Options struct {
+	// AEAD selects the payload encryption; zero selects
+	// XChaCha20-Poly1305.
+	AEAD AEAD
+
+	// AdditionalData is authenticated but not encrypted, and must be
+	// passed again to open the box.
+	AdditionalData []byte
+}
+
+func (o *Options) aead() AEAD {
+	if o == nil || o.AEAD == 0 {
+		return XChaCha20Poly1305
+	}
+	return o.AEAD
+}
+
+func (o *Options) additionalData() []byte {
+	if o == nil {
+		return nil
+	}
+	return o.AdditionalData
+}
+
+// SealAnonymous encrypts msg to the ML-KEM-768 encapsulation key ek.
+// The recipient learns nothing about the sender. opts may be nil.
+func SealAnonymous(msg []byte, ek [kyberk2so.Kyber768PKBytes]byte, opts *Options) ([]byte, error) {
+	aeadID := opts.aead()
+	if aeadID != XChaCha20Poly1305 && aeadID != AES256GCM {
+		return nil, ErrUnsupported
+	}
+	ct, ss, err := kyberk2so.KemEncrypt768(ek)
+	if err != nil {
+		return nil, err
+	}
+	pkh := sha3.Sum256(ek[:])
+	return seal([]byte{Version, modeAnonymous, byte(aeadID)}, ct[:], ss[:], pkh[:], msg, opts.additionalData())
+}
+
+// OpenAnonymous decrypts a box from SealAnonymous with the ML-KEM-768
+// decapsulation key dk and the additional data given when sealing.
+func OpenAnonymous(box []byte, dk [kyberk2so.Kyber768SKBytes]byte, additionalData []byte) ([]byte, error) {
+	header, ct, err := parse(box, modeAnonymous)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := kyberk2so.KemDecrypt768([kyberk2so.Kyber768CTBytes]byte(ct), dk)
+	if err != nil {
+		return nil, err
+	}
+	return open(box, header, ct, ss[:], mlkem.MLKEM768.KeyHash(dk[:]), additionalData)
+}
+
+// Seal encrypts msg from the sender of an ML-KEM-768 authkem link to the
+// recipient with encapsulation key ek, so that the recipient can verify
+// that the box comes from that sender. opts may be nil.
+func Seal(msg []byte, ek [kyberk2so.Kyber768PKBytes]byte, link *authkem.Link, opts *Options) ([]byte, error) {
+	aeadID := opts.aead()
+	if aeadID != XChaCha20Poly1305 && aeadID != AES256GCM {
+		return nil, ErrUnsupported
+	}
+	if link.KEM() != authkem.MLKEM768 {
+		return nil, ErrInvalidLink
+	}
+	ct, ss, err := authkem.Encapsulate(link, ek[:])
+	if err != nil {
+		if errors.Is(err, authkem.ErrInvalidLink) {
+			return nil, ErrInvalidLink
+		}
+		return nil, err
+	}
+	pkh := sha3.Sum256(ek[:])
+	return seal([]byte{Version, modeAuthenticated, byte(aeadID)}, ct, ss, pkh[:], msg, opts.additionalData())
+}
+
+// Open decrypts a box from Seal with the ML-KEM-768 decapsulation key dk,
+// the recipient's authkem link to the expected sender, and the
+// additional data given when sealing. Boxes from any other sender fail
+// to open.
+func Open(box []byte, dk [kyberk2so.Kyber768SKBytes]byte, link *authkem.Link, additionalData []byte) ([]byte, error) {
+	header, ct, err := parse(box, modeAuthenticated)
+	if err != nil {
+		return nil, err
+	}
+	if link.KEM() != authkem.MLKEM768 {
+		return nil, ErrInvalidLink
+	}
+	recipient := authkem.KeyPair{Private: dk[:], Public: mlkem.MLKEM768.PublicKey(dk[:])}
+	ss, err := authkem.Decapsulate(link, recipient, ct)
+	if err != nil {
+		if errors.Is(err, authkem.ErrInvalidLink) {
+			return nil, ErrInvalidLink
+		}
+		return nil, err
+	}
+	return open(box, header, ct, ss, mlkem.MLKEM768.KeyHash(dk[:]), additionalData)
+}
+
+// parse checks the header and length of a box and returns its header
+// and KEM ciphertext.
+func parse(box []byte, mode byte) ([]byte, []byte, error) {
+	if len(box) < headerSize {
+		return nil, nil, ErrOpen
+	}
+	if box[0] != Version || box[1] != mode || (AEAD(box[2]) != XChaCha20Poly1305 && AEAD(box[2]) != AES256GCM) {
+		return nil, nil, ErrUnsupported
+	}
+	if len(box) < Overhead {
+		return nil, nil, ErrOpen
+	}
+	return box[:headerSize], box[headerSize : headerSize+kyberk2so.Kyber768CTBytes], nil
+}
+
+// keys derives the AEAD key and the key commitment.
+func keys(header, ct, ss, pkh []byte) (cipher.AEAD, []byte, error) {
+	info := append([]byte("kyberk2so box v1"), header...)
+	info = append(info, pkh...)
+	info = append(info, ct...)
+	okm, err := hkdf.Key(sha256.New, ss, nil, string(info), 32+commitmentSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(okm[:32])
+	var aead cipher.AEAD
+	if AEAD(header[2]) == AES256GCM {
+		block, err := aes.NewCipher(okm[:32])
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		aead, err = chacha20poly1305.NewX(okm[:32])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return aead, okm[32:], nil
+}
+
+// seal assembles a box from its header, KEM ciphertext and shared secret.
+func seal(header, ct, ss, pkh, msg, additionalData []byte) ([]byte, error) {
+	defer mlkem.ZeroBytes(ss)
+	aead, commitment, err := keys(header, ct, ss, pkh)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(msg)+Overhead)
+	out = append(out, header...)
+	out = append(out, ct...)
+	out = append(out, commitment...)
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(out, nonce, msg, additionalData), nil
+}
+
+// open checks the key commitment of a box and decrypts its payload.
+func open(box, header, ct, ss, pkh, additionalData []byte) ([]byte, error) {
+	defer mlkem.ZeroBytes(ss)
+	aead, commitment, err := keys(header, ct, ss, pkh)
+	if err != nil {
+		return nil, err
+	}
+	offset := headerSize + len(ct)
+	if subtle.ConstantTimeCompare(commitment, box[offset:offset+commitmentSize]) != 1 {
+		return nil, ErrOpen
+	}
+	nonce := make([]byte, aead.NonceSize())
+	msg, err := aead.Open(nil, nonce, box[offset+commitmentSize:], additionalData)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return msg, nil
+}
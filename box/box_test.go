@@ -0,0 +1,188 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package box
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/authkem"
+)
+
+func keyPair(t *testing.T) ([kyberk2so.Kyber768SKBytes]byte, [kyberk2so.Kyber768PKBytes]byte) {
+	t.Helper()
+	dk, ek, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dk, ek
+}
+
+// links establishes an authkem link from a new sender to the recipient
+// with the given key pair, returning the sender's and recipient's links.
+func links(t *testing.T, dk [kyberk2so.Kyber768SKBytes]byte, ek [kyberk2so.Kyber768PKBytes]byte) (*authkem.Link, *authkem.Link) {
+	t.Helper()
+	sender, err := authkem.GenerateKeyPair(authkem.MLKEM768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, recipientLink, err := authkem.Establish(authkem.MLKEM768, ek[:], sender.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	senderLink, err := authkem.Accept(authkem.MLKEM768, sender, ek[:], msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return senderLink, recipientLink
+}
+
+func TestAnonymous(t *testing.T) {
+	dk, ek := keyPair(t)
+	msg := []byte("attack at dawn")
+	for _, aead := range []AEAD{0, XChaCha20Poly1305, AES256GCM} {
+		opts := &Options{AEAD: aead, AdditionalData: []byte("header")}
+		box, err := SealAnonymous(msg, ek, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(box) != len(msg)+Overhead {
+			t.Fatalf("AEAD %d: box is %d bytes, want %d", aead, len(box), len(msg)+Overhead)
+		}
+		opened, err := OpenAnonymous(box, dk, []byte("header"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(opened, msg) {
+			t.Fatalf("AEAD %d: message does not round-trip", aead)
+		}
+		if _, err := OpenAnonymous(box, dk, []byte("footer")); !errors.Is(err, ErrOpen) {
+			t.Fatalf("AEAD %d: wrong additional data: got %v, want ErrOpen", aead, err)
+		}
+	}
+	box, err := SealAnonymous(nil, ek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := OpenAnonymous(box, dk, nil); err != nil || len(opened) != 0 {
+		t.Fatal("empty message does not round-trip")
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	dk, ek := keyPair(t)
+	senderLink, recipientLink := links(t, dk, ek)
+	msg := []byte("attack at dawn")
+	for _, aead := range []AEAD{XChaCha20Poly1305, AES256GCM} {
+		box, err := Seal(msg, ek, senderLink, &Options{AEAD: aead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := Open(box, dk, recipientLink, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(opened, msg) {
+			t.Fatalf("AEAD %d: message does not round-trip", aead)
+		}
+		// Authenticated and anonymous boxes are not interchangeable.
+		if _, err := OpenAnonymous(box, dk, nil); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("got %v, want ErrUnsupported", err)
+		}
+	}
+	// A box from another sender does not open under this link.
+	otherLink, _ := links(t, dk, ek)
+	box, err := Seal(msg, ek, otherLink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(box, dk, recipientLink, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("other sender: got %v, want ErrOpen", err)
+	}
+	// A link to another recipient is rejected.
+	_, otherEK := keyPair(t)
+	if _, err := Seal(msg, otherEK, senderLink, nil); !errors.Is(err, ErrInvalidLink) {
+		t.Fatalf("got %v, want ErrInvalidLink", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	dk, ek := keyPair(t)
+	for _, aead := range []AEAD{XChaCha20Poly1305, AES256GCM} {
+		box, err := SealAnonymous([]byte("attack at dawn"), ek, &Options{AEAD: aead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range []struct {
+			region string
+			offset int
+		}{
+			{"KEM ciphertext", headerSize},
+			{"KEM ciphertext end", headerSize + kyberk2so.Kyber768CTBytes - 1},
+			{"commitment", headerSize + kyberk2so.Kyber768CTBytes},
+			{"payload", headerSize + kyberk2so.Kyber768CTBytes + commitmentSize},
+			{"tag", len(box) - 1},
+		} {
+			tampered := bytes.Clone(box)
+			tampered[tc.offset] ^= 1
+			if _, err := OpenAnonymous(tampered, dk, nil); !errors.Is(err, ErrOpen) {
+				t.Fatalf("AEAD %d: tampered %s: got %v, want ErrOpen", aead, tc.region, err)
+			}
+		}
+		// Switching the AEAD byte changes the derived keys.
+		tampered := bytes.Clone(box)
+		tampered[2] = byte(XChaCha20Poly1305 + AES256GCM - aead)
+		if _, err := OpenAnonymous(tampered, dk, nil); !errors.Is(err, ErrOpen) {
+			t.Fatalf("AEAD %d: switched AEAD: got %v, want ErrOpen", aead, err)
+		}
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	dk, ek := keyPair(t)
+	box, err := SealAnonymous([]byte("attack at dawn"), ek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 1, headerSize, headerSize + kyberk2so.Kyber768CTBytes, Overhead - 1, len(box) - 1} {
+		if _, err := OpenAnonymous(box[:n], dk, nil); err == nil {
+			t.Fatalf("box truncated to %d bytes was accepted", n)
+		}
+	}
+	if _, err := OpenAnonymous(append(bytes.Clone(box), 0), dk, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("extended box: got %v, want ErrOpen", err)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	_, ek := keyPair(t)
+	otherDK, _ := keyPair(t)
+	box, err := SealAnonymous([]byte("attack at dawn"), ek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAnonymous(box, otherDK, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("got %v, want ErrOpen", err)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	dk, ek := keyPair(t)
+	if _, err := SealAnonymous(nil, ek, &Options{AEAD: 3}); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("unknown AEAD: got %v, want ErrUnsupported", err)
+	}
+	box, err := SealAnonymous([]byte("attack at dawn"), ek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range []byte{Version + 1, 0x02, 0x00} {
+		tampered := bytes.Clone(box)
+		tampered[i] = b
+		if _, err := OpenAnonymous(tampered, dk, nil); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("header byte %d: got %v, want ErrUnsupported", i, err)
+		}
+	}
+}
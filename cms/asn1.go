@@ -0,0 +1,111 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package cms
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+)
+
+var (
+	oidData              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAuthEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 23}
+	oidORIKEM            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 13, 3}
+	oidMLKEM512          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 1}
+	oidMLKEM768          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 2}
+	oidMLKEM1024         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 4, 3}
+	oidHKDFWithSHA256    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 28}
+	oidHKDFWithSHA512    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 3, 30}
+	oidKMAC256           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 22}
+	oidAES128Wrap        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 5}
+	oidAES256Wrap        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 45}
+	oidAES128CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES256CBC         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidAES128GCM         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 6}
+	oidAES256GCM         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
+)
+
+const (
+	tagOtherRecipientInfo    = 4
+	tagSubjectKeyIdentifier  = 0
+	envelopedDataVersionORI  = 3
+	authEnvelopedDataVersion = 0
+	kemRecipientInfoVersion  = 0
+	gcmNonceSize             = 12
+	gcmTagSize               = 16
+)
+
+// contentInfo is the outer ContentInfo structure from RFC 5652 §3.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// envelopedData is the EnvelopedData structure from RFC 5652 §6.1.
+type envelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+	UnprotectedAttrs     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// authEnvelopedData is the AuthEnvelopedData structure from RFC 5083 §2.1.
+type authEnvelopedData struct {
+	Version                  int
+	OriginatorInfo           asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos           []asn1.RawValue `asn1:"set"`
+	AuthEncryptedContentInfo encryptedContentInfo
+	AuthAttrs                asn1.RawValue `asn1:"optional,tag:1"`
+	MAC                      []byte
+	UnauthAttrs              asn1.RawValue `asn1:"optional,tag:2"`
+}
+
+// encryptedContentInfo is the EncryptedContentInfo structure from RFC 5652 §6.1.
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+// otherRecipientInfo is the OtherRecipientInfo structure from RFC 5652 §6.2.5.
+type otherRecipientInfo struct {
+	OriType  asn1.ObjectIdentifier
+	OriValue asn1.RawValue
+}
+
+// kemRecipientInfo is the KEMRecipientInfo structure from RFC 9629 §3.
+type kemRecipientInfo struct {
+	Version      int
+	RID          asn1.RawValue
+	KEM          pkix.AlgorithmIdentifier
+	KEMCT        []byte
+	KDF          pkix.AlgorithmIdentifier
+	KEKLength    int
+	UKM          []byte `asn1:"optional,explicit,tag:0"`
+	Wrap         pkix.AlgorithmIdentifier
+	EncryptedKey []byte
+}
+
+// kemOtherInfo is the CMSORIforKEMOtherInfo structure from RFC 9629 §5,
+// whose DER encoding is used as the info input to the KDF.
+type kemOtherInfo struct {
+	Wrap      pkix.AlgorithmIdentifier
+	KEKLength int
+	UKM       []byte `asn1:"optional,explicit,tag:0"`
+}
+
+// issuerAndSerialNumber is the IssuerAndSerialNumber structure from RFC 5652 §10.2.4.
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// gcmParameters is the GCMParameters structure from RFC 5084 §3.2.
+type gcmParameters struct {
+	Nonce  []byte
+	ICVLen int `asn1:"default:12"`
+}
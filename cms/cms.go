@@ -0,0 +1,327 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package cms implements CMS EnvelopedData (RFC 5652) and AuthEnvelopedData
+// (RFC 5083) for ML-KEM recipients. Each recipient is described by a
+// KEMRecipientInfo (RFC 9629) as profiled for ML-KEM by the LAMPS
+// "Use of ML-KEM in the Cryptographic Message Syntax" specification:
+// the content-encryption key is wrapped with AES key wrap under a
+// key-encryption key derived from the ML-KEM shared secret.
+//
+// Messages are produced and parsed as DER using only encoding/asn1.
+package cms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+var (
+	// ErrUnsupportedAlgorithm is returned when a message or option names
+	// an algorithm that this package does not implement.
+	ErrUnsupportedAlgorithm = errors.New("cms: unsupported algorithm")
+
+	// ErrInvalidKey is returned when a public or private key does not
+	// have the length required by its ML-KEM parameter set.
+	ErrInvalidKey = errors.New("cms: invalid key")
+
+	// ErrInvalidRecipient is returned when a recipient identifier
+	// carries neither a subject key identifier nor an issuer and serial number.
+	ErrInvalidRecipient = errors.New("cms: invalid recipient identifier")
+
+	// ErrRecipientNotFound is returned when no KEMRecipientInfo in a
+	// message matches the requested recipient identifier.
+	ErrRecipientNotFound = errors.New("cms: recipient not found")
+
+	// ErrMalformedMessage is returned when a message cannot be parsed.
+	ErrMalformedMessage = errors.New("cms: malformed message")
+
+	// ErrDecryptionFailed is returned when the content-encryption key
+	// cannot be unwrapped or the content fails to decrypt.
+	ErrDecryptionFailed = errors.New("cms: decryption failed")
+)
+
+// KEM identifies an ML-KEM parameter set.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// KDF identifies the key derivation function applied to the
+// ML-KEM shared secret to obtain the key-encryption key.
+type KDF int
+
+// Supported key derivation functions.
+const (
+	HKDFSHA256 KDF = iota + 1
+	HKDFSHA512
+	KMAC256
+)
+
+// KeyWrap identifies the key-encryption algorithm used to wrap
+// the content-encryption key.
+type KeyWrap int
+
+// Supported key-encryption algorithms.
+const (
+	AES128Wrap KeyWrap = iota + 1
+	AES256Wrap
+)
+
+// RecipientIdentifier identifies a recipient either by subject key
+// identifier or by the issuer and serial number of its certificate.
+// When SubjectKeyID is set it takes precedence.
+type RecipientIdentifier struct {
+	SubjectKeyID []byte
+	Issuer       []byte // DER-encoded issuer Name.
+	SerialNumber *big.Int
+}
+
+// NewRecipientIdentifier returns the identifier for cert, using its
+// subject key identifier when present and its issuer and serial
+// number otherwise.
+func NewRecipientIdentifier(cert *x509.Certificate) RecipientIdentifier {
+	if len(cert.SubjectKeyId) > 0 {
+		return RecipientIdentifier{SubjectKeyID: cert.SubjectKeyId}
+	}
+	return RecipientIdentifier{Issuer: cert.RawIssuer, SerialNumber: cert.SerialNumber}
+}
+
+// Equal reports whether id and other identify the same recipient.
+func (id RecipientIdentifier) Equal(other RecipientIdentifier) bool {
+	if len(id.SubjectKeyID) > 0 || len(other.SubjectKeyID) > 0 {
+		return bytes.Equal(id.SubjectKeyID, other.SubjectKeyID)
+	}
+	if id.SerialNumber == nil || other.SerialNumber == nil {
+		return false
+	}
+	return bytes.Equal(id.Issuer, other.Issuer) && id.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+// Recipient is an ML-KEM encapsulation key together with the
+// identifier under which it appears in a message.
+type Recipient struct {
+	ID        RecipientIdentifier
+	KEM       KEM
+	PublicKey []byte
+}
+
+// Options configures message creation. A nil *Options selects the
+// defaults: id-data content, HKDF-SHA256, and AES-128 key wrap for
+// ML-KEM-512 or AES-256 key wrap for ML-KEM-768 and ML-KEM-1024.
+type Options struct {
+	// ContentType is the type of the encrypted content.
+	ContentType asn1.ObjectIdentifier
+	// KDF derives the key-encryption key from the shared secret.
+	KDF KDF
+	// KeyWrap overrides the per-parameter-set key wrap default.
+	KeyWrap KeyWrap
+	// UKM is optional user keying material mixed into the KDF.
+	UKM []byte
+}
+
+// Encrypt returns a DER-encoded ContentInfo holding an EnvelopedData
+// that encrypts content with AES-256-CBC for each of the recipients.
+func Encrypt(content []byte, recipients []Recipient, opts *Options) ([]byte, error) {
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(cek)
+	infos, err := marshalRecipientInfos(cek, recipients, opts)
+	if err != nil {
+		return nil, err
+	}
+	eci, err := encryptContentCBC(content, cek, opts.contentType())
+	if err != nil {
+		return nil, err
+	}
+	return marshalContentInfo(oidEnvelopedData, envelopedData{
+		Version:              envelopedDataVersionORI,
+		RecipientInfos:       infos,
+		EncryptedContentInfo: eci,
+	})
+}
+
+// EncryptAuthenticated returns a DER-encoded ContentInfo holding an
+// AuthEnvelopedData that encrypts content with AES-256-GCM for each
+// of the recipients.
+func EncryptAuthenticated(content []byte, recipients []Recipient, opts *Options) ([]byte, error) {
+	cek := make([]byte, 32)
+	if _, err := rand.Read(cek); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(cek)
+	infos, err := marshalRecipientInfos(cek, recipients, opts)
+	if err != nil {
+		return nil, err
+	}
+	eci, mac, err := encryptContentGCM(content, cek, opts.contentType())
+	if err != nil {
+		return nil, err
+	}
+	return marshalContentInfo(oidAuthEnvelopedData, authEnvelopedData{
+		Version:                  authEnvelopedDataVersion,
+		RecipientInfos:           infos,
+		AuthEncryptedContentInfo: eci,
+		MAC:                      mac,
+	})
+}
+
+// Message is a parsed EnvelopedData or AuthEnvelopedData.
+type Message struct {
+	// Authenticated is true for AuthEnvelopedData.
+	Authenticated bool
+	// ContentType is the type of the encrypted content.
+	ContentType asn1.ObjectIdentifier
+	// Recipients lists the KEMRecipientInfo entries of the message.
+	// Recipient infos of other types are skipped.
+	Recipients []RecipientInfo
+
+	eci       encryptedContentInfo
+	mac       []byte
+	authAttrs []byte
+}
+
+// RecipientInfo describes one KEMRecipientInfo of a parsed message.
+type RecipientInfo struct {
+	ID      RecipientIdentifier
+	KEM     KEM
+	KDF     KDF
+	KeyWrap KeyWrap
+	UKM     []byte
+
+	ciphertext   []byte
+	encryptedKey []byte
+}
+
+// Parse parses a DER-encoded ContentInfo holding an EnvelopedData
+// or an AuthEnvelopedData.
+func Parse(der []byte) (*Message, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) > 0 {
+		return nil, ErrMalformedMessage
+	}
+	m := new(Message)
+	var infos []asn1.RawValue
+	switch {
+	case ci.ContentType.Equal(oidEnvelopedData):
+		var ed envelopedData
+		if rest, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil || len(rest) > 0 {
+			return nil, ErrMalformedMessage
+		}
+		infos, m.eci = ed.RecipientInfos, ed.EncryptedContentInfo
+	case ci.ContentType.Equal(oidAuthEnvelopedData):
+		var aed authEnvelopedData
+		if rest, err := asn1.Unmarshal(ci.Content.Bytes, &aed); err != nil || len(rest) > 0 {
+			return nil, ErrMalformedMessage
+		}
+		infos, m.eci, m.mac = aed.RecipientInfos, aed.AuthEncryptedContentInfo, aed.MAC
+		m.Authenticated = true
+		if len(aed.AuthAttrs.FullBytes) > 0 {
+			// The authenticated attributes are MACed with their
+			// SET OF tag rather than the implicit [1] tag.
+			m.authAttrs = append([]byte(nil), aed.AuthAttrs.FullBytes...)
+			m.authAttrs[0] = 0x31
+		}
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	m.ContentType = m.eci.ContentType
+	for _, raw := range infos {
+		ri, ok, err := parseRecipientInfo(raw)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.Recipients = append(m.Recipients, ri)
+		}
+	}
+	return m, nil
+}
+
+// Decrypt decapsulates the KEMRecipientInfo addressed to id with
+// privateKey, unwraps the content-encryption key and returns the
+// decrypted content.
+func (m *Message) Decrypt(id RecipientIdentifier, privateKey []byte) ([]byte, error) {
+	for i := range m.Recipients {
+		if !m.Recipients[i].ID.Equal(id) {
+			continue
+		}
+		cek, err := m.Recipients[i].unwrap(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		defer mlkem.ZeroBytes(cek)
+		if m.Authenticated {
+			return decryptContentGCM(m.eci, cek, m.mac, m.authAttrs)
+		}
+		return decryptContentCBC(m.eci, cek)
+	}
+	return nil, ErrRecipientNotFound
+}
+
+// Decrypt parses der and decrypts it for the recipient id.
+func Decrypt(der []byte, id RecipientIdentifier, privateKey []byte) ([]byte, error) {
+	m, err := Parse(der)
+	if err != nil {
+		return nil, err
+	}
+	return m.Decrypt(id, privateKey)
+}
+
+// contentType returns the configured content type, defaulting to id-data.
+func (opts *Options) contentType() asn1.ObjectIdentifier {
+	if opts == nil || len(opts.ContentType) == 0 {
+		return oidData
+	}
+	return opts.ContentType
+}
+
+// marshalContentInfo wraps content in a ContentInfo of the given type.
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content any) ([]byte, error) {
+	inner, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{FullBytes: explicitTag0(inner)},
+	})
+}
+
+// explicitTag0 wraps der in an explicit [0] tag.
+func explicitTag0(der []byte) []byte {
+	out, _ := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      der,
+	})
+	return out
+}
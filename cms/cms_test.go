@@ -0,0 +1,189 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package cms
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+type testRecipient struct {
+	recipient  Recipient
+	privateKey []byte
+}
+
+func newTestRecipients(t *testing.T) []testRecipient {
+	t.Helper()
+	sk512, pk512, err := kyberk2so.KemKeypair512()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk768, pk768, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk1024, pk1024, err := kyberk2so.KemKeypair1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := asn1.Marshal(pkix.Name{CommonName: "Kyber-K2SO Test CA"}.ToRDNSequence())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []testRecipient{
+		{Recipient{RecipientIdentifier{SubjectKeyID: []byte("ski-512")}, MLKEM512, pk512[:]}, sk512[:]},
+		{Recipient{RecipientIdentifier{SubjectKeyID: []byte("ski-768")}, MLKEM768, pk768[:]}, sk768[:]},
+		{
+			Recipient{RecipientIdentifier{Issuer: issuer, SerialNumber: big.NewInt(1024)}, MLKEM1024, pk1024[:]},
+			sk1024[:],
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	recipients := newTestRecipients(t)
+	all := make([]Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		all = append(all, r.recipient)
+	}
+	content := []byte("compliance archive document")
+	for _, opts := range []*Options{
+		nil,
+		{KDF: HKDFSHA512},
+		{KDF: KMAC256, UKM: []byte("user keying material")},
+		{KeyWrap: AES128Wrap},
+	} {
+		for _, encrypt := range []func([]byte, []Recipient, *Options) ([]byte, error){
+			Encrypt, EncryptAuthenticated,
+		} {
+			der, err := encrypt(content, all, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := Parse(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Recipients) != len(recipients) || !m.ContentType.Equal(oidData) {
+				t.Fatalf("unexpected parsed message: %+v", m)
+			}
+			for _, r := range recipients {
+				got, err := m.Decrypt(r.recipient.ID, r.privateKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("content mismatch for %v", r.recipient.KEM)
+				}
+			}
+		}
+	}
+}
+
+func TestEmptyContent(t *testing.T) {
+	r := newTestRecipients(t)[1]
+	for _, encrypt := range []func([]byte, []Recipient, *Options) ([]byte, error){
+		Encrypt, EncryptAuthenticated,
+	} {
+		der, err := encrypt(nil, []Recipient{r.recipient}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decrypt(der, r.recipient.ID, r.privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("expected empty content, got %x", got)
+		}
+	}
+}
+
+func TestRecipientInfoEncoding(t *testing.T) {
+	r := newTestRecipients(t)[1]
+	der, err := EncryptAuthenticated([]byte("x"), []Recipient{r.recipient}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri := m.Recipients[0]
+	if ri.KEM != MLKEM768 || ri.KDF != HKDFSHA256 || ri.KeyWrap != AES256Wrap {
+		t.Errorf("unexpected algorithms: %+v", ri)
+	}
+	if len(ri.ciphertext) != kyberk2so.Kyber768CTBytes || len(ri.encryptedKey) != 40 {
+		t.Errorf("unexpected lengths: ct %d, wrapped key %d", len(ri.ciphertext), len(ri.encryptedKey))
+	}
+	if !m.Authenticated {
+		t.Error("expected AuthEnvelopedData")
+	}
+}
+
+func TestWrongRecipient(t *testing.T) {
+	recipients := newTestRecipients(t)
+	der, err := Encrypt([]byte("secret"), []Recipient{recipients[1].recipient}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(der, recipients[0].recipient.ID, recipients[0].privateKey); err != ErrRecipientNotFound {
+		t.Errorf("expected ErrRecipientNotFound, got %v", err)
+	}
+	otherKey, _, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(der, recipients[1].recipient.ID, otherKey[:]); err != ErrDecryptionFailed {
+		t.Errorf("expected ErrDecryptionFailed, got %v", err)
+	}
+	if _, err := Decrypt(der, recipients[1].recipient.ID, recipients[2].privateKey); err != ErrInvalidKey {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestTamperedAuthenticatedContent(t *testing.T) {
+	r := newTestRecipients(t)[2]
+	content := []byte("tamper-evident content")
+	der, err := EncryptAuthenticated(content, []Recipient{r.recipient}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.eci.EncryptedContent[0] ^= 1
+	if _, err := m.Decrypt(r.recipient.ID, r.privateKey); err != ErrDecryptionFailed {
+		t.Errorf("expected ErrDecryptionFailed, got %v", err)
+	}
+	tampered := append([]byte(nil), der...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Decrypt(tampered, r.recipient.ID, r.privateKey); err != ErrDecryptionFailed {
+		t.Errorf("expected ErrDecryptionFailed for tampered MAC, got %v", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	if _, err := Parse([]byte{0x30, 0x00}); err != ErrMalformedMessage {
+		t.Errorf("expected ErrMalformedMessage, got %v", err)
+	}
+	r := newTestRecipients(t)[1]
+	der, err := Encrypt([]byte("x"), []Recipient{r.recipient}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(der[:len(der)-1]); err != ErrMalformedMessage {
+		t.Errorf("expected ErrMalformedMessage for truncated message, got %v", err)
+	}
+	anonymous := Recipient{KEM: MLKEM768, PublicKey: r.recipient.PublicKey}
+	if _, err := Encrypt([]byte("x"), []Recipient{anonymous}, nil); err != ErrInvalidRecipient {
+		t.Errorf("expected ErrInvalidRecipient, got %v", err)
+	}
+}
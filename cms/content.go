@@ -0,0 +1,163 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package cms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+// encryptContentCBC encrypts content with AES-CBC and PKCS #7 padding
+// per RFC 3565, carrying the IV as the algorithm parameters.
+func encryptContentCBC(content, cek []byte, contentType asn1.ObjectIdentifier) (encryptedContentInfo, error) {
+	var eci encryptedContentInfo
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return eci, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return eci, err
+	}
+	params, err := asn1.Marshal(iv)
+	if err != nil {
+		return eci, err
+	}
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	ciphertext := make([]byte, len(content)+pad)
+	copy(ciphertext, content)
+	for i := len(content); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+	return encryptedContentInfo{
+		ContentType: contentType,
+		ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  contentAlgorithmCBC(len(cek)),
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedContent: ciphertext,
+	}, nil
+}
+
+// decryptContentCBC reverses encryptContentCBC. The padding check
+// does not branch on the padding bytes.
+func decryptContentCBC(eci encryptedContentInfo, cek []byte) ([]byte, error) {
+	alg := eci.ContentEncryptionAlgorithm
+	if !alg.Algorithm.Equal(contentAlgorithmCBC(len(cek))) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	var iv []byte
+	if rest, err := asn1.Unmarshal(alg.Parameters.FullBytes, &iv); err != nil || len(rest) > 0 {
+		return nil, ErrMalformedMessage
+	}
+	ciphertext := eci.EncryptedContent
+	if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrMalformedMessage
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	pad := plaintext[len(plaintext)-1]
+	good := subtle.ConstantTimeLessOrEq(1, int(pad)) & subtle.ConstantTimeLessOrEq(int(pad), aes.BlockSize)
+	for i := 1; i <= aes.BlockSize; i++ {
+		inPad := subtle.ConstantTimeLessOrEq(i, int(pad))
+		match := subtle.ConstantTimeByteEq(plaintext[len(plaintext)-i], pad)
+		good &= subtle.ConstantTimeSelect(inPad, match, 1)
+	}
+	if good != 1 {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext[:len(plaintext)-int(pad)], nil
+}
+
+// encryptContentGCM encrypts content with AES-GCM per RFC 5084,
+// returning the encrypted content info and the authentication tag.
+func encryptContentGCM(content, cek []byte, contentType asn1.ObjectIdentifier) (
+	encryptedContentInfo, []byte, error,
+) {
+	var eci encryptedContentInfo
+	aead, err := newGCM(cek)
+	if err != nil {
+		return eci, nil, err
+	}
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return eci, nil, err
+	}
+	params, err := asn1.Marshal(gcmParameters{Nonce: nonce, ICVLen: gcmTagSize})
+	if err != nil {
+		return eci, nil, err
+	}
+	sealed := aead.Seal(nil, nonce, content, nil)
+	tagStart := len(sealed) - gcmTagSize
+	return encryptedContentInfo{
+		ContentType: contentType,
+		ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  contentAlgorithmGCM(len(cek)),
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedContent: sealed[:tagStart],
+	}, sealed[tagStart:], nil
+}
+
+// decryptContentGCM reverses encryptContentGCM, authenticating
+// authAttrs as additional data when present.
+func decryptContentGCM(eci encryptedContentInfo, cek, mac, authAttrs []byte) ([]byte, error) {
+	alg := eci.ContentEncryptionAlgorithm
+	if !alg.Algorithm.Equal(contentAlgorithmGCM(len(cek))) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	var params gcmParameters
+	if rest, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil || len(rest) > 0 {
+		return nil, ErrMalformedMessage
+	}
+	if len(params.Nonce) != gcmNonceSize || params.ICVLen != gcmTagSize || len(mac) != gcmTagSize {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	aead, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, len(eci.EncryptedContent)+len(mac))
+	sealed = append(sealed, eci.EncryptedContent...)
+	sealed = append(sealed, mac...)
+	plaintext, err := aead.Open(nil, params.Nonce, sealed, authAttrs)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// newGCM returns AES-GCM keyed with cek.
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// contentAlgorithmCBC returns the AES-CBC OID for the given key size.
+func contentAlgorithmCBC(keySize int) asn1.ObjectIdentifier {
+	if keySize == 16 {
+		return oidAES128CBC
+	}
+	return oidAES256CBC
+}
+
+// contentAlgorithmGCM returns the AES-GCM OID for the given key size.
+func contentAlgorithmGCM(keySize int) asn1.ObjectIdentifier {
+	if keySize == 16 {
+		return oidAES128GCM
+	}
+	return oidAES256GCM
+}
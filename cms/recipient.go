@@ -0,0 +1,340 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package cms
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+	"io"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/keywrap"
+	"github.com/symbolicsoft/kyber-k2so/internal/kmac"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/hkdf"
+)
+
+// marshalRecipientInfos encapsulates to each recipient and returns
+// the RecipientInfo entries wrapping cek.
+func marshalRecipientInfos(cek []byte, recipients []Recipient, opts *Options) ([]asn1.RawValue, error) {
+	if len(recipients) == 0 {
+		return nil, ErrInvalidRecipient
+	}
+	infos := make([]asn1.RawValue, 0, len(recipients))
+	for _, r := range recipients {
+		info, err := marshalRecipientInfo(cek, r, opts)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, asn1.RawValue{FullBytes: info})
+	}
+	return infos, nil
+}
+
+// marshalRecipientInfo returns a RecipientInfo holding an
+// OtherRecipientInfo of type id-ori-kem for recipient r.
+func marshalRecipientInfo(cek []byte, r Recipient, opts *Options) ([]byte, error) {
+	kdf, wrap, ukm := HKDFSHA256, defaultKeyWrap(r.KEM), []byte(nil)
+	if opts != nil {
+		if opts.KDF != 0 {
+			kdf = opts.KDF
+		}
+		if opts.KeyWrap != 0 {
+			wrap = opts.KeyWrap
+		}
+		if len(opts.UKM) > 0 {
+			ukm = opts.UKM
+		}
+	}
+	kemAlg, err := r.KEM.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	kdfAlg, err := kdf.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	wrapAlg, kekLength, err := wrap.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	rid, err := r.ID.marshal()
+	if err != nil {
+		return nil, err
+	}
+	ct, ss, err := encapsulate(r.KEM, r.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	kek, err := deriveKEK(kdf, ss, wrapAlg, kekLength, ukm)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(kek)
+	encryptedKey, err := keywrap.Wrap(kek, cek)
+	if err != nil {
+		return nil, err
+	}
+	kemri, err := asn1.Marshal(kemRecipientInfo{
+		Version:      kemRecipientInfoVersion,
+		RID:          rid,
+		KEM:          kemAlg,
+		KEMCT:        ct,
+		KDF:          kdfAlg,
+		KEKLength:    kekLength,
+		UKM:          ukm,
+		Wrap:         wrapAlg,
+		EncryptedKey: encryptedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.MarshalWithParams(otherRecipientInfo{
+		OriType:  oidORIKEM,
+		OriValue: asn1.RawValue{FullBytes: kemri},
+	}, "tag:4")
+}
+
+// parseRecipientInfo parses a RecipientInfo. It reports false,
+// without error, for recipient infos other than id-ori-kem.
+func parseRecipientInfo(raw asn1.RawValue) (RecipientInfo, bool, error) {
+	var ri RecipientInfo
+	if raw.Class != asn1.ClassContextSpecific || raw.Tag != tagOtherRecipientInfo {
+		return ri, false, nil
+	}
+	var ori otherRecipientInfo
+	if _, err := asn1.UnmarshalWithParams(raw.FullBytes, &ori, "tag:4"); err != nil {
+		return ri, false, ErrMalformedMessage
+	}
+	if !ori.OriType.Equal(oidORIKEM) {
+		return ri, false, nil
+	}
+	var kemri kemRecipientInfo
+	if rest, err := asn1.Unmarshal(ori.OriValue.FullBytes, &kemri); err != nil || len(rest) > 0 {
+		return ri, false, ErrMalformedMessage
+	}
+	if kemri.Version != kemRecipientInfoVersion {
+		return ri, false, ErrMalformedMessage
+	}
+	id, err := parseRecipientIdentifier(kemri.RID)
+	if err != nil {
+		return ri, false, err
+	}
+	ri = RecipientInfo{
+		ID:           id,
+		KEM:          kemFromAlgorithm(kemri.KEM),
+		KDF:          kdfFromAlgorithm(kemri.KDF),
+		KeyWrap:      keyWrapFromAlgorithm(kemri.Wrap),
+		UKM:          kemri.UKM,
+		ciphertext:   kemri.KEMCT,
+		encryptedKey: kemri.EncryptedKey,
+	}
+	if _, kekLength, err := ri.KeyWrap.algorithm(); err == nil && kekLength != kemri.KEKLength {
+		return ri, false, ErrMalformedMessage
+	}
+	return ri, true, nil
+}
+
+// unwrap decapsulates the recipient's ciphertext with privateKey and
+// unwraps the content-encryption key.
+func (ri *RecipientInfo) unwrap(privateKey []byte) ([]byte, error) {
+	if _, err := ri.KDF.algorithm(); err != nil {
+		return nil, err
+	}
+	wrapAlg, kekLength, err := ri.KeyWrap.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	ss, err := decapsulate(ri.KEM, ri.ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	kek, err := deriveKEK(ri.KDF, ss, wrapAlg, kekLength, ri.UKM)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(kek)
+	cek, err := keywrap.Unwrap(kek, ri.encryptedKey)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return cek, nil
+}
+
+// deriveKEK derives the key-encryption key from the shared secret
+// per RFC 9629 §5, using the DER encoding of CMSORIforKEMOtherInfo
+// as the KDF info input.
+func deriveKEK(kdf KDF, ss []byte, wrap pkix.AlgorithmIdentifier, kekLength int, ukm []byte) ([]byte, error) {
+	info, err := asn1.Marshal(kemOtherInfo{Wrap: wrap, KEKLength: kekLength, UKM: ukm})
+	if err != nil {
+		return nil, err
+	}
+	kek := make([]byte, kekLength)
+	var h func() hash.Hash
+	switch kdf {
+	case HKDFSHA256:
+		h = sha256.New
+	case HKDFSHA512:
+		h = sha512.New
+	case KMAC256:
+		kmac.Sum256(kek, ss, info, nil)
+		return kek, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if _, err := io.ReadFull(hkdf.New(h, ss, nil, info), kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+// marshal encodes id as a RecipientIdentifier CHOICE.
+func (id RecipientIdentifier) marshal() (asn1.RawValue, error) {
+	if len(id.SubjectKeyID) > 0 {
+		return asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   tagSubjectKeyIdentifier,
+			Bytes: id.SubjectKeyID,
+		}, nil
+	}
+	if len(id.Issuer) == 0 || id.SerialNumber == nil {
+		return asn1.RawValue{}, ErrInvalidRecipient
+	}
+	der, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: id.Issuer},
+		SerialNumber: id.SerialNumber,
+	})
+	return asn1.RawValue{FullBytes: der}, err
+}
+
+// parseRecipientIdentifier decodes a RecipientIdentifier CHOICE.
+func parseRecipientIdentifier(raw asn1.RawValue) (RecipientIdentifier, error) {
+	if raw.Class == asn1.ClassContextSpecific && raw.Tag == tagSubjectKeyIdentifier && !raw.IsCompound {
+		return RecipientIdentifier{SubjectKeyID: raw.Bytes}, nil
+	}
+	var ias issuerAndSerialNumber
+	if rest, err := asn1.Unmarshal(raw.FullBytes, &ias); err != nil || len(rest) > 0 {
+		return RecipientIdentifier{}, ErrMalformedMessage
+	}
+	return RecipientIdentifier{Issuer: ias.Issuer.FullBytes, SerialNumber: ias.SerialNumber}, nil
+}
+
+// encapsulate runs ML-KEM encapsulation for the given parameter set.
+func encapsulate(kem KEM, publicKey []byte) ([]byte, []byte, error) {
+	pkSize, _, _ := kem.params().Sizes()
+	switch {
+	case pkSize == 0:
+		return nil, nil, ErrUnsupportedAlgorithm
+	case len(publicKey) != pkSize:
+		return nil, nil, ErrInvalidKey
+	}
+	return kem.params().Encapsulate(publicKey)
+}
+
+// decapsulate runs ML-KEM decapsulation for the given parameter set.
+func decapsulate(kem KEM, ciphertext, privateKey []byte) ([]byte, error) {
+	_, skSize, ctSize := kem.params().Sizes()
+	switch {
+	case skSize == 0:
+		return nil, ErrUnsupportedAlgorithm
+	case len(privateKey) != skSize:
+		return nil, ErrInvalidKey
+	case len(ciphertext) != ctSize:
+		return nil, ErrMalformedMessage
+	}
+	return kem.params().Decapsulate(ciphertext, privateKey)
+}
+
+// defaultKeyWrap returns the key wrap algorithm paired with each
+// parameter set by the ML-KEM CMS profile.
+func defaultKeyWrap(kem KEM) KeyWrap {
+	if kem == MLKEM512 {
+		return AES128Wrap
+	}
+	return AES256Wrap
+}
+
+// algorithm returns the AlgorithmIdentifier for kem; its parameters are absent.
+func (kem KEM) algorithm() (pkix.AlgorithmIdentifier, error) {
+	switch kem {
+	case MLKEM512:
+		return pkix.AlgorithmIdentifier{Algorithm: oidMLKEM512}, nil
+	case MLKEM768:
+		return pkix.AlgorithmIdentifier{Algorithm: oidMLKEM768}, nil
+	case MLKEM1024:
+		return pkix.AlgorithmIdentifier{Algorithm: oidMLKEM1024}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// algorithm returns the AlgorithmIdentifier for kdf; its parameters are absent.
+func (kdf KDF) algorithm() (pkix.AlgorithmIdentifier, error) {
+	switch kdf {
+	case HKDFSHA256:
+		return pkix.AlgorithmIdentifier{Algorithm: oidHKDFWithSHA256}, nil
+	case HKDFSHA512:
+		return pkix.AlgorithmIdentifier{Algorithm: oidHKDFWithSHA512}, nil
+	case KMAC256:
+		return pkix.AlgorithmIdentifier{Algorithm: oidKMAC256}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// algorithm returns the AlgorithmIdentifier for wrap and its key length.
+func (wrap KeyWrap) algorithm() (pkix.AlgorithmIdentifier, int, error) {
+	switch wrap {
+	case AES128Wrap:
+		return pkix.AlgorithmIdentifier{Algorithm: oidAES128Wrap}, 16, nil
+	case AES256Wrap:
+		return pkix.AlgorithmIdentifier{Algorithm: oidAES256Wrap}, 32, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, 0, ErrUnsupportedAlgorithm
+	}
+}
+
+// kemFromAlgorithm maps an AlgorithmIdentifier to a KEM, or 0 if unknown.
+func kemFromAlgorithm(alg pkix.AlgorithmIdentifier) KEM {
+	switch {
+	case alg.Algorithm.Equal(oidMLKEM512):
+		return MLKEM512
+	case alg.Algorithm.Equal(oidMLKEM768):
+		return MLKEM768
+	case alg.Algorithm.Equal(oidMLKEM1024):
+		return MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// kdfFromAlgorithm maps an AlgorithmIdentifier to a KDF, or 0 if unknown.
+func kdfFromAlgorithm(alg pkix.AlgorithmIdentifier) KDF {
+	switch {
+	case alg.Algorithm.Equal(oidHKDFWithSHA256):
+		return HKDFSHA256
+	case alg.Algorithm.Equal(oidHKDFWithSHA512):
+		return HKDFSHA512
+	case alg.Algorithm.Equal(oidKMAC256):
+		return KMAC256
+	default:
+		return 0
+	}
+}
+
+// keyWrapFromAlgorithm maps an AlgorithmIdentifier to a KeyWrap, or 0 if unknown.
+func keyWrapFromAlgorithm(alg pkix.AlgorithmIdentifier) KeyWrap {
+	switch {
+	case alg.Algorithm.Equal(oidAES128Wrap):
+		return AES128Wrap
+	case alg.Algorithm.Equal(oidAES256Wrap):
+		return AES256Wrap
+	default:
+		return 0
+	}
+}
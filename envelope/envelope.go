@@ -0,0 +1,324 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package envelope implements multi-recipient envelope encryption with
+// ML-KEM-768 and ML-KEM-1024.
+//
+// The payload is encrypted once with ChaCha20-Poly1305 under a random
+// 32-byte data key, and each recipient gets a stanza holding a KEM
+// ciphertext and the data key wrapped with the AES key wrap of RFC 3394
+// under a key derived from the recipient's shared secret:
+//
+//	envelope = version (0x01) || u16 stanza count || stanzas || payload
+//	stanza   = KEM || flags || [key ID] || KEM ciphertext || wrapped key
+//
+// The KEM byte is 0x02 for ML-KEM-768 and 0x03 for ML-KEM-1024, so one
+// envelope may mix parameter sets. If bit 0 of the flags is set, the
+// stanza names its recipient by key ID, the SHA3-256 hash of the
+// encapsulation key; otherwise the recipient is hidden and finds its
+// stanza by trial decapsulation, which relies on the implicit rejection
+// of ML-KEM. Hidden stanzas still reveal their parameter set.
+//
+// The wrapping key is derived with HKDF-SHA256 from the shared secret,
+// with the stanza's KEM byte, the recipient's key ID and the KEM
+// ciphertext as info, and the payload authenticates the whole header
+// and the caller's additional data. As in other multi-recipient formats
+// without signatures, any recipient can produce a different envelope
+// that the other recipients accept; the envelope authenticates the
+// payload only against outsiders.
+package envelope
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/keywrap"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidKey is returned when a key has the wrong size for its
+	// KEM, or the KEM is unknown.
+	ErrInvalidKey = errors.New("envelope: invalid key")
+
+	// ErrNoRecipients is returned when encrypting to no recipients, or to
+	// more than an envelope can hold.
+	ErrNoRecipients = errors.New("envelope: no recipients")
+
+	// ErrInvalidEnvelope is returned when an envelope is truncated or
+	// malformed, or has an unknown version.
+	ErrInvalidEnvelope = errors.New("envelope: invalid envelope")
+
+	// ErrNoMatch is returned when no stanza of an envelope opens with the
+	// given key.
+	ErrNoMatch = errors.New("envelope: no stanza for this key")
+
+	// ErrAuthenticationFailed is returned when the payload or header of
+	// an envelope has been modified, or the additional data differs.
+	ErrAuthenticationFailed = errors.New("envelope: authentication failed")
+)
+
+// KEM identifies the ML-KEM parameter set of a recipient.
+type KEM byte
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM768  KEM = 0x02
+	MLKEM1024 KEM = 0x03
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// Version is the version byte of envelopes.
+const Version = 0x01
+
+// KeyIDSize is the size of key IDs.
+const KeyIDSize = 32
+
+// flagKeyID marks a stanza that carries its recipient's key ID.
+const flagKeyID = 0x01
+
+// dataKeySize is the size of the data key.
+const dataKeySize = 32
+
+// wrappedKeySize is the size of the data key wrapped with AES key wrap.
+const wrappedKeySize = dataKeySize + 8
+
+// KeyPair is a recipient's ML-KEM key pair.
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// GenerateKeyPair generates a key pair for the given KEM.
+func GenerateKeyPair(kem KEM) (KeyPair, error) {
+	sk, pk, err := kem.params().Keypair()
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return KeyPair{}, ErrInvalidKey
+	}
+	return KeyPair{Private: sk, Public: pk}, err
+}
+
+// KeyID returns the key ID of an encapsulation key, its SHA3-256 hash,
+// which is also stored in the matching decapsulation key.
+func KeyID(publicKey []byte) [KeyIDSize]byte {
+	return sha3.Sum256(publicKey)
+}
+
+// Recipient is an encapsulation key to encrypt to.
+type Recipient struct {
+	KEM       KEM
+	PublicKey []byte
+
+	// HideKeyID omits the key ID from the recipient's stanza, so that
+	// the envelope does not reveal who it is for.
+	HideKeyID bool
+}
+
+// Encrypt encrypts plaintext to all recipients, authenticating
+// additionalData, which is not included in the envelope.
+func Encrypt(plaintext, additionalData []byte, recipients ...Recipient) ([]byte, error) {
+	if len(recipients) == 0 || len(recipients) > math.MaxUint16 {
+		return nil, ErrNoRecipients
+	}
+	for _, r := range recipients {
+		if pkSize, _, _ := r.KEM.params().Sizes(); pkSize == 0 || len(r.PublicKey) != pkSize {
+			return nil, ErrInvalidKey
+		}
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(dataKey)
+	header := []byte{Version}
+	header = binary.BigEndian.AppendUint16(header, uint16(len(recipients)))
+	for _, r := range recipients {
+		ct, ss, err := r.KEM.params().Encapsulate(r.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		keyID := KeyID(r.PublicKey)
+		wrapped, err := wrap(r.KEM, keyID[:], ct, ss, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		if r.HideKeyID {
+			header = append(header, byte(r.KEM), 0)
+		} else {
+			header = append(header, byte(r.KEM), flagKeyID)
+			header = append(header, keyID[:]...)
+		}
+		header = append(header, ct...)
+		header = append(header, wrapped...)
+	}
+	aead, err := chacha20poly1305.New(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	return aead.Seal(header, nonce, plaintext, payloadAD(header, additionalData)), nil
+}
+
+// Decrypt decrypts an envelope with the recipient's key pair for the
+// given KEM, authenticating additionalData. It tries the stanzas that
+// name the key's ID and the hidden stanzas of its KEM, and returns
+// ErrNoMatch if none of them opens.
+func Decrypt(kem KEM, key KeyPair, envelope, additionalData []byte) ([]byte, error) {
+	pkSize, skSize, _ := kem.params().Sizes()
+	if pkSize == 0 || len(key.Private) != skSize || len(key.Public) != pkSize {
+		return nil, ErrInvalidKey
+	}
+	stanzas, payload, err := parse(envelope)
+	if err != nil {
+		return nil, err
+	}
+	keyID := kem.params().KeyHash(key.Private)
+	for _, s := range stanzas {
+		if s.kem != kem || (s.keyID != nil && subtle.ConstantTimeCompare(s.keyID, keyID) != 1) {
+			continue
+		}
+		ss, err := kem.params().Decapsulate(s.ct, key.Private)
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err := unwrap(kem, keyID, s.ct, ss, s.wrapped)
+		if err != nil {
+			continue
+		}
+		defer mlkem.ZeroBytes(dataKey)
+		aead, err := chacha20poly1305.New(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		header := envelope[:len(envelope)-len(payload)]
+		nonce := make([]byte, chacha20poly1305.NonceSize)
+		plaintext, err := aead.Open(nil, nonce, payload, payloadAD(header, additionalData))
+		if err != nil {
+			return nil, ErrAuthenticationFailed
+		}
+		return plaintext, nil
+	}
+	return nil, ErrNoMatch
+}
+
+// KeyIDs returns the key IDs named by the stanzas of an envelope, or
+// nil for hidden stanzas, in stanza order.
+func KeyIDs(envelope []byte) ([][]byte, error) {
+	stanzas, _, err := parse(envelope)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([][]byte, len(stanzas))
+	for i, s := range stanzas {
+		ids[i] = s.keyID
+	}
+	return ids, nil
+}
+
+// stanza is a parsed recipient stanza, whose fields alias the envelope.
+type stanza struct {
+	kem     KEM
+	keyID   []byte
+	ct      []byte
+	wrapped []byte
+}
+
+// parse splits an envelope into its stanzas and its payload.
+func parse(envelope []byte) ([]stanza, []byte, error) {
+	if len(envelope) < 3 || envelope[0] != Version {
+		return nil, nil, ErrInvalidEnvelope
+	}
+	n := int(binary.BigEndian.Uint16(envelope[1:3]))
+	if n == 0 {
+		return nil, nil, ErrInvalidEnvelope
+	}
+	b := envelope[3:]
+	stanzas := make([]stanza, n)
+	for i := range stanzas {
+		if len(b) < 2 {
+			return nil, nil, ErrInvalidEnvelope
+		}
+		s := &stanzas[i]
+		s.kem = KEM(b[0])
+		_, _, ctSize := s.kem.params().Sizes()
+		if ctSize == 0 {
+			return nil, nil, ErrInvalidEnvelope
+		}
+		flags := b[1]
+		b = b[2:]
+		switch flags {
+		case 0:
+		case flagKeyID:
+			if len(b) < KeyIDSize {
+				return nil, nil, ErrInvalidEnvelope
+			}
+			s.keyID, b = b[:KeyIDSize], b[KeyIDSize:]
+		default:
+			return nil, nil, ErrInvalidEnvelope
+		}
+		if len(b) < ctSize+wrappedKeySize {
+			return nil, nil, ErrInvalidEnvelope
+		}
+		s.ct, b = b[:ctSize], b[ctSize:]
+		s.wrapped, b = b[:wrappedKeySize], b[wrappedKeySize:]
+	}
+	if len(b) < chacha20poly1305.Overhead {
+		return nil, nil, ErrInvalidEnvelope
+	}
+	return stanzas, b, nil
+}
+
+// wrappingKey derives the key that wraps the data key in a stanza.
+func wrappingKey(kem KEM, keyID, ct, ss []byte) ([]byte, error) {
+	info := append([]byte("kyberk2so envelope v1"), byte(kem))
+	info = append(info, keyID...)
+	info = append(info, ct...)
+	return hkdf.Key(sha256.New, ss, nil, string(info), 32)
+}
+
+func wrap(kem KEM, keyID, ct, ss, dataKey []byte) ([]byte, error) {
+	defer mlkem.ZeroBytes(ss)
+	kek, err := wrappingKey(kem, keyID, ct, ss)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(kek)
+	return keywrap.Wrap(kek, dataKey)
+}
+
+func unwrap(kem KEM, keyID, ct, ss, wrapped []byte) ([]byte, error) {
+	defer mlkem.ZeroBytes(ss)
+	kek, err := wrappingKey(kem, keyID, ct, ss)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(kek)
+	return keywrap.Unwrap(kek, wrapped)
+}
+
+// payloadAD returns the associated data of the payload: the header,
+// which is self-delimiting, followed by the caller's additional data.
+func payloadAD(header, additionalData []byte) []byte {
+	ad := make([]byte, 0, len(header)+len(additionalData))
+	ad = append(ad, header...)
+	return append(ad, additionalData...)
+}
@@ -0,0 +1,152 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package envelope
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+func generate(t *testing.T, kem KEM) KeyPair {
+	t.Helper()
+	key, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestMixed(t *testing.T) {
+	kems := []KEM{MLKEM768, MLKEM1024, MLKEM768, MLKEM1024}
+	keys := make([]KeyPair, len(kems))
+	recipients := make([]Recipient, len(kems))
+	for i, kem := range kems {
+		keys[i] = generate(t, kem)
+		recipients[i] = Recipient{KEM: kem, PublicKey: keys[i].Public, HideKeyID: i%2 == 1}
+	}
+	plaintext := bytes.Repeat([]byte("payload "), 1000)
+	envelope, err := Encrypt(plaintext, []byte("review"), recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, kem := range kems {
+		got, err := Decrypt(kem, keys[i], envelope, []byte("review"))
+		if err != nil {
+			t.Fatalf("recipient %d: %v", i, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("recipient %d: plaintext differs", i)
+		}
+	}
+	if _, err := Decrypt(kems[0], keys[0], envelope, []byte("other")); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("wrong additional data: got %v, want ErrAuthenticationFailed", err)
+	}
+	for _, kem := range []KEM{MLKEM768, MLKEM1024} {
+		if _, err := Decrypt(kem, generate(t, kem), envelope, []byte("review")); !errors.Is(err, ErrNoMatch) {
+			t.Fatalf("outsider: got %v, want ErrNoMatch", err)
+		}
+	}
+}
+
+func TestKeyIDs(t *testing.T) {
+	a, b := generate(t, MLKEM768), generate(t, MLKEM1024)
+	envelope, err := Encrypt([]byte("payload"), nil,
+		Recipient{KEM: MLKEM768, PublicKey: a.Public},
+		Recipient{KEM: MLKEM1024, PublicKey: b.Public, HideKeyID: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := KeyIDs(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := KeyID(a.Public)
+	if len(ids) != 2 || !bytes.Equal(ids[0], id[:]) || ids[1] != nil {
+		t.Fatal("unexpected key IDs")
+	}
+	// The key ID is the hash of the encapsulation key stored in the
+	// decapsulation key.
+	if !bytes.Equal(a.Private[kyberk2so.Kyber768SKBytes-64:kyberk2so.Kyber768SKBytes-32], id[:]) {
+		t.Fatal("key ID differs from the stored hash")
+	}
+	// The hidden stanza does not contain the key ID anywhere.
+	hidden := KeyID(b.Public)
+	if bytes.Contains(envelope, hidden[:]) {
+		t.Fatal("envelope contains the hidden key ID")
+	}
+}
+
+func TestTampering(t *testing.T) {
+	key := generate(t, MLKEM768)
+	other := generate(t, MLKEM768)
+	envelope, err := Encrypt([]byte("payload"), nil,
+		Recipient{KEM: MLKEM768, PublicKey: key.Public},
+		Recipient{KEM: MLKEM768, PublicKey: other.Public})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzaSize := 2 + KeyIDSize + kyberk2so.Kyber768CTBytes + wrappedKeySize
+	for _, tc := range []struct {
+		region string
+		offset int
+		want   error
+	}{
+		{"key ID", 3 + 2, ErrNoMatch},
+		{"KEM ciphertext", 3 + 2 + KeyIDSize, ErrNoMatch},
+		{"wrapped key", 3 + stanzaSize - 1, ErrNoMatch},
+		{"other stanza", 3 + stanzaSize + 2 + KeyIDSize, ErrAuthenticationFailed},
+		{"payload", 3 + 2*stanzaSize, ErrAuthenticationFailed},
+		{"tag", len(envelope) - 1, ErrAuthenticationFailed},
+	} {
+		tampered := bytes.Clone(envelope)
+		tampered[tc.offset] ^= 1
+		if _, err := Decrypt(MLKEM768, key, tampered, nil); !errors.Is(err, tc.want) {
+			t.Fatalf("tampered %s: got %v, want %v", tc.region, err, tc.want)
+		}
+	}
+	for _, n := range []int{0, 2, 3 + stanzaSize, 3 + 2*stanzaSize + 15} {
+		if _, err := Decrypt(MLKEM768, key, envelope[:n], nil); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Fatalf("truncated to %d bytes: got %v, want ErrInvalidEnvelope", n, err)
+		}
+	}
+	for _, tc := range []struct {
+		field  string
+		offset int
+		value  byte
+	}{
+		{"version", 0, Version + 1},
+		{"stanza count", 1, 0xff},
+		{"KEM", 3, 0x01},
+		{"flags", 4, 0x02},
+	} {
+		tampered := bytes.Clone(envelope)
+		tampered[tc.offset] = tc.value
+		if _, err := Decrypt(MLKEM768, key, tampered, nil); !errors.Is(err, ErrInvalidEnvelope) {
+			t.Fatalf("%s: got %v, want ErrInvalidEnvelope", tc.field, err)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := Encrypt(nil, nil); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("got %v, want ErrNoRecipients", err)
+	}
+	key := generate(t, MLKEM768)
+	if _, err := Encrypt(nil, nil, Recipient{KEM: MLKEM1024, PublicKey: key.Public}); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("got %v, want ErrInvalidKey", err)
+	}
+	if _, err := GenerateKeyPair(0x01); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("got %v, want ErrInvalidKey", err)
+	}
+	envelope, err := Encrypt(nil, nil, Recipient{KEM: MLKEM768, PublicKey: key.Public})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(MLKEM1024, key, envelope, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("got %v, want ErrInvalidKey", err)
+	}
+}
@@ -5,6 +5,9 @@ module github.com/symbolicsoft/kyber-k2so
 
 go 1.26.0
 
-require golang.org/x/crypto v0.48.0
+require (
+	filippo.io/edwards25519 v1.2.0
+	golang.org/x/crypto v0.48.0
+)
 
 require golang.org/x/sys v0.41.0 // indirect
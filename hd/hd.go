@@ -0,0 +1,153 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package hd derives ML-KEM key pairs hierarchically from one master
+// seed, so that any key pair can be re-derived from the seed and its
+// path instead of being stored.
+//
+// A node of the hierarchy is a 32-byte key and a 32-byte chain code.
+// The master node is HMAC-SHA512(key = "kyberk2so hd seed", seed), split
+// into key and chain code. The child of a node for a path element is
+//
+//	HMAC-SHA512(key = chain code, 0x00 || key || element)
+//
+// split the same way, where an index element is 0x01 followed by the
+// big-endian 32-bit index and a label element is 0x02 followed by the
+// label length as one byte and the label. The 64-byte coins d || z for
+// KemKeypairDerand of a parameter set are
+//
+//	HMAC-SHA512(key = chain code, 0x01 || key || "ML-KEM-512")
+//
+// with "ML-KEM-768" or "ML-KEM-1024" for the other sets, so that the key
+// pairs of one node are independent. Derivation is hardened only: unlike
+// elliptic curve keys, ML-KEM keys cannot be derived from a parent
+// public key, and every child needs the parent's secret key and chain
+// code.
+package hd
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+var (
+	// ErrInvalidSeed is returned when a master seed is shorter than
+	// MinSeedSize bytes.
+	ErrInvalidSeed = errors.New("hd: master seed too short")
+
+	// ErrInvalidPath is returned when a path is malformed, has an index
+	// above MaxIndex, or has a label that is empty, too long, not UTF-8
+	// or contains '/'.
+	ErrInvalidPath = errors.New("hd: invalid derivation path")
+
+	// ErrNotHardened is returned when a path has an index without the
+	// hardened marker.
+	ErrNotHardened = errors.New("hd: ML-KEM keys only support hardened derivation")
+)
+
+// MinSeedSize is the smallest size of a master seed.
+const MinSeedSize = 32
+
+// Key is a node of the hierarchy. It is secret: anyone holding it can
+// derive every key pair below it.
+type Key struct {
+	key       [32]byte
+	chainCode [32]byte
+}
+
+// NewMaster returns the master node for a seed of at least MinSeedSize
+// bytes.
+func NewMaster(seed []byte) (*Key, error) {
+	if len(seed) < MinSeedSize {
+		return nil, ErrInvalidSeed
+	}
+	mac := hmac.New(sha512.New, []byte("kyberk2so hd seed"))
+	mac.Write(seed)
+	return split(mac.Sum(nil)), nil
+}
+
+// split returns the node for 64 bytes of HMAC output and zeroizes them.
+func split(sum []byte) *Key {
+	k := &Key{}
+	copy(k.key[:], sum[:32])
+	copy(k.chainCode[:], sum[32:])
+	mlkem.ZeroBytes(sum)
+	return k
+}
+
+// Derive returns the node at path below k.
+func (k *Key) Derive(path Path) (*Key, error) {
+	node := *k
+	defer node.Destroy()
+	for _, e := range path {
+		encoded, err := e.encode()
+		if err != nil {
+			return nil, err
+		}
+		child := split(node.prf(0x00, encoded))
+		node.Destroy()
+		node = *child
+		child.Destroy()
+	}
+	derived := node
+	return &derived, nil
+}
+
+// DerivePath parses path with ParsePath and returns the node at path
+// below k.
+func (k *Key) DerivePath(path string) (*Key, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.Derive(p)
+}
+
+// coins returns the 64 bytes d || z that KemKeypairDerand consumes for
+// the parameter set named "ML-KEM-512", "ML-KEM-768" or "ML-KEM-1024".
+func (k *Key) coins(parameterSet string) [64]byte {
+	sum := k.prf(0x01, []byte(parameterSet))
+	coins := [64]byte(sum)
+	mlkem.ZeroBytes(sum)
+	return coins
+}
+
+// prf returns HMAC-SHA512(chain code, domain || key || data).
+func (k *Key) prf(domain byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, k.chainCode[:])
+	mac.Write([]byte{domain})
+	mac.Write(k.key[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Keypair512 returns the ML-KEM-512 key pair of the node.
+func (k *Key) Keypair512() ([kyberk2so.Kyber512SKBytes]byte, [kyberk2so.Kyber512PKBytes]byte, error) {
+	coins := k.coins("ML-KEM-512")
+	defer mlkem.ZeroBytes(coins[:])
+	return kyberk2so.KemKeypairDerand512(coins)
+}
+
+// Keypair768 returns the ML-KEM-768 key pair of the node.
+func (k *Key) Keypair768() ([kyberk2so.Kyber768SKBytes]byte, [kyberk2so.Kyber768PKBytes]byte, error) {
+	coins := k.coins("ML-KEM-768")
+	defer mlkem.ZeroBytes(coins[:])
+	return kyberk2so.KemKeypairDerand768(coins)
+}
+
+// Keypair1024 returns the ML-KEM-1024 key pair of the node.
+func (k *Key) Keypair1024() ([kyberk2so.Kyber1024SKBytes]byte, [kyberk2so.Kyber1024PKBytes]byte, error) {
+	coins := k.coins("ML-KEM-1024")
+	defer mlkem.ZeroBytes(coins[:])
+	return kyberk2so.KemKeypairDerand1024(coins)
+}
+
+// Destroy zeroizes the node.
+func (k *Key) Destroy() {
+	mlkem.ZeroBytes(k.key[:])
+	mlkem.ZeroBytes(k.chainCode[:])
+}
@@ -0,0 +1,184 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package hd
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"golang.org/x/crypto/sha3"
+)
+
+func katMaster(t *testing.T) *Key {
+	t.Helper()
+	seed := make([]byte, 64)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	m, err := NewMaster(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPathEncodingKAT(t *testing.T) {
+	for _, tc := range []struct {
+		element Element
+		want    string
+	}{
+		{Index(0), "0100000000"},
+		{Index(7), "0100000007"},
+		{Index(MaxIndex), "017fffffff"},
+		{Label("device-1234"), "020b6465766963652d31323334"},
+		{Label("é"), "0202c3a9"},
+	} {
+		encoded, err := tc.element.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(encoded); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.element, got, tc.want)
+		}
+	}
+}
+
+func TestDerivationKAT(t *testing.T) {
+	m := katMaster(t)
+	if hex.EncodeToString(m.key[:]) != "9b29c8991bbf947d4ce6e8a3bc995f6973bdcd705c6433b098566a5469f7bb53" ||
+		hex.EncodeToString(m.chainCode[:]) != "5bedbc5cfc1acb9e47a995fc4aaf51fc39edc52aba78ffd7d07a44e10ebab7cf" {
+		t.Fatal("master node differs")
+	}
+	k, err := m.DerivePath("m/7'/42'/device-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		parameterSet string
+		want         string
+	}{
+		{"ML-KEM-512", "4354e39954b206eb5e8e0a7310b3506ab6228e69befff366cbf5b6ddd1efa54f" +
+			"1ac316423ebf84009bbdfe7334f8b557318891f677b08ab03ca96fa70e281e37"},
+		{"ML-KEM-768", "6a59e1e4100b64110605d09272d38952791912c6fa6eb1af8ac7155db200fd91" +
+			"616101bc763d7fd5ca241c1c7ebddf91e65fe09d549f25a80068438dbfad8963"},
+		{"ML-KEM-1024", "37c7fe113337a0a68abbacd7696473c2934b0bd5fb51197f3bb56783756b0bd7" +
+			"d84e527f1b03018eab8f923738919ca2e3b11344bd6e0e10c74ba087de2ff5ba"},
+	} {
+		coins := k.coins(tc.parameterSet)
+		if got := hex.EncodeToString(coins[:]); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.parameterSet, got, tc.want)
+		}
+	}
+	_, publicKey, err := k.Keypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := sha3.Sum256(publicKey[:])
+	if got := hex.EncodeToString(keyID[:]); got != "46e5778365d49b7dd021b35739fb7a2488f11048415c7e0a8502ca16597c689e" {
+		t.Errorf("H(ek): got %s", got)
+	}
+}
+
+func TestDerivation(t *testing.T) {
+	m := katMaster(t)
+	a, err := m.DerivePath("m/7'/42'/device-1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Paths compose, and the h marker is equivalent to '.
+	parent, err := m.DerivePath("m/7h/42h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parent.Derive(Path{Label("device-1234")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *a != *b {
+		t.Fatal("derivation does not compose")
+	}
+	skA, pkA, err := a.Keypair1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	skB, _, _ := b.Keypair1024()
+	if skA != skB {
+		t.Fatal("key pair is not deterministic")
+	}
+	ct, ssA, _ := kyberk2so.KemEncrypt1024(pkA)
+	ssB, err := kyberk2so.KemDecrypt1024(ct, skA)
+	if err != nil || ssA != ssB {
+		t.Fatal("derived key pair does not work")
+	}
+	// Siblings, labels and indices, and parameter sets are independent.
+	seen := make(map[[32]byte]string)
+	for _, path := range []string{"m", "m/0'", "m/1'", "m/0'/0'", "m/1'/0'", "m/device-1", "m/device-2", "m/1'/device-1"} {
+		k, err := m.DerivePath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, pk512, _ := k.Keypair512()
+		_, pk768, _ := k.Keypair768()
+		for _, h := range [][32]byte{sha3.Sum256(pk512[:]), sha3.Sum256(pk768[:])} {
+			if other, ok := seen[h]; ok {
+				t.Fatalf("%s repeats a key of %s", path, other)
+			}
+			seen[h] = path
+		}
+	}
+	// Out-of-range indices and invalid labels are rejected when deriving.
+	for _, path := range []Path{{Index(MaxIndex + 1)}, {Index(0), Index(^uint32(0))}, {Label("a/b")}} {
+		if _, err := m.Derive(path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%s: got %v, want ErrInvalidPath", path, err)
+		}
+	}
+	// Destroy zeroizes the node but not its parent.
+	a.Destroy()
+	if *a != (Key{}) || *m == (Key{}) {
+		t.Fatal("Destroy did not zeroize only the node")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, s := range []string{"m", "m/0'", "m/7'/42'/device-1234", "m/2147483647'/x'/é"} {
+		p, err := ParsePath(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if p.String() != s {
+			t.Fatalf("%s: round trip gave %s", s, p)
+		}
+	}
+	for _, tc := range []struct {
+		path string
+		want error
+	}{
+		{"m/0", ErrNotHardened},
+		{"m/7'/42", ErrNotHardened},
+		{"", ErrInvalidPath},
+		{"0'", ErrInvalidPath},
+		{"m/", ErrInvalidPath},
+		{"m//1'", ErrInvalidPath},
+		{"m/1''", ErrInvalidPath},
+		{"m/2147483648'", ErrInvalidPath},
+		{"m/" + strings.Repeat("a", MaxLabelLength+1), ErrInvalidPath},
+		{"m/\xff", ErrInvalidPath},
+	} {
+		if _, err := ParsePath(tc.path); !errors.Is(err, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.path, err, tc.want)
+		}
+	}
+	m := katMaster(t)
+	for _, label := range []string{"", "a/b", "12", "12'"} {
+		if _, err := m.Derive(Path{Label(label)}); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("label %q: got %v, want ErrInvalidPath", label, err)
+		}
+	}
+	if _, err := NewMaster(make([]byte, MinSeedSize-1)); !errors.Is(err, ErrInvalidSeed) {
+		t.Fatalf("got %v, want ErrInvalidSeed", err)
+	}
+}
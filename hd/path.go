@@ -0,0 +1,124 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package hd
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxIndex is the largest index of a path element.
+const MaxIndex = 1<<31 - 1
+
+// MaxLabelLength is the largest length in bytes of a label.
+const MaxLabelLength = 255
+
+// Element tags of the path encoding.
+const (
+	tagIndex = 0x01
+	tagLabel = 0x02
+)
+
+// Element is one step of a derivation path: a hardened index or a
+// label. All derivation is hardened, since ML-KEM keys admit no public
+// derivation.
+type Element struct {
+	index   uint32
+	label   string
+	isLabel bool
+}
+
+// Index returns the path element for the hardened index i, written i'
+// in paths. Indices must not exceed MaxIndex, and are checked when
+// deriving.
+func Index(i uint32) Element {
+	return Element{index: i}
+}
+
+// Label returns the path element for a label, such as a device serial
+// number. Labels must be 1 to MaxLabelLength bytes of UTF-8 without
+// '/', and are checked when deriving.
+func Label(s string) Element {
+	return Element{label: s, isLabel: true}
+}
+
+// encode returns the encoding of the element: 0x01 followed by the
+// big-endian 32-bit index, or 0x02 followed by the label length as one
+// byte and the label.
+func (e Element) encode() ([]byte, error) {
+	if !e.isLabel {
+		if e.index > MaxIndex {
+			return nil, ErrInvalidPath
+		}
+		return binary.BigEndian.AppendUint32([]byte{tagIndex}, e.index), nil
+	}
+	if !validLabel(e.label) {
+		return nil, ErrInvalidPath
+	}
+	return append([]byte{tagLabel, byte(len(e.label))}, e.label...), nil
+}
+
+// String returns the element as written in paths.
+func (e Element) String() string {
+	if e.isLabel {
+		return e.label
+	}
+	return strconv.FormatUint(uint64(e.index), 10) + "'"
+}
+
+// Path is a derivation path from a master key.
+type Path []Element
+
+// ParsePath parses a path such as m/7'/42'/device-1234. Indices must be
+// hardened, marked with ' or h; an element of digits alone is rejected
+// with ErrNotHardened. Any other element is a label.
+func ParsePath(s string) (Path, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	path := make(Path, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		digits := strings.TrimRight(part, "'h")
+		if digits != "" && strings.Trim(digits, "0123456789") == "" {
+			if len(part)-len(digits) != 1 {
+				if digits == part {
+					return nil, ErrNotHardened
+				}
+				return nil, ErrInvalidPath
+			}
+			i, err := strconv.ParseUint(digits, 10, 32)
+			if err != nil || i > MaxIndex {
+				return nil, ErrInvalidPath
+			}
+			path = append(path, Index(uint32(i)))
+			continue
+		}
+		if !validLabel(part) {
+			return nil, ErrInvalidPath
+		}
+		path = append(path, Label(part))
+	}
+	return path, nil
+}
+
+// String returns the path in the syntax of ParsePath.
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, e := range p {
+		b.WriteString("/")
+		b.WriteString(e.String())
+	}
+	return b.String()
+}
+
+func validLabel(s string) bool {
+	if digits := strings.TrimRight(s, "'h"); digits != "" && strings.Trim(digits, "0123456789") == "" {
+		return false
+	}
+	return s != "" && len(s) <= MaxLabelLength && !strings.Contains(s, "/") && utf8.ValidString(s)
+}
@@ -0,0 +1,154 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// The hedged functions below do not use system randomness directly as
+// ML-KEM coins. Instead they derive the seed d || z, or the message m,
+// as SHAKE256 over a domain separation string, 32 bytes of fresh system
+// randomness, a long-term secret held by the caller and an optional
+// per-call context such as a counter; encapsulation also absorbs the
+// public key. Every variable-length input is prefixed with its 64-bit
+// big-endian length. The seed or message is then passed to
+// KemKeypairDerand or KemEncryptDerand, so keys and ciphertexts remain
+// standard ML-KEM.
+//
+// As long as either the system randomness or the long-term secret is
+// unpredictable, the outputs are as secure as those of KemKeypair and
+// KemEncrypt. With a repeating or constant random number generator,
+// outputs still differ whenever the context, the long-term secret or,
+// for encapsulation, the public key differs, so a device with a weak
+// generator should pass a counter that never repeats as the context.
+
+const (
+	hedgedKeypairDomain = "kyberk2so hedged keygen v1"
+	hedgedEncryptDomain = "kyberk2so hedged encaps v1"
+)
+
+// hedgedCoins fills out with SHAKE256 over domain, fresh system
+// randomness and the length-prefixed inputs.
+func hedgedCoins(out []byte, domain string, inputs ...[]byte) error {
+	var fresh [paramsSymBytes]byte
+	_, err := rand.Read(fresh[:])
+	if err != nil {
+		return err
+	}
+	h := sha3.NewShake256()
+	_, _ = h.Write([]byte(domain))
+	_, _ = h.Write(fresh[:])
+	var length [8]byte
+	for _, in := range inputs {
+		binary.BigEndian.PutUint64(length[:], uint64(len(in)))
+		_, _ = h.Write(length[:])
+		_, _ = h.Write(in)
+	}
+	_, _ = h.Read(out)
+	byteopsZeroBytes(fresh[:])
+	return nil
+}
+
+// KemKeypairHedged512 returns an ML-KEM-512 private key and a corresponding
+// ML-KEM-512 public key, generated from a seed that is hedged with the
+// long-term secret and the optional context.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemKeypairHedged512(secret, context []byte) ([Kyber512SKBytes]byte, [Kyber512PKBytes]byte, error) {
+	var coins [2 * paramsSymBytes]byte
+	if err := hedgedCoins(coins[:], hedgedKeypairDomain, secret, context); err != nil {
+		return [Kyber512SKBytes]byte{}, [Kyber512PKBytes]byte{}, err
+	}
+	privateKey, publicKey, err := KemKeypairDerand512(coins)
+	byteopsZeroBytes(coins[:])
+	return privateKey, publicKey, err
+}
+
+// KemEncryptHedged512 takes a public key (from KemKeypair512), a long-term
+// secret and an optional context as input and returns a ciphertext and a
+// 32-byte shared secret, encapsulating a message that is hedged with the
+// long-term secret, the context and the public key.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptHedged512(publicKey [Kyber512PKBytes]byte, secret, context []byte) (
+	[Kyber512CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var m [paramsSymBytes]byte
+	if err := hedgedCoins(m[:], hedgedEncryptDomain, secret, context, publicKey[:]); err != nil {
+		return [Kyber512CTBytes]byte{}, [KyberSSBytes]byte{}, err
+	}
+	ciphertext, sharedSecret, err := KemEncryptDerand512(publicKey, m)
+	byteopsZeroBytes(m[:])
+	return ciphertext, sharedSecret, err
+}
+
+// KemKeypairHedged768 returns an ML-KEM-768 private key and a corresponding
+// ML-KEM-768 public key, generated from a seed that is hedged with the
+// long-term secret and the optional context.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemKeypairHedged768(secret, context []byte) ([Kyber768SKBytes]byte, [Kyber768PKBytes]byte, error) {
+	var coins [2 * paramsSymBytes]byte
+	if err := hedgedCoins(coins[:], hedgedKeypairDomain, secret, context); err != nil {
+		return [Kyber768SKBytes]byte{}, [Kyber768PKBytes]byte{}, err
+	}
+	privateKey, publicKey, err := KemKeypairDerand768(coins)
+	byteopsZeroBytes(coins[:])
+	return privateKey, publicKey, err
+}
+
+// KemEncryptHedged768 takes a public key (from KemKeypair768), a long-term
+// secret and an optional context as input and returns a ciphertext and a
+// 32-byte shared secret, encapsulating a message that is hedged with the
+// long-term secret, the context and the public key.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptHedged768(publicKey [Kyber768PKBytes]byte, secret, context []byte) (
+	[Kyber768CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var m [paramsSymBytes]byte
+	if err := hedgedCoins(m[:], hedgedEncryptDomain, secret, context, publicKey[:]); err != nil {
+		return [Kyber768CTBytes]byte{}, [KyberSSBytes]byte{}, err
+	}
+	ciphertext, sharedSecret, err := KemEncryptDerand768(publicKey, m)
+	byteopsZeroBytes(m[:])
+	return ciphertext, sharedSecret, err
+}
+
+// KemKeypairHedged1024 returns an ML-KEM-1024 private key and a corresponding
+// ML-KEM-1024 public key, generated from a seed that is hedged with the
+// long-term secret and the optional context.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemKeypairHedged1024(secret, context []byte) ([Kyber1024SKBytes]byte, [Kyber1024PKBytes]byte, error) {
+	var coins [2 * paramsSymBytes]byte
+	if err := hedgedCoins(coins[:], hedgedKeypairDomain, secret, context); err != nil {
+		return [Kyber1024SKBytes]byte{}, [Kyber1024PKBytes]byte{}, err
+	}
+	privateKey, publicKey, err := KemKeypairDerand1024(coins)
+	byteopsZeroBytes(coins[:])
+	return privateKey, publicKey, err
+}
+
+// KemEncryptHedged1024 takes a public key (from KemKeypair1024), a long-term
+// secret and an optional context as input and returns a ciphertext and a
+// 32-byte shared secret, encapsulating a message that is hedged with the
+// long-term secret, the context and the public key.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemEncryptHedged1024(publicKey [Kyber1024PKBytes]byte, secret, context []byte) (
+	[Kyber1024CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var m [paramsSymBytes]byte
+	if err := hedgedCoins(m[:], hedgedEncryptDomain, secret, context, publicKey[:]); err != nil {
+		return [Kyber1024CTBytes]byte{}, [KyberSSBytes]byte{}, err
+	}
+	ciphertext, sharedSecret, err := KemEncryptDerand1024(publicKey, m)
+	byteopsZeroBytes(m[:])
+	return ciphertext, sharedSecret, err
+}
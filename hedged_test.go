@@ -0,0 +1,136 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// constantReader is a broken random number generator that always
+// returns the same bytes.
+type constantReader struct{}
+
+func (constantReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0x42
+	}
+	return len(b), nil
+}
+
+// useConstantRandom replaces the system random number generator with
+// constantReader for the duration of the test.
+func useConstantRandom(t *testing.T) {
+	t.Helper()
+	reader := rand.Reader
+	rand.Reader = constantReader{}
+	t.Cleanup(func() { rand.Reader = reader })
+	var a, b [32]byte
+	_, _ = rand.Read(a[:])
+	_, _ = rand.Read(b[:])
+	if a != b || a[0] != 0x42 {
+		t.Skip("system randomness cannot be replaced")
+	}
+}
+
+func counter(i uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, i)
+}
+
+func TestHedged768(t *testing.T) {
+	secret := []byte("long-term device secret")
+	privateKey, publicKey, err := KemKeypairHedged768(secret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, ssA, err := KemEncryptHedged768(publicKey, secret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemDecrypt768(ciphertext, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	var invalid [Kyber768PKBytes]byte
+	for i := range invalid {
+		invalid[i] = 0xff
+	}
+	if _, _, err := KemEncryptHedged768(invalid, secret, nil); !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidEncapsulationKey", err)
+	}
+}
+
+func TestHedgedConstantRandom(t *testing.T) {
+	useConstantRandom(t)
+	secret := []byte("long-term device secret")
+
+	// The plain functions repeat themselves under a constant generator.
+	_, publicKeyA, _ := KemKeypair512()
+	_, publicKeyB, _ := KemKeypair512()
+	if publicKeyA != publicKeyB {
+		t.Fatal("constant generator was not used")
+	}
+
+	// A fresh context per call keeps keys distinct.
+	keys := make(map[[Kyber1024PKBytes]byte]bool)
+	for i := uint64(0); i < 16; i++ {
+		_, publicKey, err := KemKeypairHedged1024(secret, counter(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys[publicKey] {
+			t.Fatalf("key %d repeats", i)
+		}
+		keys[publicKey] = true
+	}
+	// So does a different long-term secret.
+	_, publicKeyA, _ = KemKeypairHedged512([]byte("device A"), nil)
+	_, publicKeyB, _ = KemKeypairHedged512([]byte("device B"), nil)
+	if publicKeyA == publicKeyB {
+		t.Fatal("keys of different devices repeat")
+	}
+
+	// Encapsulations differ per context and per public key.
+	privateKey, publicKey, _ := KemKeypairHedged768(secret, counter(100))
+	_, otherPublicKey, _ := KemKeypairHedged768(secret, counter(101))
+	secrets := make(map[[KyberSSBytes]byte]bool)
+	for i := uint64(0); i < 16; i++ {
+		ciphertext, ss, err := KemEncryptHedged768(publicKey, secret, counter(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secrets[ss] {
+			t.Fatalf("shared secret %d repeats", i)
+		}
+		secrets[ss] = true
+		if ssB, _ := KemDecrypt768(ciphertext, privateKey); ssB != ss {
+			t.Fatal("shared secrets differ")
+		}
+		_, ss, _ = KemEncryptHedged768(otherPublicKey, secret, counter(i))
+		if secrets[ss] {
+			t.Fatalf("shared secret %d repeats across public keys", i)
+		}
+		secrets[ss] = true
+	}
+
+	// Length prefixes keep the secret and the context apart.
+	_, publicKeyA, _ = KemKeypairHedged512([]byte("ab"), []byte("c"))
+	_, publicKeyB, _ = KemKeypairHedged512([]byte("a"), []byte("bc"))
+	if publicKeyA == publicKeyB {
+		t.Fatal("secret and context are not separated")
+	}
+
+	// With everything equal the output repeats: the hedge is
+	// deterministic in its inputs.
+	_, publicKeyA, _ = KemKeypairHedged512(secret, counter(7))
+	_, publicKeyB, _ = KemKeypairHedged512(secret, counter(7))
+	if publicKeyA != publicKeyB {
+		t.Fatal("hedged key generation is not a function of its inputs")
+	}
+}
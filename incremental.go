@@ -0,0 +1,147 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Kyber768EKHeaderBytes is a constant representing the byte length of the header
+// of a split ML-KEM-768 public key: the public seed ρ followed by H(ek).
+const Kyber768EKHeaderBytes int = 2 * paramsSymBytes
+
+// Kyber768EKVectorBytes is a constant representing the byte length of the encoded
+// vector t̂ of a split ML-KEM-768 public key.
+const Kyber768EKVectorBytes int = paramsPolyvecBytesK768
+
+// Kyber768CT1Bytes is a constant representing the byte length of the first part
+// of an incremental ML-KEM-768 ciphertext, the compressed vector u.
+const Kyber768CT1Bytes int = paramsPolyvecCompressedBytesK768
+
+// Kyber768CT2Bytes is a constant representing the byte length of the second part
+// of an incremental ML-KEM-768 ciphertext, the compressed polynomial v.
+const Kyber768CT2Bytes int = paramsPolyCompressedBytesK768
+
+// KemSplitPublicKey768 splits an ML-KEM-768 public key (from KemKeypair768)
+// into a short header, holding ρ and H(ek), and the large encoded vector t̂.
+func KemSplitPublicKey768(publicKey [Kyber768PKBytes]byte) (
+	[Kyber768EKHeaderBytes]byte, [Kyber768EKVectorBytes]byte,
+) {
+	var header [Kyber768EKHeaderBytes]byte
+	var vector [Kyber768EKVectorBytes]byte
+	copy(header[:paramsSymBytes], publicKey[Kyber768EKVectorBytes:])
+	pkh := sha3.Sum256(publicKey[:])
+	copy(header[paramsSymBytes:], pkh[:])
+	copy(vector[:], publicKey[:Kyber768EKVectorBytes])
+	return header, vector
+}
+
+// KemJoinPublicKey768 reassembles an ML-KEM-768 public key from the parts
+// returned by KemSplitPublicKey768, checking that they belong together.
+func KemJoinPublicKey768(header [Kyber768EKHeaderBytes]byte, vector [Kyber768EKVectorBytes]byte) (
+	[Kyber768PKBytes]byte, error,
+) {
+	var publicKey [Kyber768PKBytes]byte
+	copy(publicKey[:], vector[:])
+	copy(publicKey[Kyber768EKVectorBytes:], header[:paramsSymBytes])
+	pkh := sha3.Sum256(publicKey[:])
+	if subtle.ConstantTimeCompare(pkh[:], header[paramsSymBytes:]) != 1 {
+		return [Kyber768PKBytes]byte{}, ErrInconsistentEncapsulationKey
+	}
+	return publicKey, nil
+}
+
+// KemEncapsulation768 holds the state of an incremental ML-KEM-768
+// encapsulation between its two phases.
+type KemEncapsulation768 struct {
+	header   [Kyber768EKHeaderBytes]byte
+	m        [paramsSymBytes]byte
+	sp       polyvec
+	epp      poly
+	finished bool
+}
+
+// KemEncryptIncremental768 runs the first phase of an ML-KEM-768
+// encapsulation using only the header of a split public key. It returns
+// the encapsulation state, the first ciphertext part and the 32-byte
+// shared secret. The shared secret must not be used until Finish has
+// accepted the vector t̂, since only then is the public key validated.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemEncryptIncremental768(header [Kyber768EKHeaderBytes]byte) (
+	*KemEncapsulation768, [Kyber768CT1Bytes]byte, [KyberSSBytes]byte, error,
+) {
+	var m [paramsSymBytes]byte
+	_, err := rand.Read(m[:])
+	if err != nil {
+		return nil, [Kyber768CT1Bytes]byte{}, [KyberSSBytes]byte{}, err
+	}
+	defer byteopsZeroBytes(m[:])
+	return KemEncryptIncrementalDerand768(header, m)
+}
+
+// KemEncryptIncrementalDerand768 runs the first phase of an ML-KEM-768
+// encapsulation deterministically using the provided 32-byte message m.
+// Together with Finish, it produces the same ciphertext and shared
+// secret as KemEncryptDerand768 on the full public key.
+func KemEncryptIncrementalDerand768(header [Kyber768EKHeaderBytes]byte, m [32]byte) (
+	*KemEncapsulation768, [Kyber768CT1Bytes]byte, [KyberSSBytes]byte, error,
+) {
+	const paramsK = 3
+	var ct1 [Kyber768CT1Bytes]byte
+	var sharedSecretFixedLength [KyberSSBytes]byte
+	var krInput [64]byte
+	copy(krInput[:32], m[:])
+	copy(krInput[32:], header[paramsSymBytes:])
+	kr := sha3.Sum512(krInput[:])
+	defer byteopsZeroBytes(krInput[:])
+	defer byteopsZeroBytes(kr[:])
+	sp, epp, err := indcpaEncryptU(ct1[:], header[:paramsSymBytes], kr[paramsSymBytes:], paramsK)
+	if err != nil {
+		return nil, ct1, sharedSecretFixedLength, err
+	}
+	e := &KemEncapsulation768{header: header, m: m, sp: sp, epp: epp}
+	copy(sharedSecretFixedLength[:], kr[:paramsSymBytes])
+	byteopsZeroPolyvec(&sp)
+	byteopsZeroPoly(&epp)
+	return e, ct1, sharedSecretFixedLength, nil
+}
+
+// Finish runs the second phase of the encapsulation once the vector t̂
+// is available and returns the second ciphertext part. The vector must
+// pass the modulus check of FIPS 203 §7.2 and, with ρ, hash to the
+// H(ek) of the header; otherwise the state is kept so that the correct
+// vector can still be supplied. The state is erased on success.
+func (e *KemEncapsulation768) Finish(vector [Kyber768EKVectorBytes]byte) ([Kyber768CT2Bytes]byte, error) {
+	const paramsK = 3
+	var ct2 [Kyber768CT2Bytes]byte
+	if e.finished {
+		return ct2, ErrEncapsulationFinished
+	}
+	if !polyvecBytesValid(vector[:], paramsK) {
+		return ct2, ErrInvalidEncapsulationKey
+	}
+	if _, err := KemJoinPublicKey768(e.header, vector); err != nil {
+		return ct2, err
+	}
+	publicKeyPolyvec := polyvecFromBytes(vector[:], paramsK)
+	indcpaEncryptV(ct2[:], e.m[:], &publicKeyPolyvec, &e.sp, &e.epp, paramsK)
+	byteopsZeroBytes(e.m[:])
+	byteopsZeroPolyvec(&e.sp)
+	byteopsZeroPoly(&e.epp)
+	e.finished = true
+	return ct2, nil
+}
+
+// KemCombineCiphertext768 recombines the two parts of an incremental
+// ML-KEM-768 ciphertext into a standard ciphertext for KemDecrypt768.
+func KemCombineCiphertext768(ct1 [Kyber768CT1Bytes]byte, ct2 [Kyber768CT2Bytes]byte) [Kyber768CTBytes]byte {
+	var ciphertext [Kyber768CTBytes]byte
+	copy(ciphertext[:], ct1[:])
+	copy(ciphertext[Kyber768CT1Bytes:], ct2[:])
+	return ciphertext
+}
@@ -0,0 +1,131 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestIncremental768(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		privateKey, publicKey, err := KemKeypair768()
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, vector := KemSplitPublicKey768(publicKey)
+		joined, err := KemJoinPublicKey768(header, vector)
+		if err != nil || joined != publicKey {
+			t.Fatal("split public key does not rejoin")
+		}
+		var m [32]byte
+		if _, err := rand.Read(m[:]); err != nil {
+			t.Fatal(err)
+		}
+		e, ct1, ssIncremental, err := KemEncryptIncrementalDerand768(header, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct2, err := e.Finish(vector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertext := KemCombineCiphertext768(ct1, ct2)
+		ctOneShot, ssOneShot, err := KemEncryptDerand768(publicKey, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ciphertext != ctOneShot || ssIncremental != ssOneShot {
+			t.Fatalf("incremental encapsulation differs from one-shot at iteration %d", i)
+		}
+		ssDecrypted, err := KemDecrypt768(ciphertext, privateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ssDecrypted != ssIncremental {
+			t.Fatalf("decapsulation mismatch at iteration %d", i)
+		}
+	}
+}
+
+func TestIncremental768Random(t *testing.T) {
+	privateKey, publicKey, err := KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, vector := KemSplitPublicKey768(publicKey)
+	e, ct1, ss, err := KemEncryptIncremental768(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct2, err := e.Finish(vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssDecrypted, err := KemDecrypt768(KemCombineCiphertext768(ct1, ct2), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssDecrypted != ss {
+		t.Fatal("decapsulation mismatch")
+	}
+	if _, err := e.Finish(vector); !errors.Is(err, ErrEncapsulationFinished) {
+		t.Fatalf("expected ErrEncapsulationFinished, got %v", err)
+	}
+}
+
+func TestIncremental768Inconsistent(t *testing.T) {
+	_, publicKey, err := KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPublicKey, err := KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, vector := KemSplitPublicKey768(publicKey)
+	_, otherVector := KemSplitPublicKey768(otherPublicKey)
+	e, _, _, err := KemEncryptIncremental768(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Finish(otherVector); !errors.Is(err, ErrInconsistentEncapsulationKey) {
+		t.Fatalf("expected ErrInconsistentEncapsulationKey, got %v", err)
+	}
+	if _, err := KemJoinPublicKey768(header, otherVector); !errors.Is(err, ErrInconsistentEncapsulationKey) {
+		t.Fatalf("expected ErrInconsistentEncapsulationKey, got %v", err)
+	}
+	// A failed Finish leaves the state usable with the right vector.
+	if _, err := e.Finish(vector); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncremental768InvalidVector(t *testing.T) {
+	_, publicKey, err := KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Set the first coefficient to 0xFFF, above the modulus, and commit
+	// to the resulting key in the header so that only the modulus check
+	// can reject it.
+	publicKey[0] = 0xFF
+	publicKey[1] |= 0x0F
+	header, vector := KemSplitPublicKey768(publicKey)
+	pkh := sha3.Sum256(publicKey[:])
+	if !bytes.Equal(header[32:], pkh[:]) {
+		t.Fatal("header does not commit to the public key")
+	}
+	e, _, _, err := KemEncryptIncremental768(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Finish(vector); !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("expected ErrInvalidEncapsulationKey, got %v", err)
+	}
+}
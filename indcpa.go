@@ -36,36 +36,11 @@ func indcpaUnpackPrivateKey(packedPrivateKey []byte, paramsK int) polyvec {
 	return polyvecFromBytes(packedPrivateKey, paramsK)
 }
 
-// indcpaPackCiphertext serializes the ciphertext as a concatenation of
-// the compressed and serialized vector of polynomials `b` and the
-// compressed and serialized polynomial `v`.
-func indcpaPackCiphertext(dst []byte, b *polyvec, v *poly, paramsK int) {
-	var polyvecCompressedSize int
-	switch paramsK {
-	case 2:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK512
-	case 3:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK768
-	default:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK1024
-	}
-	polyvecCompress(dst[:polyvecCompressedSize], b, paramsK)
-	polyCompress(dst[polyvecCompressedSize:], v, paramsK)
-}
-
 // indcpaUnpackCiphertext de-serializes and decompresses the ciphertext
-// from a byte array, and represents the approximate inverse of
-// indcpaPackCiphertext.
+// from a byte array, and represents the approximate inverse of the
+// compression in indcpaEncryptU and indcpaEncryptV.
 func indcpaUnpackCiphertext(c []byte, paramsK int) (polyvec, poly) {
-	var polyvecCompressedSize int
-	switch paramsK {
-	case 2:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK512
-	case 3:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK768
-	default:
-		polyvecCompressedSize = paramsPolyvecCompressedBytesK1024
-	}
+	polyvecCompressedSize := indcpaPolyvecCompressedBytes(paramsK)
 	b := polyvecDecompress(c[:polyvecCompressedSize], paramsK)
 	v := polyDecompress(c[polyvecCompressedSize:], paramsK)
 	return b, v
@@ -160,7 +135,6 @@ func indcpaPrf(dst []byte, prf sha3.ShakeHash, key []byte, nonce byte) {
 // public-key encryption scheme underlying Kyber, using the provided
 // 32-byte seed d as the source of randomness (FIPS 203 Algorithm 13).
 func indcpaKeypairDerand(sk, pk []byte, d *[paramsSymBytes]byte, paramsK int) error {
-	var skpv, pkpv, e polyvec
 	var buf [64]byte
 	var hashInput [33]byte
 	h := sha3.New512()
@@ -175,6 +149,17 @@ func indcpaKeypairDerand(sk, pk []byte, d *[paramsSymBytes]byte, paramsK int) er
 	var noiseSeed [paramsSymBytes]byte
 	copy(publicSeed[:], buf[:paramsSymBytes])
 	copy(noiseSeed[:], buf[paramsSymBytes:])
+	err = indcpaKeypairFromSeeds(sk, pk, &publicSeed, &noiseSeed, paramsK)
+	byteopsZeroBytes(buf[:])
+	byteopsZeroBytes(hashInput[:])
+	byteopsZeroBytes(noiseSeed[:])
+	return err
+}
+
+// indcpaKeypairFromSeeds generates public and private keys from the
+// public seed used to generate the matrix `A` and the noise seed.
+func indcpaKeypairFromSeeds(sk, pk []byte, publicSeed, noiseSeed *[paramsSymBytes]byte, paramsK int) error {
+	var skpv, pkpv, e polyvec
 	a, err := indcpaGenMatrix(publicSeed[:], false, paramsK)
 	if err != nil {
 		return err
@@ -201,9 +186,6 @@ func indcpaKeypairDerand(sk, pk []byte, d *[paramsSymBytes]byte, paramsK int) er
 	skpv = polyvecReduceFull(&skpv, paramsK)
 	indcpaPackPrivateKey(sk, &skpv, paramsK)
 	indcpaPackPublicKey(pk, &pkpv, publicSeed[:], paramsK)
-	byteopsZeroBytes(buf[:])
-	byteopsZeroBytes(hashInput[:])
-	byteopsZeroBytes(noiseSeed[:])
 	byteopsZeroPolyvec(&skpv)
 	byteopsZeroPolyvec(&e)
 	return nil
@@ -267,13 +249,28 @@ func indcpaKeypair(sk, pk []byte, paramsK int) error {
 // indcpaEncrypt is the encryption function of the CPA-secure
 // public-key encryption scheme underlying Kyber.
 func indcpaEncrypt(ct, m, publicKey, coins []byte, paramsK int) error {
-	var sp, ep, bp polyvec
 	publicKeyPolyvec, seed := indcpaUnpackPublicKey(publicKey, paramsK)
-	k := polyFromMsg(m)
-	at, err := indcpaGenMatrix(seed[:paramsSymBytes], true, paramsK)
+	polyvecCompressedSize := indcpaPolyvecCompressedBytes(paramsK)
+	sp, epp, err := indcpaEncryptU(ct[:polyvecCompressedSize], seed, coins, paramsK)
 	if err != nil {
 		return err
 	}
+	indcpaEncryptV(ct[polyvecCompressedSize:], m, &publicKeyPolyvec, &sp, &epp, paramsK)
+	byteopsZeroPolyvec(&sp)
+	byteopsZeroPoly(&epp)
+	return nil
+}
+
+// indcpaEncryptU computes the first part of an encryption, the
+// compressed vector `u`, which depends only on the public seed used to
+// generate the matrix `A` and on the coins. It returns the noise vector
+// `r` in the NTT domain and the noise polynomial `e2` for indcpaEncryptV.
+func indcpaEncryptU(ct, seed, coins []byte, paramsK int) (polyvec, poly, error) {
+	var sp, ep, bp polyvec
+	at, err := indcpaGenMatrix(seed[:paramsSymBytes], true, paramsK)
+	if err != nil {
+		return sp, poly{}, err
+	}
 	prf := sha3.NewShake256()
 	for i := 0; i < paramsK; i++ {
 		sp[i] = polyGetNoise(prf, coins, byte(i), paramsK)
@@ -285,22 +282,41 @@ func indcpaEncrypt(ct, m, publicKey, coins []byte, paramsK int) error {
 	for i := 0; i < paramsK; i++ {
 		bp[i] = polyvecPointWiseAccMontgomery(&at[i], &sp, paramsK)
 	}
-	v := polyvecPointWiseAccMontgomery(&publicKeyPolyvec, &sp, paramsK)
 	bp = polyvecInvNttToMont(&bp, paramsK)
-	v = polyInvNttToMont(&v)
 	bp = polyvecAdd(&bp, &ep, paramsK)
-	v = polyAdd(&v, &epp)
-	v = polyAdd(&v, &k)
 	bp = polyvecReduceFull(&bp, paramsK)
-	v = polyReduceFull(&v)
-	indcpaPackCiphertext(ct, &bp, &v, paramsK)
-	byteopsZeroPolyvec(&sp)
+	polyvecCompress(ct, &bp, paramsK)
 	byteopsZeroPolyvec(&ep)
 	byteopsZeroPolyvec(&bp)
+	return sp, epp, nil
+}
+
+// indcpaEncryptV computes the second part of an encryption, the
+// compressed polynomial `v`, from the vector of polynomials of the
+// public key and the noise returned by indcpaEncryptU.
+func indcpaEncryptV(ct, m []byte, publicKeyPolyvec, sp *polyvec, epp *poly, paramsK int) {
+	k := polyFromMsg(m)
+	v := polyvecPointWiseAccMontgomery(publicKeyPolyvec, sp, paramsK)
+	v = polyInvNttToMont(&v)
+	v = polyAdd(&v, epp)
+	v = polyAdd(&v, &k)
+	v = polyReduceFull(&v)
+	polyCompress(ct, &v, paramsK)
 	byteopsZeroPoly(&k)
-	byteopsZeroPoly(&epp)
 	byteopsZeroPoly(&v)
-	return nil
+}
+
+// indcpaPolyvecCompressedBytes returns the size of the compressed
+// vector `u` of a ciphertext.
+func indcpaPolyvecCompressedBytes(paramsK int) int {
+	switch paramsK {
+	case 2:
+		return paramsPolyvecCompressedBytesK512
+	case 3:
+		return paramsPolyvecCompressedBytesK768
+	default:
+		return paramsPolyvecCompressedBytesK1024
+	}
 }
 
 // indcpaDecrypt is the decryption function of the CPA-secure
@@ -0,0 +1,87 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package keywrap implements the AES key wrap algorithm from RFC 3394.
+package keywrap
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrInvalidLength is returned when the key to wrap, or the wrapped
+	// key, is not a multiple of 8 bytes of at least the minimum length.
+	ErrInvalidLength = errors.New("keywrap: invalid input length")
+
+	// ErrUnwrapFailed is returned when the integrity check of a wrapped
+	// key fails.
+	ErrUnwrapFailed = errors.New("keywrap: integrity check failed")
+)
+
+// defaultIV is the default initial value from RFC 3394 §2.2.3.1.
+var defaultIV = [8]byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// Wrap wraps key under the AES key-encryption key kek.
+func Wrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, ErrInvalidLength
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(key) / 8
+	out := make([]byte, len(key)+8)
+	copy(out[:8], defaultIV[:])
+	copy(out[8:], key)
+	var buf [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf[:8], out[:8])
+			copy(buf[8:], out[8*i:8*i+8])
+			block.Encrypt(buf[:], buf[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buf[:8])^t)
+			copy(out[8*i:8*i+8], buf[8:])
+		}
+	}
+	return out, nil
+}
+
+// Unwrap unwraps wrapped under the AES key-encryption key kek and
+// checks its integrity.
+func Unwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, ErrInvalidLength
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	var a [8]byte
+	copy(a[:], wrapped[:8])
+	out := make([]byte, len(wrapped)-8)
+	copy(out, wrapped[8:])
+	var buf [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(buf[8:], out[8*(i-1):8*i])
+			block.Decrypt(buf[:], buf[:])
+			copy(a[:], buf[:8])
+			copy(out[8*(i-1):8*i], buf[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a[:], defaultIV[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrUnwrapFailed
+	}
+	return out, nil
+}
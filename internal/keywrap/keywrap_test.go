@@ -0,0 +1,57 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package keywrap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 3394 §4.
+var keywrapTestVectors = []struct {
+	kek, key, wrapped string
+}{
+	{
+		kek:     "000102030405060708090a0b0c0d0e0f",
+		key:     "00112233445566778899aabbccddeeff",
+		wrapped: "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5",
+	},
+	{
+		kek:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		key:     "00112233445566778899aabbccddeeff",
+		wrapped: "64e8c3f9ce0f5ba263e9777905818a2a93c8191e7d6e8ae7",
+	},
+	{
+		kek:     "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+		key:     "00112233445566778899aabbccddeeff000102030405060708090a0b0c0d0e0f",
+		wrapped: "28c9f404c4b810f4cbccb35cfb87f8263f5786e2d80ed326cbc7f0e71a99f43bfb988b9b7a02dd21",
+	},
+}
+
+func TestKeywrapVectors(t *testing.T) {
+	for i, v := range keywrapTestVectors {
+		kek, _ := hex.DecodeString(v.kek)
+		key, _ := hex.DecodeString(v.key)
+		expected, _ := hex.DecodeString(v.wrapped)
+		wrapped, err := Wrap(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Errorf("vector %d: wrap mismatch\nExpected: %x\nGot: %x", i, expected, wrapped)
+		}
+		unwrapped, err := Unwrap(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Errorf("vector %d: unwrap mismatch", i)
+		}
+		wrapped[len(wrapped)-1] ^= 1
+		if _, err := Unwrap(kek, wrapped); err != ErrUnwrapFailed {
+			t.Errorf("vector %d: tampered key unwrapped: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package kmac implements the KMAC128 and KMAC256 keyed functions
+// from NIST SP 800-185 on top of cSHAKE.
+package kmac
+
+import "golang.org/x/crypto/sha3"
+
+const (
+	rate128 = 168
+	rate256 = 136
+)
+
+// Sum128 returns KMAC128(key, data, len(dst)*8, custom) in dst.
+func Sum128(dst, key, data, custom []byte) {
+	sum(sha3.NewCShake128([]byte("KMAC"), custom), rate128, dst, key, data)
+}
+
+// Sum256 returns KMAC256(key, data, len(dst)*8, custom) in dst.
+func Sum256(dst, key, data, custom []byte) {
+	sum(sha3.NewCShake256([]byte("KMAC"), custom), rate256, dst, key, data)
+}
+
+// sum absorbs bytepad(encode_string(key), rate) || data || right_encode(L)
+// into the given cSHAKE instance and squeezes len(dst) bytes.
+func sum(h sha3.ShakeHash, rate int, dst, key, data []byte) {
+	padded := leftEncode(uint64(rate))
+	padded = append(padded, leftEncode(uint64(len(key))*8)...)
+	padded = append(padded, key...)
+	for len(padded)%rate != 0 {
+		padded = append(padded, 0)
+	}
+	_, _ = h.Write(padded)
+	_, _ = h.Write(data)
+	_, _ = h.Write(rightEncode(uint64(len(dst)) * 8))
+	_, _ = h.Read(dst)
+	for i := range padded {
+		padded[i] = 0
+	}
+}
+
+// leftEncode implements left_encode(x) from SP 800-185 §2.3.1.
+func leftEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append([]byte{byte(len(b))}, b...)
+}
+
+// rightEncode implements right_encode(x) from SP 800-185 §2.3.1.
+func rightEncode(x uint64) []byte {
+	b := encodeUint(x)
+	return append(b, byte(len(b)))
+}
+
+// encodeUint returns the minimal big-endian encoding of x,
+// using a single zero byte for x = 0.
+func encodeUint(x uint64) []byte {
+	n := 1
+	for v := x >> 8; v > 0; v >>= 8 {
+		n++
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+	return b
+}
@@ -0,0 +1,31 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kmac
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Sample vectors from the NIST SP 800-185 KMAC example values.
+func TestKMACSamples(t *testing.T) {
+	key, _ := hex.DecodeString("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+	data := []byte{0x00, 0x01, 0x02, 0x03}
+	custom := []byte("My Tagged Application")
+	expected128, _ := hex.DecodeString("3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5")
+	expected256, _ := hex.DecodeString(
+		"20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7" +
+			"f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd")
+	out128 := make([]byte, 32)
+	Sum128(out128, key, data, custom)
+	if !bytes.Equal(out128, expected128) {
+		t.Errorf("KMAC128 sample failed\nExpected: %x\nGot: %x", expected128, out128)
+	}
+	out256 := make([]byte, 64)
+	Sum256(out256, key, data, custom)
+	if !bytes.Equal(out256, expected256) {
+		t.Errorf("KMAC256 sample failed\nExpected: %x\nGot: %x", expected256, out256)
+	}
+}
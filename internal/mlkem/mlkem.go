@@ -0,0 +1,224 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package mlkem dispatches the ML-KEM operations of kyberk2so on byte
+// slices by parameter set, for the protocol packages that select the
+// parameter set at run time, and locates the encapsulation key and its
+// hash inside a decapsulation key.
+package mlkem
+
+import (
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+var (
+	// ErrParameterSet is returned for an unknown parameter set.
+	ErrParameterSet = errors.New("mlkem: unknown parameter set")
+
+	// ErrInvalidLength is returned when a key or ciphertext does not
+	// have the size of the parameter set.
+	ErrInvalidLength = errors.New("mlkem: invalid key or ciphertext length")
+)
+
+// KeyHashSize is the size of H(ek), which is also the size of the
+// implicit rejection seed z that follows it in a decapsulation key.
+const KeyHashSize = 32
+
+// ParameterSet is an ML-KEM parameter set. The protocol packages define
+// their own KEM types and convert them to a ParameterSet.
+type ParameterSet int
+
+// Supported parameter sets.
+const (
+	MLKEM512 ParameterSet = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// String returns the name of the parameter set, such as ML-KEM-768.
+func (p ParameterSet) String() string {
+	switch p {
+	case MLKEM512:
+		return "ML-KEM-512"
+	case MLKEM768:
+		return "ML-KEM-768"
+	case MLKEM1024:
+		return "ML-KEM-1024"
+	default:
+		return ""
+	}
+}
+
+// Sizes returns the encapsulation key, decapsulation key and ciphertext
+// sizes, or zeros for an unknown parameter set.
+func (p ParameterSet) Sizes() (int, int, int) {
+	switch p {
+	case MLKEM512:
+		return kyberk2so.Kyber512PKBytes, kyberk2so.Kyber512SKBytes, kyberk2so.Kyber512CTBytes
+	case MLKEM768:
+		return kyberk2so.Kyber768PKBytes, kyberk2so.Kyber768SKBytes, kyberk2so.Kyber768CTBytes
+	case MLKEM1024:
+		return kyberk2so.Kyber1024PKBytes, kyberk2so.Kyber1024SKBytes, kyberk2so.Kyber1024CTBytes
+	default:
+		return 0, 0, 0
+	}
+}
+
+// Keypair returns a new decapsulation key and encapsulation key.
+func (p ParameterSet) Keypair() ([]byte, []byte, error) {
+	switch p {
+	case MLKEM512:
+		sk, pk, err := kyberk2so.KemKeypair512()
+		return sk[:], pk[:], err
+	case MLKEM768:
+		sk, pk, err := kyberk2so.KemKeypair768()
+		return sk[:], pk[:], err
+	case MLKEM1024:
+		sk, pk, err := kyberk2so.KemKeypair1024()
+		return sk[:], pk[:], err
+	default:
+		return nil, nil, ErrParameterSet
+	}
+}
+
+// KeypairDerand returns the decapsulation key and encapsulation key
+// derived from the seed d || z.
+func (p ParameterSet) KeypairDerand(coins [64]byte) ([]byte, []byte, error) {
+	switch p {
+	case MLKEM512:
+		sk, pk, err := kyberk2so.KemKeypairDerand512(coins)
+		return sk[:], pk[:], err
+	case MLKEM768:
+		sk, pk, err := kyberk2so.KemKeypairDerand768(coins)
+		return sk[:], pk[:], err
+	case MLKEM1024:
+		sk, pk, err := kyberk2so.KemKeypairDerand1024(coins)
+		return sk[:], pk[:], err
+	default:
+		return nil, nil, ErrParameterSet
+	}
+}
+
+// Encapsulate returns a ciphertext and shared secret for the
+// encapsulation key pk.
+func (p ParameterSet) Encapsulate(pk []byte) ([]byte, []byte, error) {
+	pkSize, _, _ := p.Sizes()
+	switch {
+	case pkSize == 0:
+		return nil, nil, ErrParameterSet
+	case len(pk) != pkSize:
+		return nil, nil, ErrInvalidLength
+	}
+	switch p {
+	case MLKEM512:
+		ct, ss, err := kyberk2so.KemEncrypt512([kyberk2so.Kyber512PKBytes]byte(pk))
+		return ct[:], ss[:], err
+	case MLKEM768:
+		ct, ss, err := kyberk2so.KemEncrypt768([kyberk2so.Kyber768PKBytes]byte(pk))
+		return ct[:], ss[:], err
+	default:
+		ct, ss, err := kyberk2so.KemEncrypt1024([kyberk2so.Kyber1024PKBytes]byte(pk))
+		return ct[:], ss[:], err
+	}
+}
+
+// Decapsulate returns the shared secret for ct under the decapsulation
+// key sk.
+func (p ParameterSet) Decapsulate(ct, sk []byte) ([]byte, error) {
+	if err := p.checkDecapsulation(ct, [][]byte{sk}); err != nil {
+		return nil, err
+	}
+	switch p {
+	case MLKEM512:
+		ciphertext, privateKey := [kyberk2so.Kyber512CTBytes]byte(ct), [kyberk2so.Kyber512SKBytes]byte(sk)
+		defer ZeroBytes(privateKey[:])
+		ss, err := kyberk2so.KemDecrypt512(ciphertext, privateKey)
+		return ss[:], err
+	case MLKEM768:
+		ciphertext, privateKey := [kyberk2so.Kyber768CTBytes]byte(ct), [kyberk2so.Kyber768SKBytes]byte(sk)
+		defer ZeroBytes(privateKey[:])
+		ss, err := kyberk2so.KemDecrypt768(ciphertext, privateKey)
+		return ss[:], err
+	default:
+		ciphertext, privateKey := [kyberk2so.Kyber1024CTBytes]byte(ct), [kyberk2so.Kyber1024SKBytes]byte(sk)
+		defer ZeroBytes(privateKey[:])
+		ss, err := kyberk2so.KemDecrypt1024(ciphertext, privateKey)
+		return ss[:], err
+	}
+}
+
+// DecapsulateTrial returns the shared secret for ct under the first of
+// the decapsulation keys sks that accepts it, in constant time, or the
+// implicit rejection value of the first key if none does.
+func (p ParameterSet) DecapsulateTrial(ct []byte, sks [][]byte) ([]byte, error) {
+	if err := p.checkDecapsulation(ct, sks); err != nil {
+		return nil, err
+	}
+	switch p {
+	case MLKEM512:
+		keys := make([][kyberk2so.Kyber512SKBytes]byte, len(sks))
+		for i, sk := range sks {
+			keys[i] = [kyberk2so.Kyber512SKBytes]byte(sk)
+		}
+		defer clear(keys)
+		ss, err := kyberk2so.KemDecryptTrial512([kyberk2so.Kyber512CTBytes]byte(ct), keys)
+		return ss[:], err
+	case MLKEM768:
+		keys := make([][kyberk2so.Kyber768SKBytes]byte, len(sks))
+		for i, sk := range sks {
+			keys[i] = [kyberk2so.Kyber768SKBytes]byte(sk)
+		}
+		defer clear(keys)
+		ss, err := kyberk2so.KemDecryptTrial768([kyberk2so.Kyber768CTBytes]byte(ct), keys)
+		return ss[:], err
+	default:
+		keys := make([][kyberk2so.Kyber1024SKBytes]byte, len(sks))
+		for i, sk := range sks {
+			keys[i] = [kyberk2so.Kyber1024SKBytes]byte(sk)
+		}
+		defer clear(keys)
+		ss, err := kyberk2so.KemDecryptTrial1024([kyberk2so.Kyber1024CTBytes]byte(ct), keys)
+		return ss[:], err
+	}
+}
+
+// checkDecapsulation checks the parameter set and the lengths of a
+// ciphertext and one or more decapsulation keys.
+func (p ParameterSet) checkDecapsulation(ct []byte, sks [][]byte) error {
+	_, skSize, ctSize := p.Sizes()
+	if skSize == 0 {
+		return ErrParameterSet
+	}
+	if len(ct) != ctSize || len(sks) == 0 {
+		return ErrInvalidLength
+	}
+	for _, sk := range sks {
+		if len(sk) != skSize {
+			return ErrInvalidLength
+		}
+	}
+	return nil
+}
+
+// PublicKey returns the encapsulation key stored in the decapsulation
+// key sk, which must have the size of the parameter set.
+func (p ParameterSet) PublicKey(sk []byte) []byte {
+	pkSize, _, _ := p.Sizes()
+	end := len(sk) - 2*KeyHashSize
+	return sk[end-pkSize : end]
+}
+
+// KeyHash returns H(ek) as stored in the decapsulation key sk, which
+// must have the size of the parameter set.
+func (p ParameterSet) KeyHash(sk []byte) []byte {
+	return sk[len(sk)-2*KeyHashSize : len(sk)-KeyHashSize]
+}
+
+// ZeroBytes clears sensitive data from memory.
+func ZeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
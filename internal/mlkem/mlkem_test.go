@@ -0,0 +1,58 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package mlkem
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestParameterSets(t *testing.T) {
+	for _, p := range []ParameterSet{MLKEM512, MLKEM768, MLKEM1024} {
+		t.Run(p.String(), func(t *testing.T) {
+			pkSize, skSize, ctSize := p.Sizes()
+			sk, pk, err := p.Keypair()
+			if err != nil || len(sk) != skSize || len(pk) != pkSize {
+				t.Fatal("bad key pair")
+			}
+			if !bytes.Equal(p.PublicKey(sk), pk) {
+				t.Fatal("PublicKey does not locate ek")
+			}
+			if h := sha3.Sum256(pk); !bytes.Equal(p.KeyHash(sk), h[:]) {
+				t.Fatal("KeyHash does not locate H(ek)")
+			}
+			ct, ssA, err := p.Encapsulate(pk)
+			if err != nil || len(ct) != ctSize {
+				t.Fatal("bad encapsulation")
+			}
+			ssB, err := p.Decapsulate(ct, sk)
+			if err != nil || !bytes.Equal(ssA, ssB) {
+				t.Fatal("decapsulation does not match")
+			}
+			other, _, _ := p.Keypair()
+			ssC, err := p.DecapsulateTrial(ct, [][]byte{other, sk})
+			if err != nil || !bytes.Equal(ssA, ssC) {
+				t.Fatal("trial decapsulation does not match")
+			}
+			if _, _, err := p.Encapsulate(pk[1:]); !errors.Is(err, ErrInvalidLength) {
+				t.Fatalf("got %v, want ErrInvalidLength", err)
+			}
+			if _, err := p.Decapsulate(ct[1:], sk); !errors.Is(err, ErrInvalidLength) {
+				t.Fatalf("got %v, want ErrInvalidLength", err)
+			}
+			if _, err := p.DecapsulateTrial(ct, nil); !errors.Is(err, ErrInvalidLength) {
+				t.Fatalf("got %v, want ErrInvalidLength", err)
+			}
+		})
+	}
+	if _, _, err := ParameterSet(0).Keypair(); !errors.Is(err, ErrParameterSet) {
+		t.Fatalf("got %v, want ErrParameterSet", err)
+	}
+	if _, _, err := ParameterSet(4).Encapsulate(nil); !errors.Is(err, ErrParameterSet) {
+		t.Fatalf("got %v, want ErrParameterSet", err)
+	}
+}
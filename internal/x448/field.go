@@ -0,0 +1,145 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package x448
+
+import "encoding/binary"
+
+// fieldElement is an element of GF(2^448 - 2^224 - 1) in sixteen
+// 28-bit limbs, least significant first. Limbs may exceed 28 bits
+// between operations; carry brings them back into range.
+type fieldElement [16]uint64
+
+const limbMask = 1<<28 - 1
+
+// fieldP is the field prime in limbs. Every limb is all ones except
+// limb 8, which holds the 2^224 term.
+var fieldP = fieldElement{
+	limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask,
+	limbMask - 1, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask,
+}
+
+// carry propagates limb overflow, folding the carry out of the top
+// limb back in with 2^448 = 2^224 + 1.
+func (v *fieldElement) carry() {
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < 15; i++ {
+			v[i+1] += v[i] >> 28
+			v[i] &= limbMask
+		}
+		c := v[15] >> 28
+		v[15] &= limbMask
+		v[0] += c
+		v[8] += c
+	}
+}
+
+func (v *fieldElement) add(a, b *fieldElement) {
+	for i := range v {
+		v[i] = a[i] + b[i]
+	}
+	v.carry()
+}
+
+// sub sets v = a - b, adding 2p first so that no limb underflows.
+func (v *fieldElement) sub(a, b *fieldElement) {
+	for i := range v {
+		v[i] = a[i] + 2*fieldP[i] - b[i]
+	}
+	v.carry()
+}
+
+func (v *fieldElement) mul(a, b *fieldElement) {
+	var c [33]uint64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			c[i+j] += a[i] * b[j]
+		}
+	}
+	for i := 0; i < 32; i++ {
+		c[i+1] += c[i] >> 28
+		c[i] &= limbMask
+	}
+	// Fold the upper half down from the top with 2^448 = 2^224 + 1.
+	for i := 32; i >= 16; i-- {
+		c[i-8] += c[i]
+		c[i-16] += c[i]
+	}
+	copy(v[:], c[:16])
+	v.carry()
+}
+
+func (v *fieldElement) square(a *fieldElement) {
+	v.mul(a, a)
+}
+
+func (v *fieldElement) mulSmall(a *fieldElement, k uint64) {
+	for i := range v {
+		v[i] = a[i] * k
+	}
+	v.carry()
+}
+
+// invert sets v = a^(p-2), iterating over the public exponent.
+func (v *fieldElement) invert(a *fieldElement) {
+	var e [56]byte
+	for i := range e {
+		e[i] = 0xff
+	}
+	e[0] = 0xfd
+	e[28] = 0xfe
+	r := fieldElement{1}
+	for i := 447; i >= 0; i-- {
+		r.square(&r)
+		if e[i/8]>>(i%8)&1 == 1 {
+			r.mul(&r, a)
+		}
+	}
+	*v = r
+}
+
+// swap exchanges a and b in constant time if bit is 1.
+func swap(a, b *fieldElement, bit uint64) {
+	mask := -bit
+	for i := range a {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
+
+// setBytes sets v from a 56-byte little-endian encoding, which need
+// not be reduced.
+func (v *fieldElement) setBytes(b []byte) {
+	var x [64]byte
+	copy(x[:], b[:56])
+	for i := range v {
+		bit := i * 28
+		v[i] = binary.LittleEndian.Uint64(x[bit/8:]) >> (bit % 8) & limbMask
+	}
+}
+
+// bytes returns the canonical 56-byte little-endian encoding of v.
+func (v *fieldElement) bytes() []byte {
+	t := *v
+	// A second round of carries clears the last fold, leaving every
+	// limb below 2^28 and t < 2^448 < 2p.
+	t.carry()
+	t.carry()
+	var d fieldElement
+	var borrow uint64
+	for i := range t {
+		x := t[i] - fieldP[i] - borrow
+		borrow = x >> 63
+		d[i] = x & limbMask
+	}
+	swap(&t, &d, 1-borrow)
+	out := make([]byte, 56)
+	for i := range t {
+		bit := i * 28
+		for j := 0; j < 28; j += 4 {
+			out[(bit+j)/8] |= byte(t[i]>>j&0xf) << ((bit + j) % 8)
+		}
+	}
+	return out
+}
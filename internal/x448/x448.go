@@ -0,0 +1,90 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package x448 implements the X448 Diffie-Hellman function from
+// RFC 7748, with the same shape as golang.org/x/crypto/curve25519.
+package x448
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+const (
+	// ScalarSize is the size of an X448 scalar in bytes.
+	ScalarSize = 56
+
+	// PointSize is the size of an X448 u-coordinate in bytes.
+	PointSize = 56
+)
+
+// Basepoint is the canonical X448 generator, u = 5.
+var Basepoint = basePoint[:]
+
+var basePoint = [PointSize]byte{5}
+
+// ErrLowOrderPoint is returned when the result of X448 is all zeros,
+// which happens when point has low order.
+var ErrLowOrderPoint = errors.New("x448: low order point")
+
+// X448 returns the result of the scalar multiplication (scalar * point),
+// according to RFC 7748, Section 5. It rejects an all-zero output.
+func X448(scalar, point []byte) ([]byte, error) {
+	if len(scalar) != ScalarSize {
+		return nil, errors.New("x448: bad scalar length")
+	}
+	if len(point) != PointSize {
+		return nil, errors.New("x448: bad point length")
+	}
+	var k [ScalarSize]byte
+	copy(k[:], scalar)
+	k[0] &= 252
+	k[55] |= 128
+	out := ladder(&k, point)
+	if subtle.ConstantTimeCompare(out, make([]byte, PointSize)) == 1 {
+		return nil, ErrLowOrderPoint
+	}
+	return out, nil
+}
+
+// ladder runs the Montgomery ladder of RFC 7748, Section 5.
+func ladder(k *[ScalarSize]byte, point []byte) []byte {
+	var x1, x2, z2, x3, z3 fieldElement
+	var a, aa, b, bb, e, c, d, da, cb fieldElement
+	x1.setBytes(point)
+	x2[0] = 1
+	x3 = x1
+	z3[0] = 1
+	var swapBit uint64
+	for t := 447; t >= 0; t-- {
+		kt := uint64(k[t/8]>>(t%8)) & 1
+		swapBit ^= kt
+		swap(&x2, &x3, swapBit)
+		swap(&z2, &z3, swapBit)
+		swapBit = kt
+
+		a.add(&x2, &z2)
+		aa.square(&a)
+		b.sub(&x2, &z2)
+		bb.square(&b)
+		e.sub(&aa, &bb)
+		c.add(&x3, &z3)
+		d.sub(&x3, &z3)
+		da.mul(&d, &a)
+		cb.mul(&c, &b)
+		x3.add(&da, &cb)
+		x3.square(&x3)
+		z3.sub(&da, &cb)
+		z3.square(&z3)
+		z3.mul(&z3, &x1)
+		x2.mul(&aa, &bb)
+		z2.mulSmall(&e, 39081)
+		z2.add(&z2, &aa)
+		z2.mul(&z2, &e)
+	}
+	swap(&x2, &x3, swapBit)
+	swap(&z2, &z3, swapBit)
+	z2.invert(&z2)
+	x2.mul(&x2, &z2)
+	return x2.bytes()
+}
@@ -0,0 +1,92 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package x448
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestRFC7748 checks the X448 vectors of RFC 7748, Sections 5.2 and 6.2.
+func TestRFC7748(t *testing.T) {
+	for _, v := range []struct{ scalar, point, out string }{
+		{
+			"3d262fddf9ec8e88495266fea19a34d28882acef045104d0d1aae121700a779c984c24f8cdd78fbff44943eba368f54b29259a4f1c600ad3",
+			"06fce640fa3487bfda5f6cf2d5263f8aad88334cbd07437f020f08f9814dc031ddbdc38c19c6da2583fa5429db94ada18aa7a7fb4ef8a086",
+			"ce3e4ff95a60dc6697da1db1d85e6afbdf79b50a2412d7546d5f239fe14fbaadeb445fc66a01b0779d98223961111e21766282f73dd96b6f",
+		},
+		{
+			"203d494428b8399352665ddca42f9de8fef600908e0d461cb021f8c538345dd77c3e4806e25f46d3315c44e0a5b4371282dd2c8d5be3095f",
+			"0fbcc2f993cd56d3305b0b7d9e55d4c1a8fb5dbb52f8e9a1e9b6201b165d015894e56c4d3570bee52fe205e28a78b91cdfbde71ce8d157db",
+			"884a02576239ff7a2f2f63b2db6a9ff37047ac13568e1e30fe63c4a7ad1b3ee3a5700df34321d62077e63633c575c1c954514e99da7c179d",
+		},
+		{
+			"9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28dd9c9baf574a9419744897391006382a6f127ab1d9ac2d8c0a598726b",
+			hex.EncodeToString(Basepoint),
+			"9b08f7cc31b7e3e67d22d5aea121074a273bd2b83de09c63faa73d2c22c5d9bbc836647241d953d40c5b12da88120d53177f80e532c41fa0",
+		},
+		{
+			"1c306a7ac2a0e2e0990b294470cba339e6453772b075811d8fad0d1d6927c120bb5ee8972b0d3e21374c9c921b09d1b0366f10b65173992d",
+			hex.EncodeToString(Basepoint),
+			"3eb7a829b0cd20f5bcfc0b599b6feccf6da4627107bdb0d4f345b43027d8b972fc3e34fb4232a13ca706dcb57aec3dae07bdc1c67bf33609",
+		},
+		{
+			"9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28dd9c9baf574a9419744897391006382a6f127ab1d9ac2d8c0a598726b",
+			"3eb7a829b0cd20f5bcfc0b599b6feccf6da4627107bdb0d4f345b43027d8b972fc3e34fb4232a13ca706dcb57aec3dae07bdc1c67bf33609",
+			"07fff4181ac6cc95ec1c16a94a0f74d12da232ce40a77552281d282bb60c0b56fd2464c335543936521c24403085d59a449a5037514a879d",
+		},
+	} {
+		out, err := X448(decodeHex(t, v.scalar), decodeHex(t, v.point))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(out) != v.out {
+			t.Fatalf("got %x, want %s", out, v.out)
+		}
+	}
+}
+
+// TestIterated checks the iterated X448 vectors of RFC 7748, Section 5.2.
+func TestIterated(t *testing.T) {
+	want := map[int]string{
+		1:    "3f482c8a9f19b01e6c46ee9711d9dc14fd4bf67af30765c2ae2b846a4d23a8cd0db897086239492caf350b51f833868b9bc2b3bca9cf4113",
+		1000: "aa3b4749d55b9daf1e5b00288826c467274ce3ebbdd5c17b975e09d4af6c67cf10d087202db88286e2b79fceea3ec353ef54faa26e219f38",
+	}
+	n := 1000
+	if testing.Short() {
+		n = 1
+	}
+	k, u := bytes.Clone(Basepoint), bytes.Clone(Basepoint)
+	for i := 1; i <= n; i++ {
+		out, err := X448(k, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, u = out, k
+		if w, ok := want[i]; ok && hex.EncodeToString(k) != w {
+			t.Fatalf("iteration %d: got %x, want %s", i, k, w)
+		}
+	}
+}
+
+func TestLowOrder(t *testing.T) {
+	scalar := bytes.Repeat([]byte{0x42}, ScalarSize)
+	one := make([]byte, PointSize)
+	one[0] = 1
+	for _, point := range [][]byte{make([]byte, PointSize), one} {
+		if _, err := X448(scalar, point); err != ErrLowOrderPoint {
+			t.Fatalf("expected ErrLowOrderPoint, got %v", err)
+		}
+	}
+}
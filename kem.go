@@ -23,6 +23,22 @@ var (
 	// ErrInvalidDecapsulationKey is returned when a decapsulation key
 	// fails the hash check per FIPS 203 §7.3.
 	ErrInvalidDecapsulationKey = errors.New("kyberk2so: invalid decapsulation key")
+
+	// ErrInconsistentEncapsulationKey is returned when the vector of a
+	// split encapsulation key does not hash to the H(ek) of its header.
+	ErrInconsistentEncapsulationKey = errors.New("kyberk2so: encapsulation key parts do not match")
+
+	// ErrEncapsulationFinished is returned when an incremental
+	// encapsulation is finished a second time.
+	ErrEncapsulationFinished = errors.New("kyberk2so: incremental encapsulation already finished")
+
+	// ErrMismatchedPublicSeed is returned when the public keys of a
+	// multi-recipient encapsulation do not share the same public seed ρ.
+	ErrMismatchedPublicSeed = errors.New("kyberk2so: public keys do not share a public seed")
+
+	// ErrNotEncodable is returned when a public key or ciphertext has no
+	// Kemeleon encoding.
+	ErrNotEncodable = errors.New("kyberk2so: key or ciphertext has no Kemeleon encoding")
 )
 
 // KemKeypairDerand512 generates an ML-KEM-512 key pair deterministically
@@ -361,10 +377,22 @@ func KemDecrypt512(
 	ciphertext [Kyber512CTBytes]byte,
 	privateKey [Kyber512SKBytes]byte,
 ) ([KyberSSBytes]byte, error) {
+	sharedSecret, _, err := kemDecrypt512(ciphertext, privateKey)
+	return sharedSecret, err
+}
+
+// kemDecrypt512 is KemDecrypt512, also returning 1 if the re-encryption
+// check accepted the ciphertext and 0 if the shared secret is the
+// implicit rejection value. The result must only be used in constant
+// time.
+func kemDecrypt512(
+	ciphertext [Kyber512CTBytes]byte,
+	privateKey [Kyber512SKBytes]byte,
+) ([KyberSSBytes]byte, int, error) {
 	const paramsK = 2
 	var sharedSecretFixedLength [KyberSSBytes]byte
 	if !kemDecapsInputCheck(privateKey[:], paramsK) {
-		return sharedSecretFixedLength, ErrInvalidDecapsulationKey
+		return sharedSecretFixedLength, 0, ErrInvalidDecapsulationKey
 	}
 	indcpaPrivateKey := privateKey[:paramsIndcpaSecretKeyBytesK512]
 	pki := paramsIndcpaSecretKeyBytesK512 + paramsIndcpaPublicKeyBytesK512
@@ -384,7 +412,8 @@ func KemDecrypt512(
 	sha3.ShakeSum256(kBar[:], jInput[:])
 	var cmp [Kyber512CTBytes]byte
 	err := indcpaEncrypt(cmp[:], mPrime[:], publicKey, kr[paramsSymBytes:], paramsK)
-	fail := byte(subtle.ConstantTimeCompare(ciphertext[:], cmp[:]) - 1)
+	accept := subtle.ConstantTimeCompare(ciphertext[:], cmp[:])
+	fail := byte(accept - 1)
 	for i := 0; i < KyberSSBytes; i++ {
 		sharedSecretFixedLength[i] = kr[i] ^ (fail & (kr[i] ^ kBar[i]))
 	}
@@ -394,7 +423,7 @@ func KemDecrypt512(
 	byteopsZeroBytes(kBar[:])
 	byteopsZeroBytes(jInput[:])
 	byteopsZeroBytes(cmp[:])
-	return sharedSecretFixedLength, err
+	return sharedSecretFixedLength, accept, err
 }
 
 // KemDecrypt768 takes a ciphertext (from KemEncrypt768),
@@ -404,10 +433,22 @@ func KemDecrypt768(
 	ciphertext [Kyber768CTBytes]byte,
 	privateKey [Kyber768SKBytes]byte,
 ) ([KyberSSBytes]byte, error) {
+	sharedSecret, _, err := kemDecrypt768(ciphertext, privateKey)
+	return sharedSecret, err
+}
+
+// kemDecrypt768 is KemDecrypt768, also returning 1 if the re-encryption
+// check accepted the ciphertext and 0 if the shared secret is the
+// implicit rejection value. The result must only be used in constant
+// time.
+func kemDecrypt768(
+	ciphertext [Kyber768CTBytes]byte,
+	privateKey [Kyber768SKBytes]byte,
+) ([KyberSSBytes]byte, int, error) {
 	const paramsK = 3
 	var sharedSecretFixedLength [KyberSSBytes]byte
 	if !kemDecapsInputCheck(privateKey[:], paramsK) {
-		return sharedSecretFixedLength, ErrInvalidDecapsulationKey
+		return sharedSecretFixedLength, 0, ErrInvalidDecapsulationKey
 	}
 	indcpaPrivateKey := privateKey[:paramsIndcpaSecretKeyBytesK768]
 	pki := paramsIndcpaSecretKeyBytesK768 + paramsIndcpaPublicKeyBytesK768
@@ -427,7 +468,8 @@ func KemDecrypt768(
 	sha3.ShakeSum256(kBar[:], jInput[:])
 	var cmp [Kyber768CTBytes]byte
 	err := indcpaEncrypt(cmp[:], mPrime[:], publicKey, kr[paramsSymBytes:], paramsK)
-	fail := byte(subtle.ConstantTimeCompare(ciphertext[:], cmp[:]) - 1)
+	accept := subtle.ConstantTimeCompare(ciphertext[:], cmp[:])
+	fail := byte(accept - 1)
 	for i := 0; i < KyberSSBytes; i++ {
 		sharedSecretFixedLength[i] = kr[i] ^ (fail & (kr[i] ^ kBar[i]))
 	}
@@ -437,7 +479,7 @@ func KemDecrypt768(
 	byteopsZeroBytes(kBar[:])
 	byteopsZeroBytes(jInput[:])
 	byteopsZeroBytes(cmp[:])
-	return sharedSecretFixedLength, err
+	return sharedSecretFixedLength, accept, err
 }
 
 // KemDecrypt1024 takes a ciphertext (from KemEncrypt1024),
@@ -447,10 +489,22 @@ func KemDecrypt1024(
 	ciphertext [Kyber1024CTBytes]byte,
 	privateKey [Kyber1024SKBytes]byte,
 ) ([KyberSSBytes]byte, error) {
+	sharedSecret, _, err := kemDecrypt1024(ciphertext, privateKey)
+	return sharedSecret, err
+}
+
+// kemDecrypt1024 is KemDecrypt1024, also returning 1 if the re-encryption
+// check accepted the ciphertext and 0 if the shared secret is the
+// implicit rejection value. The result must only be used in constant
+// time.
+func kemDecrypt1024(
+	ciphertext [Kyber1024CTBytes]byte,
+	privateKey [Kyber1024SKBytes]byte,
+) ([KyberSSBytes]byte, int, error) {
 	const paramsK = 4
 	var sharedSecretFixedLength [KyberSSBytes]byte
 	if !kemDecapsInputCheck(privateKey[:], paramsK) {
-		return sharedSecretFixedLength, ErrInvalidDecapsulationKey
+		return sharedSecretFixedLength, 0, ErrInvalidDecapsulationKey
 	}
 	indcpaPrivateKey := privateKey[:paramsIndcpaSecretKeyBytesK1024]
 	pki := paramsIndcpaSecretKeyBytesK1024 + paramsIndcpaPublicKeyBytesK1024
@@ -470,7 +524,8 @@ func KemDecrypt1024(
 	sha3.ShakeSum256(kBar[:], jInput[:])
 	var cmp [Kyber1024CTBytes]byte
 	err := indcpaEncrypt(cmp[:], mPrime[:], publicKey, kr[paramsSymBytes:], paramsK)
-	fail := byte(subtle.ConstantTimeCompare(ciphertext[:], cmp[:]) - 1)
+	accept := subtle.ConstantTimeCompare(ciphertext[:], cmp[:])
+	fail := byte(accept - 1)
 	for i := 0; i < KyberSSBytes; i++ {
 		sharedSecretFixedLength[i] = kr[i] ^ (fail & (kr[i] ^ kBar[i]))
 	}
@@ -480,5 +535,5 @@ func KemDecrypt1024(
 	byteopsZeroBytes(kBar[:])
 	byteopsZeroBytes(jInput[:])
 	byteopsZeroBytes(cmp[:])
-	return sharedSecretFixedLength, err
+	return sharedSecretFixedLength, accept, err
 }
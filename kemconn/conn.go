@@ -0,0 +1,474 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package kemconn implements a secure channel over a net.Conn, keyed by
+// an ML-KEM-768 handshake, for services that cannot use TLS.
+//
+// The client knows the server's static ML-KEM-768 encapsulation key. It
+// runs the Kyber.UAKE exchange of package ake: its first message holds
+// a fresh ephemeral encapsulation key, for forward secrecy, and an
+// encapsulation to the server's static key, which authenticates the
+// server. The server answers with an encapsulation to the ephemeral key
+// and a key confirmation tag, and the client replies with its own tag
+// and may then send application data right away, after one round trip.
+// Since the server waits for the client's tag, a server that writes
+// first does so after one and a half round trips.
+//
+// One traffic key per direction is derived from the session key.
+// Application data is carried in records of a two-byte big-endian
+// length followed by a ChaCha20-Poly1305 ciphertext, whose plaintext
+// ends with a record type byte. The nonce is the 64-bit sequence number
+// of the record under the current key, and the length is authenticated
+// as associated data. A sender replaces its traffic key after a
+// configurable number of records, announcing it with a key update
+// record, and Close sends a close_notify record so that truncation is
+// detected.
+package kemconn
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/ake"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// ErrInvalidConfig is returned when a Config lacks the key required
+	// by its side of the connection.
+	ErrInvalidConfig = errors.New("kemconn: invalid configuration")
+
+	// ErrBadRecord is returned when a record fails to authenticate or is
+	// malformed. The connection cannot be used afterwards.
+	ErrBadRecord = errors.New("kemconn: bad record")
+
+	// ErrTruncated is returned when the underlying connection ends
+	// without a close_notify record.
+	ErrTruncated = errors.New("kemconn: connection truncated")
+)
+
+// MaxPlaintext is the maximum amount of application data in a record.
+const MaxPlaintext = 16384
+
+// DefaultRekeyInterval is the number of application data records sent
+// under a traffic key before it is replaced, if Config.RekeyInterval is
+// zero.
+const DefaultRekeyInterval = 1 << 32
+
+// closeNotifyTimeout bounds how long Close waits to send close_notify.
+const closeNotifyTimeout = 5 * time.Second
+
+// Record types.
+const (
+	recordData      byte = 0
+	recordKeyUpdate byte = 1
+	recordClose     byte = 2
+)
+
+// Config configures a client or server.
+type Config struct {
+	// PublicKey is the server's static ML-KEM-768 encapsulation key,
+	// required for clients.
+	PublicKey []byte
+
+	// PrivateKey is the server's static ML-KEM-768 decapsulation key,
+	// required for servers. It embeds the encapsulation key.
+	PrivateKey []byte
+
+	// RekeyInterval is the number of application data records sent
+	// under a traffic key before it is replaced. Zero selects
+	// DefaultRekeyInterval.
+	RekeyInterval uint64
+}
+
+// akeConfig returns the Kyber.UAKE configuration of the client or server.
+func (c *Config) akeConfig(isClient bool) (ake.Config, error) {
+	cfg := ake.Config{KEM: ake.MLKEM768}
+	if isClient {
+		if len(c.PublicKey) != kyberk2so.Kyber768PKBytes {
+			return cfg, ErrInvalidConfig
+		}
+		cfg.PeerStatic = c.PublicKey
+		return cfg, nil
+	}
+	if len(c.PrivateKey) != kyberk2so.Kyber768SKBytes {
+		return cfg, ErrInvalidConfig
+	}
+	cfg.StaticKeypair = ake.KeyPair{
+		Private: c.PrivateKey,
+		Public:  mlkem.MLKEM768.PublicKey(c.PrivateKey),
+	}
+	return cfg, nil
+}
+
+func (c *Config) rekeyInterval() uint64 {
+	if c.RekeyInterval == 0 {
+		return DefaultRekeyInterval
+	}
+	return c.RekeyInterval
+}
+
+// halfConn is the record protection state of one direction.
+type halfConn struct {
+	key  []byte
+	aead cipher.AEAD
+	seq  uint64
+}
+
+// setKey installs a traffic key and resets the sequence number.
+func (hc *halfConn) setKey(key []byte) {
+	if hc.key != nil {
+		mlkem.ZeroBytes(hc.key)
+	}
+	hc.key = key
+	hc.aead, _ = chacha20poly1305.New(key)
+	hc.seq = 0
+}
+
+// update replaces the traffic key with HKDF-Expand(key, "key update").
+func (hc *halfConn) update() error {
+	next, err := hkdf.Expand(sha256.New, hc.key, "kemconn key update", chacha20poly1305.KeySize)
+	if err != nil {
+		return err
+	}
+	hc.setKey(next)
+	return nil
+}
+
+func (hc *halfConn) nonce() []byte {
+	var nonce [chacha20poly1305.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[4:], hc.seq)
+	return nonce[:]
+}
+
+// Conn is a secure channel over a net.Conn. It is safe for one
+// concurrent reader and one concurrent writer.
+type Conn struct {
+	conn     net.Conn
+	config   *Config
+	isClient bool
+
+	handshakeMutex    sync.Mutex
+	handshakeErr      error
+	handshakeDone     bool
+	handshakeComplete atomic.Bool
+
+	in      sync.Mutex
+	inState halfConn
+	readErr error
+	buffer  []byte
+
+	out      sync.Mutex
+	outState halfConn
+	writeErr error
+	closed   bool
+}
+
+// Client returns a new client side of a secure channel over conn. The
+// handshake runs on the first Read or Write, or on Handshake.
+func Client(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config, isClient: true}
+}
+
+// Server returns a new server side of a secure channel over conn. The
+// handshake runs on the first Read or Write, or on Handshake.
+func Server(conn net.Conn, config *Config) *Conn {
+	return &Conn{conn: conn, config: config}
+}
+
+// Handshake runs the handshake if it has not yet been run. Its result
+// is remembered and returned again by later calls.
+func (c *Conn) Handshake() error {
+	c.handshakeMutex.Lock()
+	defer c.handshakeMutex.Unlock()
+	if !c.handshakeDone {
+		c.handshakeDone = true
+		if c.isClient {
+			c.handshakeErr = c.clientHandshake()
+		} else {
+			c.handshakeErr = c.serverHandshake()
+		}
+		c.handshakeComplete.Store(c.handshakeErr == nil)
+	}
+	return c.handshakeErr
+}
+
+// clientHandshake sends the first message, reads the server's answer
+// and sends the key confirmation, after which the client may write
+// without waiting for the server.
+func (c *Conn) clientHandshake() error {
+	cfg, err := c.config.akeConfig(true)
+	if err != nil {
+		return err
+	}
+	initiator, msg1, err := ake.NewInitiator(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(msg1); err != nil {
+		return err
+	}
+	_, _, ctSize := kemSizes()
+	msg2 := make([]byte, ctSize+ake.TagSize)
+	if _, err := io.ReadFull(c.conn, msg2); err != nil {
+		return err
+	}
+	confirmation, sessionKey, err := initiator.Finish(msg2)
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(confirmation); err != nil {
+		mlkem.ZeroBytes(sessionKey)
+		return err
+	}
+	return c.setTrafficKeys(sessionKey)
+}
+
+// serverHandshake reads the first message, answers it, and waits for
+// the client's key confirmation.
+func (c *Conn) serverHandshake() error {
+	cfg, err := c.config.akeConfig(false)
+	if err != nil {
+		return err
+	}
+	pkSize, _, ctSize := kemSizes()
+	msg1 := make([]byte, pkSize+ctSize)
+	if _, err := io.ReadFull(c.conn, msg1); err != nil {
+		return err
+	}
+	responder, msg2, err := ake.NewResponder(cfg, msg1)
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(msg2); err != nil {
+		return err
+	}
+	confirmation := make([]byte, ake.TagSize)
+	if _, err := io.ReadFull(c.conn, confirmation); err != nil {
+		return err
+	}
+	sessionKey, err := responder.Confirm(confirmation)
+	if err != nil {
+		return err
+	}
+	return c.setTrafficKeys(sessionKey)
+}
+
+// setTrafficKeys derives the traffic keys of both directions from the
+// session key.
+func (c *Conn) setTrafficKeys(sessionKey []byte) error {
+	defer mlkem.ZeroBytes(sessionKey)
+	clientKey, err := hkdf.Expand(sha256.New, sessionKey, "kemconn client traffic", chacha20poly1305.KeySize)
+	if err != nil {
+		return err
+	}
+	serverKey, err := hkdf.Expand(sha256.New, sessionKey, "kemconn server traffic", chacha20poly1305.KeySize)
+	if err != nil {
+		return err
+	}
+	if c.isClient {
+		c.outState.setKey(clientKey)
+		c.inState.setKey(serverKey)
+	} else {
+		c.outState.setKey(serverKey)
+		c.inState.setKey(clientKey)
+	}
+	return nil
+}
+
+// Read reads application data, running the handshake first if needed.
+// It returns io.EOF after a close_notify record.
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.in.Lock()
+	defer c.in.Unlock()
+	for len(c.buffer) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		if len(b) == 0 {
+			return 0, nil
+		}
+		c.readErr = c.readRecord()
+	}
+	n := copy(b, c.buffer)
+	c.buffer = c.buffer[n:]
+	return n, nil
+}
+
+// readRecord reads and decrypts one record, acting on key updates and
+// close_notify and buffering application data.
+func (c *Conn) readRecord() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+	length := int(binary.BigEndian.Uint16(header[:]))
+	if length < 1+chacha20poly1305.Overhead || length > MaxPlaintext+1+chacha20poly1305.Overhead {
+		return ErrBadRecord
+	}
+	record := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, record); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+	plaintext, err := c.inState.aead.Open(record[:0], c.inState.nonce(), record, header[:])
+	if err != nil {
+		return ErrBadRecord
+	}
+	c.inState.seq++
+	recordType := plaintext[len(plaintext)-1]
+	plaintext = plaintext[:len(plaintext)-1]
+	switch recordType {
+	case recordData:
+		c.buffer = plaintext
+		return nil
+	case recordKeyUpdate:
+		if len(plaintext) != 0 {
+			return ErrBadRecord
+		}
+		return c.inState.update()
+	case recordClose:
+		if len(plaintext) != 0 {
+			return ErrBadRecord
+		}
+		return io.EOF
+	default:
+		return ErrBadRecord
+	}
+}
+
+// Write writes application data, running the handshake first if needed.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	n := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > MaxPlaintext {
+			chunk = chunk[:MaxPlaintext]
+		}
+		if err := c.writeRecord(recordData, chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		b = b[len(chunk):]
+	}
+	return n, nil
+}
+
+// Rekey replaces the outgoing traffic key, announcing it to the peer.
+func (c *Conn) Rekey() error {
+	if err := c.Handshake(); err != nil {
+		return err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.sendKeyUpdate()
+}
+
+func (c *Conn) sendKeyUpdate() error {
+	if err := c.writeRecord(recordKeyUpdate, nil); err != nil {
+		return err
+	}
+	return c.outState.update()
+}
+
+// writeRecord encrypts and sends one record, first replacing the
+// traffic key if it has carried RekeyInterval data records.
+func (c *Conn) writeRecord(recordType byte, data []byte) error {
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if recordType == recordData && c.outState.seq >= c.config.rekeyInterval() {
+		if err := c.sendKeyUpdate(); err != nil {
+			return err
+		}
+	}
+	if c.outState.seq == math.MaxUint64 {
+		c.writeErr = ErrBadRecord
+		return c.writeErr
+	}
+	length := len(data) + 1 + chacha20poly1305.Overhead
+	out := make([]byte, 2, 2+length)
+	binary.BigEndian.PutUint16(out, uint16(length))
+	plaintext := append(append([]byte(nil), data...), recordType)
+	out = c.outState.aead.Seal(out, c.outState.nonce(), plaintext, out[:2])
+	c.outState.seq++
+	if _, err := c.conn.Write(out); err != nil {
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// Close sends close_notify, if the handshake has completed, and closes
+// the underlying connection.
+func (c *Conn) Close() error {
+	c.out.Lock()
+	if c.handshakeComplete.Load() && !c.closed {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(closeNotifyTimeout))
+		_ = c.writeRecord(recordClose, nil)
+	}
+	c.closed = true
+	c.out.Unlock()
+	return c.conn.Close()
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// kemSizes returns the ML-KEM-768 public key, private key and
+// ciphertext sizes.
+func kemSizes() (int, int, int) {
+	return kyberk2so.Kyber768PKBytes, kyberk2so.Kyber768SKBytes, kyberk2so.Kyber768CTBytes
+}
@@ -0,0 +1,272 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kemconn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+var (
+	_ net.Conn     = (*Conn)(nil)
+	_ net.Listener = (*listener)(nil)
+)
+
+func configs(t *testing.T) (*Config, *Config) {
+	t.Helper()
+	sk, pk, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Config{PublicKey: pk[:]}, &Config{PrivateKey: sk[:]}
+}
+
+// pipe returns both sides of a secure channel over net.Pipe.
+func pipe(t *testing.T, clientConfig, serverConfig *Config) (*Conn, *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	return Client(a, clientConfig), Server(b, serverConfig)
+}
+
+// echo copies everything the connection reads back to it until EOF,
+// then closes it and returns the copy error.
+func echo(c *Conn) error {
+	_, err := io.Copy(c, c)
+	_ = c.Close()
+	return err
+}
+
+func TestPipe(t *testing.T) {
+	clientConfig, serverConfig := configs(t)
+	clientConfig.RekeyInterval = 3
+	serverConfig.RekeyInterval = 2
+	client, server := pipe(t, clientConfig, serverConfig)
+	echoErr := make(chan error, 1)
+	go func() { echoErr <- echo(server) }()
+
+	data := make([]byte, 5*MaxPlaintext+123)
+	_, _ = rand.Read(data)
+	for i := 0; i < 4; i++ {
+		done := make(chan error, 1)
+		go func() {
+			_, err := client.Write(data)
+			done <- err
+		}()
+		got := make([]byte, len(data))
+		if _, err := io.ReadFull(client, got); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("echoed data differs")
+		}
+		go func() { done <- client.Rekey() }()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The client's close_notify ends the server's stream cleanly.
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-echoErr; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Write(data); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("got %v, want net.ErrClosed", err)
+	}
+}
+
+func TestServerWritesFirst(t *testing.T) {
+	clientConfig, serverConfig := configs(t)
+	client, server := pipe(t, clientConfig, serverConfig)
+	go func() {
+		_, _ = server.Write([]byte("hello"))
+		_ = server.Close()
+	}()
+	got, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	clientConfig, serverConfig := configs(t)
+	l, err := Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = echo(conn.(*Conn)) }()
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		client, err := Dial("tcp", l.Addr().String(), clientConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 100000)
+		_, _ = rand.Read(data)
+		if _, err := client.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, len(data))
+		if _, err := io.ReadFull(client, got); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("echoed data differs")
+		}
+		if err := client.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A client expecting another server key fails the handshake.
+	otherConfig, _ := configs(t)
+	if _, err := Dial("tcp", l.Addr().String(), otherConfig); err == nil {
+		t.Fatal("handshake with the wrong server key succeeded")
+	}
+}
+
+// relay copies client traffic to the server through a function that
+// may modify or cut it.
+func relay(t *testing.T, clientConfig, serverConfig *Config, tamper func(offset int, b []byte) bool) (*Conn, *Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	c, d := net.Pipe()
+	go func() {
+		buf := make([]byte, 4096)
+		offset := 0
+		for {
+			n, err := a.Read(buf)
+			if err != nil {
+				_ = c.Close()
+				return
+			}
+			if !tamper(offset, buf[:n]) {
+				_ = c.Close()
+				_ = a.Close()
+				return
+			}
+			offset += n
+			if _, err := c.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		_, _ = io.Copy(a, c)
+		_ = a.Close()
+	}()
+	return Client(b, clientConfig), Server(d, serverConfig)
+}
+
+func TestTampering(t *testing.T) {
+	handshakeSize := kyberk2so.Kyber768PKBytes + kyberk2so.Kyber768CTBytes + 32
+	for _, tc := range []struct {
+		name   string
+		offset int
+		want   error
+	}{
+		{"ephemeral key", 10, nil},
+		{"static ciphertext", kyberk2so.Kyber768PKBytes + 10, nil},
+		{"confirmation", handshakeSize - 1, nil},
+		{"record length", handshakeSize, ErrBadRecord},
+		{"record body", handshakeSize + 5, ErrBadRecord},
+	} {
+		clientConfig, serverConfig := configs(t)
+		client, server := relay(t, clientConfig, serverConfig, func(offset int, b []byte) bool {
+			if i := tc.offset - offset; i >= 0 && i < len(b) {
+				b[i] ^= 0x80
+			}
+			return true
+		})
+		go func() {
+			if _, err := client.Write([]byte("attack at dawn")); err != nil {
+				_ = client.Close()
+			}
+		}()
+		_, err := server.Read(make([]byte, 100))
+		if err == nil {
+			t.Fatalf("%s: tampered stream was accepted", tc.name)
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+		// Errors are sticky.
+		if _, err2 := server.Read(make([]byte, 100)); err2 == nil {
+			t.Fatalf("%s: read after error succeeded", tc.name)
+		}
+		_ = client.Close()
+		_ = server.Close()
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	clientConfig, serverConfig := configs(t)
+	handshakeSize := kyberk2so.Kyber768PKBytes + kyberk2so.Kyber768CTBytes + 32
+	// Cut the stream after the first record, before any close_notify.
+	firstRecord := 2 + 5 + 1 + 16
+	client, server := relay(t, clientConfig, serverConfig, func(offset int, b []byte) bool {
+		return offset < handshakeSize+firstRecord
+	})
+	go func() {
+		_, _ = client.Write([]byte("hello"))
+		_, _ = client.Write([]byte("world"))
+	}()
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(server, got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Read(got); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+}
+
+func TestTruncatedHeader(t *testing.T) {
+	clientConfig, serverConfig := configs(t)
+	a, b := net.Pipe()
+	client, server := Client(a, clientConfig), Server(b, serverConfig)
+	go func() {
+		// Close the connection after one byte of a record header.
+		if client.Handshake() == nil {
+			_, _ = a.Write([]byte{0})
+		}
+		_ = a.Close()
+	}()
+	if _, err := server.Read(make([]byte, 5)); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("got %v, want ErrTruncated", err)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	if _, err := Listen("tcp", "127.0.0.1:0", &Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want ErrInvalidConfig", err)
+	}
+	if _, err := Dial("tcp", "127.0.0.1:0", &Config{PublicKey: make([]byte, 10)}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want ErrInvalidConfig", err)
+	}
+	a, b := net.Pipe()
+	defer b.Close()
+	if _, err := Client(a, &Config{}).Write([]byte("x")); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("got %v, want ErrInvalidConfig", err)
+	}
+}
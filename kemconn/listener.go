@@ -0,0 +1,62 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kemconn
+
+import "net"
+
+// listener wraps accepted connections as server sides of secure channels.
+type listener struct {
+	net.Listener
+	config *Config
+}
+
+// Accept waits for the next connection and returns it as a *Conn. The
+// handshake runs on its first Read or Write, or on Handshake.
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return Server(conn, l.config), nil
+}
+
+// NewListener returns a listener that accepts the connections of inner
+// as server sides of secure channels.
+func NewListener(inner net.Listener, config *Config) (net.Listener, error) {
+	if _, err := config.akeConfig(false); err != nil {
+		return nil, err
+	}
+	return &listener{Listener: inner, config: config}, nil
+}
+
+// Listen listens on the given network address and returns a listener
+// of secure channels.
+func Listen(network, address string, config *Config) (net.Listener, error) {
+	if _, err := config.akeConfig(false); err != nil {
+		return nil, err
+	}
+	inner, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{Listener: inner, config: config}, nil
+}
+
+// Dial connects to the given network address and runs the client
+// handshake.
+func Dial(network, address string, config *Config) (*Conn, error) {
+	if _, err := config.akeConfig(true); err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	c := Client(conn, config)
+	if err := c.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
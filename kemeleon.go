@@ -0,0 +1,609 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// This file implements the Kemeleon encodings of draft-irtf-cfrg-kemeleon,
+// which map ML-KEM public keys and ciphertexts to bit strings that are
+// indistinguishable from uniformly random ones.
+//
+// A public key vector t̂, whose coefficients are uniform modulo `Q`, is
+// read as the digits of an integer in base `Q`. That integer is uniform
+// below Q^(k*n); encodings are only emitted when it also lies below the
+// largest power of two not exceeding Q^(k*n), so that its bits are
+// uniform, and key generation is repeated otherwise. A ciphertext is
+// first decompressed by picking, for every coefficient of `u` and `v`, a
+// uniformly random value among those that compress to it, which yields
+// uniform coefficients modulo `Q`; these are then encoded in the same
+// way, repeating encapsulation on rejection. Unused high bits of the
+// last byte are filled with random bits. The encodings are variable
+// time, which only concerns public values. They have not been checked
+// against the test vectors of the draft.
+
+// Kemeleon512PKBytes is a constant representing the byte length of Kemeleon-encoded public keys in ML-KEM-512.
+const Kemeleon512PKBytes int = 749 + paramsSymBytes
+
+// Kemeleon768PKBytes is a constant representing the byte length of Kemeleon-encoded public keys in ML-KEM-768.
+const Kemeleon768PKBytes int = 1124 + paramsSymBytes
+
+// Kemeleon1024PKBytes is a constant representing the byte length of Kemeleon-encoded public keys in ML-KEM-1024.
+const Kemeleon1024PKBytes int = 1498 + paramsSymBytes
+
+// Kemeleon512CTBytes is a constant representing the byte length of Kemeleon-encoded ciphertexts in ML-KEM-512.
+const Kemeleon512CTBytes int = 1124
+
+// Kemeleon768CTBytes is a constant representing the byte length of Kemeleon-encoded ciphertexts in ML-KEM-768.
+const Kemeleon768CTBytes int = 1498
+
+// Kemeleon1024CTBytes is a constant representing the byte length of Kemeleon-encoded ciphertexts in ML-KEM-1024.
+const Kemeleon1024CTBytes int = 1873
+
+// kemeleonBits returns the number of uniform bits in the encoding of
+// `digits` coefficients modulo `Q`: one less than the bit length of
+// Q^digits.
+func kemeleonBits(digits int) int {
+	switch digits {
+	case 2 * paramsN:
+		return 5990
+	case 3 * paramsN:
+		return 8986
+	case 4 * paramsN:
+		return 11981
+	default:
+		return 14977
+	}
+}
+
+// kemeleonCompressionBits returns the bit widths du and dv of the
+// compressed ciphertext components.
+func kemeleonCompressionBits(paramsK int) (int, int) {
+	if paramsK == 4 {
+		return 11, 5
+	}
+	return 10, 4
+}
+
+// kemeleonVectorEncode writes the integer whose base-`Q` digits are a,
+// least significant first, to dst in little-endian order, filling the
+// unused high bits of the last byte from pad. It reports false if the
+// integer does not fit in kemeleonBits(len(a)) bits.
+func kemeleonVectorEncode(dst []byte, a []uint16, pad byte) bool {
+	bits := kemeleonBits(len(a))
+	q := big.NewInt(int64(paramsQ))
+	r := new(big.Int)
+	d := new(big.Int)
+	for i := len(a) - 1; i >= 0; i-- {
+		r.Mul(r, q)
+		r.Add(r, d.SetUint64(uint64(a[i])))
+	}
+	if r.BitLen() > bits {
+		return false
+	}
+	r.FillBytes(dst)
+	for i, j := 0, len(dst)-1; i < j; i, j = i+1, j-1 {
+		dst[i], dst[j] = dst[j], dst[i]
+	}
+	if bits%8 != 0 {
+		dst[len(dst)-1] |= pad &^ (1<<(bits%8) - 1)
+	}
+	return true
+}
+
+// kemeleonVectorDecode is the inverse of kemeleonVectorEncode. Every
+// input decodes, since the integer is below Q^len(a).
+func kemeleonVectorDecode(a []uint16, src []byte) {
+	bits := kemeleonBits(len(a))
+	be := make([]byte, len(src))
+	for i := range src {
+		be[len(src)-1-i] = src[i]
+	}
+	if bits%8 != 0 {
+		be[0] &= 1<<(bits%8) - 1
+	}
+	q := big.NewInt(int64(paramsQ))
+	r := new(big.Int).SetBytes(be)
+	d := new(big.Int)
+	for i := range a {
+		r.QuoRem(r, q, d)
+		a[i] = uint16(d.Uint64())
+	}
+}
+
+// kemeleonCompress computes Compress_d(x), rounding x * 2^d / `Q` to
+// the nearest integer modulo 2^d.
+func kemeleonCompress(x uint16, d int) uint16 {
+	return uint16(((uint32(x)<<d + uint32(paramsQ/2)) / uint32(paramsQ)) & (1<<d - 1))
+}
+
+// kemeleonPreimages returns, for each d-bit value c, the values modulo
+// `Q` that compress to c.
+var kemeleonPreimages = sync.OnceValue(func() map[int][][]uint16 {
+	tables := make(map[int][][]uint16)
+	for _, d := range []int{4, 5, 10, 11} {
+		table := make([][]uint16, 1<<d)
+		for x := uint16(0); x < uint16(paramsQ); x++ {
+			c := kemeleonCompress(x, d)
+			table[c] = append(table[c], x)
+		}
+		tables[d] = table
+	}
+	return tables
+})
+
+// kemeleonUnpack reads len(out) little-endian d-bit values from src.
+func kemeleonUnpack(out []uint16, src []byte, d int) {
+	var acc uint32
+	var n int
+	j := 0
+	for i := range out {
+		for n < d {
+			acc |= uint32(src[j]) << n
+			j++
+			n += 8
+		}
+		out[i] = uint16(acc & (1<<d - 1))
+		acc >>= d
+		n -= d
+	}
+}
+
+// kemeleonUniform returns a uniform integer below n, read from the
+// extendable-output function rng by rejection sampling.
+func kemeleonUniform(rng sha3.ShakeHash, n int) int {
+	limit := 0x10000 - 0x10000%n
+	var b [2]byte
+	for {
+		_, _ = rng.Read(b[:])
+		if v := int(b[0]) | int(b[1])<<8; v < limit {
+			return v % n
+		}
+	}
+}
+
+// kemeleonEncodePublicKey encodes the public key into dst, reporting
+// false if it has no encoding.
+func kemeleonEncodePublicKey(dst, publicKey []byte, paramsK int, pad byte) bool {
+	polyvecBytesSize := paramsK * paramsPolyBytes
+	t := make([]uint16, paramsK*paramsN)
+	for i := 0; i < paramsK; i++ {
+		p := polyFromBytes(publicKey[i*paramsPolyBytes : (i+1)*paramsPolyBytes])
+		for j := range p {
+			t[i*paramsN+j] = uint16(p[j])
+		}
+	}
+	vectorBytes := len(dst) - paramsSymBytes
+	if !kemeleonVectorEncode(dst[:vectorBytes], t, pad) {
+		return false
+	}
+	copy(dst[vectorBytes:], publicKey[polyvecBytesSize:])
+	return true
+}
+
+// kemeleonDecodePublicKey decodes a Kemeleon-encoded public key into dst.
+func kemeleonDecodePublicKey(dst, encoded []byte, paramsK int) {
+	t := make([]uint16, paramsK*paramsN)
+	vectorBytes := len(encoded) - paramsSymBytes
+	kemeleonVectorDecode(t, encoded[:vectorBytes])
+	for i := 0; i < paramsK; i++ {
+		var p poly
+		for j := range p {
+			p[j] = int16(t[i*paramsN+j])
+		}
+		polyToBytes(dst[i*paramsPolyBytes:], &p)
+	}
+	copy(dst[paramsK*paramsPolyBytes:], encoded[vectorBytes:])
+}
+
+// kemeleonEncodeCiphertext encodes the ciphertext into dst using
+// randomness from rng, reporting false if the sampled coefficients have
+// no encoding.
+func kemeleonEncodeCiphertext(dst, ciphertext []byte, paramsK int, rng sha3.ShakeHash) bool {
+	du, dv := kemeleonCompressionBits(paramsK)
+	c := make([]uint16, (paramsK+1)*paramsN)
+	kemeleonUnpack(c[:paramsK*paramsN], ciphertext, du)
+	kemeleonUnpack(c[paramsK*paramsN:], ciphertext[indcpaPolyvecCompressedBytes(paramsK):], dv)
+	tables := kemeleonPreimages()
+	for i := range c {
+		d := du
+		if i >= paramsK*paramsN {
+			d = dv
+		}
+		preimages := tables[d][c[i]]
+		c[i] = preimages[kemeleonUniform(rng, len(preimages))]
+	}
+	var pad [1]byte
+	_, _ = rng.Read(pad[:])
+	return kemeleonVectorEncode(dst, c, pad[0])
+}
+
+// kemeleonDecodeCiphertext decodes a Kemeleon-encoded ciphertext into
+// dst by compressing the decoded coefficients.
+func kemeleonDecodeCiphertext(dst, encoded []byte, paramsK int) {
+	a := make([]uint16, (paramsK+1)*paramsN)
+	kemeleonVectorDecode(a, encoded)
+	var u polyvec
+	var v poly
+	for i := 0; i < paramsK; i++ {
+		for j := 0; j < paramsN; j++ {
+			u[i][j] = int16(a[i*paramsN+j])
+		}
+	}
+	for j := 0; j < paramsN; j++ {
+		v[j] = int16(a[paramsK*paramsN+j])
+	}
+	polyvecCompressedSize := indcpaPolyvecCompressedBytes(paramsK)
+	polyvecCompress(dst[:polyvecCompressedSize], &u, paramsK)
+	polyCompress(dst[polyvecCompressedSize:], &v, paramsK)
+}
+
+// kemeleonRandom returns an extendable-output function seeded with 32
+// bytes of system randomness.
+func kemeleonRandom() (sha3.ShakeHash, error) {
+	var seed [paramsSymBytes]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	rng := sha3.NewShake256()
+	_, _ = rng.Write(seed[:])
+	byteopsZeroBytes(seed[:])
+	return rng, nil
+}
+
+// KemeleonKeypair512 generates an ML-KEM-512 key pair whose public key has a
+// Kemeleon encoding, repeating key generation until it does. It returns the
+// private key, the public key in its normal format and its encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonKeypair512() (
+	[Kyber512SKBytes]byte, [Kyber512PKBytes]byte, [Kemeleon512PKBytes]byte, error,
+) {
+	var encoded [Kemeleon512PKBytes]byte
+	for {
+		privateKey, publicKey, err := KemKeypair512()
+		if err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		var pad [1]byte
+		if _, err := rand.Read(pad[:]); err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		if kemeleonEncodePublicKey(encoded[:], publicKey[:], 2, pad[0]) {
+			return privateKey, publicKey, encoded, nil
+		}
+		byteopsZeroBytes(privateKey[:])
+	}
+}
+
+// KemeleonEncodePublicKey512 returns the Kemeleon encoding of an ML-KEM-512
+// public key, or ErrNotEncodable if it has none.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemeleonEncodePublicKey512(publicKey [Kyber512PKBytes]byte) ([Kemeleon512PKBytes]byte, error) {
+	const paramsK = 2
+	var encoded [Kemeleon512PKBytes]byte
+	if !polyvecBytesValid(publicKey[:paramsK*paramsPolyBytes], paramsK) {
+		return encoded, ErrInvalidEncapsulationKey
+	}
+	var pad [1]byte
+	if _, err := rand.Read(pad[:]); err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodePublicKey(encoded[:], publicKey[:], paramsK, pad[0]) {
+		return [Kemeleon512PKBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodePublicKey512 returns the ML-KEM-512 public key in its normal
+// format from its Kemeleon encoding. Every byte string decodes to a valid key.
+func KemeleonDecodePublicKey512(encoded [Kemeleon512PKBytes]byte) [Kyber512PKBytes]byte {
+	var publicKey [Kyber512PKBytes]byte
+	kemeleonDecodePublicKey(publicKey[:], encoded[:], 2)
+	return publicKey
+}
+
+// KemeleonEncrypt512 takes a Kemeleon-encoded ML-KEM-512 public key as input
+// and returns a Kemeleon-encoded ciphertext and a 32-byte shared secret,
+// repeating encapsulation until the ciphertext has an encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncrypt512(encodedPublicKey [Kemeleon512PKBytes]byte) (
+	[Kemeleon512CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var encoded [Kemeleon512CTBytes]byte
+	publicKey := KemeleonDecodePublicKey512(encodedPublicKey)
+	for {
+		ciphertext, sharedSecret, err := KemEncrypt512(publicKey)
+		if err != nil {
+			return encoded, sharedSecret, err
+		}
+		rng, err := kemeleonRandom()
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return encoded, [KyberSSBytes]byte{}, err
+		}
+		if kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 2, rng) {
+			return encoded, sharedSecret, nil
+		}
+		byteopsZeroBytes(sharedSecret[:])
+	}
+}
+
+// KemeleonEncodeCiphertext512 returns a Kemeleon encoding of an ML-KEM-512
+// ciphertext, or ErrNotEncodable if the randomly chosen encoding is
+// rejected. The encoding is randomized, so a later call may succeed, but
+// some ciphertexts have no encoding at all; KemeleonEncrypt repeats the
+// encapsulation instead.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncodeCiphertext512(ciphertext [Kyber512CTBytes]byte) ([Kemeleon512CTBytes]byte, error) {
+	var encoded [Kemeleon512CTBytes]byte
+	rng, err := kemeleonRandom()
+	if err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 2, rng) {
+		return [Kemeleon512CTBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodeCiphertext512 returns the ML-KEM-512 ciphertext in its normal
+// format from its Kemeleon encoding.
+func KemeleonDecodeCiphertext512(encoded [Kemeleon512CTBytes]byte) [Kyber512CTBytes]byte {
+	var ciphertext [Kyber512CTBytes]byte
+	kemeleonDecodeCiphertext(ciphertext[:], encoded[:], 2)
+	return ciphertext
+}
+
+// KemeleonDecrypt512 takes a Kemeleon-encoded ciphertext (from KemeleonEncrypt512)
+// and a private key (from KemeleonKeypair512) and returns a 32-byte shared secret.
+// An accompanying error is returned if the private key is invalid.
+func KemeleonDecrypt512(
+	encodedCiphertext [Kemeleon512CTBytes]byte,
+	privateKey [Kyber512SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	return KemDecrypt512(KemeleonDecodeCiphertext512(encodedCiphertext), privateKey)
+}
+
+// KemeleonKeypair768 generates an ML-KEM-768 key pair whose public key has a
+// Kemeleon encoding, repeating key generation until it does. It returns the
+// private key, the public key in its normal format and its encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonKeypair768() (
+	[Kyber768SKBytes]byte, [Kyber768PKBytes]byte, [Kemeleon768PKBytes]byte, error,
+) {
+	var encoded [Kemeleon768PKBytes]byte
+	for {
+		privateKey, publicKey, err := KemKeypair768()
+		if err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		var pad [1]byte
+		if _, err := rand.Read(pad[:]); err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		if kemeleonEncodePublicKey(encoded[:], publicKey[:], 3, pad[0]) {
+			return privateKey, publicKey, encoded, nil
+		}
+		byteopsZeroBytes(privateKey[:])
+	}
+}
+
+// KemeleonEncodePublicKey768 returns the Kemeleon encoding of an ML-KEM-768
+// public key, or ErrNotEncodable if it has none.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemeleonEncodePublicKey768(publicKey [Kyber768PKBytes]byte) ([Kemeleon768PKBytes]byte, error) {
+	const paramsK = 3
+	var encoded [Kemeleon768PKBytes]byte
+	if !polyvecBytesValid(publicKey[:paramsK*paramsPolyBytes], paramsK) {
+		return encoded, ErrInvalidEncapsulationKey
+	}
+	var pad [1]byte
+	if _, err := rand.Read(pad[:]); err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodePublicKey(encoded[:], publicKey[:], paramsK, pad[0]) {
+		return [Kemeleon768PKBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodePublicKey768 returns the ML-KEM-768 public key in its normal
+// format from its Kemeleon encoding. Every byte string decodes to a valid key.
+func KemeleonDecodePublicKey768(encoded [Kemeleon768PKBytes]byte) [Kyber768PKBytes]byte {
+	var publicKey [Kyber768PKBytes]byte
+	kemeleonDecodePublicKey(publicKey[:], encoded[:], 3)
+	return publicKey
+}
+
+// KemeleonEncrypt768 takes a Kemeleon-encoded ML-KEM-768 public key as input
+// and returns a Kemeleon-encoded ciphertext and a 32-byte shared secret,
+// repeating encapsulation until the ciphertext has an encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncrypt768(encodedPublicKey [Kemeleon768PKBytes]byte) (
+	[Kemeleon768CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var encoded [Kemeleon768CTBytes]byte
+	publicKey := KemeleonDecodePublicKey768(encodedPublicKey)
+	for {
+		ciphertext, sharedSecret, err := KemEncrypt768(publicKey)
+		if err != nil {
+			return encoded, sharedSecret, err
+		}
+		rng, err := kemeleonRandom()
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return encoded, [KyberSSBytes]byte{}, err
+		}
+		if kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 3, rng) {
+			return encoded, sharedSecret, nil
+		}
+		byteopsZeroBytes(sharedSecret[:])
+	}
+}
+
+// KemeleonEncodeCiphertext768 returns a Kemeleon encoding of an ML-KEM-768
+// ciphertext, or ErrNotEncodable if the randomly chosen encoding is
+// rejected. The encoding is randomized, so a later call may succeed, but
+// some ciphertexts have no encoding at all; KemeleonEncrypt repeats the
+// encapsulation instead.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncodeCiphertext768(ciphertext [Kyber768CTBytes]byte) ([Kemeleon768CTBytes]byte, error) {
+	var encoded [Kemeleon768CTBytes]byte
+	rng, err := kemeleonRandom()
+	if err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 3, rng) {
+		return [Kemeleon768CTBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodeCiphertext768 returns the ML-KEM-768 ciphertext in its normal
+// format from its Kemeleon encoding.
+func KemeleonDecodeCiphertext768(encoded [Kemeleon768CTBytes]byte) [Kyber768CTBytes]byte {
+	var ciphertext [Kyber768CTBytes]byte
+	kemeleonDecodeCiphertext(ciphertext[:], encoded[:], 3)
+	return ciphertext
+}
+
+// KemeleonDecrypt768 takes a Kemeleon-encoded ciphertext (from KemeleonEncrypt768)
+// and a private key (from KemeleonKeypair768) and returns a 32-byte shared secret.
+// An accompanying error is returned if the private key is invalid.
+func KemeleonDecrypt768(
+	encodedCiphertext [Kemeleon768CTBytes]byte,
+	privateKey [Kyber768SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	return KemDecrypt768(KemeleonDecodeCiphertext768(encodedCiphertext), privateKey)
+}
+
+// KemeleonKeypair1024 generates an ML-KEM-1024 key pair whose public key has a
+// Kemeleon encoding, repeating key generation until it does. It returns the
+// private key, the public key in its normal format and its encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonKeypair1024() (
+	[Kyber1024SKBytes]byte, [Kyber1024PKBytes]byte, [Kemeleon1024PKBytes]byte, error,
+) {
+	var encoded [Kemeleon1024PKBytes]byte
+	for {
+		privateKey, publicKey, err := KemKeypair1024()
+		if err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		var pad [1]byte
+		if _, err := rand.Read(pad[:]); err != nil {
+			return privateKey, publicKey, encoded, err
+		}
+		if kemeleonEncodePublicKey(encoded[:], publicKey[:], 4, pad[0]) {
+			return privateKey, publicKey, encoded, nil
+		}
+		byteopsZeroBytes(privateKey[:])
+	}
+}
+
+// KemeleonEncodePublicKey1024 returns the Kemeleon encoding of an ML-KEM-1024
+// public key, or ErrNotEncodable if it has none.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system or if the key is invalid.
+func KemeleonEncodePublicKey1024(publicKey [Kyber1024PKBytes]byte) ([Kemeleon1024PKBytes]byte, error) {
+	const paramsK = 4
+	var encoded [Kemeleon1024PKBytes]byte
+	if !polyvecBytesValid(publicKey[:paramsK*paramsPolyBytes], paramsK) {
+		return encoded, ErrInvalidEncapsulationKey
+	}
+	var pad [1]byte
+	if _, err := rand.Read(pad[:]); err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodePublicKey(encoded[:], publicKey[:], paramsK, pad[0]) {
+		return [Kemeleon1024PKBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodePublicKey1024 returns the ML-KEM-1024 public key in its normal
+// format from its Kemeleon encoding. Every byte string decodes to a valid key.
+func KemeleonDecodePublicKey1024(encoded [Kemeleon1024PKBytes]byte) [Kyber1024PKBytes]byte {
+	var publicKey [Kyber1024PKBytes]byte
+	kemeleonDecodePublicKey(publicKey[:], encoded[:], 4)
+	return publicKey
+}
+
+// KemeleonEncrypt1024 takes a Kemeleon-encoded ML-KEM-1024 public key as input
+// and returns a Kemeleon-encoded ciphertext and a 32-byte shared secret,
+// repeating encapsulation until the ciphertext has an encoding.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncrypt1024(encodedPublicKey [Kemeleon1024PKBytes]byte) (
+	[Kemeleon1024CTBytes]byte, [KyberSSBytes]byte, error,
+) {
+	var encoded [Kemeleon1024CTBytes]byte
+	publicKey := KemeleonDecodePublicKey1024(encodedPublicKey)
+	for {
+		ciphertext, sharedSecret, err := KemEncrypt1024(publicKey)
+		if err != nil {
+			return encoded, sharedSecret, err
+		}
+		rng, err := kemeleonRandom()
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return encoded, [KyberSSBytes]byte{}, err
+		}
+		if kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 4, rng) {
+			return encoded, sharedSecret, nil
+		}
+		byteopsZeroBytes(sharedSecret[:])
+	}
+}
+
+// KemeleonEncodeCiphertext1024 returns a Kemeleon encoding of an ML-KEM-1024
+// ciphertext, or ErrNotEncodable if the randomly chosen encoding is
+// rejected. The encoding is randomized, so a later call may succeed, but
+// some ciphertexts have no encoding at all; KemeleonEncrypt repeats the
+// encapsulation instead.
+// An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemeleonEncodeCiphertext1024(ciphertext [Kyber1024CTBytes]byte) ([Kemeleon1024CTBytes]byte, error) {
+	var encoded [Kemeleon1024CTBytes]byte
+	rng, err := kemeleonRandom()
+	if err != nil {
+		return encoded, err
+	}
+	if !kemeleonEncodeCiphertext(encoded[:], ciphertext[:], 4, rng) {
+		return [Kemeleon1024CTBytes]byte{}, ErrNotEncodable
+	}
+	return encoded, nil
+}
+
+// KemeleonDecodeCiphertext1024 returns the ML-KEM-1024 ciphertext in its normal
+// format from its Kemeleon encoding.
+func KemeleonDecodeCiphertext1024(encoded [Kemeleon1024CTBytes]byte) [Kyber1024CTBytes]byte {
+	var ciphertext [Kyber1024CTBytes]byte
+	kemeleonDecodeCiphertext(ciphertext[:], encoded[:], 4)
+	return ciphertext
+}
+
+// KemeleonDecrypt1024 takes a Kemeleon-encoded ciphertext (from KemeleonEncrypt1024)
+// and a private key (from KemeleonKeypair1024) and returns a 32-byte shared secret.
+// An accompanying error is returned if the private key is invalid.
+func KemeleonDecrypt1024(
+	encodedCiphertext [Kemeleon1024CTBytes]byte,
+	privateKey [Kyber1024SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	return KemDecrypt1024(KemeleonDecodeCiphertext1024(encodedCiphertext), privateKey)
+}
@@ -0,0 +1,388 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestKemeleonSizes(t *testing.T) {
+	q := big.NewInt(int64(paramsQ))
+	for _, tc := range []struct {
+		digits int
+		bytes  int
+	}{
+		{2 * paramsN, Kemeleon512PKBytes - paramsSymBytes},
+		{3 * paramsN, Kemeleon768PKBytes - paramsSymBytes},
+		{4 * paramsN, Kemeleon1024PKBytes - paramsSymBytes},
+		{3 * paramsN, Kemeleon512CTBytes},
+		{4 * paramsN, Kemeleon768CTBytes},
+		{5 * paramsN, Kemeleon1024CTBytes},
+	} {
+		bound := new(big.Int).Exp(q, big.NewInt(int64(tc.digits)), nil)
+		bits := new(big.Int).Sub(bound, big.NewInt(1)).BitLen() - 1
+		if kemeleonBits(tc.digits) != bits {
+			t.Fatalf("%d digits: got %d bits, want %d", tc.digits, kemeleonBits(tc.digits), bits)
+		}
+		if (bits+7)/8 != tc.bytes {
+			t.Fatalf("%d digits: got %d bytes, want %d", tc.digits, tc.bytes, (bits+7)/8)
+		}
+	}
+}
+
+func TestKemeleonCompress(t *testing.T) {
+	// The preimage tables must agree with the compression used for
+	// ciphertexts, including its reduction modulo 2^d.
+	for _, paramsK := range []int{2, 4} {
+		du, dv := kemeleonCompressionBits(paramsK)
+		var u polyvec
+		var v poly
+		uBytes := make([]byte, indcpaPolyvecCompressedBytes(paramsK))
+		vBytes := make([]byte, paramsPolyCompressedBytesK1024)
+		uValues := make([]uint16, paramsK*paramsN)
+		vValues := make([]uint16, paramsN)
+		for base := 0; base < paramsQ; base += paramsN {
+			for j := 0; j < paramsN; j++ {
+				x := int16((base + j) % paramsQ)
+				u[0][j], v[j] = x, x
+			}
+			polyvecCompress(uBytes, &u, paramsK)
+			polyCompress(vBytes, &v, paramsK)
+			kemeleonUnpack(uValues, uBytes, du)
+			kemeleonUnpack(vValues, vBytes, dv)
+			for j := 0; j < paramsN; j++ {
+				x := uint16((base + j) % paramsQ)
+				if uValues[j] != kemeleonCompress(x, du) || vValues[j] != kemeleonCompress(x, dv) {
+					t.Fatalf("K=%d: compression of %d differs", paramsK, x)
+				}
+			}
+		}
+		for _, d := range []int{du, dv} {
+			total := 0
+			for c, preimages := range kemeleonPreimages()[d] {
+				if len(preimages) == 0 {
+					t.Fatalf("d=%d: %d has no preimage", d, c)
+				}
+				total += len(preimages)
+			}
+			if total != paramsQ {
+				t.Fatalf("d=%d: preimages cover %d values", d, total)
+			}
+		}
+	}
+}
+
+func TestKemeleonVector(t *testing.T) {
+	a := make([]uint16, 3*paramsN)
+	for i := range a {
+		a[i] = uint16((i * 1237) % paramsQ)
+	}
+	// Keep the integer below 2^bits with a zero top digit.
+	a[len(a)-1] = 0
+	encoded := make([]byte, Kemeleon768PKBytes-paramsSymBytes)
+	if !kemeleonVectorEncode(encoded, a, 0xff) {
+		t.Fatal("vector was rejected")
+	}
+	decoded := make([]uint16, len(a))
+	kemeleonVectorDecode(decoded, encoded)
+	for i := range a {
+		if a[i] != decoded[i] {
+			t.Fatalf("digit %d: got %d, want %d", i, decoded[i], a[i])
+		}
+	}
+	for i := range a {
+		a[i] = uint16(paramsQ - 1)
+	}
+	if kemeleonVectorEncode(encoded, a, 0) {
+		t.Fatal("vector above the bound was accepted")
+	}
+}
+
+func TestKemeleon512(t *testing.T) {
+	privateKey, publicKey, encodedPublicKey, err := KemeleonKeypair512()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodePublicKey512(encodedPublicKey) != publicKey {
+		t.Fatal("public key does not round-trip")
+	}
+	encodedCiphertext, ssA, err := KemeleonEncrypt512(encodedPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemeleonDecrypt512(encodedCiphertext, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	var ciphertext [Kyber512CTBytes]byte
+	var ssC [KyberSSBytes]byte
+	for {
+		ciphertext, ssC, err = KemEncrypt512(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedCiphertext, err = KemeleonEncodeCiphertext512(ciphertext)
+		if !errors.Is(err, ErrNotEncodable) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodeCiphertext512(encodedCiphertext) != ciphertext {
+		t.Fatal("ciphertext does not round-trip")
+	}
+	if ssB, _ = KemeleonDecrypt512(encodedCiphertext, privateKey); ssB != ssC {
+		t.Fatal("shared secrets differ")
+	}
+}
+
+func TestKemeleon768(t *testing.T) {
+	privateKey, publicKey, encodedPublicKey, err := KemeleonKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodePublicKey768(encodedPublicKey) != publicKey {
+		t.Fatal("public key does not round-trip")
+	}
+	if reencoded, err := KemeleonEncodePublicKey768(publicKey); err != nil ||
+		KemeleonDecodePublicKey768(reencoded) != publicKey {
+		t.Fatal("public key does not re-encode")
+	}
+	encodedCiphertext, ssA, err := KemeleonEncrypt768(encodedPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemeleonDecrypt768(encodedCiphertext, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	var ciphertext [Kyber768CTBytes]byte
+	var ssC [KyberSSBytes]byte
+	for {
+		ciphertext, ssC, err = KemEncrypt768(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedCiphertext, err = KemeleonEncodeCiphertext768(ciphertext)
+		if !errors.Is(err, ErrNotEncodable) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodeCiphertext768(encodedCiphertext) != ciphertext {
+		t.Fatal("ciphertext does not round-trip")
+	}
+	if ssB, _ = KemeleonDecrypt768(encodedCiphertext, privateKey); ssB != ssC {
+		t.Fatal("shared secrets differ")
+	}
+	// Any byte string decodes to a valid public key.
+	var garbage [Kemeleon768PKBytes]byte
+	for i := range garbage {
+		garbage[i] = 0xff
+	}
+	if _, _, err := KemEncrypt768(KemeleonDecodePublicKey768(garbage)); err != nil {
+		t.Fatal(err)
+	}
+	var invalid [Kyber768PKBytes]byte
+	for i := range invalid {
+		invalid[i] = 0xff
+	}
+	if _, err := KemeleonEncodePublicKey768(invalid); !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidEncapsulationKey", err)
+	}
+}
+
+func TestKemeleon1024(t *testing.T) {
+	privateKey, publicKey, encodedPublicKey, err := KemeleonKeypair1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodePublicKey1024(encodedPublicKey) != publicKey {
+		t.Fatal("public key does not round-trip")
+	}
+	encodedCiphertext, ssA, err := KemeleonEncrypt1024(encodedPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemeleonDecrypt1024(encodedCiphertext, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssA != ssB {
+		t.Fatal("shared secrets differ")
+	}
+	var ciphertext [Kyber1024CTBytes]byte
+	var ssC [KyberSSBytes]byte
+	for {
+		ciphertext, ssC, err = KemEncrypt1024(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedCiphertext, err = KemeleonEncodeCiphertext1024(ciphertext)
+		if !errors.Is(err, ErrNotEncodable) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KemeleonDecodeCiphertext1024(encodedCiphertext) != ciphertext {
+		t.Fatal("ciphertext does not round-trip")
+	}
+	if ssB, _ = KemeleonDecrypt1024(encodedCiphertext, privateKey); ssB != ssC {
+		t.Fatal("shared secrets differ")
+	}
+}
+
+// uniformityDeviation describes how the samples deviate from uniform
+// bytes, or returns the empty string if they pass: the frequencies of the
+// bytes and of the d-bit values packed in them must pass a chi-square
+// test and no bit position may be biased by more than six standard
+// deviations.
+func uniformityDeviation(samples [][]byte, d int) string {
+	var counts [256]float64
+	ones := make([]int, 8*len(samples[0]))
+	values := make([]uint16, 8*len(samples[0])/d)
+	counts2 := make([]float64, 1<<d)
+	for _, s := range samples {
+		for i, b := range s {
+			counts[b]++
+			for j := 0; j < 8; j++ {
+				ones[8*i+j] += int(b>>j) & 1
+			}
+		}
+		kemeleonUnpack(values, s, d)
+		for _, v := range values {
+			counts2[v]++
+		}
+	}
+	if chi2 := chiSquare(counts[:]); chi2 > chiSquareBound(len(counts)) {
+		return fmt.Sprintf("byte chi-square %.1f", chi2)
+	}
+	// The normal formats pack d-bit values, whose distribution is skewed
+	// by compression or bounded by Q, which the byte counts barely show.
+	if chi2 := chiSquare(counts2); chi2 > chiSquareBound(len(counts2)) {
+		return fmt.Sprintf("%d-bit chi-square %.1f", d, chi2)
+	}
+	n := float64(len(samples))
+	for i, c := range ones {
+		if math.Abs(float64(c)-n/2) > 6*math.Sqrt(n/4) {
+			return fmt.Sprintf("bit %d set in %d of %d samples", i, c, len(samples))
+		}
+	}
+	return ""
+}
+
+// chiSquare returns the chi-square statistic of counts against the
+// uniform distribution.
+func chiSquare(counts []float64) float64 {
+	total := 0.0
+	for _, c := range counts {
+		total += c
+	}
+	expected := total / float64(len(counts))
+	chi2 := 0.0
+	for _, c := range counts {
+		chi2 += (c - expected) * (c - expected) / expected
+	}
+	return chi2
+}
+
+// chiSquareBound returns the mean of the chi-square distribution over
+// the given number of bins plus eight standard deviations.
+func chiSquareBound(bins int) float64 {
+	df := float64(bins - 1)
+	return df + 8*math.Sqrt(2*df)
+}
+
+// kemeleonSample returns a public key, its Kemeleon encoding, and a
+// ciphertext to it with its Kemeleon encoding.
+type kemeleonSample func() (publicKey, encodedPublicKey, ciphertext, encodedCiphertext []byte, err error)
+
+func kemeleonSample512() ([]byte, []byte, []byte, []byte, error) {
+	_, publicKey, encodedPublicKey, err := KemeleonKeypair512()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	encodedCiphertext, _, err := KemeleonEncrypt512(encodedPublicKey)
+	ciphertext := KemeleonDecodeCiphertext512(encodedCiphertext)
+	return publicKey[:], encodedPublicKey[:], ciphertext[:], encodedCiphertext[:], err
+}
+
+func kemeleonSample768() ([]byte, []byte, []byte, []byte, error) {
+	_, publicKey, encodedPublicKey, err := KemeleonKeypair768()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	encodedCiphertext, _, err := KemeleonEncrypt768(encodedPublicKey)
+	ciphertext := KemeleonDecodeCiphertext768(encodedCiphertext)
+	return publicKey[:], encodedPublicKey[:], ciphertext[:], encodedCiphertext[:], err
+}
+
+func kemeleonSample1024() ([]byte, []byte, []byte, []byte, error) {
+	_, publicKey, encodedPublicKey, err := KemeleonKeypair1024()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	encodedCiphertext, _, err := KemeleonEncrypt1024(encodedPublicKey)
+	ciphertext := KemeleonDecodeCiphertext1024(encodedCiphertext)
+	return publicKey[:], encodedPublicKey[:], ciphertext[:], encodedCiphertext[:], err
+}
+
+func TestKemeleonUniformity(t *testing.T) {
+	samples := 400
+	if testing.Short() {
+		samples = 50
+	}
+	for _, tc := range []struct {
+		name    string
+		paramsK int
+		sample  kemeleonSample
+	}{
+		{"ML-KEM-512", 2, kemeleonSample512},
+		{"ML-KEM-768", 3, kemeleonSample768},
+		{"ML-KEM-1024", 4, kemeleonSample1024},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var publicKeys, encodedPublicKeys, ciphertexts, encodedCiphertexts [][]byte
+			for i := 0; i < samples; i++ {
+				publicKey, encodedPublicKey, ciphertext, encodedCiphertext, err := tc.sample()
+				if err != nil {
+					t.Fatal(err)
+				}
+				publicKeys = append(publicKeys, publicKey)
+				encodedPublicKeys = append(encodedPublicKeys, encodedPublicKey)
+				ciphertexts = append(ciphertexts, ciphertext)
+				encodedCiphertexts = append(encodedCiphertexts, encodedCiphertext)
+			}
+			du, _ := kemeleonCompressionBits(tc.paramsK)
+			if d := uniformityDeviation(encodedPublicKeys, 12); d != "" {
+				t.Errorf("public keys: %s", d)
+			}
+			if d := uniformityDeviation(encodedCiphertexts, du); d != "" {
+				t.Errorf("ciphertexts: %s", d)
+			}
+			// The same check distinguishes the normal formats.
+			if uniformityDeviation(publicKeys, 12) == "" {
+				t.Error("normal public keys are not distinguished from uniform")
+			}
+			if uniformityDeviation(ciphertexts, du) == "" {
+				t.Error("normal ciphertexts are not distinguished from uniform")
+			}
+		})
+	}
+}
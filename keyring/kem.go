@@ -0,0 +1,75 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package keyring
+
+// Tag prefixes a ciphertext with the ID of its recipient key.
+func Tag(id ID, ciphertext []byte) []byte {
+	return append(id[:], ciphertext...)
+}
+
+// Encapsulate encapsulates to the current key and returns the
+// ciphertext tagged with the key ID, and the shared secret.
+func (k *Keyring) Encapsulate() ([]byte, []byte, error) {
+	current, err := k.Current()
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext, sharedSecret, err := current.KEM.params().Encapsulate(current.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Tag(current.ID, ciphertext), sharedSecret, nil
+}
+
+// Decapsulate returns the shared secret of a ciphertext, tagged or not.
+// A tagged ciphertext is decapsulated with the key it names, which must
+// be usable. An untagged ciphertext is decapsulated with every usable
+// key of its parameter set, in constant time: if none accepts it, the
+// result is an implicit rejection value rather than an error, just as
+// for a tagged ciphertext that was modified.
+func (k *Keyring) Decapsulate(ciphertext []byte) ([]byte, error) {
+	kem, tagged := kemOfCiphertext(len(ciphertext))
+	if kem == 0 {
+		return nil, ErrInvalidCiphertext
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	if tagged {
+		e := k.find(ID(ciphertext[:IDSize]))
+		if e == nil || e.KEM != kem {
+			return nil, ErrUnknownKey
+		}
+		if !e.usable(now) {
+			return nil, ErrKeyNotUsable
+		}
+		return kem.params().Decapsulate(ciphertext[IDSize:], e.private)
+	}
+	var privateKeys [][]byte
+	for _, e := range k.keys {
+		if e.KEM == kem && e.usable(now) {
+			privateKeys = append(privateKeys, e.private)
+		}
+	}
+	if len(privateKeys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return kem.params().DecapsulateTrial(ciphertext, privateKeys)
+}
+
+// kemOfCiphertext returns the parameter set of a ciphertext length and
+// whether the ciphertext is tagged, or zero. The six lengths are
+// distinct.
+func kemOfCiphertext(n int) (KEM, bool) {
+	for _, kem := range []KEM{MLKEM512, MLKEM768, MLKEM1024} {
+		_, _, ctSize := kem.params().Sizes()
+		switch n {
+		case ctSize:
+			return kem, false
+		case IDSize + ctSize:
+			return kem, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,302 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package keyring holds several ML-KEM decapsulation keys at once and
+// routes incoming ciphertexts to the right one.
+//
+// Keys are identified by their key ID, H(ek), which every ML-KEM
+// decapsulation key already embeds. Each key has a status and an
+// optional validity window: the current key is used for encapsulation,
+// and staged, current and previous keys that are within their windows
+// decapsulate. Encapsulate tags its ciphertexts with the recipient key
+// ID, as ID || ciphertext, and Decapsulate routes tagged ciphertexts by
+// ID. Untagged ciphertexts are decapsulated with every usable key of
+// their parameter set through KemDecryptTrial, which selects the
+// accepting key in constant time and otherwise returns an implicit
+// rejection value, so that a ciphertext no key accepts is as
+// indistinguishable from a valid one as under KemDecrypt.
+package keyring
+
+import (
+	"crypto/subtle"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidKey is returned when a private key has the wrong size for
+	// its KEM, its embedded key ID does not match its encapsulation key,
+	// or the KEM is unknown.
+	ErrInvalidKey = errors.New("keyring: invalid key")
+
+	// ErrDuplicateKey is returned when adding a key that is already in
+	// the keyring.
+	ErrDuplicateKey = errors.New("keyring: key already present")
+
+	// ErrUnknownKey is returned when a key ID is not in the keyring, or
+	// no usable key can decapsulate a ciphertext.
+	ErrUnknownKey = errors.New("keyring: unknown key")
+
+	// ErrKeyNotUsable is returned when a tagged ciphertext names a key
+	// that is retired or outside its validity window.
+	ErrKeyNotUsable = errors.New("keyring: key not usable")
+
+	// ErrNoCurrentKey is returned when encapsulating without a usable
+	// current key.
+	ErrNoCurrentKey = errors.New("keyring: no current key")
+
+	// ErrInvalidCiphertext is returned when a ciphertext has a length
+	// that matches no parameter set, tagged or not.
+	ErrInvalidCiphertext = errors.New("keyring: invalid ciphertext")
+)
+
+// KEM identifies the ML-KEM parameter set of a key.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// IDSize is the size of key IDs.
+const IDSize = 32
+
+// ID identifies a key by H(ek), the SHA3-256 hash of its encapsulation
+// key.
+type ID [IDSize]byte
+
+// Status is the role of a key in its rotation.
+type Status int
+
+// Key statuses.
+const (
+	// StatusStaged keys decapsulate, so that senders may start using a
+	// published key before it becomes current.
+	StatusStaged Status = iota + 1
+	// StatusCurrent marks the key used for encapsulation. A keyring has
+	// at most one current key.
+	StatusCurrent
+	// StatusPrevious keys decapsulate but are not used for encapsulation.
+	StatusPrevious
+	// StatusRetired keys neither encapsulate nor decapsulate.
+	StatusRetired
+)
+
+// KeyInfo describes a key in a keyring.
+type KeyInfo struct {
+	ID        ID
+	KEM       KEM
+	Status    Status
+	NotBefore time.Time
+	NotAfter  time.Time
+	PublicKey []byte
+}
+
+type key struct {
+	KeyInfo
+	private []byte
+}
+
+// Keyring is a set of decapsulation keys. It is safe for concurrent use.
+type Keyring struct {
+	mu   sync.RWMutex
+	keys []*key
+	now  func() time.Time
+}
+
+// New returns an empty keyring.
+func New() *Keyring {
+	return &Keyring{now: time.Now}
+}
+
+// Generate generates a key pair for the given KEM and adds it to the
+// keyring with the given status.
+func (k *Keyring) Generate(kem KEM, status Status) (ID, error) {
+	privateKey, _, err := kem.params().Keypair()
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return ID{}, ErrInvalidKey
+	}
+	if err != nil {
+		return ID{}, err
+	}
+	defer mlkem.ZeroBytes(privateKey)
+	return k.Add(kem, privateKey, status)
+}
+
+// Add adds a copy of a private key to the keyring with the given status
+// and no validity window, and returns its key ID. Adding a current key
+// demotes the previous current key to StatusPrevious.
+func (k *Keyring) Add(kem KEM, privateKey []byte, status Status) (ID, error) {
+	_, skSize, _ := kem.params().Sizes()
+	if skSize == 0 || len(privateKey) != skSize || !status.valid() {
+		return ID{}, ErrInvalidKey
+	}
+	publicKey := kem.params().PublicKey(privateKey)
+	id := ID(kem.params().KeyHash(privateKey))
+	if computed := sha3.Sum256(publicKey); subtle.ConstantTimeCompare(computed[:], id[:]) != 1 {
+		return ID{}, ErrInvalidKey
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.find(id) != nil {
+		return ID{}, ErrDuplicateKey
+	}
+	if status == StatusCurrent {
+		k.demoteCurrent()
+	}
+	k.keys = append(k.keys, &key{
+		KeyInfo: KeyInfo{ID: id, KEM: kem, Status: status, PublicKey: slices.Clone(publicKey)},
+		private: slices.Clone(privateKey),
+	})
+	return id, nil
+}
+
+// Remove removes a key from the keyring and zeroizes its private key.
+func (k *Keyring) Remove(id ID) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for i, e := range k.keys {
+		if e.ID == id {
+			mlkem.ZeroBytes(e.private)
+			k.keys = slices.Delete(k.keys, i, i+1)
+			return nil
+		}
+	}
+	return ErrUnknownKey
+}
+
+// SetStatus changes the status of a key. Making a key current demotes
+// the previous current key to StatusPrevious.
+func (k *Keyring) SetStatus(id ID, status Status) error {
+	if !status.valid() {
+		return ErrInvalidKey
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	e := k.find(id)
+	if e == nil {
+		return ErrUnknownKey
+	}
+	if status == StatusCurrent && e.Status != StatusCurrent {
+		k.demoteCurrent()
+	}
+	e.Status = status
+	return nil
+}
+
+// Rotate makes a key current, demoting the previous current key to
+// StatusPrevious so that it keeps decapsulating.
+func (k *Keyring) Rotate(id ID) error {
+	return k.SetStatus(id, StatusCurrent)
+}
+
+// SetValidity sets the validity window of a key. A zero notBefore or
+// notAfter leaves that side of the window open.
+func (k *Keyring) SetValidity(id ID, notBefore, notAfter time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	e := k.find(id)
+	if e == nil {
+		return ErrUnknownKey
+	}
+	e.NotBefore, e.NotAfter = notBefore, notAfter
+	return nil
+}
+
+// Info returns the description of a key.
+func (k *Keyring) Info(id ID) (KeyInfo, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	e := k.find(id)
+	if e == nil {
+		return KeyInfo{}, ErrUnknownKey
+	}
+	return e.info(), nil
+}
+
+// Keys returns the descriptions of all keys, in the order they were
+// added.
+func (k *Keyring) Keys() []KeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	infos := make([]KeyInfo, len(k.keys))
+	for i, e := range k.keys {
+		infos[i] = e.info()
+	}
+	return infos
+}
+
+// Current returns the description of the current key, if it is usable.
+func (k *Keyring) Current() (KeyInfo, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	now := k.now()
+	for _, e := range k.keys {
+		if e.Status == StatusCurrent && e.usable(now) {
+			return e.info(), nil
+		}
+	}
+	return KeyInfo{}, ErrNoCurrentKey
+}
+
+func (e *key) info() KeyInfo {
+	info := e.KeyInfo
+	info.PublicKey = slices.Clone(e.PublicKey)
+	return info
+}
+
+// usable reports whether the key may decapsulate at the given time.
+func (e *key) usable(now time.Time) bool {
+	if e.Status == StatusRetired {
+		return false
+	}
+	if !e.NotBefore.IsZero() && now.Before(e.NotBefore) {
+		return false
+	}
+	return e.NotAfter.IsZero() || !now.After(e.NotAfter)
+}
+
+// find returns the key with the given ID, with k.mu held.
+func (k *Keyring) find(id ID) *key {
+	for _, e := range k.keys {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// demoteCurrent demotes the current key, with k.mu held.
+func (k *Keyring) demoteCurrent() {
+	for _, e := range k.keys {
+		if e.Status == StatusCurrent {
+			e.Status = StatusPrevious
+		}
+	}
+}
+
+func (s Status) valid() bool {
+	return s >= StatusStaged && s <= StatusRetired
+}
@@ -0,0 +1,204 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+func generate(t *testing.T, k *Keyring, kem KEM, status Status) ID {
+	t.Helper()
+	id, err := k.Generate(kem, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestRotation(t *testing.T) {
+	k := New()
+	if _, _, err := k.Encapsulate(); !errors.Is(err, ErrNoCurrentKey) {
+		t.Fatalf("got %v, want ErrNoCurrentKey", err)
+	}
+	first := generate(t, k, MLKEM768, StatusCurrent)
+	ct1, ss1, err := k.Encapsulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ct1[:IDSize], first[:]) {
+		t.Fatal("ciphertext is not tagged with the current key ID")
+	}
+	second := generate(t, k, MLKEM1024, StatusStaged)
+	if err := k.Rotate(second); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := k.Info(first); info.Status != StatusPrevious {
+		t.Fatalf("old current key has status %d", info.Status)
+	}
+	ct2, ss2, err := k.Encapsulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ct2[:IDSize], second[:]) || len(ct2) != IDSize+kyberk2so.Kyber1024CTBytes {
+		t.Fatal("encapsulation does not use the new current key")
+	}
+	for _, tc := range []struct {
+		ct, ss []byte
+	}{{ct1, ss1}, {ct2, ss2}} {
+		got, err := k.Decapsulate(tc.ct)
+		if err != nil || !bytes.Equal(got, tc.ss) {
+			t.Fatal("tagged ciphertext does not decapsulate")
+		}
+		got, err = k.Decapsulate(tc.ct[IDSize:])
+		if err != nil || !bytes.Equal(got, tc.ss) {
+			t.Fatal("untagged ciphertext does not decapsulate")
+		}
+	}
+	if err := k.SetStatus(first, StatusRetired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Decapsulate(ct1); !errors.Is(err, ErrKeyNotUsable) {
+		t.Fatalf("retired key: got %v, want ErrKeyNotUsable", err)
+	}
+	if _, err := k.Decapsulate(ct1[IDSize:]); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("retired key: got %v, want ErrUnknownKey", err)
+	}
+	if err := k.Remove(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Decapsulate(ct1); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("removed key: got %v, want ErrUnknownKey", err)
+	}
+	if len(k.Keys()) != 1 {
+		t.Fatal("removed key is still listed")
+	}
+}
+
+func TestTrialDecapsulation(t *testing.T) {
+	k := New()
+	ids := []ID{
+		generate(t, k, MLKEM768, StatusPrevious),
+		generate(t, k, MLKEM768, StatusCurrent),
+		generate(t, k, MLKEM768, StatusStaged),
+	}
+	for _, id := range ids {
+		info, _ := k.Info(id)
+		ct, ss, err := kyberk2so.KemEncrypt768([kyberk2so.Kyber768PKBytes]byte(info.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := k.Decapsulate(ct[:])
+		if err != nil || !bytes.Equal(got, ss[:]) {
+			t.Fatal("untagged ciphertext does not decapsulate")
+		}
+		got, err = k.Decapsulate(Tag(id, ct[:]))
+		if err != nil || !bytes.Equal(got, ss[:]) {
+			t.Fatal("tagged ciphertext does not decapsulate")
+		}
+	}
+	// A ciphertext for no key in the keyring yields an implicit rejection
+	// value, not an error.
+	other := New()
+	generate(t, other, MLKEM768, StatusCurrent)
+	ct, ss, err := other.Encapsulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := k.Decapsulate(ct[IDSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, ss) {
+		t.Fatal("foreign ciphertext was accepted")
+	}
+	if _, err := k.Decapsulate(ct); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("foreign tag: got %v, want ErrUnknownKey", err)
+	}
+	if _, err := k.Decapsulate(ct[1:]); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Fatalf("got %v, want ErrInvalidCiphertext", err)
+	}
+	// No key of the parameter set.
+	if _, err := k.Decapsulate(make([]byte, kyberk2so.Kyber512CTBytes)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestValidity(t *testing.T) {
+	k := New()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	k.now = func() time.Time { return now }
+	id := generate(t, k, MLKEM512, StatusCurrent)
+	ct, ss, err := k.Encapsulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetValidity(id, now.Add(time.Hour), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := k.Encapsulate(); !errors.Is(err, ErrNoCurrentKey) {
+		t.Fatalf("not yet valid: got %v, want ErrNoCurrentKey", err)
+	}
+	if _, err := k.Decapsulate(ct); !errors.Is(err, ErrKeyNotUsable) {
+		t.Fatalf("not yet valid: got %v, want ErrKeyNotUsable", err)
+	}
+	now = now.Add(90 * time.Minute)
+	if got, err := k.Decapsulate(ct); err != nil || !bytes.Equal(got, ss) {
+		t.Fatal("valid key does not decapsulate")
+	}
+	now = now.Add(time.Hour)
+	if _, err := k.Decapsulate(ct); !errors.Is(err, ErrKeyNotUsable) {
+		t.Fatalf("expired: got %v, want ErrKeyNotUsable", err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	k := New()
+	sk, _, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := k.Add(MLKEM768, sk[:], StatusCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(id[:], sk[kyberk2so.Kyber768SKBytes-64:kyberk2so.Kyber768SKBytes-32]) {
+		t.Fatal("key ID is not H(ek)")
+	}
+	if _, err := k.Add(MLKEM768, sk[:], StatusStaged); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("got %v, want ErrDuplicateKey", err)
+	}
+	// The keyring holds its own copy.
+	sk[0] ^= 1
+	ct, ss, err := k.Encapsulate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k.Decapsulate(ct); err != nil || !bytes.Equal(got, ss) {
+		t.Fatal("keyring shares the caller's private key")
+	}
+	for _, tc := range []struct {
+		kem    KEM
+		key    []byte
+		status Status
+	}{
+		{MLKEM1024, sk[:], StatusStaged},
+		{0, sk[:], StatusStaged},
+		{MLKEM768, sk[:], 0},
+		{MLKEM768, sk[1:], StatusStaged},
+	} {
+		if _, err := k.Add(tc.kem, tc.key, tc.status); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("got %v, want ErrInvalidKey", err)
+		}
+	}
+	bad := sk
+	bad[kyberk2so.Kyber768SKBytes-40] ^= 1
+	if _, err := k.Add(MLKEM768, bad[:], StatusStaged); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("mismatched key ID: got %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,245 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/kmac"
+	"golang.org/x/crypto/sha3"
+)
+
+// KDF identifies the key derivation function of a labeled encapsulation.
+//
+// The derived key is computed from the 32-byte ML-KEM shared secret ss
+// and the transcript
+//
+//	T = len(label) || label || len(context) || context || keyID || ct || L
+//
+// where lengths and the output length L in bytes are 64-bit big-endian
+// integers and keyID = H(ek) = SHA3-256(ek). With D the string
+// "kyberk2so labeled " followed by the KDF name and " v1", the key is
+//
+//	KDFHKDFSHA256: HKDF-SHA256(ikm = ss, salt = "", info = D || T, L)
+//	KDFKMAC256:    KMAC256(key = ss, data = T, L, customization = D)
+//	KDFSHAKE256:   SHAKE256(D || ss || T, L)
+//
+// so keys derived under different labels, contexts, key pairs,
+// ciphertexts, lengths or KDFs are independent.
+type KDF int
+
+// Supported key derivation functions.
+const (
+	KDFHKDFSHA256 KDF = iota + 1
+	KDFKMAC256
+	KDFSHAKE256
+)
+
+var (
+	// ErrUnknownKDF is returned when a labeled encapsulation names an
+	// unknown key derivation function.
+	ErrUnknownKDF = errors.New("kyberk2so: unknown key derivation function")
+
+	// ErrInvalidKeyLength is returned when a derived key length is not
+	// positive or exceeds what the key derivation function can produce.
+	ErrInvalidKeyLength = errors.New("kyberk2so: invalid derived key length")
+)
+
+// labeledMaxHKDFLength is the largest output of HKDF-SHA256.
+const labeledMaxHKDFLength = 255 * sha256.Size
+
+// kemLabeledCheck reports whether kdf is supported and can derive a
+// key of the given length.
+func kemLabeledCheck(kdf KDF, length int) error {
+	switch kdf {
+	case KDFHKDFSHA256, KDFKMAC256, KDFSHAKE256:
+	default:
+		return ErrUnknownKDF
+	}
+	if length <= 0 || (kdf == KDFHKDFSHA256 && length > labeledMaxHKDFLength) {
+		return ErrInvalidKeyLength
+	}
+	return nil
+}
+
+// kemLabeledKey derives a length-byte key from the shared secret ss,
+// after kemLabeledCheck has accepted kdf and length.
+func kemLabeledKey(kdf KDF, ss, keyID, ciphertext, label, context []byte, length int) ([]byte, error) {
+	var name string
+	switch kdf {
+	case KDFHKDFSHA256:
+		name = "HKDF-SHA256"
+	case KDFKMAC256:
+		name = "KMAC256"
+	default:
+		name = "SHAKE256"
+	}
+	domain := "kyberk2so labeled " + name + " v1"
+	t := binary.BigEndian.AppendUint64(nil, uint64(len(label)))
+	t = append(t, label...)
+	t = binary.BigEndian.AppendUint64(t, uint64(len(context)))
+	t = append(t, context...)
+	t = append(t, keyID...)
+	t = append(t, ciphertext...)
+	t = binary.BigEndian.AppendUint64(t, uint64(length))
+	if kdf == KDFHKDFSHA256 {
+		return hkdf.Key(sha256.New, ss, nil, domain+string(t), length)
+	}
+	key := make([]byte, length)
+	switch kdf {
+	case KDFKMAC256:
+		kmac.Sum256(key, ss, t, []byte(domain))
+	default:
+		h := sha3.NewShake256()
+		_, _ = h.Write([]byte(domain))
+		_, _ = h.Write(ss)
+		_, _ = h.Write(t)
+		_, _ = h.Read(key)
+	}
+	return key, nil
+}
+
+// KemEncapsulateLabeled512 takes a public key (from KemKeypair512), a key
+// derivation function, an application label, context bytes and a key
+// length as input, and returns a ciphertext and a key of that length
+// derived from the shared secret, the label, the context, the ciphertext
+// and the key ID H(ek).
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, if the key is invalid or if the key
+// derivation function or length is not supported.
+func KemEncapsulateLabeled512(
+	publicKey [Kyber512PKBytes]byte, kdf KDF, label, context []byte, length int,
+) ([Kyber512CTBytes]byte, []byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return [Kyber512CTBytes]byte{}, nil, err
+	}
+	ciphertext, ss, err := KemEncrypt512(publicKey)
+	if err != nil {
+		return ciphertext, nil, err
+	}
+	keyID := sha3.Sum256(publicKey[:])
+	key, err := kemLabeledKey(kdf, ss[:], keyID[:], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return ciphertext, key, err
+}
+
+// KemDecapsulateLabeled512 takes a ciphertext (from KemEncapsulateLabeled512),
+// a private key (from KemKeypair512) and the key derivation function, label,
+// context and key length used for encapsulation, and returns the derived
+// key. The key ID is read from the private key.
+// An accompanying error is returned if the private key is invalid or if
+// the key derivation function or length is not supported.
+func KemDecapsulateLabeled512(
+	ciphertext [Kyber512CTBytes]byte, privateKey [Kyber512SKBytes]byte,
+	kdf KDF, label, context []byte, length int,
+) ([]byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return nil, err
+	}
+	ss, err := KemDecrypt512(ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK512 + paramsIndcpaPublicKeyBytesK512
+	key, err := kemLabeledKey(kdf, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return key, err
+}
+
+// KemEncapsulateLabeled768 takes a public key (from KemKeypair768), a key
+// derivation function, an application label, context bytes and a key
+// length as input, and returns a ciphertext and a key of that length
+// derived from the shared secret, the label, the context, the ciphertext
+// and the key ID H(ek).
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, if the key is invalid or if the key
+// derivation function or length is not supported.
+func KemEncapsulateLabeled768(
+	publicKey [Kyber768PKBytes]byte, kdf KDF, label, context []byte, length int,
+) ([Kyber768CTBytes]byte, []byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return [Kyber768CTBytes]byte{}, nil, err
+	}
+	ciphertext, ss, err := KemEncrypt768(publicKey)
+	if err != nil {
+		return ciphertext, nil, err
+	}
+	keyID := sha3.Sum256(publicKey[:])
+	key, err := kemLabeledKey(kdf, ss[:], keyID[:], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return ciphertext, key, err
+}
+
+// KemDecapsulateLabeled768 takes a ciphertext (from KemEncapsulateLabeled768),
+// a private key (from KemKeypair768) and the key derivation function, label,
+// context and key length used for encapsulation, and returns the derived
+// key. The key ID is read from the private key.
+// An accompanying error is returned if the private key is invalid or if
+// the key derivation function or length is not supported.
+func KemDecapsulateLabeled768(
+	ciphertext [Kyber768CTBytes]byte, privateKey [Kyber768SKBytes]byte,
+	kdf KDF, label, context []byte, length int,
+) ([]byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return nil, err
+	}
+	ss, err := KemDecrypt768(ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK768 + paramsIndcpaPublicKeyBytesK768
+	key, err := kemLabeledKey(kdf, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return key, err
+}
+
+// KemEncapsulateLabeled1024 takes a public key (from KemKeypair1024), a key
+// derivation function, an application label, context bytes and a key
+// length as input, and returns a ciphertext and a key of that length
+// derived from the shared secret, the label, the context, the ciphertext
+// and the key ID H(ek).
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, if the key is invalid or if the key
+// derivation function or length is not supported.
+func KemEncapsulateLabeled1024(
+	publicKey [Kyber1024PKBytes]byte, kdf KDF, label, context []byte, length int,
+) ([Kyber1024CTBytes]byte, []byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return [Kyber1024CTBytes]byte{}, nil, err
+	}
+	ciphertext, ss, err := KemEncrypt1024(publicKey)
+	if err != nil {
+		return ciphertext, nil, err
+	}
+	keyID := sha3.Sum256(publicKey[:])
+	key, err := kemLabeledKey(kdf, ss[:], keyID[:], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return ciphertext, key, err
+}
+
+// KemDecapsulateLabeled1024 takes a ciphertext (from KemEncapsulateLabeled1024),
+// a private key (from KemKeypair1024) and the key derivation function, label,
+// context and key length used for encapsulation, and returns the derived
+// key. The key ID is read from the private key.
+// An accompanying error is returned if the private key is invalid or if
+// the key derivation function or length is not supported.
+func KemDecapsulateLabeled1024(
+	ciphertext [Kyber1024CTBytes]byte, privateKey [Kyber1024SKBytes]byte,
+	kdf KDF, label, context []byte, length int,
+) ([]byte, error) {
+	if err := kemLabeledCheck(kdf, length); err != nil {
+		return nil, err
+	}
+	ss, err := KemDecrypt1024(ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	pki := paramsIndcpaSecretKeyBytesK1024 + paramsIndcpaPublicKeyBytesK1024
+	key, err := kemLabeledKey(kdf, ss[:], privateKey[pki:pki+paramsSymBytes], ciphertext[:], label, context, length)
+	byteopsZeroBytes(ss[:])
+	return key, err
+}
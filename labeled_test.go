@@ -0,0 +1,167 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	xhkdf "golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// labeledKATKeys returns the ML-KEM-768 key pair and ciphertext of the
+// labeled known-answer tests: the seed is 0x00..0x3f and m is 0x40..0x5f.
+func labeledKATKeys(t *testing.T) ([Kyber768SKBytes]byte, [Kyber768CTBytes]byte) {
+	t.Helper()
+	var coins [64]byte
+	var m [32]byte
+	for i := range coins {
+		coins[i] = byte(i)
+	}
+	for i := range m {
+		m[i] = byte(64 + i)
+	}
+	privateKey, publicKey, err := KemKeypairDerand768(coins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _, err := KemEncryptDerand768(publicKey, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, ciphertext
+}
+
+func TestLabeledKAT(t *testing.T) {
+	privateKey, ciphertext := labeledKATKeys(t)
+	for _, tc := range []struct {
+		kdf  KDF
+		want string
+	}{
+		{KDFHKDFSHA256, "3f54cb61a924d28fd706ef3b78e05979724a449dde054a7f793310163488653f1bdad7acce1ba349b8fdb9b31e21f273"},
+		{KDFKMAC256, "659bf09818e849b6fc3da3ba2baa225ab1ed61e907a806bf8d022b8265ba56034413032c378d65302a2cfeb056608284"},
+		{KDFSHAKE256, "b468541fcfd87a146e1762cc51782258e50c2c97f762f14a8c35e67a8e65b272e730724f54cf80a7e2d5d31c1b5bf99d"},
+	} {
+		key, err := KemDecapsulateLabeled768(ciphertext, privateKey, tc.kdf, []byte("file encryption"), []byte("context"), 48)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(key) != tc.want {
+			t.Errorf("KDF %d: got %x", tc.kdf, key)
+		}
+	}
+}
+
+func TestLabeledFormat(t *testing.T) {
+	// The HKDF and SHAKE256 derivations follow the documented format.
+	privateKey, ciphertext := labeledKATKeys(t)
+	ss, _ := KemDecrypt768(ciphertext, privateKey)
+	keyID := sha3.Sum256(privateKey[paramsIndcpaSecretKeyBytesK768 : paramsIndcpaSecretKeyBytesK768+Kyber768PKBytes])
+	label, context := []byte("backup"), []byte{1, 2, 3}
+	var transcript []byte
+	transcript = binary.BigEndian.AppendUint64(transcript, uint64(len(label)))
+	transcript = append(transcript, label...)
+	transcript = binary.BigEndian.AppendUint64(transcript, uint64(len(context)))
+	transcript = append(transcript, context...)
+	transcript = append(transcript, keyID[:]...)
+	transcript = append(transcript, ciphertext[:]...)
+	transcript = binary.BigEndian.AppendUint64(transcript, 100)
+
+	want := make([]byte, 100)
+	info := append([]byte("kyberk2so labeled HKDF-SHA256 v1"), transcript...)
+	if _, err := io.ReadFull(xhkdf.New(sha256.New, ss[:], nil, info), want); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := KemDecapsulateLabeled768(ciphertext, privateKey, KDFHKDFSHA256, label, context, 100)
+	if !bytes.Equal(got, want) {
+		t.Fatal("HKDF-SHA256 derivation does not match")
+	}
+	input := append(append([]byte("kyberk2so labeled SHAKE256 v1"), ss[:]...), transcript...)
+	sha3.ShakeSum256(want, input)
+	got, _ = KemDecapsulateLabeled768(ciphertext, privateKey, KDFSHAKE256, label, context, 100)
+	if !bytes.Equal(got, want) {
+		t.Fatal("SHAKE256 derivation does not match")
+	}
+}
+
+func TestLabeled(t *testing.T) {
+	privateKey512, publicKey512, _ := KemKeypair512()
+	privateKey1024, publicKey1024, _ := KemKeypair1024()
+	for _, kdf := range []KDF{KDFHKDFSHA256, KDFKMAC256, KDFSHAKE256} {
+		ciphertext, keyA, err := KemEncapsulateLabeled512(publicKey512, kdf, []byte("session"), nil, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyB, err := KemDecapsulateLabeled512(ciphertext, privateKey512, kdf, []byte("session"), nil, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(keyA, keyB) {
+			t.Fatal("derived keys differ")
+		}
+		ciphertext1024, keyA, err := KemEncapsulateLabeled1024(publicKey1024, kdf, []byte("file"), []byte("ctx"), 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyB, err = KemDecapsulateLabeled1024(ciphertext1024, privateKey1024, kdf, []byte("file"), []byte("ctx"), 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keyA) != 1000 || !bytes.Equal(keyA, keyB) {
+			t.Fatal("derived keys differ")
+		}
+		// Every input separates the derived keys.
+		for _, other := range []struct {
+			label, context []byte
+			length         int
+		}{
+			{[]byte("backup"), []byte("ctx"), 1000},
+			{[]byte("file"), []byte("ctx2"), 1000},
+			{[]byte("filectx"), nil, 1000},
+			{[]byte("file"), []byte("ctx"), 999},
+		} {
+			keyC, _ := KemDecapsulateLabeled1024(ciphertext1024, privateKey1024, kdf, other.label, other.context, other.length)
+			if bytes.Equal(keyA[:32], keyC[:32]) {
+				t.Fatalf("KDF %d: derived key was not separated", kdf)
+			}
+		}
+	}
+	keyA, _ := KemDecapsulateLabeled512([Kyber512CTBytes]byte{}, privateKey512, KDFKMAC256, nil, nil, 32)
+	keyB, _ := KemDecapsulateLabeled512([Kyber512CTBytes]byte{}, privateKey512, KDFSHAKE256, nil, nil, 32)
+	if bytes.Equal(keyA, keyB) {
+		t.Fatal("KDFs were not separated")
+	}
+}
+
+func TestLabeledInvalid(t *testing.T) {
+	privateKey, publicKey, _ := KemKeypair768()
+	if _, _, err := KemEncapsulateLabeled768(publicKey, 0, nil, nil, 32); !errors.Is(err, ErrUnknownKDF) {
+		t.Fatalf("got %v, want ErrUnknownKDF", err)
+	}
+	for _, tc := range []struct {
+		kdf    KDF
+		length int
+	}{
+		{KDFSHAKE256, 0},
+		{KDFKMAC256, -1},
+		{KDFHKDFSHA256, 255*32 + 1},
+	} {
+		if _, err := KemDecapsulateLabeled768([Kyber768CTBytes]byte{}, privateKey, tc.kdf, nil, nil, tc.length); !errors.Is(err, ErrInvalidKeyLength) {
+			t.Fatalf("got %v, want ErrInvalidKeyLength", err)
+		}
+	}
+	if _, err := KemDecapsulateLabeled768([Kyber768CTBytes]byte{}, privateKey, KDFHKDFSHA256, nil, nil, 255*32); err != nil {
+		t.Fatal(err)
+	}
+	privateKey[Kyber768SKBytes-2*paramsSymBytes] ^= 1
+	if _, err := KemDecapsulateLabeled768([Kyber768CTBytes]byte{}, privateKey, KDFKMAC256, nil, nil, 32); !errors.Is(err, ErrInvalidDecapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidDecapsulationKey", err)
+	}
+}
@@ -0,0 +1,221 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// This file implements an opt-in multi-recipient KEM (mKEM) following
+// Katsumata, Kwiatkowski, Pintore and Prest, "Scalable Ciphertext
+// Compression Techniques for Post-Quantum KEMs and Their Applications"
+// (ASIACRYPT 2020). Recipients share the matrix `A`, so a single
+// encryption randomness yields one shared ciphertext component `u`,
+// and each recipient only adds its own compressed polynomial `v`.
+//
+// Security note: this construction is not ML-KEM and its ciphertexts
+// are not accepted by KemDecrypt768.
+//   - All recipients decrypt the same message, so any recipient can
+//     derive the keys of every other recipient. Per-recipient keys are
+//     independent only towards outsiders; use the mKEM to broadcast the
+//     same content, never to keep recipients apart.
+//   - Keys generated under a common public seed share the matrix `A`.
+//     Precomputation against `A` then applies to every key of the
+//     group, which ML-KEM avoids by giving each key its own seed. Pick
+//     the seed uniformly at random per group and rotate it.
+
+// Kyber768SharedCTBytes is a constant representing the byte length of the
+// shared ciphertext component of an ML-KEM-768 multi-recipient encapsulation.
+const Kyber768SharedCTBytes int = paramsPolyvecCompressedBytesK768
+
+// Kyber768RecipientCTBytes is a constant representing the byte length of the
+// per-recipient ciphertext component of an ML-KEM-768 multi-recipient encapsulation.
+const Kyber768RecipientCTBytes int = paramsPolyCompressedBytesK768
+
+// KemKeypairMultiRecipient768 returns an ML-KEM-768 private key and public
+// key whose matrix `A` is generated from the group's 32-byte public seed,
+// for use with KemEncryptMultiRecipient768. The keys are also valid
+// ML-KEM-768 keys. An accompanying error is returned if no sufficient
+// randomness could be obtained from the system.
+func KemKeypairMultiRecipient768(publicSeed [32]byte) ([Kyber768SKBytes]byte, [Kyber768PKBytes]byte, error) {
+	var coins [64]byte
+	_, err := rand.Read(coins[:])
+	if err != nil {
+		return [Kyber768SKBytes]byte{}, [Kyber768PKBytes]byte{}, err
+	}
+	defer byteopsZeroBytes(coins[:])
+	return KemKeypairMultiRecipientDerand768(publicSeed, coins)
+}
+
+// KemKeypairMultiRecipientDerand768 generates the key pair of
+// KemKeypairMultiRecipient768 deterministically from a 64-byte seed (d || z).
+// The noise seed is derived from d as in FIPS 203 Algorithm 13, while
+// the public seed replaces ρ.
+func KemKeypairMultiRecipientDerand768(publicSeed [32]byte, coins [64]byte) (
+	[Kyber768SKBytes]byte, [Kyber768PKBytes]byte, error,
+) {
+	const paramsK = 3
+	var privateKeyFixedLength [Kyber768SKBytes]byte
+	var publicKeyFixedLength [Kyber768PKBytes]byte
+	var hashInput [33]byte
+	copy(hashInput[:paramsSymBytes], coins[:32])
+	hashInput[32] = byte(paramsK)
+	buf := sha3.Sum512(hashInput[:])
+	var noiseSeed [paramsSymBytes]byte
+	copy(noiseSeed[:], buf[paramsSymBytes:])
+	err := indcpaKeypairFromSeeds(
+		privateKeyFixedLength[:paramsIndcpaSecretKeyBytesK768],
+		publicKeyFixedLength[:],
+		&publicSeed,
+		&noiseSeed,
+		paramsK,
+	)
+	byteopsZeroBytes(hashInput[:])
+	byteopsZeroBytes(buf[:])
+	byteopsZeroBytes(noiseSeed[:])
+	if err != nil {
+		return privateKeyFixedLength, publicKeyFixedLength, err
+	}
+	pkh := sha3.Sum256(publicKeyFixedLength[:])
+	skStart := paramsIndcpaSecretKeyBytesK768
+	skStart += copy(privateKeyFixedLength[skStart:], publicKeyFixedLength[:])
+	skStart += copy(privateKeyFixedLength[skStart:], pkh[:])
+	copy(privateKeyFixedLength[skStart:], coins[32:])
+	return privateKeyFixedLength, publicKeyFixedLength, nil
+}
+
+// KemEncryptMultiRecipient768 encapsulates to every public key (from
+// KemKeypairMultiRecipient768 with a common public seed) at once. It
+// returns the shared ciphertext component, one ciphertext component
+// per recipient and one 32-byte shared secret per recipient, in the
+// order of publicKeys. Each public key is validated per FIPS 203 §7.2.
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, if a key is invalid or if the keys do
+// not share a public seed.
+func KemEncryptMultiRecipient768(publicKeys [][Kyber768PKBytes]byte) (
+	[Kyber768SharedCTBytes]byte, [][Kyber768RecipientCTBytes]byte, [][KyberSSBytes]byte, error,
+) {
+	var m [paramsSymBytes]byte
+	_, err := rand.Read(m[:])
+	if err != nil {
+		return [Kyber768SharedCTBytes]byte{}, nil, nil, err
+	}
+	defer byteopsZeroBytes(m[:])
+	return KemEncryptMultiRecipientDerand768(publicKeys, m)
+}
+
+// KemEncryptMultiRecipientDerand768 performs KemEncryptMultiRecipient768
+// deterministically using the provided 32-byte message m.
+func KemEncryptMultiRecipientDerand768(publicKeys [][Kyber768PKBytes]byte, m [32]byte) (
+	[Kyber768SharedCTBytes]byte, [][Kyber768RecipientCTBytes]byte, [][KyberSSBytes]byte, error,
+) {
+	const paramsK = 3
+	var sharedCiphertext [Kyber768SharedCTBytes]byte
+	if len(publicKeys) == 0 {
+		return sharedCiphertext, nil, nil, nil
+	}
+	seed := publicKeys[0][paramsPolyvecBytesK768:]
+	for i := range publicKeys {
+		if !polyvecBytesValid(publicKeys[i][:paramsPolyvecBytesK768], paramsK) {
+			return sharedCiphertext, nil, nil, ErrInvalidEncapsulationKey
+		}
+		if subtle.ConstantTimeCompare(publicKeys[i][paramsPolyvecBytesK768:], seed) != 1 {
+			return sharedCiphertext, nil, nil, ErrMismatchedPublicSeed
+		}
+	}
+	kr := kemMultiRecipientG(m[:], seed)
+	defer byteopsZeroBytes(kr[:])
+	sp, epp, err := indcpaEncryptU(sharedCiphertext[:], seed, kr[paramsSymBytes:], paramsK)
+	if err != nil {
+		return sharedCiphertext, nil, nil, err
+	}
+	defer byteopsZeroPolyvec(&sp)
+	defer byteopsZeroPoly(&epp)
+	recipientCiphertexts := make([][Kyber768RecipientCTBytes]byte, len(publicKeys))
+	sharedSecrets := make([][KyberSSBytes]byte, len(publicKeys))
+	for i := range publicKeys {
+		publicKeyPolyvec := polyvecFromBytes(publicKeys[i][:paramsPolyvecBytesK768], paramsK)
+		indcpaEncryptV(recipientCiphertexts[i][:], m[:], &publicKeyPolyvec, &sp, &epp, paramsK)
+		pkh := sha3.Sum256(publicKeys[i][:])
+		sharedSecrets[i] = kemMultiRecipientKey(kr[:paramsSymBytes], pkh[:], sharedCiphertext[:], recipientCiphertexts[i][:])
+	}
+	return sharedCiphertext, recipientCiphertexts, sharedSecrets, nil
+}
+
+// KemDecryptMultiRecipient768 takes the shared ciphertext component and
+// the recipient's own component (from KemEncryptMultiRecipient768) and
+// the recipient's private key (from KemKeypairMultiRecipient768) and
+// returns the recipient's 32-byte shared secret. As in ML-KEM, invalid
+// ciphertexts are implicitly rejected with a pseudorandom secret.
+// Per FIPS 203 §7.3, the decapsulation key hash is validated before use.
+func KemDecryptMultiRecipient768(
+	sharedCiphertext [Kyber768SharedCTBytes]byte,
+	recipientCiphertext [Kyber768RecipientCTBytes]byte,
+	privateKey [Kyber768SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	const paramsK = 3
+	var sharedSecretFixedLength [KyberSSBytes]byte
+	if !kemDecapsInputCheck(privateKey[:], paramsK) {
+		return sharedSecretFixedLength, ErrInvalidDecapsulationKey
+	}
+	indcpaPrivateKey := privateKey[:paramsIndcpaSecretKeyBytesK768]
+	pki := paramsIndcpaSecretKeyBytesK768 + paramsIndcpaPublicKeyBytesK768
+	publicKey := privateKey[paramsIndcpaSecretKeyBytesK768:pki]
+	h := privateKey[pki : pki+paramsSymBytes]
+	z := privateKey[Kyber768SKBytes-paramsSymBytes:]
+	var ciphertext [Kyber768CTBytes]byte
+	copy(ciphertext[:], sharedCiphertext[:])
+	copy(ciphertext[Kyber768SharedCTBytes:], recipientCiphertext[:])
+	var mPrime [paramsSymBytes]byte
+	indcpaDecrypt(mPrime[:], ciphertext[:], indcpaPrivateKey, paramsK)
+	kr := kemMultiRecipientG(mPrime[:], publicKey[paramsPolyvecBytesK768:])
+	k := kemMultiRecipientKey(kr[:paramsSymBytes], h, sharedCiphertext[:], recipientCiphertext[:])
+	var kBar [KyberSSBytes]byte
+	var jInput [paramsSymBytes + Kyber768CTBytes]byte
+	copy(jInput[:paramsSymBytes], z)
+	copy(jInput[paramsSymBytes:], ciphertext[:])
+	sha3.ShakeSum256(kBar[:], jInput[:])
+	var cmp [Kyber768CTBytes]byte
+	err := indcpaEncrypt(cmp[:], mPrime[:], publicKey, kr[paramsSymBytes:], paramsK)
+	fail := byte(subtle.ConstantTimeCompare(ciphertext[:], cmp[:]) - 1)
+	for i := 0; i < KyberSSBytes; i++ {
+		sharedSecretFixedLength[i] = k[i] ^ (fail & (k[i] ^ kBar[i]))
+	}
+	byteopsZeroBytes(mPrime[:])
+	byteopsZeroBytes(kr[:])
+	byteopsZeroBytes(k[:])
+	byteopsZeroBytes(kBar[:])
+	byteopsZeroBytes(jInput[:])
+	byteopsZeroBytes(cmp[:])
+	return sharedSecretFixedLength, err
+}
+
+// kemMultiRecipientG derives the common key and the encryption coins
+// from m and the public seed, in place of G(m || H(ek)), which would
+// differ between recipients.
+func kemMultiRecipientG(m, publicSeed []byte) [64]byte {
+	var krInput [2 * paramsSymBytes]byte
+	copy(krInput[:paramsSymBytes], m)
+	copy(krInput[paramsSymBytes:], publicSeed)
+	kr := sha3.Sum512(krInput[:])
+	byteopsZeroBytes(krInput[:])
+	return kr
+}
+
+// kemMultiRecipientKey derives a recipient's shared secret from the
+// common key, binding it to the recipient's H(ek) and to both
+// ciphertext components.
+func kemMultiRecipientKey(k, pkh, sharedCiphertext, recipientCiphertext []byte) [KyberSSBytes]byte {
+	h := sha3.New256()
+	_, _ = h.Write(k)
+	_, _ = h.Write(pkh)
+	_, _ = h.Write(sharedCiphertext)
+	_, _ = h.Write(recipientCiphertext)
+	var out [KyberSSBytes]byte
+	h.Sum(out[:0])
+	return out
+}
@@ -0,0 +1,132 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func multiRecipientKeys768(tb testing.TB, n int) ([][Kyber768SKBytes]byte, [][Kyber768PKBytes]byte) {
+	tb.Helper()
+	var publicSeed [32]byte
+	if _, err := rand.Read(publicSeed[:]); err != nil {
+		tb.Fatal(err)
+	}
+	privateKeys := make([][Kyber768SKBytes]byte, n)
+	publicKeys := make([][Kyber768PKBytes]byte, n)
+	for i := range privateKeys {
+		var err error
+		privateKeys[i], publicKeys[i], err = KemKeypairMultiRecipient768(publicSeed)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return privateKeys, publicKeys
+}
+
+func TestMultiRecipient768(t *testing.T) {
+	privateKeys, publicKeys := multiRecipientKeys768(t, 8)
+	sharedCiphertext, recipientCiphertexts, sharedSecrets, err := KemEncryptMultiRecipient768(publicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[[KyberSSBytes]byte]bool)
+	for i := range privateKeys {
+		ss, err := KemDecryptMultiRecipient768(sharedCiphertext, recipientCiphertexts[i], privateKeys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ss != sharedSecrets[i] {
+			t.Fatalf("recipient %d derived a different shared secret", i)
+		}
+		if seen[ss] {
+			t.Fatalf("recipient %d shares its secret with another recipient", i)
+		}
+		seen[ss] = true
+	}
+	// A recipient decrypting another recipient's component is rejected.
+	ss, err := KemDecryptMultiRecipient768(sharedCiphertext, recipientCiphertexts[1], privateKeys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss == sharedSecrets[0] || ss == sharedSecrets[1] {
+		t.Fatal("swapped recipient component was accepted")
+	}
+	sharedCiphertext[0] ^= 1
+	ss, err = KemDecryptMultiRecipient768(sharedCiphertext, recipientCiphertexts[0], privateKeys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss == sharedSecrets[0] {
+		t.Fatal("tampered shared component was accepted")
+	}
+}
+
+// TestMultiRecipient768Keys checks that multi-recipient keys are valid
+// ML-KEM-768 keys and that the key pair is derived from its seeds.
+func TestMultiRecipient768Keys(t *testing.T) {
+	var publicSeed [32]byte
+	var coins [64]byte
+	for i := range coins {
+		coins[i] = byte(i)
+	}
+	privateKey, publicKey, err := KemKeypairMultiRecipientDerand768(publicSeed, coins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if [32]byte(publicKey[paramsPolyvecBytesK768:]) != publicSeed {
+		t.Fatal("public key does not carry the public seed")
+	}
+	again, _, err := KemKeypairMultiRecipientDerand768(publicSeed, coins)
+	if err != nil || again != privateKey {
+		t.Fatal("key generation is not deterministic")
+	}
+	ciphertext, ssA, err := KemEncrypt768(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssB, err := KemDecrypt768(ciphertext, privateKey)
+	if err != nil || ssA != ssB {
+		t.Fatal("multi-recipient key pair is not a valid ML-KEM-768 key pair")
+	}
+}
+
+func TestMultiRecipient768MismatchedSeed(t *testing.T) {
+	_, publicKeys := multiRecipientKeys768(t, 2)
+	_, other, err := KemKeypair768()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = KemEncryptMultiRecipient768(append(publicKeys, other))
+	if !errors.Is(err, ErrMismatchedPublicSeed) {
+		t.Fatalf("expected ErrMismatchedPublicSeed, got %v", err)
+	}
+	publicKeys[1][0] = 0xFF
+	publicKeys[1][1] |= 0x0F
+	_, _, _, err = KemEncryptMultiRecipient768(publicKeys)
+	if !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("expected ErrInvalidEncapsulationKey, got %v", err)
+	}
+}
+
+func BenchmarkKemEncryptMultiRecipient768(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		_, publicKeys := multiRecipientKeys768(b, n)
+		b.Run(fmt.Sprintf("mKEM/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, _, _ = KemEncryptMultiRecipient768(publicKeys)
+			}
+		})
+		b.Run(fmt.Sprintf("KemEncrypt768/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, publicKey := range publicKeys {
+					_, _, _ = KemEncrypt768(publicKey)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package noise
+
+import (
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// pskSize is the size of a Noise pre-shared key.
+const pskSize = 32
+
+// HandshakeState runs one party of a PQNoise handshake.
+type HandshakeState struct {
+	ss        symmetricState
+	kem       KEM
+	pattern   HandshakePattern
+	initiator bool
+	psk       []byte
+	s         KeyPair
+	e         KeyPair
+	rs        []byte
+	re        []byte
+	msgIndex  int
+}
+
+// NewHandshakeState initializes a handshake from c. The protocol name
+// is "Noise_" followed by the pattern name, the KEM, "ChaChaPoly" and
+// the hash, separated by underscores, for example
+// Noise_pqXXpsk3_MLKEM768_ChaChaPoly_BLAKE2s.
+func NewHandshakeState(c Config) (*HandshakeState, error) {
+	hashName, newHash := c.Hash.hash()
+	if newHash == nil || c.KEM.name() == "" || len(c.Pattern.Messages) == 0 {
+		return nil, ErrInvalidConfig
+	}
+	pkSize, skSize, _ := c.KEM.params().Sizes()
+	hs := &HandshakeState{
+		kem:       c.KEM,
+		pattern:   c.Pattern,
+		initiator: c.Initiator,
+		s:         c.StaticKeypair,
+		rs:        c.PeerStatic,
+	}
+	if c.Pattern.hasPSK() {
+		if len(c.PresharedKey) != pskSize {
+			return nil, ErrInvalidConfig
+		}
+		hs.psk = c.PresharedKey
+	}
+	localPre, remotePre := c.Pattern.InitiatorPreMessages, c.Pattern.ResponderPreMessages
+	if !c.Initiator {
+		localPre, remotePre = remotePre, localPre
+	}
+	if hs.sendsToken(TokenS) || len(localPre) > 0 {
+		if len(hs.s.Public) != pkSize || len(hs.s.Private) != skSize {
+			return nil, ErrInvalidConfig
+		}
+	}
+	if len(remotePre) > 0 && len(hs.rs) != pkSize {
+		return nil, ErrInvalidConfig
+	}
+	name := "Noise_" + c.Pattern.Name + "_" + c.KEM.name() + "_ChaChaPoly_" + hashName
+	hs.ss.initializeSymmetric(newHash, name)
+	hs.ss.mixHash(c.Prologue)
+	// Pre-message keys are hashed initiator first.
+	initiatorKey, responderKey := hs.s.Public, hs.rs
+	if !c.Initiator {
+		initiatorKey, responderKey = responderKey, initiatorKey
+	}
+	if len(c.Pattern.InitiatorPreMessages) > 0 {
+		hs.ss.mixHash(initiatorKey)
+	}
+	if len(c.Pattern.ResponderPreMessages) > 0 {
+		hs.ss.mixHash(responderKey)
+	}
+	return hs, nil
+}
+
+// sendsToken reports whether the local party sends token t in any
+// handshake message.
+func (hs *HandshakeState) sendsToken(t Token) bool {
+	for i, m := range hs.pattern.Messages {
+		if (i%2 == 0) != hs.initiator {
+			continue
+		}
+		for _, token := range m {
+			if token == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandshakeHash returns the handshake hash h. Once the handshake is
+// complete, both parties hold the same value, which can serve as a
+// channel binding.
+func (hs *HandshakeState) HandshakeHash() []byte {
+	return append([]byte(nil), hs.ss.h...)
+}
+
+// PeerStatic returns the peer's static public key, if known.
+func (hs *HandshakeState) PeerStatic() []byte {
+	return hs.rs
+}
+
+// myTurn reports whether the local party writes the next message.
+func (hs *HandshakeState) myTurn() bool {
+	return (hs.msgIndex%2 == 0) == hs.initiator
+}
+
+// WriteMessage appends the next handshake message, carrying payload, to
+// out. After the final message it also returns the transport
+// CipherStates for messages from the initiator and from the responder.
+func (hs *HandshakeState) WriteMessage(out, payload []byte) ([]byte, *CipherState, *CipherState, error) {
+	if hs.msgIndex >= len(hs.pattern.Messages) || !hs.myTurn() {
+		return nil, nil, nil, ErrOutOfTurn
+	}
+	start := len(out)
+	var err error
+	for _, token := range hs.pattern.Messages[hs.msgIndex] {
+		switch token {
+		case TokenE:
+			if hs.e, err = GenerateKeyPair(hs.kem); err != nil {
+				return nil, nil, nil, err
+			}
+			out = append(out, hs.e.Public...)
+			hs.ss.mixHash(hs.e.Public)
+			if hs.psk != nil {
+				hs.ss.mixKey(hs.e.Public)
+			}
+		case TokenS:
+			if out, err = hs.ss.encryptAndHash(out, hs.s.Public); err != nil {
+				return nil, nil, nil, err
+			}
+		case TokenEKEM:
+			ct, k, err := hs.kem.params().Encapsulate(hs.re)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			out = append(out, ct...)
+			hs.ss.mixHash(ct)
+			hs.ss.mixKey(k)
+			mlkem.ZeroBytes(k)
+		case TokenSKEM:
+			ct, k, err := hs.kem.params().Encapsulate(hs.rs)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if out, err = hs.ss.encryptAndHash(out, ct); err != nil {
+				return nil, nil, nil, err
+			}
+			hs.ss.mixKey(k)
+			mlkem.ZeroBytes(k)
+		case TokenPSK:
+			hs.ss.mixKeyAndHash(hs.psk)
+		}
+	}
+	if out, err = hs.ss.encryptAndHash(out, payload); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(out)-start > MaxMessageSize {
+		return nil, nil, nil, ErrMessageTooLong
+	}
+	c1, c2 := hs.advance()
+	return out, c1, c2, nil
+}
+
+// ReadMessage processes the next handshake message from the peer and
+// appends its payload to out. After the final message it also returns
+// the transport CipherStates for messages from the initiator and from
+// the responder.
+func (hs *HandshakeState) ReadMessage(out, message []byte) ([]byte, *CipherState, *CipherState, error) {
+	if hs.msgIndex >= len(hs.pattern.Messages) || hs.myTurn() {
+		return nil, nil, nil, ErrOutOfTurn
+	}
+	if len(message) > MaxMessageSize {
+		return nil, nil, nil, ErrMessageTooLong
+	}
+	pkSize, _, ctSize := hs.kem.params().Sizes()
+	next := func(n int) ([]byte, error) {
+		if hs.ss.cs.hasKey() && n > 0 {
+			n += chacha20poly1305.Overhead
+		}
+		if len(message) < n {
+			return nil, ErrShortMessage
+		}
+		b := message[:n]
+		message = message[n:]
+		return b, nil
+	}
+	for _, token := range hs.pattern.Messages[hs.msgIndex] {
+		switch token {
+		case TokenE:
+			if len(message) < pkSize {
+				return nil, nil, nil, ErrShortMessage
+			}
+			hs.re = append([]byte(nil), message[:pkSize]...)
+			message = message[pkSize:]
+			hs.ss.mixHash(hs.re)
+			if hs.psk != nil {
+				hs.ss.mixKey(hs.re)
+			}
+		case TokenS:
+			b, err := next(pkSize)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if hs.rs, err = hs.ss.decryptAndHash(nil, b); err != nil {
+				return nil, nil, nil, err
+			}
+		case TokenEKEM:
+			if len(message) < ctSize {
+				return nil, nil, nil, ErrShortMessage
+			}
+			ct := message[:ctSize]
+			message = message[ctSize:]
+			hs.ss.mixHash(ct)
+			k, err := hs.kem.params().Decapsulate(ct, hs.e.Private)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hs.ss.mixKey(k)
+			mlkem.ZeroBytes(k)
+		case TokenSKEM:
+			b, err := next(ctSize)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			ct, err := hs.ss.decryptAndHash(nil, b)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			k, err := hs.kem.params().Decapsulate(ct, hs.s.Private)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			hs.ss.mixKey(k)
+			mlkem.ZeroBytes(k)
+		case TokenPSK:
+			hs.ss.mixKeyAndHash(hs.psk)
+		}
+	}
+	out, err := hs.ss.decryptAndHash(out, message)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c1, c2 := hs.advance()
+	return out, c1, c2, nil
+}
+
+// advance moves to the next message and, after the last one, splits
+// the symmetric state into the transport CipherStates.
+func (hs *HandshakeState) advance() (*CipherState, *CipherState) {
+	hs.msgIndex++
+	if hs.msgIndex < len(hs.pattern.Messages) {
+		return nil, nil
+	}
+	mlkem.ZeroBytes(hs.e.Private)
+	return hs.ss.split()
+}
@@ -0,0 +1,150 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package noise implements the Noise Protocol Framework with the
+// KEM-based handshake patterns of PQNoise (Angel et al., "Post Quantum
+// Noise", CCS 2022). The Diffie-Hellman tokens of classical Noise are
+// replaced by ekem and skem tokens that encapsulate to the peer's
+// ephemeral or static ML-KEM key; any of the three ML-KEM parameter
+// sets can be used.
+//
+// The symmetric layer follows the Noise specification, revision 34:
+// CipherState uses ChaCha20-Poly1305 and SymmetricState uses SHA-256 or
+// BLAKE2s. Handshake patterns may carry psk modifiers.
+package noise
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/blake2s"
+)
+
+var (
+	// ErrInvalidConfig is returned when a Config lacks a key required by
+	// its handshake pattern or names an unknown KEM or hash function.
+	ErrInvalidConfig = errors.New("noise: invalid handshake configuration")
+
+	// ErrOutOfTurn is returned when a message is written or read out of
+	// turn, or after the handshake has completed.
+	ErrOutOfTurn = errors.New("noise: handshake message out of turn")
+
+	// ErrShortMessage is returned when a handshake message is too short
+	// for the tokens of its pattern.
+	ErrShortMessage = errors.New("noise: handshake message too short")
+
+	// ErrMessageTooLong is returned when a message would exceed the
+	// 65535-byte Noise message limit.
+	ErrMessageTooLong = errors.New("noise: message too long")
+
+	// ErrDecryptionFailed is returned when a ciphertext fails to
+	// authenticate.
+	ErrDecryptionFailed = errors.New("noise: decryption failed")
+
+	// ErrNonceExhausted is returned when a CipherState has used every
+	// available nonce.
+	ErrNonceExhausted = errors.New("noise: nonce exhausted")
+)
+
+// MaxMessageSize is the maximum size of a Noise message.
+const MaxMessageSize = 65535
+
+// KEM identifies the ML-KEM parameter set used for all KEM tokens.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// HashFunc identifies the hash function of the SymmetricState.
+type HashFunc int
+
+// Supported hash functions.
+const (
+	SHA256 HashFunc = iota + 1
+	BLAKE2s
+)
+
+// KeyPair is an ML-KEM key pair.
+type KeyPair struct {
+	Private []byte
+	Public  []byte
+}
+
+// GenerateKeyPair returns a new ML-KEM key pair of the given parameter set.
+func GenerateKeyPair(kem KEM) (KeyPair, error) {
+	sk, pk, err := kem.params().Keypair()
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return KeyPair{}, ErrInvalidConfig
+	}
+	return KeyPair{sk, pk}, err
+}
+
+// name returns the KEM name used in the protocol name.
+func (kem KEM) name() string {
+	switch kem {
+	case MLKEM512:
+		return "MLKEM512"
+	case MLKEM768:
+		return "MLKEM768"
+	case MLKEM1024:
+		return "MLKEM1024"
+	default:
+		return ""
+	}
+}
+
+// hash returns the hash name and constructor.
+func (h HashFunc) hash() (string, func() hash.Hash) {
+	switch h {
+	case SHA256:
+		return "SHA256", sha256.New
+	case BLAKE2s:
+		return "BLAKE2s", func() hash.Hash {
+			d, _ := blake2s.New256(nil)
+			return d
+		}
+	default:
+		return "", nil
+	}
+}
+
+// Config describes one party of a handshake.
+type Config struct {
+	KEM       KEM
+	Hash      HashFunc
+	Pattern   HandshakePattern
+	Initiator bool
+	Prologue  []byte
+
+	// StaticKeypair is the local static key pair, required when the
+	// pattern sends or pre-shares the local static key.
+	StaticKeypair KeyPair
+
+	// PeerStatic is the peer's static public key, required when the
+	// pattern pre-shares it.
+	PeerStatic []byte
+
+	// PresharedKey is the 32-byte key used by psk tokens.
+	PresharedKey []byte
+}
@@ -0,0 +1,300 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package noise
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+var allPatterns = []HandshakePattern{
+	PatternNN, PatternNK, PatternNX, PatternXN, PatternXK, PatternXX,
+	PatternKN, PatternKK, PatternKX, PatternIN, PatternIK, PatternIX,
+}
+
+// newPair returns initiator and responder handshake states for p, with
+// static keys and a pre-shared key supplied to both sides.
+func newPair(t *testing.T, p HandshakePattern, kem KEM, h HashFunc, psk []byte) (*HandshakeState, *HandshakeState) {
+	t.Helper()
+	si, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := GenerateKeyPair(kem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic := Config{KEM: kem, Hash: h, Pattern: p, Initiator: true, Prologue: []byte("prologue"), StaticKeypair: si, PresharedKey: psk}
+	rc := Config{KEM: kem, Hash: h, Pattern: p, Prologue: []byte("prologue"), StaticKeypair: sr, PresharedKey: psk}
+	if len(p.ResponderPreMessages) > 0 {
+		ic.PeerStatic = sr.Public
+	}
+	if len(p.InitiatorPreMessages) > 0 {
+		rc.PeerStatic = si.Public
+	}
+	initiator, err := NewHandshakeState(ic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, err := NewHandshakeState(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return initiator, responder
+}
+
+// runHandshake exchanges every handshake message and returns the
+// transport CipherStates of both parties.
+func runHandshake(initiator, responder *HandshakeState) ([2]*CipherState, [2]*CipherState, error) {
+	var ics, rcs [2]*CipherState
+	writer, reader := initiator, responder
+	for i := range initiator.pattern.Messages {
+		payload := []byte{byte(i), 'p'}
+		msg, w1, w2, err := writer.WriteMessage(nil, payload)
+		if err != nil {
+			return ics, rcs, err
+		}
+		got, r1, r2, err := reader.ReadMessage(nil, msg)
+		if err != nil {
+			return ics, rcs, err
+		}
+		if !bytes.Equal(got, payload) {
+			return ics, rcs, errors.New("payload mismatch")
+		}
+		if writer == initiator {
+			ics, rcs = [2]*CipherState{w1, w2}, [2]*CipherState{r1, r2}
+		} else {
+			ics, rcs = [2]*CipherState{r1, r2}, [2]*CipherState{w1, w2}
+		}
+		writer, reader = reader, writer
+	}
+	return ics, rcs, nil
+}
+
+func checkTransport(t *testing.T, ics, rcs [2]*CipherState) {
+	t.Helper()
+	if ics[0] == nil || ics[1] == nil || rcs[0] == nil || rcs[1] == nil {
+		t.Fatal("handshake did not complete")
+	}
+	for i, msg := range [][]byte{[]byte("ping"), []byte("pong"), nil} {
+		send, recv := ics[0], rcs[0]
+		if i%2 == 1 {
+			send, recv = rcs[1], ics[1]
+		}
+		ct, err := send.Encrypt(nil, nil, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := recv.Decrypt(nil, nil, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pt, msg) {
+			t.Fatal("transport message mismatch")
+		}
+	}
+}
+
+func TestHandshakes(t *testing.T) {
+	for _, kem := range []KEM{MLKEM512, MLKEM768, MLKEM1024} {
+		for _, h := range []HashFunc{SHA256, BLAKE2s} {
+			for _, p := range allPatterns {
+				name, _ := h.hash()
+				t.Run(p.Name+"_"+kem.name()+"_"+name, func(t *testing.T) {
+					initiator, responder := newPair(t, p, kem, h, nil)
+					ics, rcs, err := runHandshake(initiator, responder)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(initiator.HandshakeHash(), responder.HandshakeHash()) {
+						t.Fatal("handshake hash mismatch")
+					}
+					checkTransport(t, ics, rcs)
+				})
+			}
+		}
+	}
+}
+
+func TestPeerStatic(t *testing.T) {
+	initiator, responder := newPair(t, PatternXX, MLKEM768, BLAKE2s, nil)
+	if _, _, err := runHandshake(initiator, responder); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initiator.PeerStatic(), responder.s.Public) {
+		t.Fatal("initiator learned the wrong responder static key")
+	}
+	if !bytes.Equal(responder.PeerStatic(), initiator.s.Public) {
+		t.Fatal("responder learned the wrong initiator static key")
+	}
+}
+
+func TestPSK(t *testing.T) {
+	psk := bytes.Repeat([]byte{0x42}, 32)
+	for _, p := range []HandshakePattern{
+		PatternNN.WithPSK(0), PatternNN.WithPSK(2), PatternXX.WithPSK(3),
+		PatternIK.WithPSK(1), PatternKK.WithPSK(0, 2),
+	} {
+		t.Run(p.Name, func(t *testing.T) {
+			initiator, responder := newPair(t, p, MLKEM768, SHA256, psk)
+			ics, rcs, err := runHandshake(initiator, responder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(initiator.HandshakeHash(), responder.HandshakeHash()) {
+				t.Fatal("handshake hash mismatch")
+			}
+			checkTransport(t, ics, rcs)
+		})
+	}
+	if name := PatternKK.WithPSK(0, 2).Name; name != "pqKKpsk0+psk2" {
+		t.Fatalf("unexpected pattern name %q", name)
+	}
+}
+
+func TestWrongPSK(t *testing.T) {
+	p := PatternXX.WithPSK(3)
+	initiator, responder := newPair(t, p, MLKEM768, BLAKE2s, bytes.Repeat([]byte{1}, 32))
+	responder.psk = bytes.Repeat([]byte{2}, 32)
+	if _, _, err := runHandshake(initiator, responder); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+}
+
+func TestPrologueMismatch(t *testing.T) {
+	sr, err := GenerateKeyPair(MLKEM768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initiator, err := NewHandshakeState(Config{KEM: MLKEM768, Hash: SHA256, Pattern: PatternNK, Initiator: true, Prologue: []byte("a"), PeerStatic: sr.Public})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responder, err := NewHandshakeState(Config{KEM: MLKEM768, Hash: SHA256, Pattern: PatternNK, Prologue: []byte("b"), StaticKeypair: sr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := runHandshake(initiator, responder); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	kp, err := GenerateKeyPair(MLKEM768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Config{
+		{Hash: SHA256, Pattern: PatternNN},
+		{KEM: MLKEM768, Pattern: PatternNN},
+		{KEM: MLKEM768, Hash: SHA256},
+		{KEM: MLKEM768, Hash: SHA256, Pattern: PatternXX, Initiator: true},
+		{KEM: MLKEM768, Hash: SHA256, Pattern: PatternNK, Initiator: true},
+		{KEM: MLKEM768, Hash: SHA256, Pattern: PatternNK, Initiator: true, PeerStatic: kp.Public[:10]},
+		{KEM: MLKEM768, Hash: SHA256, Pattern: PatternNN.WithPSK(0), Initiator: true},
+		{KEM: MLKEM512, Hash: SHA256, Pattern: PatternXX, Initiator: true, StaticKeypair: kp},
+	} {
+		if _, err := NewHandshakeState(c); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: expected ErrInvalidConfig, got %v", c.Pattern.Name, err)
+		}
+	}
+}
+
+func TestOutOfTurn(t *testing.T) {
+	initiator, responder := newPair(t, PatternNN, MLKEM768, SHA256, nil)
+	if _, _, _, err := responder.WriteMessage(nil, nil); !errors.Is(err, ErrOutOfTurn) {
+		t.Fatalf("expected ErrOutOfTurn, got %v", err)
+	}
+	if _, _, _, err := initiator.ReadMessage(nil, nil); !errors.Is(err, ErrOutOfTurn) {
+		t.Fatalf("expected ErrOutOfTurn, got %v", err)
+	}
+	if _, _, err := runHandshake(initiator, responder); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := initiator.WriteMessage(nil, nil); !errors.Is(err, ErrOutOfTurn) {
+		t.Fatalf("expected ErrOutOfTurn after completion, got %v", err)
+	}
+}
+
+func TestShortMessage(t *testing.T) {
+	initiator, responder := newPair(t, PatternXX, MLKEM768, SHA256, nil)
+	msg, _, _, err := initiator.WriteMessage(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := responder.ReadMessage(nil, msg[:len(msg)-1]); !errors.Is(err, ErrShortMessage) {
+		t.Fatalf("expected ErrShortMessage, got %v", err)
+	}
+}
+
+func TestMessageTooLong(t *testing.T) {
+	initiator, _ := newPair(t, PatternNN, MLKEM768, SHA256, nil)
+	if _, _, _, err := initiator.WriteMessage(nil, make([]byte, MaxMessageSize)); !errors.Is(err, ErrMessageTooLong) {
+		t.Fatalf("expected ErrMessageTooLong, got %v", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	initiator, responder := newPair(t, PatternIK, MLKEM768, BLAKE2s, nil)
+	msg, _, _, err := initiator.WriteMessage(nil, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg[len(msg)-1] ^= 1
+	if _, _, _, err := responder.ReadMessage(nil, msg); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+}
+
+func TestRekey(t *testing.T) {
+	initiator, responder := newPair(t, PatternNN, MLKEM768, SHA256, nil)
+	ics, rcs, err := runHandshake(initiator, responder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ics[0].Rekey()
+	ct, err := ics[0].Encrypt(nil, nil, []byte("after rekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rcs[0].Decrypt(nil, nil, ct); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatal("decryption succeeded under the old key")
+	}
+	rcs[0].Rekey()
+	pt, err := rcs[0].Decrypt(nil, nil, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pt) != "after rekey" {
+		t.Fatal("plaintext mismatch after rekey")
+	}
+}
+
+func TestNonceExhausted(t *testing.T) {
+	var cs CipherState
+	cs.initializeKey(make([]byte, 32))
+	cs.n = maxNonce
+	if _, err := cs.Encrypt(nil, nil, nil); !errors.Is(err, ErrNonceExhausted) {
+		t.Fatalf("expected ErrNonceExhausted, got %v", err)
+	}
+}
+
+// TestHKDF checks the Noise HKDF against RFC 5869 HKDF with ck as the
+// salt and an empty info string.
+func TestHKDF(t *testing.T) {
+	var ss symmetricState
+	ss.initializeSymmetric(sha256.New, "Noise_pqNN_MLKEM768_ChaChaPoly_SHA256")
+	ikm := []byte("input keying material")
+	outputs := ss.hkdf(ikm, 3)
+	want, err := hkdf.Key(sha256.New, ikm, ss.ck, "", 3*sha256.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.Join(outputs, nil), want) {
+		t.Fatal("HKDF output mismatch")
+	}
+}
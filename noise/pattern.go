@@ -0,0 +1,181 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package noise
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Token is a handshake pattern token.
+type Token int
+
+// Handshake tokens. TokenE sends an ephemeral KEM public key and
+// TokenS an encrypted static KEM public key. TokenEKEM encapsulates to
+// the peer's ephemeral key and sends the ciphertext in the clear;
+// TokenSKEM encapsulates to the peer's static key and sends the
+// ciphertext encrypted. Both KEM tokens mix the shared secret into
+// the chaining key. TokenPSK mixes in the pre-shared key.
+const (
+	TokenE Token = iota + 1
+	TokenS
+	TokenEKEM
+	TokenSKEM
+	TokenPSK
+)
+
+// HandshakePattern is a PQNoise handshake pattern. Messages alternate
+// between the initiator, who sends the first, and the responder.
+type HandshakePattern struct {
+	Name                 string
+	InitiatorPreMessages []Token
+	ResponderPreMessages []Token
+	Messages             [][]Token
+}
+
+// The interactive KEM-based handshake patterns of PQNoise.
+var (
+	PatternNN = HandshakePattern{
+		Name: "pqNN",
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM},
+		},
+	}
+	PatternNK = HandshakePattern{
+		Name:                 "pqNK",
+		ResponderPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenSKEM, TokenE},
+			{TokenEKEM},
+		},
+	}
+	PatternNX = HandshakePattern{
+		Name: "pqNX",
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM, TokenS},
+			{TokenSKEM},
+		},
+	}
+	PatternXN = HandshakePattern{
+		Name: "pqXN",
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM},
+			{TokenS},
+			{TokenSKEM},
+		},
+	}
+	PatternXK = HandshakePattern{
+		Name:                 "pqXK",
+		ResponderPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenSKEM, TokenE},
+			{TokenEKEM},
+			{TokenS},
+			{TokenSKEM},
+		},
+	}
+	PatternXX = HandshakePattern{
+		Name: "pqXX",
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM, TokenS},
+			{TokenSKEM, TokenS},
+			{TokenSKEM},
+		},
+	}
+	PatternKN = HandshakePattern{
+		Name:                 "pqKN",
+		InitiatorPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM, TokenSKEM},
+		},
+	}
+	PatternKK = HandshakePattern{
+		Name:                 "pqKK",
+		InitiatorPreMessages: []Token{TokenS},
+		ResponderPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenSKEM, TokenE},
+			{TokenEKEM, TokenSKEM},
+		},
+	}
+	PatternKX = HandshakePattern{
+		Name:                 "pqKX",
+		InitiatorPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenE},
+			{TokenEKEM, TokenSKEM, TokenS},
+			{TokenSKEM},
+		},
+	}
+	PatternIN = HandshakePattern{
+		Name: "pqIN",
+		Messages: [][]Token{
+			{TokenE, TokenS},
+			{TokenEKEM, TokenSKEM},
+		},
+	}
+	PatternIK = HandshakePattern{
+		Name:                 "pqIK",
+		ResponderPreMessages: []Token{TokenS},
+		Messages: [][]Token{
+			{TokenSKEM, TokenE, TokenS},
+			{TokenEKEM, TokenSKEM},
+		},
+	}
+	PatternIX = HandshakePattern{
+		Name: "pqIX",
+		Messages: [][]Token{
+			{TokenE, TokenS},
+			{TokenEKEM, TokenSKEM, TokenS},
+			{TokenSKEM},
+		},
+	}
+)
+
+// WithPSK returns the pattern with the given psk modifiers applied:
+// placement 0 puts a psk token at the start of the first message and
+// placement i > 0 at the end of the i-th message. It panics if a
+// placement is past the last message.
+func (p HandshakePattern) WithPSK(placements ...int) HandshakePattern {
+	out := HandshakePattern{
+		Name:                 p.Name,
+		InitiatorPreMessages: p.InitiatorPreMessages,
+		ResponderPreMessages: p.ResponderPreMessages,
+		Messages:             make([][]Token, len(p.Messages)),
+	}
+	for i, m := range p.Messages {
+		out.Messages[i] = append([]Token(nil), m...)
+	}
+	modifiers := make([]string, 0, len(placements))
+	for _, i := range placements {
+		switch {
+		case i == 0:
+			out.Messages[0] = append([]Token{TokenPSK}, out.Messages[0]...)
+		case i > 0 && i <= len(out.Messages):
+			out.Messages[i-1] = append(out.Messages[i-1], TokenPSK)
+		default:
+			panic("noise: psk placement out of range")
+		}
+		modifiers = append(modifiers, "psk"+strconv.Itoa(i))
+	}
+	out.Name += strings.Join(modifiers, "+")
+	return out
+}
+
+// hasPSK reports whether any message carries a psk token.
+func (p *HandshakePattern) hasPSK() bool {
+	for _, m := range p.Messages {
+		for _, t := range m {
+			if t == TokenPSK {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package noise
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+	"math"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// maxNonce is reserved by the Noise specification and never used to
+// encrypt.
+const maxNonce = math.MaxUint64
+
+// CipherState encrypts and decrypts transport messages with
+// ChaCha20-Poly1305 under a 64-bit message counter.
+type CipherState struct {
+	aead cipher.AEAD
+	k    [chacha20poly1305.KeySize]byte
+	n    uint64
+}
+
+// initializeKey sets the key and resets the counter. A nil key leaves
+// the CipherState without a key.
+func (cs *CipherState) initializeKey(k []byte) {
+	cs.n = 0
+	if k == nil {
+		cs.aead = nil
+		return
+	}
+	copy(cs.k[:], k)
+	cs.aead, _ = chacha20poly1305.New(cs.k[:])
+}
+
+func (cs *CipherState) hasKey() bool {
+	return cs.aead != nil
+}
+
+// nonce encodes n as 32 zero bits followed by the little-endian counter.
+func (cs *CipherState) nonce() []byte {
+	var nonce [chacha20poly1305.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], cs.n)
+	return nonce[:]
+}
+
+// Encrypt appends the encryption of plaintext with associated data ad
+// to out and advances the counter.
+func (cs *CipherState) Encrypt(out, ad, plaintext []byte) ([]byte, error) {
+	if !cs.hasKey() {
+		return append(out, plaintext...), nil
+	}
+	if cs.n == maxNonce {
+		return nil, ErrNonceExhausted
+	}
+	out = cs.aead.Seal(out, cs.nonce(), plaintext, ad)
+	cs.n++
+	return out, nil
+}
+
+// Decrypt appends the decryption of ciphertext with associated data ad
+// to out. The counter only advances if authentication succeeds.
+func (cs *CipherState) Decrypt(out, ad, ciphertext []byte) ([]byte, error) {
+	if !cs.hasKey() {
+		return append(out, ciphertext...), nil
+	}
+	if cs.n == maxNonce {
+		return nil, ErrNonceExhausted
+	}
+	out, err := cs.aead.Open(out, cs.nonce(), ciphertext, ad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	cs.n++
+	return out, nil
+}
+
+// Rekey replaces the key with the first 32 bytes of its encryption of
+// 32 zero bytes under the reserved nonce, as in the Noise specification.
+func (cs *CipherState) Rekey() {
+	if !cs.hasKey() {
+		return
+	}
+	var nonce [chacha20poly1305.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], maxNonce)
+	k := cs.aead.Seal(nil, nonce[:], make([]byte, chacha20poly1305.KeySize), nil)
+	n := cs.n
+	cs.initializeKey(k[:chacha20poly1305.KeySize])
+	cs.n = n
+	mlkem.ZeroBytes(k)
+}
+
+// symmetricState holds the chaining key ck and the handshake hash h.
+type symmetricState struct {
+	cs      CipherState
+	newHash func() hash.Hash
+	ck      []byte
+	h       []byte
+}
+
+// initializeSymmetric sets h to the protocol name, padded or hashed to
+// the hash length, and ck to h.
+func (ss *symmetricState) initializeSymmetric(newHash func() hash.Hash, protocolName string) {
+	ss.newHash = newHash
+	hashLen := newHash().Size()
+	if len(protocolName) <= hashLen {
+		ss.h = make([]byte, hashLen)
+		copy(ss.h, protocolName)
+	} else {
+		h := newHash()
+		_, _ = h.Write([]byte(protocolName))
+		ss.h = h.Sum(nil)
+	}
+	ss.ck = append([]byte(nil), ss.h...)
+}
+
+func (ss *symmetricState) mixKey(ikm []byte) {
+	outputs := ss.hkdf(ikm, 2)
+	ss.ck = outputs[0]
+	ss.cs.initializeKey(outputs[1][:chacha20poly1305.KeySize])
+	mlkem.ZeroBytes(outputs[1])
+}
+
+func (ss *symmetricState) mixHash(data []byte) {
+	h := ss.newHash()
+	_, _ = h.Write(ss.h)
+	_, _ = h.Write(data)
+	ss.h = h.Sum(ss.h[:0])
+}
+
+func (ss *symmetricState) mixKeyAndHash(ikm []byte) {
+	outputs := ss.hkdf(ikm, 3)
+	ss.ck = outputs[0]
+	ss.mixHash(outputs[1])
+	ss.cs.initializeKey(outputs[2][:chacha20poly1305.KeySize])
+	mlkem.ZeroBytes(outputs[2])
+}
+
+func (ss *symmetricState) encryptAndHash(out, plaintext []byte) ([]byte, error) {
+	start := len(out)
+	out, err := ss.cs.Encrypt(out, ss.h, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	ss.mixHash(out[start:])
+	return out, nil
+}
+
+func (ss *symmetricState) decryptAndHash(out, ciphertext []byte) ([]byte, error) {
+	out, err := ss.cs.Decrypt(out, ss.h, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	ss.mixHash(ciphertext)
+	return out, nil
+}
+
+// split returns the two transport CipherStates, for messages from the
+// initiator and from the responder.
+func (ss *symmetricState) split() (*CipherState, *CipherState) {
+	outputs := ss.hkdf(nil, 2)
+	c1, c2 := new(CipherState), new(CipherState)
+	c1.initializeKey(outputs[0][:chacha20poly1305.KeySize])
+	c2.initializeKey(outputs[1][:chacha20poly1305.KeySize])
+	mlkem.ZeroBytes(outputs[0])
+	mlkem.ZeroBytes(outputs[1])
+	mlkem.ZeroBytes(ss.ck)
+	return c1, c2
+}
+
+// hkdf is the Noise HKDF: HMAC-HASH extraction keyed with ck, followed
+// by n chained expansion blocks.
+func (ss *symmetricState) hkdf(ikm []byte, n int) [][]byte {
+	extract := hmac.New(ss.newHash, ss.ck)
+	_, _ = extract.Write(ikm)
+	prk := extract.Sum(nil)
+	defer mlkem.ZeroBytes(prk)
+	outputs := make([][]byte, n)
+	var prev []byte
+	for i := range outputs {
+		expand := hmac.New(ss.newHash, prk)
+		_, _ = expand.Write(prev)
+		_, _ = expand.Write([]byte{byte(i + 1)})
+		outputs[i] = expand.Sum(nil)
+		prev = outputs[i]
+	}
+	return outputs
+}
@@ -0,0 +1,174 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package openpgp
+
+import (
+	"crypto/rand"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"github.com/symbolicsoft/kyber-k2so/internal/x448"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/sha3"
+)
+
+// mlkemSeedSize is the size of the ML-KEM private key seed d || z,
+// the form in which secret key packets store the ML-KEM key.
+const mlkemSeedSize = 64
+
+// combinerDomain is the domain separator of the KEM combiner.
+const combinerDomain = "OpenPGPCompositeKDFv1"
+
+// algorithmParams holds the component sizes of a composite algorithm.
+// For X25519 and X448 the public key, secret key and ciphertext of the
+// ECDH component all have the same size.
+type algorithmParams struct {
+	ecdhSize    int
+	mlkemPKSize int
+	mlkemCTSize int
+}
+
+func (alg PublicKeyAlgorithm) params() (algorithmParams, error) {
+	switch alg {
+	case MLKEM768X25519:
+		return algorithmParams{curve25519.PointSize, kyberk2so.Kyber768PKBytes, kyberk2so.Kyber768CTBytes}, nil
+	case MLKEM1024X448:
+		return algorithmParams{x448.PointSize, kyberk2so.Kyber1024PKBytes, kyberk2so.Kyber1024CTBytes}, nil
+	default:
+		return algorithmParams{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// ecdh runs the algorithm's Diffie-Hellman function, rejecting
+// low-order points.
+func (alg PublicKeyAlgorithm) ecdh(scalar, point []byte) ([]byte, error) {
+	if alg == MLKEM1024X448 {
+		return x448.X448(scalar, point)
+	}
+	return curve25519.X25519(scalar, point)
+}
+
+// basepoint returns the generator of the algorithm's ECDH group.
+func basepoint(alg PublicKeyAlgorithm) []byte {
+	if alg == MLKEM1024X448 {
+		return x448.Basepoint
+	}
+	return curve25519.Basepoint
+}
+
+// ecdhKeypair generates an ECDH component key pair.
+func (alg PublicKeyAlgorithm) ecdhKeypair() ([]byte, []byte, error) {
+	p, err := alg.params()
+	if err != nil {
+		return nil, nil, err
+	}
+	secret := make([]byte, p.ecdhSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, nil, err
+	}
+	public, err := alg.ecdh(secret, basepoint(alg))
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret, public, nil
+}
+
+// mlkemKeypair expands the ML-KEM seed d || z into the decapsulation
+// and encapsulation keys.
+func (alg PublicKeyAlgorithm) mlkemKeypair(seed [mlkemSeedSize]byte) ([]byte, []byte, error) {
+	switch alg {
+	case MLKEM768X25519:
+		dk, ek, err := kyberk2so.KemKeypairDerand768(seed)
+		return dk[:], ek[:], err
+	case MLKEM1024X448:
+		dk, ek, err := kyberk2so.KemKeypairDerand1024(seed)
+		return dk[:], ek[:], err
+	default:
+		return nil, nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// encapsulate runs the composite encapsulation to pk, returning the
+// ECDH ciphertext, the ML-KEM ciphertext and the key-encryption key.
+func encapsulate(pk *PublicKey) ([]byte, []byte, []byte, error) {
+	ephemeral, ecdhCT, err := pk.Algorithm.ecdhKeypair()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ephemeral)
+	ecdhSS, err := pk.Algorithm.ecdh(ephemeral, pk.ecdh)
+	if err != nil {
+		return nil, nil, nil, ErrMalformedPacket
+	}
+	defer mlkem.ZeroBytes(ecdhSS)
+	var mlkemCT, mlkemSS []byte
+	switch pk.Algorithm {
+	case MLKEM768X25519:
+		ct, ss, err := kyberk2so.KemEncrypt768([kyberk2so.Kyber768PKBytes]byte(pk.mlkem))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mlkemCT, mlkemSS = ct[:], ss[:]
+	case MLKEM1024X448:
+		ct, ss, err := kyberk2so.KemEncrypt1024([kyberk2so.Kyber1024PKBytes]byte(pk.mlkem))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mlkemCT, mlkemSS = ct[:], ss[:]
+	default:
+		return nil, nil, nil, ErrUnsupportedAlgorithm
+	}
+	defer mlkem.ZeroBytes(mlkemSS)
+	kek := multiKeyCombine(mlkemSS, ecdhSS, ecdhCT, pk.ecdh, pk.Algorithm)
+	return ecdhCT, mlkemCT, kek, nil
+}
+
+// decapsulate recovers the key-encryption key from the ECDH and
+// ML-KEM ciphertexts with sk.
+func decapsulate(sk *PrivateKey, ecdhCT, mlkemCT []byte) ([]byte, error) {
+	ecdhSS, err := sk.Algorithm.ecdh(sk.ecdhSecret, ecdhCT)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	defer mlkem.ZeroBytes(ecdhSS)
+	var mlkemSS []byte
+	switch sk.Algorithm {
+	case MLKEM768X25519:
+		ss, err := kyberk2so.KemDecrypt768(
+			[kyberk2so.Kyber768CTBytes]byte(mlkemCT), [kyberk2so.Kyber768SKBytes]byte(sk.mlkemDK),
+		)
+		if err != nil {
+			return nil, err
+		}
+		mlkemSS = ss[:]
+	case MLKEM1024X448:
+		ss, err := kyberk2so.KemDecrypt1024(
+			[kyberk2so.Kyber1024CTBytes]byte(mlkemCT), [kyberk2so.Kyber1024SKBytes]byte(sk.mlkemDK),
+		)
+		if err != nil {
+			return nil, err
+		}
+		mlkemSS = ss[:]
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	defer mlkem.ZeroBytes(mlkemSS)
+	return multiKeyCombine(mlkemSS, ecdhSS, ecdhCT, sk.ecdh, sk.Algorithm), nil
+}
+
+// multiKeyCombine is the KEM combiner of draft-ietf-openpgp-pqc:
+//
+//	SHA3-256(mlkemKeyShare || ecdhKeyShare || ecdhCipherText ||
+//	         ecdhPublicKey || algId || domSep || len(domSep))
+func multiKeyCombine(mlkemSS, ecdhSS, ecdhCT, ecdhPK []byte, alg PublicKeyAlgorithm) []byte {
+	h := sha3.New256()
+	_, _ = h.Write(mlkemSS)
+	_, _ = h.Write(ecdhSS)
+	_, _ = h.Write(ecdhCT)
+	_, _ = h.Write(ecdhPK)
+	_, _ = h.Write([]byte{byte(alg)})
+	_, _ = h.Write([]byte(combinerDomain))
+	_, _ = h.Write([]byte{byte(len(combinerDomain))})
+	return h.Sum(nil)
+}
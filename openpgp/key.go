@@ -0,0 +1,176 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package openpgp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+// keyVersion is the version of the key packets handled here.
+const keyVersion = 6
+
+// s2kUsageNone is the S2K usage octet of unprotected secret key material.
+const s2kUsageNone = 0
+
+// PublicKey is a version 6 public key or subkey of a composite
+// ML-KEM + ECDH algorithm.
+type PublicKey struct {
+	CreationTime time.Time
+	Algorithm    PublicKeyAlgorithm
+	IsSubkey     bool
+	ecdh         []byte
+	mlkem        []byte
+}
+
+// PrivateKey is a version 6 secret key or subkey of a composite
+// ML-KEM + ECDH algorithm. The ML-KEM key is held as its 64-byte seed.
+type PrivateKey struct {
+	PublicKey
+	ecdhSecret []byte
+	mlkemSeed  [mlkemSeedSize]byte
+	mlkemDK    []byte
+}
+
+// GenerateKey returns a new private key of the given algorithm with
+// its creation time truncated to whole seconds. Encryption keys are
+// usually subkeys of a signing primary key.
+func GenerateKey(alg PublicKeyAlgorithm, creationTime time.Time, subkey bool) (*PrivateKey, error) {
+	sk := &PrivateKey{PublicKey: PublicKey{
+		CreationTime: time.Unix(creationTime.Unix(), 0),
+		Algorithm:    alg,
+		IsSubkey:     subkey,
+	}}
+	var err error
+	if sk.ecdhSecret, sk.ecdh, err = alg.ecdhKeypair(); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(sk.mlkemSeed[:]); err != nil {
+		return nil, err
+	}
+	if sk.mlkemDK, sk.mlkem, err = alg.mlkemKeypair(sk.mlkemSeed); err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+// Public returns the public part of the key.
+func (sk *PrivateKey) Public() *PublicKey {
+	pk := sk.PublicKey
+	return &pk
+}
+
+// Fingerprint returns the v6 fingerprint of the key: SHA-256 over the
+// octet 0x9B, the four-octet body length and the public key body.
+func (pk *PublicKey) Fingerprint() []byte {
+	body := pk.body()
+	h := sha256.New()
+	_, _ = h.Write([]byte{0x9b})
+	_, _ = h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(body))))
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
+
+// KeyID returns the v6 key ID, the first eight octets of the fingerprint.
+func (pk *PublicKey) KeyID() uint64 {
+	return binary.BigEndian.Uint64(pk.Fingerprint()[:8])
+}
+
+// Serialize writes the key as a Public-Key or Public-Subkey packet.
+func (pk *PublicKey) Serialize(w io.Writer) error {
+	tag := byte(tagPublicKey)
+	if pk.IsSubkey {
+		tag = tagPublicSubkey
+	}
+	return writePacket(w, tag, pk.body())
+}
+
+// Serialize writes the key as an unprotected Secret-Key or
+// Secret-Subkey packet. The secret key material is the ECDH secret
+// key followed by the ML-KEM seed.
+func (sk *PrivateKey) Serialize(w io.Writer) error {
+	tag := byte(tagSecretKey)
+	if sk.IsSubkey {
+		tag = tagSecretSubkey
+	}
+	body := sk.PublicKey.body()
+	body = append(body, s2kUsageNone)
+	body = append(body, sk.ecdhSecret...)
+	body = append(body, sk.mlkemSeed[:]...)
+	defer mlkem.ZeroBytes(body)
+	return writePacket(w, tag, body)
+}
+
+// body returns the public key packet body.
+func (pk *PublicKey) body() []byte {
+	b := []byte{keyVersion}
+	b = binary.BigEndian.AppendUint32(b, uint32(pk.CreationTime.Unix()))
+	b = append(b, byte(pk.Algorithm))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(pk.ecdh)+len(pk.mlkem)))
+	b = append(b, pk.ecdh...)
+	return append(b, pk.mlkem...)
+}
+
+// parse parses a public key packet body and returns the bytes that
+// follow the key material.
+func (pk *PublicKey) parse(b []byte) ([]byte, error) {
+	if len(b) < 10 {
+		return nil, ErrMalformedPacket
+	}
+	if b[0] != keyVersion {
+		return nil, ErrUnsupportedPacket
+	}
+	pk.CreationTime = time.Unix(int64(binary.BigEndian.Uint32(b[1:5])), 0)
+	pk.Algorithm = PublicKeyAlgorithm(b[5])
+	p, err := pk.Algorithm.params()
+	if err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(b[6:10]))
+	b = b[10:]
+	if n != p.ecdhSize+p.mlkemPKSize || len(b) < n {
+		return nil, ErrMalformedPacket
+	}
+	pk.ecdh = append([]byte(nil), b[:p.ecdhSize]...)
+	pk.mlkem = append([]byte(nil), b[p.ecdhSize:n]...)
+	return b[n:], nil
+}
+
+// parse parses a secret key packet body. The ML-KEM seed must expand
+// to the encapsulation key of the public key material.
+func (sk *PrivateKey) parse(b []byte) error {
+	rest, err := sk.PublicKey.parse(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return ErrMalformedPacket
+	}
+	if rest[0] != s2kUsageNone {
+		return ErrProtectedSecretKey
+	}
+	rest = rest[1:]
+	p, _ := sk.Algorithm.params()
+	if len(rest) != p.ecdhSize+mlkemSeedSize {
+		return ErrMalformedPacket
+	}
+	sk.ecdhSecret = append([]byte(nil), rest[:p.ecdhSize]...)
+	copy(sk.mlkemSeed[:], rest[p.ecdhSize:])
+	dk, ek, err := sk.Algorithm.mlkemKeypair(sk.mlkemSeed)
+	if err != nil {
+		return err
+	}
+	public, err := sk.Algorithm.ecdh(sk.ecdhSecret, basepoint(sk.Algorithm))
+	if err != nil || !bytes.Equal(ek, sk.mlkem) || !bytes.Equal(public, sk.ecdh) {
+		return ErrMalformedPacket
+	}
+	sk.mlkemDK = dk
+	return nil
+}
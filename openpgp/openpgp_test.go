@@ -0,0 +1,285 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package openpgp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+var testAlgorithms = []PublicKeyAlgorithm{MLKEM768X25519, MLKEM1024X448}
+
+func generateTestKey(t *testing.T, alg PublicKeyAlgorithm) *PrivateKey {
+	t.Helper()
+	sk, err := GenerateKey(alg, time.Unix(1700000000, 0), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk
+}
+
+func TestEncryptedKeyRoundTrip(t *testing.T) {
+	for _, alg := range testAlgorithms {
+		sk := generateTestKey(t, alg)
+		for _, size := range []int{16, 24, 32} {
+			sessionKey := make([]byte, size)
+			_, _ = rand.Read(sessionKey)
+			var buf bytes.Buffer
+			if err := SerializeEncryptedKey(&buf, sk.Public(), sessionKey); err != nil {
+				t.Fatal(err)
+			}
+			p, err := ReadPacket(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e, ok := p.(*EncryptedKey)
+			if !ok {
+				t.Fatalf("unexpected packet %T", p)
+			}
+			if e.KeyVersion != 6 || !bytes.Equal(e.KeyFingerprint, sk.Fingerprint()) || e.Algorithm != alg {
+				t.Fatal("recipient fields mismatch")
+			}
+			got, err := e.Decrypt(sk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, sessionKey) {
+				t.Fatalf("algorithm %d: session key mismatch", alg)
+			}
+		}
+	}
+}
+
+func TestEncryptedKeyEncoding(t *testing.T) {
+	sk := generateTestKey(t, MLKEM768X25519)
+	e, err := NewEncryptedKey(sk.Public(), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := e.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// Tag 1, a two-octet length, version 6, 33 octets of key version
+	// and fingerprint, algorithm 35, then 32 + 1088 ciphertext octets
+	// and the 40-octet wrapped key with its length.
+	bodyLen := 1 + 1 + 33 + 1 + 32 + 1088 + 1 + 40
+	if b[0] != 0xc1 || len(b) != 3+bodyLen || int(b[1]-192)<<8+int(b[2])+192 != bodyLen {
+		t.Fatalf("unexpected packet header %x", b[:3])
+	}
+	if b[3] != 6 || b[4] != 33 || b[5] != 6 || b[38] != 35 || b[3+bodyLen-41] != 40 {
+		t.Fatal("unexpected PKESK fields")
+	}
+}
+
+func TestAnonymousRecipient(t *testing.T) {
+	sk := generateTestKey(t, MLKEM1024X448)
+	e, err := NewEncryptedKey(sk.Public(), make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.KeyVersion, e.KeyFingerprint = 0, nil
+	var buf bytes.Buffer
+	if err := e.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadPacket(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.(*EncryptedKey).KeyVersion != 0 {
+		t.Fatal("expected an anonymous recipient")
+	}
+	if _, err := p.(*EncryptedKey).Decrypt(sk); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySerialization(t *testing.T) {
+	for _, alg := range testAlgorithms {
+		for _, subkey := range []bool{false, true} {
+			sk, err := GenerateKey(alg, time.Now(), subkey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pub, priv bytes.Buffer
+			if err := sk.Public().Serialize(&pub); err != nil {
+				t.Fatal(err)
+			}
+			if err := sk.Serialize(&priv); err != nil {
+				t.Fatal(err)
+			}
+			wantTags := map[bool][2]byte{false: {0xc6, 0xc5}, true: {0xce, 0xc7}}[subkey]
+			if pub.Bytes()[0] != wantTags[0] || priv.Bytes()[0] != wantTags[1] {
+				t.Fatalf("unexpected packet tags %x %x", pub.Bytes()[0], priv.Bytes()[0])
+			}
+
+			p, err := ReadPacket(bytes.NewReader(pub.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pk := p.(*PublicKey)
+			if pk.IsSubkey != subkey || !bytes.Equal(pk.Fingerprint(), sk.Fingerprint()) {
+				t.Fatal("public key round trip mismatch")
+			}
+			if !pk.CreationTime.Equal(sk.CreationTime) || pk.KeyID() != sk.KeyID() {
+				t.Fatal("public key metadata mismatch")
+			}
+
+			// The fingerprint hashes 0x9B, a four-octet length and the
+			// packet body.
+			body := pub.Bytes()[4:]
+			if pub.Bytes()[1] != 255 {
+				body = pub.Bytes()[3:]
+			}
+			h := sha256.New()
+			h.Write([]byte{0x9b, 0, 0, byte(len(body) >> 8), byte(len(body))})
+			h.Write(body)
+			if !bytes.Equal(h.Sum(nil), pk.Fingerprint()) {
+				t.Fatal("fingerprint mismatch")
+			}
+
+			p, err = ReadPacket(bytes.NewReader(priv.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed := p.(*PrivateKey)
+			e, err := NewEncryptedKey(pk, make([]byte, 32))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := e.Decrypt(parsed); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	a := generateTestKey(t, MLKEM768X25519)
+	b := generateTestKey(t, MLKEM768X25519)
+	c := generateTestKey(t, MLKEM1024X448)
+	e, err := NewEncryptedKey(a.Public(), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sk := range []*PrivateKey{b, c} {
+		if _, err := e.Decrypt(sk); err != ErrKeyMismatch {
+			t.Fatalf("expected ErrKeyMismatch, got %v", err)
+		}
+	}
+	e.KeyVersion, e.KeyFingerprint = 0, nil
+	if _, err := e.Decrypt(b); err != ErrDecryptionFailed {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	sk := generateTestKey(t, MLKEM1024X448)
+	var buf bytes.Buffer
+	if err := SerializeEncryptedKey(&buf, sk.Public(), make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+	packet := buf.Bytes()
+	// Flip a bit in the ECDH ciphertext, the ML-KEM ciphertext and the
+	// wrapped key in turn.
+	for _, off := range []int{3 + 36 + 1, 3 + 36 + 56 + 100, len(packet) - 1} {
+		tampered := bytes.Clone(packet)
+		tampered[off] ^= 0x01
+		p, err := ReadPacket(bytes.NewReader(tampered))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.(*EncryptedKey).Decrypt(sk); err != ErrDecryptionFailed {
+			t.Fatalf("offset %d: expected ErrDecryptionFailed, got %v", off, err)
+		}
+	}
+}
+
+func TestMalformedPackets(t *testing.T) {
+	sk := generateTestKey(t, MLKEM768X25519)
+	var pkesk, priv bytes.Buffer
+	if err := SerializeEncryptedKey(&pkesk, sk.Public(), make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sk.Serialize(&priv); err != nil {
+		t.Fatal(err)
+	}
+	truncated := bytes.Clone(pkesk.Bytes()[:pkesk.Len()-1])
+	truncated[2]--
+	protected := bytes.Clone(priv.Bytes())
+	protected[3+10+32+1184] = 254
+	otherSeed := bytes.Clone(priv.Bytes())
+	otherSeed[len(otherSeed)-mlkemSeedSize] ^= 0x01
+	for _, tc := range []struct {
+		packet []byte
+		err    error
+	}{
+		{pkesk.Bytes()[:pkesk.Len()-1], ErrMalformedPacket},
+		{truncated, ErrMalformedPacket},
+		{protected, ErrProtectedSecretKey},
+		{otherSeed, ErrMalformedPacket},
+		{[]byte{0x99, 0x00, 0x00}, ErrUnsupportedPacket},
+		{[]byte{0xc2, 0x00}, ErrUnsupportedPacket},
+		{[]byte{0xc1, 0xe1, 0x00}, ErrMalformedPacket},
+		{[]byte{0xc1, 0x03, 0x06, 0x00, 0x12}, ErrUnsupportedAlgorithm},
+		{[]byte{0xc1, 0x02, 0x03, 0x00}, ErrUnsupportedPacket},
+	} {
+		if _, err := ReadPacket(bytes.NewReader(tc.packet)); err != tc.err {
+			t.Fatalf("%x: expected %v, got %v", tc.packet[:min(len(tc.packet), 8)], tc.err, err)
+		}
+	}
+	if _, err := NewEncryptedKey(sk.Public(), make([]byte, 20)); err != ErrInvalidSessionKey {
+		t.Fatalf("expected ErrInvalidSessionKey, got %v", err)
+	}
+}
+
+func TestMultiKeyCombine(t *testing.T) {
+	// The combiner binds the algorithm identifier and every input.
+	in := [][]byte{make([]byte, 32), make([]byte, 32), make([]byte, 32), make([]byte, 32)}
+	base := multiKeyCombine(in[0], in[1], in[2], in[3], MLKEM768X25519)
+	if bytes.Equal(base, multiKeyCombine(in[0], in[1], in[2], in[3], MLKEM1024X448)) {
+		t.Fatal("combiner ignores the algorithm")
+	}
+	for i := range in {
+		in[i][0] = 1
+		if bytes.Equal(base, multiKeyCombine(in[0], in[1], in[2], in[3], MLKEM768X25519)) {
+			t.Fatalf("combiner ignores input %d", i)
+		}
+		in[i][0] = 0
+	}
+}
+
+// sequence returns n bytes counting up from start.
+func sequence(start byte, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+func TestMultiKeyCombineKnownAnswer(t *testing.T) {
+	// The expected values are SHA3-256 over the concatenation
+	// mlkemSS || ecdhSS || ecdhCT || ecdhPK || algId ||
+	// "OpenPGPCompositeKDFv1" || 21, computed independently.
+	for _, tc := range []struct {
+		alg  PublicKeyAlgorithm
+		size int
+		want string
+	}{
+		{MLKEM768X25519, 32, "8bec7eddd69de8f3d4d05efe44d46cb08d0e01435812de41168e0786a4dca40e"},
+		{MLKEM1024X448, 56, "589e7e5ff2773e6204034597c1475b756f1c6e03cb73ab6c43ca1674143ca4b2"},
+	} {
+		got := multiKeyCombine(sequence(0, 32), sequence(32, tc.size), sequence(96, tc.size), sequence(160, tc.size), tc.alg)
+		if hex.EncodeToString(got) != tc.want {
+			t.Errorf("%v: got %x, want %s", tc.alg, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pake
+
+import (
+	"crypto/hmac"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+// Initiator is the state of the initiator between sending its first
+// message and receiving the responder's answer.
+type Initiator struct {
+	cfg         Config
+	context     []byte
+	passwordKey []byte
+	sk          []byte
+	pk          []byte
+	msg         []byte
+	done        bool
+}
+
+// NewInitiator starts an exchange and returns the initiator state and
+// the first message: a fresh Kemeleon-encoded public key encrypted
+// under the password.
+func NewInitiator(cfg Config) (*Initiator, []byte, error) {
+	if err := cfg.check(); err != nil {
+		return nil, nil, err
+	}
+	context := cfg.context()
+	sk, pk, err := cfg.KEM.funcs().keypair()
+	if err != nil {
+		return nil, nil, err
+	}
+	passwordKey := cfg.passwordKey(context)
+	msg := append([]byte(nil), pk...)
+	feistel(msg, passwordKey, cipherTweak(context, "public key"), false)
+	return &Initiator{cfg: cfg, context: context, passwordKey: passwordKey, sk: sk, pk: pk, msg: msg}, msg, nil
+}
+
+// Finish processes the responder's answer. It returns the key
+// confirmation message to send back and the session key. A wrong
+// password on either side results in ErrAuthenticationFailed. The state
+// cannot be used again, whether or not Finish succeeds.
+func (i *Initiator) Finish(response []byte) ([]byte, []byte, error) {
+	if i.done {
+		return nil, nil, ErrFinished
+	}
+	i.done = true
+	defer mlkem.ZeroBytes(i.sk)
+	defer mlkem.ZeroBytes(i.passwordKey)
+	funcs := i.cfg.KEM.funcs()
+	bodySize := funcs.ctSize
+	if i.cfg.Mode == CAKE {
+		bodySize = funcs.encodedCTSize
+	}
+	if len(response) != bodySize+TagSize {
+		return nil, nil, ErrMalformedMessage
+	}
+	body := response[:bodySize]
+	ct := append([]byte(nil), body...)
+	if i.cfg.Mode == CAKE {
+		feistel(ct, i.passwordKey, cipherTweak(i.context, "ciphertext"), true)
+	}
+	ss, err := funcs.decrypt(ct, i.sk, i.cfg.Mode == CAKE)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	th := transcriptHash(i.context, i.msg, body, i.pk, ct)
+	k, err := deriveKeys(ss, th)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hmac.Equal(tag(k.responder, th), response[bodySize:]) {
+		k.wipe(false)
+		return nil, nil, ErrAuthenticationFailed
+	}
+	confirmation := tag(k.initiator, th)
+	k.wipe(true)
+	return confirmation, k.session, nil
+}
+
+// Responder is the state of the responder between answering the
+// initiator and receiving its key confirmation.
+type Responder struct {
+	keys       *keys
+	transcript []byte
+	done       bool
+}
+
+// NewResponder processes the initiator's first message and returns the
+// responder state and the answer: the ciphertext, encrypted under the
+// password in CAKE, followed by the responder's key confirmation tag.
+func NewResponder(cfg Config, msg []byte) (*Responder, []byte, error) {
+	if err := cfg.check(); err != nil {
+		return nil, nil, err
+	}
+	funcs := cfg.KEM.funcs()
+	if len(msg) != funcs.pkSize {
+		return nil, nil, ErrMalformedMessage
+	}
+	context := cfg.context()
+	passwordKey := cfg.passwordKey(context)
+	defer mlkem.ZeroBytes(passwordKey)
+	pk := append([]byte(nil), msg...)
+	feistel(pk, passwordKey, cipherTweak(context, "public key"), true)
+	ct, ss, err := funcs.encrypt(pk, cfg.Mode == CAKE)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ss)
+	body := append([]byte(nil), ct...)
+	if cfg.Mode == CAKE {
+		feistel(body, passwordKey, cipherTweak(context, "ciphertext"), false)
+	}
+	th := transcriptHash(context, msg, body, pk, ct)
+	k, err := deriveKeys(ss, th)
+	if err != nil {
+		return nil, nil, err
+	}
+	response := append(body, tag(k.responder, th)...)
+	return &Responder{keys: k, transcript: th}, response, nil
+}
+
+// Confirm verifies the initiator's key confirmation message and returns
+// the session key. The state cannot be used again, whether or not
+// Confirm succeeds.
+func (r *Responder) Confirm(confirmation []byte) ([]byte, error) {
+	if r.done {
+		return nil, ErrFinished
+	}
+	r.done = true
+	if !hmac.Equal(tag(r.keys.initiator, r.transcript), confirmation) {
+		r.keys.wipe(false)
+		return nil, ErrAuthenticationFailed
+	}
+	r.keys.wipe(true)
+	return r.keys.session, nil
+}
@@ -0,0 +1,373 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package pake implements the CAKE and OCAKE password-authenticated key
+// exchanges (Beguinet, Chevalier, Pointcheval, Ricosset and Rossi,
+// "GeT a CAKE: Generic Transformations from Key Encapsulation Mechanisms
+// to Password Authenticated Key Exchanges", ACNS 2023) over ML-KEM.
+//
+// The initiator generates an ephemeral ML-KEM key pair and sends its
+// public key encrypted under the password with an ideal cipher. The
+// responder decrypts it, encapsulates to it, and returns the ciphertext,
+// encrypted under the password in CAKE and in the clear in OCAKE. Both
+// parties derive a session key and key confirmation keys from the KEM
+// shared secret and the transcript, and exchange key confirmation tags:
+// the responder's tag is the authenticator of OCAKE, and the
+// initiator's tag completes explicit mutual authentication. Each run
+// lets an active attacker test a single password guess.
+//
+// The ideal cipher must not reveal whether a decryption is a plausible
+// key: a 12-bit packed encapsulation key decrypted under a wrong
+// password would usually hold coefficients of at least q, which would
+// allow offline password testing. Public keys and ciphertexts are
+// therefore first mapped to uniformly random bit strings with the
+// Kemeleon encodings of the root package, for which every bit string
+// decodes to a valid key or ciphertext, and the ideal cipher is an
+// 8-round Feistel network over the whole encoding with SHAKE256 round
+// functions, keyed by the password and tweaked by the session
+// identifier, the party identities and the role of the value (Dai and
+// Steinberger, "Indifferentiability of 8-Round Feistel Networks",
+// CRYPTO 2016).
+//
+// The password is hardened with Argon2id, salted with a hash of the
+// session identifier and the party identities.
+package pake
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidConfig is returned when a Config has no password, or
+	// names an unknown mode or KEM.
+	ErrInvalidConfig = errors.New("pake: invalid configuration")
+
+	// ErrMalformedMessage is returned when a message has the wrong length.
+	ErrMalformedMessage = errors.New("pake: malformed message")
+
+	// ErrAuthenticationFailed is returned when a key confirmation tag
+	// does not verify, which includes the case of a wrong password.
+	ErrAuthenticationFailed = errors.New("pake: authentication failed")
+
+	// ErrFinished is returned when an exchange state is used again after
+	// it has completed or failed.
+	ErrFinished = errors.New("pake: exchange already finished")
+)
+
+// SessionKeySize is the size of the session key.
+const SessionKeySize = 32
+
+// TagSize is the size of a key confirmation tag.
+const TagSize = sha256.Size
+
+// Mode selects the CAKE or OCAKE construction.
+type Mode int
+
+// Supported modes.
+const (
+	CAKE Mode = iota + 1
+	OCAKE
+)
+
+// KEM identifies the ML-KEM parameter set.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// Default Argon2id parameters, the second recommended option of RFC 9106.
+const (
+	DefaultArgon2Time    = 3
+	DefaultArgon2Memory  = 64 * 1024
+	DefaultArgon2Threads = 4
+)
+
+// Config describes one party of an exchange. Both parties must use the
+// same mode, KEM, session identifier, identities and Argon2id parameters.
+type Config struct {
+	Mode     Mode
+	KEM      KEM
+	Password []byte
+
+	// SessionID identifies the session, for example a pairing code
+	// shown on both devices. It may be empty.
+	SessionID []byte
+
+	// InitiatorID and ResponderID name the parties and may be empty.
+	InitiatorID []byte
+	ResponderID []byte
+
+	// Argon2Time, Argon2Memory (in KiB) and Argon2Threads are the
+	// Argon2id parameters. Zero values select the defaults.
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// check validates the configuration.
+func (c *Config) check() error {
+	if (c.Mode != CAKE && c.Mode != OCAKE) || c.KEM.funcs() == nil || len(c.Password) == 0 {
+		return ErrInvalidConfig
+	}
+	return nil
+}
+
+// context returns the length-prefixed protocol name, session identifier
+// and identities, which tweak the cipher, salt the password and start
+// the transcript.
+func (c *Config) context() []byte {
+	name := "kyberk2so CAKE v1"
+	if c.Mode == OCAKE {
+		name = "kyberk2so OCAKE v1"
+	}
+	var out []byte
+	for _, field := range [][]byte{[]byte(name), {byte(c.KEM)}, c.SessionID, c.InitiatorID, c.ResponderID} {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(field)))
+		out = append(out, field...)
+	}
+	return out
+}
+
+// passwordKey hardens the password with Argon2id.
+func (c *Config) passwordKey(context []byte) []byte {
+	t, m, p := c.Argon2Time, c.Argon2Memory, c.Argon2Threads
+	if t == 0 {
+		t = DefaultArgon2Time
+	}
+	if m == 0 {
+		m = DefaultArgon2Memory
+	}
+	if p == 0 {
+		p = DefaultArgon2Threads
+	}
+	salt := sha256.Sum256(append([]byte("kyberk2so pake salt"), context...))
+	return argon2.IDKey(c.Password, salt[:], t, m, p, 32)
+}
+
+// kemFuncs holds the operations of a parameter set. Public keys are
+// always Kemeleon-encoded; ciphertexts are Kemeleon-encoded in CAKE,
+// where they are encrypted, and in the normal format in OCAKE.
+type kemFuncs struct {
+	pkSize        int
+	ctSize        int
+	encodedCTSize int
+	keypair       func() (sk, pk []byte, err error)
+	encrypt       func(pk []byte, encoded bool) (ct, ss []byte, err error)
+	decrypt       func(ct, sk []byte, encoded bool) ([]byte, error)
+}
+
+var kem512 = kemFuncs{
+	pkSize:        kyberk2so.Kemeleon512PKBytes,
+	ctSize:        kyberk2so.Kyber512CTBytes,
+	encodedCTSize: kyberk2so.Kemeleon512CTBytes,
+	keypair: func() ([]byte, []byte, error) {
+		sk, _, pk, err := kyberk2so.KemeleonKeypair512()
+		return sk[:], pk[:], err
+	},
+	encrypt: func(pk []byte, encoded bool) ([]byte, []byte, error) {
+		if encoded {
+			ct, ss, err := kyberk2so.KemeleonEncrypt512([kyberk2so.Kemeleon512PKBytes]byte(pk))
+			return ct[:], ss[:], err
+		}
+		decoded := kyberk2so.KemeleonDecodePublicKey512([kyberk2so.Kemeleon512PKBytes]byte(pk))
+		ct, ss, err := kyberk2so.KemEncrypt512(decoded)
+		return ct[:], ss[:], err
+	},
+	decrypt: func(ct, sk []byte, encoded bool) ([]byte, error) {
+		privateKey := [kyberk2so.Kyber512SKBytes]byte(sk)
+		if encoded {
+			ciphertext := [kyberk2so.Kemeleon512CTBytes]byte(ct)
+			ss, err := kyberk2so.KemeleonDecrypt512(ciphertext, privateKey)
+			return ss[:], err
+		}
+		ciphertext := [kyberk2so.Kyber512CTBytes]byte(ct)
+		ss, err := kyberk2so.KemDecrypt512(ciphertext, privateKey)
+		return ss[:], err
+	},
+}
+
+var kem768 = kemFuncs{
+	pkSize:        kyberk2so.Kemeleon768PKBytes,
+	ctSize:        kyberk2so.Kyber768CTBytes,
+	encodedCTSize: kyberk2so.Kemeleon768CTBytes,
+	keypair: func() ([]byte, []byte, error) {
+		sk, _, pk, err := kyberk2so.KemeleonKeypair768()
+		return sk[:], pk[:], err
+	},
+	encrypt: func(pk []byte, encoded bool) ([]byte, []byte, error) {
+		if encoded {
+			ct, ss, err := kyberk2so.KemeleonEncrypt768([kyberk2so.Kemeleon768PKBytes]byte(pk))
+			return ct[:], ss[:], err
+		}
+		decoded := kyberk2so.KemeleonDecodePublicKey768([kyberk2so.Kemeleon768PKBytes]byte(pk))
+		ct, ss, err := kyberk2so.KemEncrypt768(decoded)
+		return ct[:], ss[:], err
+	},
+	decrypt: func(ct, sk []byte, encoded bool) ([]byte, error) {
+		privateKey := [kyberk2so.Kyber768SKBytes]byte(sk)
+		if encoded {
+			ciphertext := [kyberk2so.Kemeleon768CTBytes]byte(ct)
+			ss, err := kyberk2so.KemeleonDecrypt768(ciphertext, privateKey)
+			return ss[:], err
+		}
+		ciphertext := [kyberk2so.Kyber768CTBytes]byte(ct)
+		ss, err := kyberk2so.KemDecrypt768(ciphertext, privateKey)
+		return ss[:], err
+	},
+}
+
+var kem1024 = kemFuncs{
+	pkSize:        kyberk2so.Kemeleon1024PKBytes,
+	ctSize:        kyberk2so.Kyber1024CTBytes,
+	encodedCTSize: kyberk2so.Kemeleon1024CTBytes,
+	keypair: func() ([]byte, []byte, error) {
+		sk, _, pk, err := kyberk2so.KemeleonKeypair1024()
+		return sk[:], pk[:], err
+	},
+	encrypt: func(pk []byte, encoded bool) ([]byte, []byte, error) {
+		if encoded {
+			ct, ss, err := kyberk2so.KemeleonEncrypt1024([kyberk2so.Kemeleon1024PKBytes]byte(pk))
+			return ct[:], ss[:], err
+		}
+		decoded := kyberk2so.KemeleonDecodePublicKey1024([kyberk2so.Kemeleon1024PKBytes]byte(pk))
+		ct, ss, err := kyberk2so.KemEncrypt1024(decoded)
+		return ct[:], ss[:], err
+	},
+	decrypt: func(ct, sk []byte, encoded bool) ([]byte, error) {
+		privateKey := [kyberk2so.Kyber1024SKBytes]byte(sk)
+		if encoded {
+			ciphertext := [kyberk2so.Kemeleon1024CTBytes]byte(ct)
+			ss, err := kyberk2so.KemeleonDecrypt1024(ciphertext, privateKey)
+			return ss[:], err
+		}
+		ciphertext := [kyberk2so.Kyber1024CTBytes]byte(ct)
+		ss, err := kyberk2so.KemDecrypt1024(ciphertext, privateKey)
+		return ss[:], err
+	},
+}
+
+// funcs returns the operations of the parameter set, or nil.
+func (kem KEM) funcs() *kemFuncs {
+	switch kem {
+	case MLKEM512:
+		return &kem512
+	case MLKEM768:
+		return &kem768
+	case MLKEM1024:
+		return &kem1024
+	default:
+		return nil
+	}
+}
+
+// feistelRounds is the number of rounds of the ideal cipher.
+const feistelRounds = 8
+
+// feistel encrypts or decrypts x in place with the Feistel network keyed
+// by key and tweaked by tweak. The left half holds the first len(x)/2
+// bytes.
+func feistel(x, key, tweak []byte, decrypt bool) {
+	left, right := x[:len(x)/2], x[len(x)/2:]
+	for r := 0; r < feistelRounds; r++ {
+		round := r
+		if decrypt {
+			round = feistelRounds - 1 - r
+		}
+		if round%2 == 0 {
+			feistelRound(right, left, key, tweak, round)
+		} else {
+			feistelRound(left, right, key, tweak, round)
+		}
+	}
+}
+
+// feistelRound XORs dst with SHAKE256(key, tweak, round, src).
+func feistelRound(dst, src, key, tweak []byte, round int) {
+	h := sha3.NewShake256()
+	_, _ = h.Write([]byte("kyberk2so pake feistel"))
+	_, _ = h.Write(key)
+	_, _ = h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(tweak))))
+	_, _ = h.Write(tweak)
+	_, _ = h.Write([]byte{byte(round)})
+	_, _ = h.Write(src)
+	mask := make([]byte, len(dst))
+	_, _ = h.Read(mask)
+	for i := range dst {
+		dst[i] ^= mask[i]
+	}
+}
+
+// cipherTweak returns the tweak for the value of the given role.
+func cipherTweak(context []byte, role string) []byte {
+	return append(append([]byte(nil), context...), role...)
+}
+
+// keys holds the keys derived at the end of an exchange.
+type keys struct {
+	session   []byte
+	initiator []byte
+	responder []byte
+}
+
+// deriveKeys extracts a pseudorandom key from the KEM shared secret with
+// the transcript hash as salt, and expands it into the session key and
+// the two key confirmation keys.
+func deriveKeys(ss, transcript []byte) (*keys, error) {
+	prk, err := hkdf.Extract(sha256.New, ss, transcript)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(prk)
+	k := new(keys)
+	if k.session, err = hkdf.Expand(sha256.New, prk, "session key", SessionKeySize); err != nil {
+		return nil, err
+	}
+	if k.initiator, err = hkdf.Expand(sha256.New, prk, "initiator confirmation", sha256.Size); err != nil {
+		return nil, err
+	}
+	if k.responder, err = hkdf.Expand(sha256.New, prk, "responder confirmation", sha256.Size); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// wipe clears the confirmation keys, and the session key unless it is
+// being returned.
+func (k *keys) wipe(keepSession bool) {
+	if !keepSession {
+		mlkem.ZeroBytes(k.session)
+	}
+	mlkem.ZeroBytes(k.initiator)
+	mlkem.ZeroBytes(k.responder)
+}
+
+// tag returns HMAC-SHA256(key, transcript).
+func tag(key, transcript []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(transcript)
+	return mac.Sum(nil)
+}
+
+// transcriptHash returns SHA-256 over the context, both messages without
+// tags, and the public key and ciphertext they carry.
+func transcriptHash(context, msg1, body, pk, ct []byte) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{context, msg1, body, pk, ct} {
+		_, _ = h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(field))))
+		_, _ = h.Write(field)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,184 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// testConfig returns a configuration with cheap Argon2id parameters.
+func testConfig(mode Mode, kem KEM, password string) Config {
+	return Config{
+		Mode:          mode,
+		KEM:           kem,
+		Password:      []byte(password),
+		SessionID:     []byte("pairing 4711"),
+		InitiatorID:   []byte("phone"),
+		ResponderID:   []byte("gateway"),
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+	}
+}
+
+// run performs an exchange, applying tamper to each message in turn.
+func run(initiatorConfig, responderConfig Config, tamper func(step int, msg []byte)) ([]byte, []byte, error) {
+	initiator, msg1, err := NewInitiator(initiatorConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(1, msg1)
+	responder, msg2, err := NewResponder(responderConfig, msg1)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(2, msg2)
+	msg3, keyA, err := initiator.Finish(msg2)
+	if err != nil {
+		return nil, nil, err
+	}
+	tamper(3, msg3)
+	keyB, err := responder.Confirm(msg3)
+	return keyA, keyB, err
+}
+
+func noTamper(int, []byte) {}
+
+func TestAgreement(t *testing.T) {
+	for _, mode := range []Mode{CAKE, OCAKE} {
+		for _, kem := range []KEM{MLKEM512, MLKEM768, MLKEM1024} {
+			cfg := testConfig(mode, kem, "correct horse battery staple")
+			keyA, keyB, err := run(cfg, cfg, noTamper)
+			if err != nil {
+				t.Fatalf("mode %d, KEM %d: %v", mode, kem, err)
+			}
+			if len(keyA) != SessionKeySize || !bytes.Equal(keyA, keyB) {
+				t.Fatalf("mode %d, KEM %d: session keys differ", mode, kem)
+			}
+		}
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	for _, mode := range []Mode{CAKE, OCAKE} {
+		cfg := testConfig(mode, MLKEM768, "correct horse battery staple")
+		wrong := testConfig(mode, MLKEM768, "correct horse battery stapler")
+		if _, _, err := run(cfg, wrong, noTamper); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("mode %d: got %v, want ErrAuthenticationFailed", mode, err)
+		}
+		if _, _, err := run(wrong, cfg, noTamper); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("mode %d: got %v, want ErrAuthenticationFailed", mode, err)
+		}
+		// The session identifier and identities are bound as well.
+		other := cfg
+		other.SessionID = []byte("pairing 4712")
+		if _, _, err := run(cfg, other, noTamper); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("mode %d: got %v, want ErrAuthenticationFailed", mode, err)
+		}
+		other = cfg
+		other.InitiatorID, other.ResponderID = other.ResponderID, other.InitiatorID
+		if _, _, err := run(cfg, other, noTamper); !errors.Is(err, ErrAuthenticationFailed) {
+			t.Fatalf("mode %d: got %v, want ErrAuthenticationFailed", mode, err)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	for _, mode := range []Mode{CAKE, OCAKE} {
+		cfg := testConfig(mode, MLKEM512, "hunter2")
+		for step := 1; step <= 3; step++ {
+			for _, offset := range []int{0, 500, -1} {
+				_, _, err := run(cfg, cfg, func(s int, msg []byte) {
+					if s != step {
+						return
+					}
+					i := offset
+					if i < 0 || i >= len(msg) {
+						i = len(msg) - 1
+					}
+					msg[i] ^= 1
+				})
+				if !errors.Is(err, ErrAuthenticationFailed) {
+					t.Fatalf("mode %d, step %d, offset %d: got %v", mode, step, offset, err)
+				}
+			}
+		}
+	}
+}
+
+func TestFeistel(t *testing.T) {
+	key, tweak := []byte("key"), []byte("tweak")
+	for _, size := range []int{1, 2, 33, 1156} {
+		x := make([]byte, size)
+		_, _ = rand.Read(x)
+		y := append([]byte(nil), x...)
+		feistel(y, key, tweak, false)
+		if size > 1 && bytes.Equal(x, y) {
+			t.Fatalf("size %d: encryption is the identity", size)
+		}
+		z := append([]byte(nil), y...)
+		feistel(z, key, append(tweak, 0), true)
+		if size > 1 && bytes.Equal(x, z) {
+			t.Fatalf("size %d: tweak is ignored", size)
+		}
+		feistel(y, key, tweak, true)
+		if !bytes.Equal(x, y) {
+			t.Fatalf("size %d: decryption does not invert encryption", size)
+		}
+	}
+}
+
+func TestWrongPasswordDecryption(t *testing.T) {
+	// Decrypting the first message under any password yields a valid
+	// public key that the responder encapsulates to, so a wrong
+	// password is only detected through key confirmation.
+	cfg := testConfig(CAKE, MLKEM768, "correct horse battery staple")
+	_, msg1, err := NewInitiator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"a", "b", "c", "d"} {
+		if _, _, err := NewResponder(testConfig(CAKE, MLKEM768, password), msg1); err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, cfg := range []Config{
+		testConfig(0, MLKEM768, "pw"),
+		testConfig(CAKE, 0, "pw"),
+		testConfig(OCAKE, MLKEM768, ""),
+	} {
+		if _, _, err := NewInitiator(cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("got %v, want ErrInvalidConfig", err)
+		}
+	}
+	cfg := testConfig(CAKE, MLKEM768, "pw")
+	if _, _, err := NewResponder(cfg, make([]byte, 10)); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("got %v, want ErrMalformedMessage", err)
+	}
+	// The modes are not interchangeable.
+	if _, _, err := run(cfg, testConfig(OCAKE, MLKEM768, "pw"), noTamper); !errors.Is(err, ErrMalformedMessage) {
+		t.Fatalf("got %v, want ErrMalformedMessage", err)
+	}
+	initiator, msg1, _ := NewInitiator(cfg)
+	responder, msg2, _ := NewResponder(cfg, msg1)
+	msg3, _, err := initiator.Finish(msg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := initiator.Finish(msg2); !errors.Is(err, ErrFinished) {
+		t.Fatalf("got %v, want ErrFinished", err)
+	}
+	if _, err := responder.Confirm(msg3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := responder.Confirm(msg3); !errors.Is(err, ErrFinished) {
+		t.Fatalf("got %v, want ErrFinished", err)
+	}
+}
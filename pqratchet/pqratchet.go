@@ -0,0 +1,430 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package pqratchet implements a sparse post-quantum ratchet over
+// ML-KEM-768, meant to run alongside a classical double ratchet to give
+// long-lived sessions post-compromise security against quantum
+// adversaries.
+//
+// The session moves through epochs. In each epoch one party, alternating
+// from epoch to epoch, generates a fresh ML-KEM-768 key pair and sends
+// its encapsulation key in fixed-size chunks attached to its outgoing
+// messages. Once the peer holds every chunk it encapsulates and sends
+// the ciphertext back the same way. The shared secret is mixed into the
+// epoch key; the decapsulating party starts sending in the new epoch,
+// and the encapsulating party follows as soon as it receives a message
+// from it. Chunks are sent round-robin until the peer moves on, so lost
+// messages only delay an epoch change.
+//
+// Every message gets a key from a per-epoch, per-direction symmetric
+// chain. Callers combine it with the classical double ratchet's message
+// key, for example with HKDF over both, and authenticate the header
+// returned by Send as associated data.
+package pqratchet
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+const (
+	// KeySize is the size of a message key.
+	KeySize = 32
+
+	// MaxSkip is the largest number of message keys a single message
+	// may skip in a receiving chain.
+	MaxSkip = 1000
+
+	// headerSize is the size of a header without a chunk: the epoch,
+	// the message counter and the chunk type.
+	headerSize = 8 + 4 + 1
+)
+
+var (
+	// ErrInvalidChunkSize is returned by New for a chunk size outside
+	// 1 to 65535.
+	ErrInvalidChunkSize = errors.New("pqratchet: invalid chunk size")
+
+	// ErrMalformedHeader is returned when a header cannot be parsed.
+	ErrMalformedHeader = errors.New("pqratchet: malformed header")
+
+	// ErrUnknownEpoch is returned when a header names an epoch whose
+	// receiving chain is not available, either because it has been
+	// pruned or because it has not started.
+	ErrUnknownEpoch = errors.New("pqratchet: unknown epoch")
+
+	// ErrDuplicateMessage is returned when a message key has already
+	// been used.
+	ErrDuplicateMessage = errors.New("pqratchet: duplicate message")
+
+	// ErrTooManySkipped is returned when a header would skip more than
+	// MaxSkip message keys.
+	ErrTooManySkipped = errors.New("pqratchet: too many skipped messages")
+
+	// ErrInvalidState is returned by UnmarshalBinary for malformed state.
+	ErrInvalidState = errors.New("pqratchet: invalid serialized state")
+)
+
+// Chunk types carried in headers.
+const (
+	chunkNone = iota
+	chunkEK
+	chunkCT
+)
+
+// phase is the KEM exchange step of the current epoch.
+type phase byte
+
+const (
+	// phaseSendEK: this party generated the key pair, sends ek chunks
+	// and collects ct chunks.
+	phaseSendEK phase = iota + 1
+	// phaseCollectEK: this party collects ek chunks.
+	phaseCollectEK
+	// phaseSendCT: this party has encapsulated, sends ct chunks and
+	// waits for a message in the next epoch.
+	phaseSendCT
+)
+
+// chain is a symmetric KDF chain and its message counter.
+type chain struct {
+	key [32]byte
+	n   uint32
+}
+
+// next returns the message key for n and advances the chain.
+func (c *chain) next() [KeySize]byte {
+	var mk [KeySize]byte
+	m := hmac.New(sha256.New, c.key[:])
+	_, _ = m.Write([]byte{0x01})
+	m.Sum(mk[:0])
+	m = hmac.New(sha256.New, c.key[:])
+	_, _ = m.Write([]byte{0x02})
+	m.Sum(c.key[:0])
+	c.n++
+	return mk
+}
+
+type skippedKey struct {
+	epoch uint64
+	n     uint32
+}
+
+// Ratchet is one party's sparse ratchet state. It is not safe for
+// concurrent use.
+type Ratchet struct {
+	initiator bool
+	chunkSize int
+
+	// epochKey is the latest epoch key this party knows.
+	epochKey  [32]byte
+	sendEpoch uint64
+	send      chain
+	recv      map[uint64]chain
+	skipped   map[skippedKey][KeySize]byte
+
+	phase     phase
+	dk        [kyberk2so.Kyber768SKBytes]byte
+	ek        [kyberk2so.Kyber768PKBytes]byte
+	ct        [kyberk2so.Kyber768CTBytes]byte
+	received  []bool
+	nextChunk int
+	// nextSend is the sending chain of the next epoch, held in
+	// phaseSendCT until the peer is known to have the epoch key.
+	nextSend chain
+}
+
+// New returns the ratchet state for one party of a session whose
+// initial shared secret is sk, such as the PQXDH session key. Exactly
+// one party must be the initiator, and both must use the same chunk
+// size, which bounds the KEM data carried by each header.
+func New(sk []byte, initiator bool, chunkSize int) (*Ratchet, error) {
+	if chunkSize < 1 || chunkSize > 0xffff {
+		return nil, ErrInvalidChunkSize
+	}
+	root, err := hkdf.Key(sha256.New, sk, nil, "KyberK2SO PQRatchet v1 root", 32)
+	if err != nil {
+		return nil, err
+	}
+	r := &Ratchet{
+		initiator: initiator,
+		chunkSize: chunkSize,
+		recv:      make(map[uint64]chain),
+		skipped:   make(map[skippedKey][KeySize]byte),
+	}
+	copy(r.epochKey[:], root)
+	mlkem.ZeroBytes(root)
+	r.send = r.chain(initiator)
+	r.recv[0] = r.chain(!initiator)
+	if err := r.startEpoch(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Epoch returns the epoch this party sends in.
+func (r *Ratchet) Epoch() uint64 {
+	return r.sendEpoch
+}
+
+// chain returns the initial chain of the current epoch key for
+// messages sent by the initiator or by the responder.
+func (r *Ratchet) chain(initiator bool) chain {
+	label := "KyberK2SO PQRatchet v1 chain B"
+	if initiator {
+		label = "KyberK2SO PQRatchet v1 chain A"
+	}
+	var c chain
+	key, _ := hkdf.Expand(sha256.New, r.epochKey[:], label, 32)
+	copy(c.key[:], key)
+	mlkem.ZeroBytes(key)
+	return c
+}
+
+// startEpoch sets up the KEM exchange of a new sending epoch: the
+// initiator generates the key pair in even epochs and the responder in
+// odd ones.
+func (r *Ratchet) startEpoch() error {
+	r.nextChunk = 0
+	if (r.sendEpoch%2 == 0) != r.initiator {
+		r.phase = phaseCollectEK
+		r.received = make([]bool, r.chunks(len(r.ek)))
+		return nil
+	}
+	dk, ek, err := kyberk2so.KemKeypair768()
+	if err != nil {
+		return err
+	}
+	r.phase = phaseSendEK
+	r.dk, r.ek = dk, ek
+	mlkem.ZeroBytes(dk[:])
+	r.received = make([]bool, r.chunks(len(r.ct)))
+	return nil
+}
+
+// chunks returns the number of chunks of an n-byte value.
+func (r *Ratchet) chunks(n int) int {
+	return (n + r.chunkSize - 1) / r.chunkSize
+}
+
+// chunk returns chunk i of b.
+func (r *Ratchet) chunk(b []byte, i int) []byte {
+	return b[i*r.chunkSize : min((i+1)*r.chunkSize, len(b))]
+}
+
+// Send returns the header of the next outgoing message and its message
+// key. The header carries the next KEM chunk, if any, and must be
+// delivered with the message and authenticated by it.
+func (r *Ratchet) Send() ([]byte, []byte) {
+	header := binary.BigEndian.AppendUint64(make([]byte, 0, headerSize+2+r.chunkSize), r.sendEpoch)
+	header = binary.BigEndian.AppendUint32(header, r.send.n)
+	var data []byte
+	switch r.phase {
+	case phaseSendEK:
+		header = append(header, chunkEK)
+		data = r.ek[:]
+	case phaseSendCT:
+		header = append(header, chunkCT)
+		data = r.ct[:]
+	default:
+		header = append(header, chunkNone)
+	}
+	if data != nil {
+		header = binary.BigEndian.AppendUint16(header, uint16(r.nextChunk))
+		header = append(header, r.chunk(data, r.nextChunk)...)
+		r.nextChunk = (r.nextChunk + 1) % r.chunks(len(data))
+	}
+	mk := r.send.next()
+	return header, mk[:]
+}
+
+// Recv processes the header of an incoming message and calls open with
+// its message key. The state is only updated if open returns nil, so a
+// forged or corrupted message cannot disturb the ratchet; open's error
+// is returned unchanged.
+func (r *Ratchet) Recv(header []byte, open func(key []byte) error) error {
+	if len(header) < headerSize {
+		return ErrMalformedHeader
+	}
+	epoch := binary.BigEndian.Uint64(header)
+	n := binary.BigEndian.Uint32(header[8:])
+	kind, rest := header[12], header[headerSize:]
+	var index int
+	switch kind {
+	case chunkNone:
+		if len(rest) != 0 {
+			return ErrMalformedHeader
+		}
+	case chunkEK, chunkCT:
+		size := len(r.ek)
+		if kind == chunkCT {
+			size = len(r.ct)
+		}
+		if len(rest) < 2 {
+			return ErrMalformedHeader
+		}
+		index, rest = int(binary.BigEndian.Uint16(rest)), rest[2:]
+		if index >= r.chunks(size) || len(rest) != len(r.chunk(make([]byte, size), index)) {
+			return ErrMalformedHeader
+		}
+	default:
+		return ErrMalformedHeader
+	}
+
+	next := r.clone()
+	mk, err := next.messageKey(epoch, n)
+	if err != nil {
+		return err
+	}
+	defer mlkem.ZeroBytes(mk[:])
+	if err := next.advance(epoch, kind, index, rest); err != nil {
+		return err
+	}
+	if err := open(mk[:]); err != nil {
+		return err
+	}
+	*r = *next
+	return nil
+}
+
+// messageKey returns the key of message n in the receiving chain of
+// epoch, storing the keys of skipped messages.
+func (r *Ratchet) messageKey(epoch uint64, n uint32) ([KeySize]byte, error) {
+	id := skippedKey{epoch, n}
+	if mk, ok := r.skipped[id]; ok {
+		delete(r.skipped, id)
+		return mk, nil
+	}
+	c, ok := r.recv[epoch]
+	if !ok {
+		return [KeySize]byte{}, ErrUnknownEpoch
+	}
+	if n < c.n {
+		return [KeySize]byte{}, ErrDuplicateMessage
+	}
+	if n-c.n > MaxSkip {
+		return [KeySize]byte{}, ErrTooManySkipped
+	}
+	for c.n < n {
+		r.skipped[skippedKey{epoch, c.n}] = c.next()
+	}
+	mk := c.next()
+	r.recv[epoch] = c
+	return mk, nil
+}
+
+// advance applies the epoch change and KEM chunk of an incoming message.
+func (r *Ratchet) advance(epoch uint64, kind byte, index int, data []byte) error {
+	if r.phase == phaseSendCT && epoch == r.sendEpoch+1 {
+		// The peer decapsulated and moved on, so follow it.
+		r.sendEpoch++
+		r.send = r.nextSend
+		r.prune()
+		if err := r.startEpoch(); err != nil {
+			return err
+		}
+	}
+	if epoch != r.sendEpoch {
+		return nil
+	}
+	switch {
+	case kind == chunkEK && r.phase == phaseCollectEK:
+		copy(r.ek[index*r.chunkSize:], data)
+		if !r.receiveChunk(index) {
+			return nil
+		}
+		ct, ss, err := kyberk2so.KemEncrypt768(r.ek)
+		if err != nil {
+			return err
+		}
+		defer mlkem.ZeroBytes(ss[:])
+		r.ct = ct
+		r.nextEpochKey(ss[:])
+		r.nextSend = r.chain(r.initiator)
+		r.recv[r.sendEpoch+1] = r.chain(!r.initiator)
+		r.phase = phaseSendCT
+		r.nextChunk = 0
+	case kind == chunkCT && r.phase == phaseSendEK:
+		copy(r.ct[index*r.chunkSize:], data)
+		if !r.receiveChunk(index) {
+			return nil
+		}
+		ss, err := kyberk2so.KemDecrypt768(r.ct, r.dk)
+		if err != nil {
+			return err
+		}
+		defer mlkem.ZeroBytes(ss[:])
+		mlkem.ZeroBytes(r.dk[:])
+		r.nextEpochKey(ss[:])
+		r.sendEpoch++
+		r.send = r.chain(r.initiator)
+		r.recv[r.sendEpoch] = r.chain(!r.initiator)
+		r.prune()
+		return r.startEpoch()
+	}
+	return nil
+}
+
+// receiveChunk marks chunk index as received and reports whether every
+// chunk has arrived.
+func (r *Ratchet) receiveChunk(index int) bool {
+	r.received[index] = true
+	for _, ok := range r.received {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// nextEpochKey replaces the epoch key with HKDF-SHA-256 of the shared
+// secret, salted with the current epoch key and bound to the new epoch
+// number and the exchanged ek and ct.
+func (r *Ratchet) nextEpochKey(ss []byte) {
+	h := sha256.New()
+	_, _ = h.Write(r.ek[:])
+	_, _ = h.Write(r.ct[:])
+	info := binary.BigEndian.AppendUint64([]byte("KyberK2SO PQRatchet v1 epoch"), r.sendEpoch+1)
+	info = h.Sum(info)
+	key, _ := hkdf.Key(sha256.New, ss, r.epochKey[:], string(info), 32)
+	copy(r.epochKey[:], key)
+	mlkem.ZeroBytes(key)
+}
+
+// prune drops the receiving chains and skipped keys of epochs before
+// the previous one, which the peer can no longer be sending in.
+func (r *Ratchet) prune() {
+	for epoch, c := range r.recv {
+		if epoch+1 < r.sendEpoch {
+			mlkem.ZeroBytes(c.key[:])
+			delete(r.recv, epoch)
+		}
+	}
+	for id, mk := range r.skipped {
+		if id.epoch+1 < r.sendEpoch {
+			mlkem.ZeroBytes(mk[:])
+			delete(r.skipped, id)
+		}
+	}
+}
+
+// clone returns a deep copy of r.
+func (r *Ratchet) clone() *Ratchet {
+	c := *r
+	c.recv = make(map[uint64]chain, len(r.recv))
+	for k, v := range r.recv {
+		c.recv[k] = v
+	}
+	c.skipped = make(map[skippedKey][KeySize]byte, len(r.skipped))
+	for k, v := range r.skipped {
+		c.skipped[k] = v
+	}
+	c.received = append([]bool(nil), r.received...)
+	return &c
+}
@@ -0,0 +1,212 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pqratchet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// envelope is a message in flight: the ratchet header and a payload
+// sealed under the message key with the header as associated data.
+type envelope struct {
+	header, sealed []byte
+}
+
+func seal(r *Ratchet, payload []byte) envelope {
+	header, key := r.Send()
+	aead, _ := chacha20poly1305.New(key)
+	return envelope{header, aead.Seal(nil, make([]byte, 12), payload, header)}
+}
+
+func open(r *Ratchet, e envelope) ([]byte, error) {
+	var payload []byte
+	err := r.Recv(e.header, func(key []byte) error {
+		aead, _ := chacha20poly1305.New(key)
+		var err error
+		payload, err = aead.Open(nil, make([]byte, 12), e.sealed, e.header)
+		return err
+	})
+	return payload, err
+}
+
+func newPair(t *testing.T, chunkSize int) (*Ratchet, *Ratchet) {
+	t.Helper()
+	sk := bytes.Repeat([]byte{0x5a}, 32)
+	a, err := New(sk, true, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(sk, false, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+// reload round-trips r through its serialized form.
+func reload(t *testing.T, r *Ratchet) *Ratchet {
+	t.Helper()
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(Ratchet)
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	again, err := out.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Fatal("serialization is not stable")
+	}
+	return out
+}
+
+func TestPingPong(t *testing.T) {
+	for _, chunkSize := range []int{32, 100, 1184} {
+		t.Run(fmt.Sprint(chunkSize), func(t *testing.T) {
+			a, b := newPair(t, chunkSize)
+			parties := [2]*Ratchet{a, b}
+			for i := range 600 {
+				from, to := parties[i%2], parties[1-i%2]
+				payload := []byte(fmt.Sprintf("message %d", i))
+				got, err := open(to, seal(from, payload))
+				if err != nil {
+					t.Fatalf("message %d: %v", i, err)
+				}
+				if !bytes.Equal(got, payload) {
+					t.Fatalf("message %d: payload mismatch", i)
+				}
+			}
+			if a.Epoch() < 3 || b.Epoch() < 3 {
+				t.Fatalf("epochs did not advance: %d, %d", a.Epoch(), b.Epoch())
+			}
+		})
+	}
+}
+
+// TestSimulation runs two parties over a channel that drops a fifth
+// of the messages and delivers the rest in random order, reloading both
+// states from their serialized form along the way.
+func TestSimulation(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	a, b := newPair(t, 64)
+	parties := [2]*Ratchet{a, b}
+	var inFlight [2][]envelope // messages to party i
+	var delivered, epochs int
+	for step := range 6000 {
+		switch k := rng.IntN(10); {
+		case k < 4:
+			from := rng.IntN(2)
+			e := seal(parties[from], []byte(fmt.Sprint(step)))
+			if rng.IntN(5) != 0 {
+				inFlight[1-from] = append(inFlight[1-from], e)
+			}
+		case k < 9:
+			to := rng.IntN(2)
+			if len(inFlight[to]) == 0 {
+				continue
+			}
+			// Deliver a random pending message, which reorders them.
+			i := rng.IntN(len(inFlight[to]))
+			e := inFlight[to][i]
+			inFlight[to] = append(inFlight[to][:i], inFlight[to][i+1:]...)
+			if _, err := open(parties[to], e); err != nil {
+				// Only messages from pruned epochs may be rejected.
+				epoch := binary.BigEndian.Uint64(e.header)
+				if !errors.Is(err, ErrUnknownEpoch) || epoch+1 >= parties[to].Epoch() {
+					t.Fatalf("step %d: %v", step, err)
+				}
+				continue
+			}
+			delivered++
+		default:
+			i := rng.IntN(2)
+			parties[i] = reload(t, parties[i])
+		}
+		epochs = int(min(parties[0].Epoch(), parties[1].Epoch()))
+	}
+	if delivered < 1000 || epochs < 5 {
+		t.Fatalf("delivered %d messages over %d epochs", delivered, epochs)
+	}
+}
+
+func TestForgedHeader(t *testing.T) {
+	a, b := newPair(t, 32)
+	e := seal(a, []byte("hello"))
+	before, _ := b.MarshalBinary()
+	forged := envelope{append([]byte(nil), e.header...), e.sealed}
+	forged.header[len(forged.header)-1] ^= 1
+	if _, err := open(b, forged); err == nil {
+		t.Fatal("forged header accepted")
+	}
+	if after, _ := b.MarshalBinary(); !bytes.Equal(before, after) {
+		t.Fatal("forged header changed the state")
+	}
+	if _, err := open(b, e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(b, e); !errors.Is(err, ErrDuplicateMessage) {
+		t.Fatalf("expected ErrDuplicateMessage, got %v", err)
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	a, b := newPair(t, 32)
+	var sent []envelope
+	for range 5 {
+		sent = append(sent, seal(a, nil))
+	}
+	for _, i := range []int{4, 0, 2, 1, 3} {
+		if _, err := open(b, sent[i]); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+	for range MaxSkip + 1 {
+		seal(a, nil)
+	}
+	if _, err := open(b, seal(a, nil)); !errors.Is(err, ErrTooManySkipped) {
+		t.Fatalf("expected ErrTooManySkipped, got %v", err)
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	a, b := newPair(t, 32)
+	header, _ := a.Send()
+	for _, h := range [][]byte{
+		nil,
+		header[:headerSize-1],
+		header[:len(header)-1],
+		append(append([]byte(nil), header[:12]...), 9),
+	} {
+		err := b.Recv(h, func([]byte) error { return nil })
+		if !errors.Is(err, ErrMalformedHeader) {
+			t.Fatalf("expected ErrMalformedHeader, got %v", err)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, n := range []int{0, -1, 1 << 16} {
+		if _, err := New(make([]byte, 32), true, n); !errors.Is(err, ErrInvalidChunkSize) {
+			t.Fatalf("chunk size %d: expected ErrInvalidChunkSize, got %v", n, err)
+		}
+	}
+	a, _ := newPair(t, 32)
+	data, _ := a.MarshalBinary()
+	for _, d := range [][]byte{nil, data[:len(data)-1], append(data, 0), append([]byte{2}, data[1:]...)} {
+		if err := new(Ratchet).UnmarshalBinary(d); !errors.Is(err, ErrInvalidState) {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	}
+}
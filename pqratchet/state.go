@@ -0,0 +1,185 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pqratchet
+
+import (
+	"cmp"
+	"encoding/binary"
+	"maps"
+	"slices"
+)
+
+// stateVersion is the version byte of serialized ratchet states.
+const stateVersion = 1
+
+// MarshalBinary serializes the ratchet state, including its secrets.
+// The encoding is deterministic; callers must store it as securely as
+// the session keys it protects.
+func (r *Ratchet) MarshalBinary() ([]byte, error) {
+	b := []byte{stateVersion, 0}
+	if r.initiator {
+		b[1] = 1
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(r.chunkSize))
+	b = append(b, r.epochKey[:]...)
+	b = binary.BigEndian.AppendUint64(b, r.sendEpoch)
+	b = appendChain(b, r.send)
+	b = append(b, byte(r.phase))
+	b = append(b, r.dk[:]...)
+	b = append(b, r.ek[:]...)
+	b = append(b, r.ct[:]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(r.nextChunk))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(r.received)))
+	for _, ok := range r.received {
+		if ok {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+	b = appendChain(b, r.nextSend)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.recv)))
+	for _, epoch := range slices.Sorted(maps.Keys(r.recv)) {
+		b = binary.BigEndian.AppendUint64(b, epoch)
+		b = appendChain(b, r.recv[epoch])
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.skipped)))
+	ids := slices.SortedFunc(maps.Keys(r.skipped), func(a, b skippedKey) int {
+		return cmp.Or(cmp.Compare(a.epoch, b.epoch), cmp.Compare(a.n, b.n))
+	})
+	for _, id := range ids {
+		b = binary.BigEndian.AppendUint64(b, id.epoch)
+		b = binary.BigEndian.AppendUint32(b, id.n)
+		mk := r.skipped[id]
+		b = append(b, mk[:]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores a ratchet state serialized by MarshalBinary.
+func (r *Ratchet) UnmarshalBinary(data []byte) error {
+	d := decoder{b: data}
+	if d.byte() != stateVersion {
+		return ErrInvalidState
+	}
+	s := Ratchet{
+		recv:    make(map[uint64]chain),
+		skipped: make(map[skippedKey][KeySize]byte),
+	}
+	switch d.byte() {
+	case 0:
+	case 1:
+		s.initiator = true
+	default:
+		return ErrInvalidState
+	}
+	s.chunkSize = int(d.uint16())
+	d.read(s.epochKey[:])
+	s.sendEpoch = d.uint64()
+	s.send = d.chain()
+	s.phase = phase(d.byte())
+	d.read(s.dk[:])
+	d.read(s.ek[:])
+	d.read(s.ct[:])
+	s.nextChunk = int(d.uint16())
+	s.received = make([]bool, d.uint16())
+	for i := range s.received {
+		switch d.byte() {
+		case 0:
+		case 1:
+			s.received[i] = true
+		default:
+			return ErrInvalidState
+		}
+	}
+	s.nextSend = d.chain()
+	for range d.uint32() {
+		if d.err {
+			break
+		}
+		epoch := d.uint64()
+		s.recv[epoch] = d.chain()
+	}
+	for range d.uint32() {
+		if d.err {
+			break
+		}
+		id := skippedKey{d.uint64(), d.uint32()}
+		var mk [KeySize]byte
+		d.read(mk[:])
+		s.skipped[id] = mk
+	}
+	if d.err || len(d.b) != 0 || s.chunkSize == 0 {
+		return ErrInvalidState
+	}
+	var collecting, sending int
+	switch s.phase {
+	case phaseSendEK:
+		collecting, sending = len(s.ct), len(s.ek)
+	case phaseCollectEK:
+		collecting, sending = len(s.ek), len(s.ek)
+	case phaseSendCT:
+		collecting, sending = len(s.ek), len(s.ct)
+	default:
+		return ErrInvalidState
+	}
+	if len(s.received) != s.chunks(collecting) || s.nextChunk >= s.chunks(sending) {
+		return ErrInvalidState
+	}
+	*r = s
+	return nil
+}
+
+func appendChain(b []byte, c chain) []byte {
+	b = append(b, c.key[:]...)
+	return binary.BigEndian.AppendUint32(b, c.n)
+}
+
+// decoder reads fixed-size fields, recording rather than returning
+// errors so that a whole state can be parsed before checking.
+type decoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *decoder) read(out []byte) {
+	if len(d.b) < len(out) {
+		d.err = true
+		d.b = nil
+		return
+	}
+	copy(out, d.b)
+	d.b = d.b[len(out):]
+}
+
+func (d *decoder) byte() byte {
+	var b [1]byte
+	d.read(b[:])
+	return b[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	var b [2]byte
+	d.read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+func (d *decoder) uint32() uint32 {
+	var b [4]byte
+	d.read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func (d *decoder) uint64() uint64 {
+	var b [8]byte
+	d.read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (d *decoder) chain() chain {
+	var c chain
+	d.read(c.key[:])
+	c.n = d.uint32()
+	return c
+}
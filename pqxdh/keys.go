@@ -0,0 +1,291 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pqxdh
+
+import (
+	"crypto/rand"
+	"sync"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// curveKey is an X25519 key pair.
+type curveKey struct {
+	private [32]byte
+	public  [32]byte
+}
+
+func generateCurveKey() (*curveKey, error) {
+	k := new(curveKey)
+	if _, err := rand.Read(k.private[:]); err != nil {
+		return nil, err
+	}
+	return k, k.setPublic()
+}
+
+func (k *curveKey) setPublic() error {
+	pub, err := curve25519.X25519(k.private[:], curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	copy(k.public[:], pub)
+	return nil
+}
+
+// IdentityKey is a long-term X25519 identity key pair, which also signs
+// prekeys with XEdDSA.
+type IdentityKey struct {
+	curveKey
+}
+
+// GenerateIdentityKey returns a new identity key pair.
+func GenerateIdentityKey() (*IdentityKey, error) {
+	k, err := generateCurveKey()
+	if err != nil {
+		return nil, err
+	}
+	return &IdentityKey{*k}, nil
+}
+
+// NewIdentityKey returns the identity key pair with the given X25519
+// private key.
+func NewIdentityKey(private [32]byte) (*IdentityKey, error) {
+	k := &IdentityKey{curveKey{private: private}}
+	return k, k.setPublic()
+}
+
+// Private returns the X25519 private key.
+func (k *IdentityKey) Private() [32]byte {
+	return k.private
+}
+
+// Public returns the X25519 public key.
+func (k *IdentityKey) Public() [32]byte {
+	return k.public
+}
+
+// Sign returns an XEdDSA signature on msg.
+func (k *IdentityKey) Sign(msg []byte) ([SignatureSize]byte, error) {
+	var z [64]byte
+	if _, err := rand.Read(z[:]); err != nil {
+		return [SignatureSize]byte{}, err
+	}
+	defer mlkem.ZeroBytes(z[:])
+	return xeddsaSign(&k.private, msg, &z), nil
+}
+
+// Verify reports whether sig is a valid XEdDSA signature on msg under
+// the X25519 public key.
+func Verify(public [32]byte, msg []byte, sig [SignatureSize]byte) bool {
+	return xeddsaVerify(&public, msg, &sig)
+}
+
+// Bundle is a responder's prekey bundle, as fetched from the server by
+// an initiator.
+type Bundle struct {
+	IdentityKey [32]byte
+
+	SignedPrekeyID        uint32
+	SignedPrekey          [32]byte
+	SignedPrekeySignature [SignatureSize]byte
+
+	// KEMPrekey is a one-time ML-KEM-1024 prekey if one was available
+	// and the last-resort prekey otherwise.
+	KEMPrekeyID        uint32
+	KEMPrekey          [kyberk2so.Kyber1024PKBytes]byte
+	KEMPrekeySignature [SignatureSize]byte
+
+	OneTimePrekeyID  uint32
+	OneTimePrekey    [32]byte
+	HasOneTimePrekey bool
+}
+
+// kemPrekey is a signed ML-KEM-1024 prekey.
+type kemPrekey struct {
+	dk        [kyberk2so.Kyber1024SKBytes]byte
+	ek        [kyberk2so.Kyber1024PKBytes]byte
+	signature [SignatureSize]byte
+	oneTime   bool
+}
+
+// Responder holds a party's identity key and prekeys and answers
+// initial messages. It is safe for concurrent use.
+type Responder struct {
+	mu       sync.Mutex
+	identity *IdentityKey
+	nextID   uint32
+
+	signedPrekeyID        uint32
+	signedPrekey          *curveKey
+	signedPrekeySignature [SignatureSize]byte
+	lastResortID          uint32
+
+	kemPrekeys   map[uint32]*kemPrekey
+	curvePrekeys map[uint32]*curveKey
+
+	// unpublishedKEM and unpublishedCurve list the one-time prekeys not
+	// yet handed out in a bundle.
+	unpublishedKEM   []uint32
+	unpublishedCurve []uint32
+}
+
+// NewResponder returns a Responder for identity with a fresh signed
+// curve prekey and last-resort KEM prekey.
+func NewResponder(identity *IdentityKey) (*Responder, error) {
+	r := &Responder{
+		identity:     identity,
+		kemPrekeys:   make(map[uint32]*kemPrekey),
+		curvePrekeys: make(map[uint32]*curveKey),
+	}
+	spk, err := generateCurveKey()
+	if err != nil {
+		return nil, err
+	}
+	r.signedPrekeyID = r.newID()
+	r.signedPrekey = spk
+	if r.signedPrekeySignature, err = identity.Sign(EncodeCurveKey(&spk.public)); err != nil {
+		return nil, err
+	}
+	if r.lastResortID, err = r.addKEMPrekey(false); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Responder) newID() uint32 {
+	r.nextID++
+	return r.nextID
+}
+
+// addKEMPrekey generates and stores a signed KEM prekey.
+func (r *Responder) addKEMPrekey(oneTime bool) (uint32, error) {
+	dk, ek, err := kyberk2so.KemKeypair1024()
+	if err != nil {
+		return 0, err
+	}
+	pq := &kemPrekey{dk: dk, ek: ek, oneTime: oneTime}
+	if pq.signature, err = r.identity.Sign(EncodeKEMKey(&ek)); err != nil {
+		return 0, err
+	}
+	id := r.newID()
+	r.kemPrekeys[id] = pq
+	return id, nil
+}
+
+// AddOneTimePrekeys generates n one-time curve prekeys and n signed
+// one-time KEM prekeys.
+func (r *Responder) AddOneTimePrekeys(n int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for range n {
+		opk, err := generateCurveKey()
+		if err != nil {
+			return err
+		}
+		id := r.newID()
+		r.curvePrekeys[id] = opk
+		r.unpublishedCurve = append(r.unpublishedCurve, id)
+		if id, err = r.addKEMPrekey(true); err != nil {
+			return err
+		}
+		r.unpublishedKEM = append(r.unpublishedKEM, id)
+	}
+	return nil
+}
+
+// Bundle returns a prekey bundle, handing out at most one one-time
+// curve prekey and one one-time KEM prekey. Once none are left, the
+// bundle carries the last-resort KEM prekey and no one-time curve
+// prekey.
+func (r *Responder) Bundle() *Bundle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &Bundle{
+		IdentityKey:           r.identity.public,
+		SignedPrekeyID:        r.signedPrekeyID,
+		SignedPrekey:          r.signedPrekey.public,
+		SignedPrekeySignature: r.signedPrekeySignature,
+		KEMPrekeyID:           r.lastResortID,
+	}
+	if len(r.unpublishedKEM) > 0 {
+		b.KEMPrekeyID, r.unpublishedKEM = r.unpublishedKEM[0], r.unpublishedKEM[1:]
+	}
+	pq := r.kemPrekeys[b.KEMPrekeyID]
+	b.KEMPrekey, b.KEMPrekeySignature = pq.ek, pq.signature
+	if len(r.unpublishedCurve) > 0 {
+		b.OneTimePrekeyID, r.unpublishedCurve = r.unpublishedCurve[0], r.unpublishedCurve[1:]
+		b.OneTimePrekey = r.curvePrekeys[b.OneTimePrekeyID].public
+		b.HasOneTimePrekey = true
+	}
+	return b
+}
+
+// Respond runs the responder's side of PQXDH on msg with the
+// application's info string. It returns the decrypted initial
+// plaintext and SK. One-time prekeys used by msg are deleted once the
+// initial ciphertext authenticates, so a replayed message fails with
+// ErrUnknownPrekey.
+func (r *Responder) Respond(msg *InitialMessage, info string) ([]byte, []byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pq, ok := r.kemPrekeys[msg.KEMPrekeyID]
+	if !ok || msg.SignedPrekeyID != r.signedPrekeyID {
+		return nil, nil, ErrUnknownPrekey
+	}
+	var opk *curveKey
+	if msg.HasOneTimePrekey {
+		if opk, ok = r.curvePrekeys[msg.OneTimePrekeyID]; !ok {
+			return nil, nil, ErrUnknownPrekey
+		}
+	}
+	var km []byte
+	var err error
+	defer func() { mlkem.ZeroBytes(km) }()
+	for _, dh := range [][2]*[32]byte{
+		{&r.signedPrekey.private, &msg.IdentityKey},
+		{&r.identity.private, &msg.EphemeralKey},
+		{&r.signedPrekey.private, &msg.EphemeralKey},
+	} {
+		if km, err = appendDH(km, dh[0], dh[1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opk != nil {
+		if km, err = appendDH(km, &opk.private, &msg.EphemeralKey); err != nil {
+			return nil, nil, err
+		}
+	}
+	ss, err := kyberk2so.KemDecrypt1024(msg.KEMCiphertext, pq.dk)
+	if err != nil {
+		return nil, nil, err
+	}
+	km = append(km, ss[:]...)
+	mlkem.ZeroBytes(ss[:])
+	sk, err := kdf(km, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := chacha20poly1305.New(sk)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	plaintext, err := aead.Open(nil, nonce, msg.Ciphertext, AssociatedData(&msg.IdentityKey, &r.identity.public))
+	if err != nil {
+		mlkem.ZeroBytes(sk)
+		return nil, nil, ErrDecryptionFailed
+	}
+	if pq.oneTime {
+		mlkem.ZeroBytes(pq.dk[:])
+		delete(r.kemPrekeys, msg.KEMPrekeyID)
+	}
+	if opk != nil {
+		mlkem.ZeroBytes(opk.private[:])
+		delete(r.curvePrekeys, msg.OneTimePrekeyID)
+	}
+	return plaintext, sk, nil
+}
@@ -0,0 +1,175 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package pqxdh implements the PQXDH asynchronous key agreement
+// protocol (Signal, revision 3) with X25519, SHA-256 and ML-KEM-1024.
+//
+// A responder publishes a prekey bundle holding its identity key, a
+// signed curve prekey, a signed ML-KEM-1024 prekey (one-time if any is
+// left, otherwise the last-resort prekey) and optionally a one-time
+// curve prekey. An initiator verifies the bundle, encapsulates to the
+// KEM prekey, and derives the session key SK from four Diffie-Hellman
+// outputs and the KEM shared secret. The initial message carries the
+// KEM ciphertext and a first AEAD ciphertext under SK, from which the
+// responder recomputes SK.
+//
+// Identity keys are X25519 keys that also sign prekeys with XEdDSA.
+package pqxdh
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Key type bytes that prefix encoded public keys.
+const (
+	CurveKeyType = 0x05
+	KEMKeyType   = 0x0a
+)
+
+// SessionKeySize is the size of the session key SK.
+const SessionKeySize = 32
+
+var (
+	// ErrInvalidSignature is returned when a prekey signature in a
+	// bundle does not verify under the bundle's identity key.
+	ErrInvalidSignature = errors.New("pqxdh: invalid prekey signature")
+
+	// ErrInvalidKey is returned when a curve public key is invalid or
+	// yields an all-zero Diffie-Hellman output.
+	ErrInvalidKey = errors.New("pqxdh: invalid public key")
+
+	// ErrUnknownPrekey is returned when an initial message references a
+	// prekey the responder does not hold, for example a one-time prekey
+	// that was already used.
+	ErrUnknownPrekey = errors.New("pqxdh: unknown prekey")
+
+	// ErrDecryptionFailed is returned when the initial ciphertext fails
+	// to authenticate.
+	ErrDecryptionFailed = errors.New("pqxdh: decryption failed")
+)
+
+// EncodeCurveKey returns Encode(PK) for an X25519 public key: the curve
+// key type byte followed by the little-endian u-coordinate.
+func EncodeCurveKey(pk *[32]byte) []byte {
+	return append([]byte{CurveKeyType}, pk[:]...)
+}
+
+// EncodeKEMKey returns EncodeKEM(PK) for an ML-KEM-1024 encapsulation
+// key: the KEM key type byte followed by the encapsulation key.
+func EncodeKEMKey(pk *[kyberk2so.Kyber1024PKBytes]byte) []byte {
+	return append([]byte{KEMKeyType}, pk[:]...)
+}
+
+// AssociatedData returns AD = Encode(IK_A) || Encode(IK_B), which
+// authenticates the initial ciphertext.
+func AssociatedData(initiator, responder *[32]byte) []byte {
+	return append(EncodeCurveKey(initiator), EncodeCurveKey(responder)...)
+}
+
+// InitialMessage is the message an initiator sends to start a session.
+type InitialMessage struct {
+	IdentityKey   [32]byte
+	EphemeralKey  [32]byte
+	KEMCiphertext [kyberk2so.Kyber1024CTBytes]byte
+
+	// SignedPrekeyID, KEMPrekeyID and OneTimePrekeyID identify the
+	// responder's prekeys used by the initiator. OneTimePrekeyID is
+	// only meaningful when HasOneTimePrekey is set.
+	SignedPrekeyID   uint32
+	KEMPrekeyID      uint32
+	OneTimePrekeyID  uint32
+	HasOneTimePrekey bool
+
+	// Ciphertext is the initial ChaCha20-Poly1305 ciphertext under SK,
+	// with a zero nonce and the associated data AD.
+	Ciphertext []byte
+}
+
+// Initiate runs the initiator's side of PQXDH against bundle. It
+// verifies both prekey signatures, derives SK with the application's
+// info string and encrypts plaintext as the initial ciphertext. It
+// returns the initial message and SK; SK has been used once with a
+// zero nonce, so later keys should be derived from it.
+func Initiate(identity *IdentityKey, bundle *Bundle, info string, plaintext []byte) (*InitialMessage, []byte, error) {
+	if !xeddsaVerify(&bundle.IdentityKey, EncodeCurveKey(&bundle.SignedPrekey), &bundle.SignedPrekeySignature) ||
+		!xeddsaVerify(&bundle.IdentityKey, EncodeKEMKey(&bundle.KEMPrekey), &bundle.KEMPrekeySignature) {
+		return nil, nil, ErrInvalidSignature
+	}
+	ephemeral, err := generateCurveKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(ephemeral.private[:])
+	var km []byte
+	defer func() { mlkem.ZeroBytes(km) }()
+	for _, dh := range [][2]*[32]byte{
+		{&identity.private, &bundle.SignedPrekey},
+		{&ephemeral.private, &bundle.IdentityKey},
+		{&ephemeral.private, &bundle.SignedPrekey},
+	} {
+		if km, err = appendDH(km, dh[0], dh[1]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if bundle.HasOneTimePrekey {
+		if km, err = appendDH(km, &ephemeral.private, &bundle.OneTimePrekey); err != nil {
+			return nil, nil, err
+		}
+	}
+	ct, ss, err := kyberk2so.KemEncrypt1024(bundle.KEMPrekey)
+	if err != nil {
+		return nil, nil, err
+	}
+	km = append(km, ss[:]...)
+	mlkem.ZeroBytes(ss[:])
+	sk, err := kdf(km, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg := &InitialMessage{
+		IdentityKey:      identity.public,
+		EphemeralKey:     ephemeral.public,
+		KEMCiphertext:    ct,
+		SignedPrekeyID:   bundle.SignedPrekeyID,
+		KEMPrekeyID:      bundle.KEMPrekeyID,
+		OneTimePrekeyID:  bundle.OneTimePrekeyID,
+		HasOneTimePrekey: bundle.HasOneTimePrekey,
+	}
+	aead, err := chacha20poly1305.New(sk)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	msg.Ciphertext = aead.Seal(nil, nonce, plaintext, AssociatedData(&identity.public, &bundle.IdentityKey))
+	return msg, sk, nil
+}
+
+// appendDH appends the X25519 output of private and public to km.
+func appendDH(km []byte, private, public *[32]byte) ([]byte, error) {
+	dh, err := curve25519.X25519(private[:], public[:])
+	if err != nil {
+		return km, ErrInvalidKey
+	}
+	km = append(km, dh...)
+	mlkem.ZeroBytes(dh)
+	return km, nil
+}
+
+// kdf returns SK = HKDF-SHA-256(F || km) with F 32 0xFF bytes, a zero
+// salt and the info string, as in Section 2.2 of the specification.
+func kdf(km []byte, info string) ([]byte, error) {
+	ikm := make([]byte, 32, 32+len(km))
+	for i := range ikm {
+		ikm[i] = 0xff
+	}
+	ikm = append(ikm, km...)
+	defer mlkem.ZeroBytes(ikm)
+	return hkdf.Key(sha256.New, ikm, make([]byte, sha256.Size), info, SessionKeySize)
+}
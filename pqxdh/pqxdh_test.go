@@ -0,0 +1,225 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pqxdh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"filippo.io/edwards25519"
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"golang.org/x/crypto/curve25519"
+)
+
+const testInfo = "kyber-k2so PQXDH test"
+
+func newParties(t *testing.T, oneTime int) (*IdentityKey, *Responder) {
+	t.Helper()
+	alice, err := GenerateIdentityKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobIdentity, err := GenerateIdentityKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := NewResponder(bobIdentity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.AddOneTimePrekeys(oneTime); err != nil {
+		t.Fatal(err)
+	}
+	return alice, bob
+}
+
+func TestSession(t *testing.T) {
+	alice, bob := newParties(t, 2)
+	for i := range 4 {
+		bundle := bob.Bundle()
+		// The first two bundles carry one-time prekeys; later ones fall
+		// back to the last-resort KEM prekey.
+		if bundle.HasOneTimePrekey != (i < 2) || (bundle.KEMPrekeyID == bob.lastResortID) != (i >= 2) {
+			t.Fatalf("bundle %d: unexpected prekey selection", i)
+		}
+		msg, skAlice, err := Initiate(alice, bundle, testInfo, []byte("hello bob"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, skBob, err := bob.Respond(msg, testInfo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(skAlice, skBob) || len(skAlice) != SessionKeySize {
+			t.Fatal("session key mismatch")
+		}
+		if string(plaintext) != "hello bob" {
+			t.Fatal("plaintext mismatch")
+		}
+	}
+}
+
+// TestSessionKey recomputes SK from the specification's definition
+// with curve25519, ML-KEM decapsulation and HKDF.
+func TestSessionKey(t *testing.T) {
+	alice, bob := newParties(t, 1)
+	bundle := bob.Bundle()
+	msg, sk, err := Initiate(alice, bundle, testInfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dh := func(private [32]byte, public [32]byte) []byte {
+		out, err := curve25519.X25519(private[:], public[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	spk := bob.signedPrekey.private
+	opk := bob.curvePrekeys[bundle.OneTimePrekeyID].private
+	ss, err := kyberk2so.KemDecrypt1024(msg.KEMCiphertext, bob.kemPrekeys[bundle.KEMPrekeyID].dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ikm := bytes.Repeat([]byte{0xff}, 32)
+	ikm = append(ikm, dh(spk, alice.Public())...)
+	ikm = append(ikm, dh(bob.identity.Private(), msg.EphemeralKey)...)
+	ikm = append(ikm, dh(spk, msg.EphemeralKey)...)
+	ikm = append(ikm, dh(opk, msg.EphemeralKey)...)
+	ikm = append(ikm, ss[:]...)
+	want, err := hkdf.Key(sha256.New, ikm, make([]byte, 32), testInfo, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sk, want) {
+		t.Fatal("session key does not match the specification")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	alice, bob := newParties(t, 1)
+	msg, _, err := Initiate(alice, bob.Bundle(), testInfo, []byte("once"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bob.Respond(msg, testInfo); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bob.Respond(msg, testInfo); !errors.Is(err, ErrUnknownPrekey) {
+		t.Fatalf("expected ErrUnknownPrekey, got %v", err)
+	}
+}
+
+func TestFailedResponseKeepsPrekeys(t *testing.T) {
+	alice, bob := newParties(t, 1)
+	msg, _, err := Initiate(alice, bob.Bundle(), testInfo, []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bob.Respond(msg, "another application"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+	msg.Ciphertext[0] ^= 1
+	if _, _, err := bob.Respond(msg, testInfo); !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("expected ErrDecryptionFailed, got %v", err)
+	}
+	msg.Ciphertext[0] ^= 1
+	if _, _, err := bob.Respond(msg, testInfo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidBundleSignature(t *testing.T) {
+	alice, bob := newParties(t, 0)
+	bundle := bob.Bundle()
+	bundle.SignedPrekey[0] ^= 1
+	if _, _, err := Initiate(alice, bundle, testInfo, nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+	bundle = bob.Bundle()
+	bundle.KEMPrekey[100] ^= 1
+	if _, _, err := Initiate(alice, bundle, testInfo, nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestUnknownPrekey(t *testing.T) {
+	alice, bob := newParties(t, 1)
+	msg, _, err := Initiate(alice, bob.Bundle(), testInfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.SignedPrekeyID++
+	if _, _, err := bob.Respond(msg, testInfo); !errors.Is(err, ErrUnknownPrekey) {
+		t.Fatalf("expected ErrUnknownPrekey, got %v", err)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	var pk [32]byte
+	pk[0] = 9
+	if got := EncodeCurveKey(&pk); len(got) != 33 || got[0] != CurveKeyType || got[1] != 9 {
+		t.Fatalf("unexpected curve key encoding %x", got)
+	}
+	var ek [kyberk2so.Kyber1024PKBytes]byte
+	if got := EncodeKEMKey(&ek); len(got) != kyberk2so.Kyber1024PKBytes+1 || got[0] != KEMKeyType {
+		t.Fatal("unexpected KEM key encoding")
+	}
+}
+
+// TestXEdDSAEd25519 checks that XEdDSA signatures verify as Ed25519
+// signatures under the Edwards form of the X25519 public key.
+func TestXEdDSAEd25519(t *testing.T) {
+	for range 16 {
+		k, err := GenerateIdentityKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := []byte("prekey")
+		sig, err := k.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(k.Public(), msg, sig) {
+			t.Fatal("XEdDSA signature does not verify")
+		}
+		scalar, _ := edwards25519.NewScalar().SetBytesWithClamping(k.private[:])
+		edPublic := new(edwards25519.Point).ScalarBaseMult(scalar).Bytes()
+		edPublic[31] &^= 0x80
+		if !ed25519.Verify(edPublic, msg, sig[:]) {
+			t.Fatal("XEdDSA signature does not verify as Ed25519")
+		}
+	}
+}
+
+func TestXEdDSAReject(t *testing.T) {
+	k, err := GenerateIdentityKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("prekey")
+	sig, err := k.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Verify(k.Public(), []byte("prekez"), sig) {
+		t.Fatal("signature verified on the wrong message")
+	}
+	for _, i := range []int{0, 31, 32, 63} {
+		bad := sig
+		bad[i] ^= 0x01
+		if Verify(k.Public(), msg, bad) {
+			t.Fatalf("signature with byte %d flipped verified", i)
+		}
+	}
+	pub := k.Public()
+	pub[31] |= 0x80
+	if Verify(pub, msg, sig) {
+		t.Fatal("signature verified under a non-canonical public key")
+	}
+}
@@ -0,0 +1,83 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package pqxdh
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/subtle"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+// SignatureSize is the size of an XEdDSA signature.
+const SignatureSize = 64
+
+// hash1Prefix is the domain separator of hash_1 in the XEdDSA
+// specification: the 32-byte little-endian encoding of 2^256 - 2.
+var hash1Prefix = append([]byte{0xfe}, bytes.Repeat([]byte{0xff}, 31)...)
+
+// xeddsaSign signs msg with the X25519 private key k and the 64 random
+// bytes z, as in Section 2.4 of the XEdDSA specification.
+func xeddsaSign(k *[32]byte, msg []byte, z *[64]byte) [SignatureSize]byte {
+	kScalar, _ := edwards25519.NewScalar().SetBytesWithClamping(k[:])
+	publicKey := new(edwards25519.Point).ScalarBaseMult(kScalar).Bytes()
+	// calculate_key_pair: force the sign bit of A to zero, negating the
+	// private scalar to match.
+	a := kScalar
+	if publicKey[31]&0x80 != 0 {
+		a = edwards25519.NewScalar().Negate(kScalar)
+		publicKey[31] &^= 0x80
+	}
+	h := sha512.New()
+	_, _ = h.Write(hash1Prefix)
+	_, _ = h.Write(a.Bytes())
+	_, _ = h.Write(msg)
+	_, _ = h.Write(z[:])
+	r, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+	h.Reset()
+	_, _ = h.Write(R)
+	_, _ = h.Write(publicKey)
+	_, _ = h.Write(msg)
+	c, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	s := edwards25519.NewScalar().MultiplyAdd(c, a, r)
+	var sig [SignatureSize]byte
+	copy(sig[:32], R)
+	copy(sig[32:], s.Bytes())
+	return sig
+}
+
+// xeddsaVerify verifies an XEdDSA signature on msg under the X25519
+// public key u. Non-canonical u and s are rejected.
+func xeddsaVerify(u *[32]byte, msg []byte, sig *[SignatureSize]byte) bool {
+	fu, err := new(field.Element).SetBytes(u[:])
+	if err != nil || !bytes.Equal(fu.Bytes(), u[:]) {
+		return false
+	}
+	// convert_mont: the Edwards point with y = (u - 1) / (u + 1) and a
+	// zero sign bit.
+	one := new(field.Element).One()
+	den := new(field.Element).Add(fu, one)
+	y := new(field.Element).Subtract(fu, one)
+	y.Multiply(y, den.Invert(den))
+	publicKey := y.Bytes()
+	A, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return false
+	}
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false
+	}
+	h := sha512.New()
+	_, _ = h.Write(sig[:32])
+	_, _ = h.Write(publicKey)
+	_, _ = h.Write(msg)
+	c, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	c.Negate(c)
+	R := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(c, A, s)
+	return subtle.ConstantTimeCompare(R.Bytes(), sig[:32]) == 1
+}
@@ -0,0 +1,296 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package prekey
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+// recordVersion is the version byte of stored prekeys.
+const recordVersion = 1
+
+// recordSize is the size of a stored prekey.
+const recordSize = 1 + 1 + 4 + kyberk2so.Kyber1024PKBytes + kyberk2so.Kyber1024SKBytes
+
+// Subdirectories of a FileStore.
+const (
+	dirUnpublished = "unpublished"
+	dirPublished   = "published"
+	dirLastResort  = "lastresort"
+	dirConsumed    = "consumed"
+	dirTemp        = "tmp"
+)
+
+// counterFile is the name of the file holding the highest ID a
+// FileStore has assigned.
+const counterFile = "counter"
+
+// FileStore is a Store that keeps each prekey in its own file under a
+// directory, moving it between subdirectories as it is published and
+// consumed. Publish and Consume claim a file by renaming it, which is
+// atomic, so they remain safe when several processes share the
+// directory; IDs are assigned from a counter that is persisted on every
+// Add and read when the store is opened, so IDs are never reused, but
+// only one process should add prekeys.
+//
+// Consumed files are overwritten with zeros before they are removed.
+// This is best effort: file systems and storage devices may keep
+// copies of overwritten data.
+type FileStore struct {
+	dir    string
+	mu     sync.Mutex
+	nextID uint32
+}
+
+// NewFileStore opens or creates a FileStore in dir, destroying prekeys
+// whose consumption was interrupted.
+func NewFileStore(dir string) (*FileStore, error) {
+	s := &FileStore{dir: dir}
+	for _, sub := range []string{dirUnpublished, dirPublished, dirLastResort, dirConsumed, dirTemp} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	for _, sub := range []string{dirConsumed, dirTemp} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if err := destroy(filepath.Join(dir, sub, e.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, sub := range []string{dirUnpublished, dirPublished, dirLastResort} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			id, err := parseName(e.Name())
+			if err != nil {
+				return nil, err
+			}
+			s.nextID = max(s.nextID, id)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, counterFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case len(b) != 4:
+		return nil, ErrInvalidRecord
+	default:
+		s.nextID = max(s.nextID, binary.BigEndian.Uint32(b))
+	}
+	return s, nil
+}
+
+// Add implements Store.
+func (s *FileStore) Add(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nextID == ^uint32(0) {
+		return errors.New("prekey: prekey IDs exhausted")
+	}
+	if err := s.writeFile(counterFile, binary.BigEndian.AppendUint32(nil, s.nextID+1)); err != nil {
+		return err
+	}
+	s.nextID++
+	r.ID = s.nextID
+	b := encodeRecord(r)
+	defer mlkem.ZeroBytes(b)
+	sub := dirUnpublished
+	if r.LastResort {
+		sub = dirLastResort
+	}
+	if err := s.writeFile(filepath.Join(sub, name(r.ID)), b); err != nil {
+		return err
+	}
+	if !r.LastResort {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, dirLastResort))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() != name(r.ID) {
+			if err := destroy(filepath.Join(s.dir, dirLastResort, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile atomically replaces the file at path, relative to the
+// store directory, with b: it writes a temporary file, syncs it and
+// renames it into place.
+func (s *FileStore) writeFile(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, dirTemp), "add-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	err = errors.Join(err, tmp.Sync(), tmp.Close())
+	if err != nil {
+		return errors.Join(err, destroy(tmp.Name()))
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, path)); err != nil {
+		return errors.Join(err, destroy(tmp.Name()))
+	}
+	return nil
+}
+
+// Publish implements Store.
+func (s *FileStore) Publish() (Record, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, dirUnpublished))
+	if err != nil {
+		return Record{}, false, err
+	}
+	// ReadDir sorts by name, and names sort by ID.
+	for _, e := range entries {
+		path := filepath.Join(s.dir, dirPublished, e.Name())
+		err := os.Rename(filepath.Join(s.dir, dirUnpublished, e.Name()), path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Another process published it first.
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		r, err := readRecord(path, e.Name())
+		if err != nil {
+			return Record{}, false, err
+		}
+		return publicRecord(&r), true, nil
+	}
+	return Record{}, false, nil
+}
+
+// LastResort implements Store.
+func (s *FileStore) LastResort() (Record, bool, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, dirLastResort))
+	if err != nil || len(entries) == 0 {
+		return Record{}, false, err
+	}
+	e := entries[len(entries)-1]
+	r, err := readRecord(filepath.Join(s.dir, dirLastResort, e.Name()), e.Name())
+	if err != nil {
+		return Record{}, false, err
+	}
+	return publicRecord(&r), true, nil
+}
+
+// Consume implements Store.
+func (s *FileStore) Consume(id uint32) (Record, error) {
+	r, err := readRecord(filepath.Join(s.dir, dirLastResort, name(id)), name(id))
+	if err == nil {
+		return r, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return Record{}, err
+	}
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return Record{}, err
+	}
+	claimed := filepath.Join(s.dir, dirConsumed, name(id)+"."+hex.EncodeToString(suffix[:]))
+	err = os.Rename(filepath.Join(s.dir, dirPublished, name(id)), claimed)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Record{}, ErrUnknownPrekey
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	r, err = readRecord(claimed, name(id))
+	if err != nil {
+		return Record{}, errors.Join(err, destroy(claimed))
+	}
+	if err := destroy(claimed); err != nil {
+		mlkem.ZeroBytes(r.PrivateKey[:])
+		return Record{}, err
+	}
+	return r, nil
+}
+
+// Unpublished implements Store.
+func (s *FileStore) Unpublished() (int, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, dirUnpublished))
+	return len(entries), err
+}
+
+// name returns the file name of a prekey, which sorts by ID.
+func name(id uint32) string {
+	return fmt.Sprintf("%08x", id)
+}
+
+// parseName returns the ID of a prekey file name.
+func parseName(name string) (uint32, error) {
+	id, err := strconv.ParseUint(name, 16, 32)
+	if err != nil || len(name) != 8 {
+		return 0, ErrInvalidRecord
+	}
+	return uint32(id), nil
+}
+
+func encodeRecord(r *Record) []byte {
+	b := make([]byte, 0, recordSize)
+	b = append(b, recordVersion, 0)
+	if r.LastResort {
+		b[1] = 1
+	}
+	b = binary.BigEndian.AppendUint32(b, r.ID)
+	b = append(b, r.PublicKey[:]...)
+	return append(b, r.PrivateKey[:]...)
+}
+
+// readRecord reads the prekey stored at path under the given file name.
+func readRecord(path, fileName string) (Record, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Record{}, err
+	}
+	defer mlkem.ZeroBytes(b)
+	id, err := parseName(fileName)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(b) != recordSize || b[0] != recordVersion || b[1] > 1 || binary.BigEndian.Uint32(b[2:6]) != id {
+		return Record{}, ErrInvalidRecord
+	}
+	r := Record{ID: id, LastResort: b[1] == 1}
+	copy(r.PublicKey[:], b[6:])
+	copy(r.PrivateKey[:], b[6+kyberk2so.Kyber1024PKBytes:])
+	return r, nil
+}
+
+// destroy overwrites a file with zeros and removes it.
+func destroy(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.WriteAt(make([]byte, info.Size()), 0)
+	}
+	err = errors.Join(err, f.Sync(), f.Close())
+	return errors.Join(err, os.Remove(path))
+}
@@ -0,0 +1,95 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package prekey
+
+import (
+	"sync"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+// MemoryStore is a Store that keeps prekeys in memory.
+type MemoryStore struct {
+	mu          sync.Mutex
+	nextID      uint32
+	unpublished []*Record
+	published   map[uint32]*Record
+	lastResort  *Record
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{published: make(map[uint32]*Record)}
+}
+
+// Add implements Store.
+func (s *MemoryStore) Add(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	r.ID = s.nextID
+	stored := *r
+	if r.LastResort {
+		if s.lastResort != nil {
+			mlkem.ZeroBytes(s.lastResort.PrivateKey[:])
+		}
+		s.lastResort = &stored
+	} else {
+		s.unpublished = append(s.unpublished, &stored)
+	}
+	return nil
+}
+
+// Publish implements Store.
+func (s *MemoryStore) Publish() (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.unpublished) == 0 {
+		return Record{}, false, nil
+	}
+	r := s.unpublished[0]
+	s.unpublished[0] = nil
+	s.unpublished = s.unpublished[1:]
+	s.published[r.ID] = r
+	return publicRecord(r), true, nil
+}
+
+// LastResort implements Store.
+func (s *MemoryStore) LastResort() (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastResort == nil {
+		return Record{}, false, nil
+	}
+	return publicRecord(s.lastResort), true, nil
+}
+
+// Consume implements Store.
+func (s *MemoryStore) Consume(id uint32) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastResort != nil && s.lastResort.ID == id {
+		return *s.lastResort, nil
+	}
+	r, ok := s.published[id]
+	if !ok {
+		return Record{}, ErrUnknownPrekey
+	}
+	delete(s.published, id)
+	consumed := *r
+	mlkem.ZeroBytes(r.PrivateKey[:])
+	return consumed, nil
+}
+
+// Unpublished implements Store.
+func (s *MemoryStore) Unpublished() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.unpublished), nil
+}
+
+// publicRecord returns a copy of r without its private key.
+func publicRecord(r *Record) Record {
+	return Record{ID: r.ID, LastResort: r.LastResort, PublicKey: r.PublicKey}
+}
@@ -0,0 +1,193 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package prekey manages one-time ML-KEM-1024 prekeys for asynchronous
+// messaging.
+//
+// A Manager generates prekeys in batches with KemKeypair1024 and keeps
+// them in a Store. Bundle hands out the public key of one unpublished
+// one-time prekey at a time, falling back to a last-resort prekey once
+// none are left, and refills the store when its stock runs low.
+// Decapsulate consumes a one-time prekey: the store removes it
+// atomically, so that of several concurrent decapsulations with the
+// same prekey exactly one succeeds, and its private key is zeroized
+// once used. The last-resort prekey is never consumed.
+package prekey
+
+import (
+	"errors"
+	"sync"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+)
+
+var (
+	// ErrUnknownPrekey is returned when a prekey does not exist, has
+	// already been consumed, or was never published.
+	ErrUnknownPrekey = errors.New("prekey: unknown or consumed prekey")
+
+	// ErrInvalidRecord is returned by stores when a stored prekey is
+	// malformed.
+	ErrInvalidRecord = errors.New("prekey: invalid stored prekey")
+)
+
+// Record is a stored prekey.
+type Record struct {
+	ID         uint32
+	LastResort bool
+	PublicKey  [kyberk2so.Kyber1024PKBytes]byte
+	PrivateKey [kyberk2so.Kyber1024SKBytes]byte
+}
+
+// Store persists prekeys. Implementations must be safe for concurrent
+// use, and Publish and Consume must be atomic: a prekey is published at
+// most once and consumed at most once.
+type Store interface {
+	// Add stores a new prekey and sets its ID, which must not have been
+	// used before. A new last-resort prekey replaces the previous one.
+	Add(r *Record) error
+
+	// Publish marks the oldest unpublished one-time prekey as published
+	// and returns it without its private key, or false if none is left.
+	Publish() (Record, bool, error)
+
+	// LastResort returns the last-resort prekey without its private key,
+	// or false if there is none.
+	LastResort() (Record, bool, error)
+
+	// Consume returns a published one-time prekey and removes it,
+	// zeroizing the stored copy, or returns the last-resort prekey
+	// without removing it. It returns ErrUnknownPrekey for other IDs.
+	Consume(id uint32) (Record, error)
+
+	// Unpublished returns the number of one-time prekeys not yet
+	// published.
+	Unpublished() (int, error)
+}
+
+// Bundle is the public part of a prekey, as published to senders.
+type Bundle struct {
+	ID         uint32
+	LastResort bool
+	PublicKey  [kyberk2so.Kyber1024PKBytes]byte
+}
+
+// Options configure a Manager.
+type Options struct {
+	// BatchSize is the number of one-time prekeys generated per refill.
+	// It defaults to 100.
+	BatchSize int
+
+	// LowWatermark is the number of unpublished one-time prekeys below
+	// which Bundle refills the store. It defaults to BatchSize / 4,
+	// but at least 1.
+	LowWatermark int
+}
+
+// Manager generates, publishes and consumes prekeys. It is safe for
+// concurrent use.
+type Manager struct {
+	store        Store
+	batchSize    int
+	lowWatermark int
+
+	// refill serializes refills, so that concurrent Bundle calls do not
+	// each generate a batch.
+	refill sync.Mutex
+}
+
+// NewManager returns a Manager for store, generating a last-resort
+// prekey if the store has none. opts may be nil.
+func NewManager(store Store, opts *Options) (*Manager, error) {
+	m := &Manager{store: store, batchSize: 100}
+	if opts != nil && opts.BatchSize > 0 {
+		m.batchSize = opts.BatchSize
+	}
+	m.lowWatermark = max(1, m.batchSize/4)
+	if opts != nil && opts.LowWatermark > 0 {
+		m.lowWatermark = opts.LowWatermark
+	}
+	if _, ok, err := store.LastResort(); err != nil {
+		return nil, err
+	} else if !ok {
+		if err := m.RotateLastResort(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// generate generates a prekey and adds it to the store.
+func (m *Manager) generate(lastResort bool) error {
+	dk, ek, err := kyberk2so.KemKeypair1024()
+	if err != nil {
+		return err
+	}
+	r := &Record{LastResort: lastResort, PublicKey: ek, PrivateKey: dk}
+	err = m.store.Add(r)
+	mlkem.ZeroBytes(dk[:])
+	mlkem.ZeroBytes(r.PrivateKey[:])
+	return err
+}
+
+// Refill generates a batch of one-time prekeys if fewer than the low
+// watermark are unpublished.
+func (m *Manager) Refill() error {
+	m.refill.Lock()
+	defer m.refill.Unlock()
+	n, err := m.store.Unpublished()
+	if err != nil || n >= m.lowWatermark {
+		return err
+	}
+	for range m.batchSize {
+		if err := m.generate(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateLastResort replaces the last-resort prekey. Messages in flight
+// to the previous one will fail to decapsulate.
+func (m *Manager) RotateLastResort() error {
+	return m.generate(true)
+}
+
+// Bundle publishes an unused one-time prekey, or the last-resort prekey
+// if none is left, refilling the store first when its stock is low.
+func (m *Manager) Bundle() (Bundle, error) {
+	if err := m.Refill(); err != nil {
+		return Bundle{}, err
+	}
+	r, ok, err := m.store.Publish()
+	if err != nil {
+		return Bundle{}, err
+	}
+	if !ok {
+		if r, ok, err = m.store.LastResort(); err != nil {
+			return Bundle{}, err
+		} else if !ok {
+			return Bundle{}, ErrUnknownPrekey
+		}
+	}
+	mlkem.ZeroBytes(r.PrivateKey[:])
+	return Bundle{ID: r.ID, LastResort: r.LastResort, PublicKey: r.PublicKey}, nil
+}
+
+// Decapsulate consumes the prekey with the given ID and returns the
+// shared secret of ciphertext under it. A one-time prekey is consumed
+// even if the ciphertext was not made for it, since ML-KEM cannot tell,
+// and a second Decapsulate with the same one-time prekey returns
+// ErrUnknownPrekey.
+func (m *Manager) Decapsulate(
+	id uint32,
+	ciphertext [kyberk2so.Kyber1024CTBytes]byte,
+) ([kyberk2so.KyberSSBytes]byte, error) {
+	r, err := m.store.Consume(id)
+	if err != nil {
+		return [kyberk2so.KyberSSBytes]byte{}, err
+	}
+	defer mlkem.ZeroBytes(r.PrivateKey[:])
+	return kyberk2so.KemDecrypt1024(ciphertext, r.PrivateKey)
+}
@@ -0,0 +1,307 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package prekey
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+// stores runs a test against each Store implementation.
+func stores(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("file", func(t *testing.T) {
+		store, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, store)
+	})
+}
+
+func newManager(t *testing.T, store Store, batchSize, lowWatermark int) *Manager {
+	t.Helper()
+	m, err := NewManager(store, &Options{BatchSize: batchSize, LowWatermark: lowWatermark})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestConsumeOnce(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		m := newManager(t, store, 4, 1)
+		b, err := m.Bundle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.LastResort {
+			t.Fatal("one-time prekey expected")
+		}
+		ct, ssA, err := kyberk2so.KemEncrypt1024(b.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ssB, err := m.Decapsulate(b.ID, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ssA != ssB {
+			t.Fatal("shared secrets differ")
+		}
+		if _, err := m.Decapsulate(b.ID, ct); !errors.Is(err, ErrUnknownPrekey) {
+			t.Fatalf("second use: got %v, want ErrUnknownPrekey", err)
+		}
+		// Unpublished prekeys cannot be consumed.
+		if _, err := m.Decapsulate(b.ID+1, ct); !errors.Is(err, ErrUnknownPrekey) {
+			t.Fatalf("unpublished prekey: got %v, want ErrUnknownPrekey", err)
+		}
+	})
+}
+
+func TestLastResort(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		m := newManager(t, store, 2, 1)
+		seen := make(map[uint32]bool)
+		// The third bundle refills the store once the first batch ran out.
+		for range 3 {
+			b, err := m.Bundle()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.LastResort || seen[b.ID] {
+				t.Fatal("expected a fresh one-time prekey")
+			}
+			seen[b.ID] = true
+		}
+		if n, _ := store.Unpublished(); n != 1 {
+			t.Fatalf("%d unpublished prekeys after refill, want 1", n)
+		}
+		// Exhaust the one-time prekeys without refilling.
+		for {
+			r, ok, err := store.Publish()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				break
+			}
+			if r.PrivateKey != [kyberk2so.Kyber1024SKBytes]byte{} {
+				t.Fatal("published record carries its private key")
+			}
+		}
+		m.lowWatermark = 0
+		b, err := m.Bundle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.LastResort {
+			t.Fatal("last-resort prekey expected")
+		}
+		for range 2 {
+			ct, ssA, _ := kyberk2so.KemEncrypt1024(b.PublicKey)
+			ssB, err := m.Decapsulate(b.ID, ct)
+			if err != nil || ssA != ssB {
+				t.Fatal("last-resort prekey was consumed")
+			}
+		}
+		if err := m.RotateLastResort(); err != nil {
+			t.Fatal(err)
+		}
+		if r, ok, _ := store.LastResort(); !ok || r.ID == b.ID {
+			t.Fatal("last-resort prekey was not replaced")
+		}
+		ct, _, _ := kyberk2so.KemEncrypt1024(b.PublicKey)
+		if _, err := m.Decapsulate(b.ID, ct); !errors.Is(err, ErrUnknownPrekey) {
+			t.Fatalf("replaced last-resort prekey: got %v, want ErrUnknownPrekey", err)
+		}
+	})
+}
+
+func TestConcurrentConsumption(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		m := newManager(t, store, 8, 1)
+		b, err := m.Bundle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, ss, _ := kyberk2so.KemEncrypt1024(b.PublicKey)
+		const racers = 16
+		var wg sync.WaitGroup
+		results := make(chan error, racers)
+		for range racers {
+			wg.Go(func() {
+				got, err := m.Decapsulate(b.ID, ct)
+				if err == nil && got != ss {
+					err = errors.New("wrong shared secret")
+				}
+				results <- err
+			})
+		}
+		wg.Wait()
+		close(results)
+		successes := 0
+		for err := range results {
+			switch {
+			case err == nil:
+				successes++
+			case !errors.Is(err, ErrUnknownPrekey):
+				t.Fatal(err)
+			}
+		}
+		if successes != 1 {
+			t.Fatalf("prekey consumed %d times", successes)
+		}
+	})
+}
+
+func TestConcurrentBundles(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		m := newManager(t, store, 8, 4)
+		const n = 32
+		var mu sync.Mutex
+		seen := make(map[uint32]bool)
+		var wg sync.WaitGroup
+		for range n {
+			wg.Go(func() {
+				b, err := m.Bundle()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if b.LastResort {
+					t.Errorf("last-resort prekey %d handed out while one-time prekeys remain", b.ID)
+				}
+				if seen[b.ID] {
+					t.Errorf("prekey %d handed out twice", b.ID)
+				}
+				seen[b.ID] = true
+			})
+		}
+		wg.Wait()
+	})
+}
+
+func TestSmallBatchSize(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		// The default low watermark of a small batch must still refill.
+		m, err := NewManager(store, &Options{BatchSize: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 5 {
+			b, err := m.Bundle()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.LastResort {
+				t.Fatal("fell back to the last-resort prekey")
+			}
+		}
+	})
+}
+
+func TestFileStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newManager(t, store, 4, 1)
+	b, err := m.Bundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A crash during consumption leaves a claimed file behind, which is
+	// destroyed when the store is reopened.
+	leftover := filepath.Join(dir, dirConsumed, name(99)+".0000000000000000")
+	if err := os.WriteFile(leftover, make([]byte, recordSize), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(leftover); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("interrupted consumption was not cleaned up")
+	}
+	m = newManager(t, reopened, 4, 1)
+	ct, ssA, _ := kyberk2so.KemEncrypt1024(b.PublicKey)
+	ssB, err := m.Decapsulate(b.ID, ct)
+	if err != nil || ssA != ssB {
+		t.Fatal("published prekey did not survive reopening")
+	}
+	next, err := m.Bundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= b.ID {
+		t.Fatal("reopened store reused an ID")
+	}
+	// Corrupt stored prekeys are rejected.
+	path := filepath.Join(dir, dirPublished, name(next.ID))
+	if err := os.WriteFile(path, []byte("corrupt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Decapsulate(next.ID, ct); !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("got %v, want ErrInvalidRecord", err)
+	}
+}
+
+func TestFileStoreIDsNotReused(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued uint32
+	for range 3 {
+		r := Record{}
+		if err := store.Add(&r); err != nil {
+			t.Fatal(err)
+		}
+		issued = max(issued, r.ID)
+	}
+	for {
+		r, ok, err := store.Publish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		if _, err := store.Consume(r.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		r := Record{}
+		if err := reopened.Add(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ID <= issued {
+			t.Fatalf("reopened store issued ID %d, already issued up to %d", r.ID, issued)
+		}
+	}
+	// A corrupt counter is rejected rather than silently reset.
+	if err := os.WriteFile(filepath.Join(dir, counterFile), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(dir); !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("got %v, want ErrInvalidRecord", err)
+	}
+}
@@ -0,0 +1,245 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/keywrap"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidDataKey is returned when a data key to wrap is shorter
+	// than 16 bytes or not a multiple of 8 bytes long.
+	ErrInvalidDataKey = errors.New("kyberk2so: invalid data key length")
+
+	// ErrInvalidWrappedKey is returned when a wrapped key has the wrong
+	// length for the decapsulation key, or fails its integrity check.
+	ErrInvalidWrappedKey = errors.New("kyberk2so: invalid wrapped key")
+)
+
+// rewrapLabel is the label of the labeled encapsulation that derives
+// key-encryption keys.
+var rewrapLabel = []byte("kyberk2so key wrap v1")
+
+// rewrapParams holds the functions of the parameter set of a key,
+// selected by its length so that wrapped keys can move between
+// parameter sets.
+type rewrapParams struct {
+	ciphertextBytes int
+	keyIDOffset     int
+	encrypt         func(publicKey []byte) ([]byte, []byte, error)
+	decrypt         func(ciphertext, privateKey []byte) ([]byte, error)
+}
+
+var rewrapParamSets = []rewrapParams{
+	{
+		ciphertextBytes: Kyber512CTBytes,
+		keyIDOffset:     paramsIndcpaSecretKeyBytesK512 + paramsIndcpaPublicKeyBytesK512,
+		encrypt: func(publicKey []byte) ([]byte, []byte, error) {
+			ciphertext, ss, err := KemEncrypt512([Kyber512PKBytes]byte(publicKey))
+			return ciphertext[:], ss[:], err
+		},
+		decrypt: func(ciphertext, privateKey []byte) ([]byte, error) {
+			ss, err := KemDecrypt512([Kyber512CTBytes]byte(ciphertext), [Kyber512SKBytes]byte(privateKey))
+			return ss[:], err
+		},
+	},
+	{
+		ciphertextBytes: Kyber768CTBytes,
+		keyIDOffset:     paramsIndcpaSecretKeyBytesK768 + paramsIndcpaPublicKeyBytesK768,
+		encrypt: func(publicKey []byte) ([]byte, []byte, error) {
+			ciphertext, ss, err := KemEncrypt768([Kyber768PKBytes]byte(publicKey))
+			return ciphertext[:], ss[:], err
+		},
+		decrypt: func(ciphertext, privateKey []byte) ([]byte, error) {
+			ss, err := KemDecrypt768([Kyber768CTBytes]byte(ciphertext), [Kyber768SKBytes]byte(privateKey))
+			return ss[:], err
+		},
+	},
+	{
+		ciphertextBytes: Kyber1024CTBytes,
+		keyIDOffset:     paramsIndcpaSecretKeyBytesK1024 + paramsIndcpaPublicKeyBytesK1024,
+		encrypt: func(publicKey []byte) ([]byte, []byte, error) {
+			ciphertext, ss, err := KemEncrypt1024([Kyber1024PKBytes]byte(publicKey))
+			return ciphertext[:], ss[:], err
+		},
+		decrypt: func(ciphertext, privateKey []byte) ([]byte, error) {
+			ss, err := KemDecrypt1024([Kyber1024CTBytes]byte(ciphertext), [Kyber1024SKBytes]byte(privateKey))
+			return ss[:], err
+		},
+	},
+}
+
+// rewrapPublicParams returns the parameter set of an encapsulation key.
+func rewrapPublicParams(publicKey []byte) (*rewrapParams, error) {
+	switch len(publicKey) {
+	case Kyber512PKBytes:
+		return &rewrapParamSets[0], nil
+	case Kyber768PKBytes:
+		return &rewrapParamSets[1], nil
+	case Kyber1024PKBytes:
+		return &rewrapParamSets[2], nil
+	default:
+		return nil, ErrInvalidEncapsulationKey
+	}
+}
+
+// rewrapPrivateParams returns the parameter set of a decapsulation key.
+func rewrapPrivateParams(privateKey []byte) (*rewrapParams, error) {
+	switch len(privateKey) {
+	case Kyber512SKBytes:
+		return &rewrapParamSets[0], nil
+	case Kyber768SKBytes:
+		return &rewrapParamSets[1], nil
+	case Kyber1024SKBytes:
+		return &rewrapParamSets[2], nil
+	default:
+		return nil, ErrInvalidDecapsulationKey
+	}
+}
+
+// KemWrapKey takes a public key of any parameter set and a data key of
+// at least 16 bytes and a multiple of 8 bytes long, and returns the data key wrapped
+// to the public key: a ciphertext followed by the data key wrapped with
+// the AES key wrap of RFC 3394 under a key-encryption key derived from
+// the shared secret by KDFHKDFSHA256 labeled encapsulation.
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, or if the public key or the data key
+// length is invalid.
+func KemWrapKey(publicKey, dataKey []byte) ([]byte, error) {
+	params, err := rewrapPublicParams(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	keyID := sha3.Sum256(publicKey)
+	return kemWrapKey(params, publicKey, keyID[:], dataKey)
+}
+
+// KemUnwrapKey takes a private key of any parameter set and a key
+// wrapped to its public key (from KemWrapKey or KemRewrap), and returns
+// the data key.
+// An accompanying error is returned if the private key is invalid or if
+// the wrapped key is malformed or was not wrapped to this key.
+func KemUnwrapKey(privateKey, wrapped []byte) ([]byte, error) {
+	params, err := rewrapPrivateParams(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return kemUnwrapKey(params, privateKey, wrapped)
+}
+
+// KemRewrap takes the old private key, the new public key, of the same
+// or another parameter set, and a key wrapped to the old key pair, and
+// returns the same data key wrapped to the new public key. The shared
+// secrets, key-encryption keys and data key never leave the library and
+// are zeroized before returning.
+// An accompanying error is returned if no sufficient randomness could
+// be obtained from the system, if either key is invalid or if the
+// wrapped key is malformed or was not wrapped to the old key.
+func KemRewrap(oldPrivateKey, newPublicKey, wrapped []byte) ([]byte, error) {
+	oldParams, newParams, keyID, err := kemRewrapParams(oldPrivateKey, newPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return kemRewrap(oldParams, newParams, oldPrivateKey, newPublicKey, keyID[:], wrapped)
+}
+
+// KemRewrapBatch is KemRewrap for many wrapped keys under the same pair
+// of keys, which are checked once. It returns all rewrapped keys in
+// order, or none and an error naming the index of the first wrapped key
+// that could not be rewrapped.
+func KemRewrapBatch(oldPrivateKey, newPublicKey []byte, wrapped [][]byte) ([][]byte, error) {
+	oldParams, newParams, keyID, err := kemRewrapParams(oldPrivateKey, newPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	rewrapped := make([][]byte, len(wrapped))
+	for i, w := range wrapped {
+		rewrapped[i], err = kemRewrap(oldParams, newParams, oldPrivateKey, newPublicKey, keyID[:], w)
+		if err != nil {
+			return nil, fmt.Errorf("kyberk2so: wrapped key %d: %w", i, err)
+		}
+	}
+	return rewrapped, nil
+}
+
+// kemRewrapParams selects the parameter sets of both keys, checks the
+// new public key and returns its key ID.
+func kemRewrapParams(oldPrivateKey, newPublicKey []byte) (*rewrapParams, *rewrapParams, [32]byte, error) {
+	oldParams, err := rewrapPrivateParams(oldPrivateKey)
+	if err != nil {
+		return nil, nil, [32]byte{}, err
+	}
+	newParams, err := rewrapPublicParams(newPublicKey)
+	if err != nil {
+		return nil, nil, [32]byte{}, err
+	}
+	polyvecBytes := len(newPublicKey) - paramsSymBytes
+	if !polyvecBytesValid(newPublicKey[:polyvecBytes], polyvecBytes/paramsPolyBytes) {
+		return nil, nil, [32]byte{}, ErrInvalidEncapsulationKey
+	}
+	return oldParams, newParams, sha3.Sum256(newPublicKey), nil
+}
+
+// kemRewrap unwraps one data key and wraps it again, zeroizing the data
+// key in between.
+func kemRewrap(oldParams, newParams *rewrapParams, oldPrivateKey, newPublicKey, keyID, wrapped []byte) ([]byte, error) {
+	dataKey, err := kemUnwrapKey(oldParams, oldPrivateKey, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	defer byteopsZeroBytes(dataKey)
+	return kemWrapKey(newParams, newPublicKey, keyID, dataKey)
+}
+
+// kemWrapKey encapsulates to publicKey and wraps dataKey under the
+// derived key-encryption key.
+func kemWrapKey(params *rewrapParams, publicKey, keyID, dataKey []byte) ([]byte, error) {
+	if len(dataKey) < 16 || len(dataKey)%8 != 0 {
+		return nil, ErrInvalidDataKey
+	}
+	ciphertext, ss, err := params.encrypt(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := kemLabeledKey(KDFHKDFSHA256, ss, keyID, ciphertext, rewrapLabel, nil, 32)
+	byteopsZeroBytes(ss)
+	if err != nil {
+		return nil, err
+	}
+	defer byteopsZeroBytes(kek)
+	w, err := keywrap.Wrap(kek, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(ciphertext, w...), nil
+}
+
+// kemUnwrapKey decapsulates the ciphertext of wrapped and unwraps its
+// data key.
+func kemUnwrapKey(params *rewrapParams, privateKey, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < params.ciphertextBytes+24 || len(wrapped)%8 != params.ciphertextBytes%8 {
+		return nil, ErrInvalidWrappedKey
+	}
+	ciphertext := wrapped[:params.ciphertextBytes]
+	ss, err := params.decrypt(ciphertext, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	keyID := privateKey[params.keyIDOffset : params.keyIDOffset+paramsSymBytes]
+	kek, err := kemLabeledKey(KDFHKDFSHA256, ss, keyID, ciphertext, rewrapLabel, nil, 32)
+	byteopsZeroBytes(ss)
+	if err != nil {
+		return nil, err
+	}
+	defer byteopsZeroBytes(kek)
+	dataKey, err := keywrap.Unwrap(kek, wrapped[params.ciphertextBytes:])
+	if err != nil {
+		return nil, ErrInvalidWrappedKey
+	}
+	return dataKey, nil
+}
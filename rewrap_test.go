@@ -0,0 +1,120 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRewrap(t *testing.T) {
+	sk512, pk512, _ := KemKeypair512()
+	sk768, pk768, _ := KemKeypair768()
+	sk1024, pk1024, _ := KemKeypair1024()
+	keys := []struct {
+		name       string
+		privateKey []byte
+		publicKey  []byte
+	}{
+		{"512", sk512[:], pk512[:]},
+		{"768", sk768[:], pk768[:]},
+		{"1024", sk1024[:], pk1024[:]},
+	}
+	dataKey := bytes.Repeat([]byte{0x42}, 32)
+	for _, from := range keys {
+		wrapped, err := KemWrapKey(from.publicKey, dataKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := KemUnwrapKey(from.privateKey, wrapped)
+		if err != nil || !bytes.Equal(got, dataKey) {
+			t.Fatalf("%s: data key does not round-trip", from.name)
+		}
+		for _, to := range keys {
+			rewrapped, err := KemRewrap(from.privateKey, to.publicKey, wrapped)
+			if err != nil {
+				t.Fatalf("%s to %s: %v", from.name, to.name, err)
+			}
+			got, err := KemUnwrapKey(to.privateKey, rewrapped)
+			if err != nil || !bytes.Equal(got, dataKey) {
+				t.Fatalf("%s to %s: rewrapped data key differs", from.name, to.name)
+			}
+			if to.name != from.name {
+				if _, err := KemUnwrapKey(from.privateKey, rewrapped); err == nil {
+					t.Fatalf("%s to %s: old key unwraps the rewrapped key", from.name, to.name)
+				}
+			}
+		}
+	}
+}
+
+func TestRewrapBatch(t *testing.T) {
+	oldSK, oldPK, _ := KemKeypair768()
+	newSK, newPK, _ := KemKeypair1024()
+	wrapped := make([][]byte, 5)
+	for i := range wrapped {
+		var err error
+		wrapped[i], err = KemWrapKey(oldPK[:], bytes.Repeat([]byte{byte(i)}, 16+8*i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rewrapped, err := KemRewrapBatch(oldSK[:], newPK[:], wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rewrapped {
+		got, err := KemUnwrapKey(newSK[:], rewrapped[i])
+		if err != nil || !bytes.Equal(got, bytes.Repeat([]byte{byte(i)}, 16+8*i)) {
+			t.Fatalf("key %d: rewrapped data key differs", i)
+		}
+	}
+	wrapped[3][len(wrapped[3])-1] ^= 1
+	rewrapped, err = KemRewrapBatch(oldSK[:], newPK[:], wrapped)
+	if !errors.Is(err, ErrInvalidWrappedKey) || rewrapped != nil {
+		t.Fatalf("got %v, want ErrInvalidWrappedKey", err)
+	}
+	if err.Error() != "kyberk2so: wrapped key 3: "+ErrInvalidWrappedKey.Error() {
+		t.Fatalf("error %q does not name the wrapped key", err)
+	}
+}
+
+func TestRewrapInvalid(t *testing.T) {
+	sk, pk, _ := KemKeypair768()
+	wrapped, err := KemWrapKey(pk[:], make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 15, 20} {
+		if _, err := KemWrapKey(pk[:], make([]byte, n)); !errors.Is(err, ErrInvalidDataKey) {
+			t.Fatalf("%d-byte data key: got %v, want ErrInvalidDataKey", n, err)
+		}
+	}
+	if _, err := KemWrapKey(pk[:10], make([]byte, 32)); !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidEncapsulationKey", err)
+	}
+	if _, err := KemUnwrapKey(sk[:10], wrapped); !errors.Is(err, ErrInvalidDecapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidDecapsulationKey", err)
+	}
+	for _, tampered := range [][]byte{
+		wrapped[:len(wrapped)-8],
+		wrapped[:Kyber768CTBytes],
+		append(bytes.Clone(wrapped[:10]), wrapped[11:]...),
+		func() []byte { w := bytes.Clone(wrapped); w[0] ^= 1; return w }(),
+	} {
+		if _, err := KemUnwrapKey(sk[:], tampered); !errors.Is(err, ErrInvalidWrappedKey) {
+			t.Fatalf("got %v, want ErrInvalidWrappedKey", err)
+		}
+	}
+	otherSK, _, _ := KemKeypair768()
+	if _, err := KemUnwrapKey(otherSK[:], wrapped); !errors.Is(err, ErrInvalidWrappedKey) {
+		t.Fatalf("wrong key: got %v, want ErrInvalidWrappedKey", err)
+	}
+	invalid := bytes.Clone(pk[:])
+	invalid[0], invalid[1] = 0xff, 0xff
+	if _, err := KemRewrap(sk[:], invalid, wrapped); !errors.Is(err, ErrInvalidEncapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidEncapsulationKey", err)
+	}
+}
@@ -0,0 +1,40 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package shamir
+
+// GF(256) arithmetic modulo the AES polynomial x^8 + x^4 + x^3 + x + 1,
+// without table lookups or secret-dependent branches.
+
+// gfMul returns a * b.
+func gfMul(a, b byte) byte {
+	var p byte
+	for range 8 {
+		p ^= -(b & 1) & a
+		b >>= 1
+		a = (a << 1) ^ (-(a >> 7) & 0x1b)
+	}
+	return p
+}
+
+// gfInv returns the inverse of a as a^254, or 0 for 0.
+func gfInv(a byte) byte {
+	// a^254 = a^(2+4+8+16+32+64+128).
+	sq := gfMul(a, a)
+	r := sq
+	for range 6 {
+		sq = gfMul(sq, sq)
+		r = gfMul(r, sq)
+	}
+	return r
+}
+
+// gfEval evaluates the polynomial with the given coefficients, constant
+// term first, at x.
+func gfEval(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coefficients[i]
+	}
+	return y
+}
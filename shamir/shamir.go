@@ -0,0 +1,257 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package shamir splits the 64-byte seed of an ML-KEM key pair, the
+// d || z input of KemKeypairDerand, into t-of-n Shamir shares over
+// GF(256).
+//
+// Each byte of the seed is the constant term of a random polynomial of
+// degree t-1, and share i holds the evaluations of all 64 polynomials
+// at x = i. Any t shares reconstruct the seed by Lagrange interpolation
+// at zero, and fewer reveal nothing about it. The arithmetic runs
+// without table lookups or secret-dependent branches.
+//
+// A share carries the threshold, its index, the parameter set and a
+// commitment to the key pair, the SHA3-256 hash H(ek) of its
+// encapsulation key. Combine re-derives the key pair from the
+// reconstructed seed and checks it against the commitment, so that a
+// wrong, stale or corrupted share is detected before the key is used.
+// The commitment is public and links shares to the published key.
+//
+// Refresh replaces a set of shares with new shares of the same seed,
+// without reconstructing it, so that shares leaked before the refresh
+// cannot be combined with shares issued after it.
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// ErrInvalidParameters is returned when the threshold or number of
+	// shares is out of range, or the KEM is unknown.
+	ErrInvalidParameters = errors.New("shamir: invalid parameters")
+
+	// ErrInvalidShare is returned when a serialized share is malformed.
+	ErrInvalidShare = errors.New("shamir: invalid share")
+
+	// ErrMismatchedShares is returned when shares disagree on their
+	// parameter set, threshold or commitment, or repeat an index.
+	ErrMismatchedShares = errors.New("shamir: shares do not belong together")
+
+	// ErrInsufficientShares is returned when fewer shares than the
+	// threshold are combined.
+	ErrInsufficientShares = errors.New("shamir: not enough shares")
+
+	// ErrCommitmentMismatch is returned when the reconstructed seed does
+	// not derive the committed encapsulation key.
+	ErrCommitmentMismatch = errors.New("shamir: reconstructed key does not match commitment")
+)
+
+// KEM identifies the ML-KEM parameter set of a seed.
+type KEM int
+
+// Supported ML-KEM parameter sets.
+const (
+	MLKEM512 KEM = iota + 1
+	MLKEM768
+	MLKEM1024
+)
+
+// params returns the ML-KEM parameter set of kem, or zero if kem is not
+// supported.
+func (kem KEM) params() mlkem.ParameterSet {
+	switch kem {
+	case MLKEM512:
+		return mlkem.MLKEM512
+	case MLKEM768:
+		return mlkem.MLKEM768
+	case MLKEM1024:
+		return mlkem.MLKEM1024
+	default:
+		return 0
+	}
+}
+
+// SeedSize is the size of the seed d || z.
+const SeedSize = 64
+
+// shareVersion is the version byte of serialized shares.
+const shareVersion = 1
+
+// ShareSize is the size of a serialized share.
+const ShareSize = 4 + 32 + SeedSize
+
+// Share is one custodian's share of a seed.
+type Share struct {
+	KEM        KEM
+	Threshold  int
+	Index      int
+	Commitment [32]byte
+	Value      [SeedSize]byte
+}
+
+// MarshalBinary encodes the share as version || KEM || threshold ||
+// index || commitment || value.
+func (s *Share) MarshalBinary() ([]byte, error) {
+	if !s.valid() {
+		return nil, ErrInvalidShare
+	}
+	b := []byte{shareVersion, byte(s.KEM), byte(s.Threshold), byte(s.Index)}
+	b = append(b, s.Commitment[:]...)
+	return append(b, s.Value[:]...), nil
+}
+
+// UnmarshalBinary decodes a share encoded by MarshalBinary.
+func (s *Share) UnmarshalBinary(data []byte) error {
+	if len(data) != ShareSize || data[0] != shareVersion {
+		return ErrInvalidShare
+	}
+	decoded := Share{KEM: KEM(data[1]), Threshold: int(data[2]), Index: int(data[3])}
+	copy(decoded.Commitment[:], data[4:36])
+	copy(decoded.Value[:], data[36:])
+	if !decoded.valid() {
+		return ErrInvalidShare
+	}
+	*s = decoded
+	return nil
+}
+
+// valid checks the fields of a share that can be checked alone.
+func (s *Share) valid() bool {
+	return s.KEM.valid() && s.Threshold >= 2 && s.Index >= 1 && s.Index <= 255 && s.Threshold <= 255
+}
+
+// Commitment returns H(ek) for the key pair derived from seed.
+func Commitment(kem KEM, seed [SeedSize]byte) ([32]byte, error) {
+	sk, pk, err := kem.params().KeypairDerand(seed)
+	if errors.Is(err, mlkem.ErrParameterSet) {
+		return [32]byte{}, ErrInvalidParameters
+	}
+	mlkem.ZeroBytes(sk)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha3.Sum256(pk), nil
+}
+
+// Split splits seed into n shares, any threshold of which reconstruct
+// it. It requires 2 <= threshold <= n <= 255.
+func Split(kem KEM, seed [SeedSize]byte, threshold, n int) ([]Share, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, ErrInvalidParameters
+	}
+	commitment, err := Commitment(kem, seed)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{KEM: kem, Threshold: threshold, Index: i + 1, Commitment: commitment}
+	}
+	coefficients := make([]byte, threshold)
+	defer mlkem.ZeroBytes(coefficients)
+	for j := range SeedSize {
+		coefficients[0] = seed[j]
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i].Value[j] = gfEval(coefficients, byte(shares[i].Index))
+		}
+	}
+	return shares, nil
+}
+
+// Combine reconstructs the seed from at least a threshold of shares and
+// checks it against their commitment. Only the first threshold shares
+// are interpolated.
+func Combine(shares []Share) (KEM, [SeedSize]byte, error) {
+	var seed [SeedSize]byte
+	if err := checkShares(shares); err != nil {
+		return 0, seed, err
+	}
+	first := shares[0]
+	shares = shares[:first.Threshold]
+	for i := range shares {
+		// The Lagrange basis polynomial of share i, evaluated at zero:
+		// the product of x_j / (x_j - x_i) over the other shares.
+		basis := byte(1)
+		for j := range shares {
+			if j != i {
+				xi, xj := byte(shares[i].Index), byte(shares[j].Index)
+				basis = gfMul(basis, gfMul(xj, gfInv(xj^xi)))
+			}
+		}
+		for k := range seed {
+			seed[k] ^= gfMul(basis, shares[i].Value[k])
+		}
+	}
+	commitment, err := Commitment(first.KEM, seed)
+	if err != nil {
+		mlkem.ZeroBytes(seed[:])
+		return 0, seed, err
+	}
+	if subtle.ConstantTimeCompare(commitment[:], first.Commitment[:]) != 1 {
+		mlkem.ZeroBytes(seed[:])
+		return 0, seed, ErrCommitmentMismatch
+	}
+	return first.KEM, seed, nil
+}
+
+// Refresh returns new shares of the same seed with the same indexes, by
+// adding to every share the evaluation of a random polynomial with a
+// zero constant term. The seed is never reconstructed. All shares that
+// should remain usable must be refreshed together; old and new shares
+// do not combine.
+func Refresh(shares []Share) ([]Share, error) {
+	if err := checkShares(shares); err != nil {
+		return nil, err
+	}
+	refreshed := make([]Share, len(shares))
+	copy(refreshed, shares)
+	coefficients := make([]byte, shares[0].Threshold)
+	defer mlkem.ZeroBytes(coefficients)
+	for j := range SeedSize {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range refreshed {
+			refreshed[i].Value[j] ^= gfEval(coefficients, byte(refreshed[i].Index))
+		}
+	}
+	return refreshed, nil
+}
+
+// checkShares checks that there are enough shares and that they belong
+// to the same sharing.
+func checkShares(shares []Share) error {
+	if len(shares) == 0 {
+		return ErrInsufficientShares
+	}
+	first := shares[0]
+	var seen [256]bool
+	for _, s := range shares {
+		if !s.valid() {
+			return ErrInvalidShare
+		}
+		if s.KEM != first.KEM || s.Threshold != first.Threshold || s.Commitment != first.Commitment || seen[s.Index] {
+			return ErrMismatchedShares
+		}
+		seen[s.Index] = true
+	}
+	if len(shares) < first.Threshold {
+		return ErrInsufficientShares
+	}
+	return nil
+}
+
+func (kem KEM) valid() bool {
+	_, skSize, _ := kem.params().Sizes()
+	return skSize != 0
+}
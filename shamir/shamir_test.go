@@ -0,0 +1,194 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestGF256(t *testing.T) {
+	// Compare multiplication with the carry-less product reduced by
+	// long division, and check every inverse.
+	for a := 0; a < 256; a++ {
+		for b := 0; b < 256; b++ {
+			var p uint16
+			for i := 0; i < 8; i++ {
+				if b>>i&1 == 1 {
+					p ^= uint16(a) << i
+				}
+			}
+			for i := 15; i >= 8; i-- {
+				if p>>i&1 == 1 {
+					p ^= 0x11b << (i - 8)
+				}
+			}
+			if gfMul(byte(a), byte(b)) != byte(p) {
+				t.Fatalf("%d * %d = %d, want %d", a, b, gfMul(byte(a), byte(b)), p)
+			}
+		}
+		if a != 0 && gfMul(byte(a), gfInv(byte(a))) != 1 {
+			t.Fatalf("%d has no inverse", a)
+		}
+	}
+	if gfInv(0) != 0 {
+		t.Fatal("inverse of zero is not zero")
+	}
+}
+
+func randomSeed(t *testing.T) [SeedSize]byte {
+	t.Helper()
+	var seed [SeedSize]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		t.Fatal(err)
+	}
+	return seed
+}
+
+func TestSplitCombine(t *testing.T) {
+	seed := randomSeed(t)
+	shares, err := Split(MLKEM768, seed, 3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every subset of three shares, in any order, reconstructs the seed.
+	for a := range shares {
+		for b := range shares {
+			for c := range shares {
+				if a == b || b == c || a == c {
+					continue
+				}
+				kem, got, err := Combine([]Share{shares[a], shares[b], shares[c]})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if kem != MLKEM768 || got != seed {
+					t.Fatalf("shares %d, %d, %d: wrong seed", a, b, c)
+				}
+			}
+		}
+	}
+	if _, _, err := Combine(shares[:2]); !errors.Is(err, ErrInsufficientShares) {
+		t.Fatalf("got %v, want ErrInsufficientShares", err)
+	}
+	// The commitment is H(ek) of the key pair derived from the seed.
+	_, publicKey, err := kyberk2so.KemKeypairDerand768(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sha3.Sum256(publicKey[:]) != shares[0].Commitment {
+		t.Fatal("commitment differs from H(ek)")
+	}
+}
+
+func TestCommitment(t *testing.T) {
+	seed := randomSeed(t)
+	shares, err := Split(MLKEM512, seed, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := shares[1]
+	corrupted.Value[10] ^= 1
+	if _, _, err := Combine([]Share{shares[0], corrupted}); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Fatalf("corrupted share: got %v, want ErrCommitmentMismatch", err)
+	}
+	other, err := Split(MLKEM512, randomSeed(t), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Combine([]Share{shares[0], other[1]}); !errors.Is(err, ErrMismatchedShares) {
+		t.Fatalf("other sharing: got %v, want ErrMismatchedShares", err)
+	}
+	// A share claiming the other sharing's commitment is still caught.
+	forged := other[1]
+	forged.Commitment = shares[0].Commitment
+	if _, _, err := Combine([]Share{shares[0], forged}); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Fatalf("forged share: got %v, want ErrCommitmentMismatch", err)
+	}
+	if _, _, err := Combine([]Share{shares[0], shares[0]}); !errors.Is(err, ErrMismatchedShares) {
+		t.Fatalf("repeated share: got %v, want ErrMismatchedShares", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	seed := randomSeed(t)
+	shares, err := Split(MLKEM1024, seed, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := Refresh(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range shares {
+		if refreshed[i].Value == shares[i].Value || refreshed[i].Index != shares[i].Index {
+			t.Fatalf("share %d was not refreshed", i)
+		}
+	}
+	if _, got, err := Combine(refreshed[1:]); err != nil || got != seed {
+		t.Fatal("refreshed shares do not reconstruct the seed")
+	}
+	mixed := []Share{shares[0], refreshed[1], refreshed[2]}
+	if _, _, err := Combine(mixed); !errors.Is(err, ErrCommitmentMismatch) {
+		t.Fatalf("old and new shares: got %v, want ErrCommitmentMismatch", err)
+	}
+}
+
+func TestSerialization(t *testing.T) {
+	shares, err := Split(MLKEM768, randomSeed(t), 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := shares[1].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != ShareSize {
+		t.Fatalf("share is %d bytes, want %d", len(b), ShareSize)
+	}
+	var decoded Share
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != shares[1] {
+		t.Fatal("share does not round-trip")
+	}
+	for _, tc := range []struct {
+		offset int
+		value  byte
+	}{{0, 2}, {1, 0}, {1, 4}, {2, 1}, {3, 0}} {
+		tampered := append([]byte(nil), b...)
+		tampered[tc.offset] = tc.value
+		if err := decoded.UnmarshalBinary(tampered); !errors.Is(err, ErrInvalidShare) {
+			t.Fatalf("byte %d = %d: got %v, want ErrInvalidShare", tc.offset, tc.value, err)
+		}
+	}
+	if err := decoded.UnmarshalBinary(b[1:]); !errors.Is(err, ErrInvalidShare) {
+		t.Fatalf("short share: got %v, want ErrInvalidShare", err)
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	seed := randomSeed(t)
+	for _, tc := range []struct {
+		kem          KEM
+		threshold, n int
+	}{
+		{MLKEM768, 1, 3},
+		{MLKEM768, 4, 3},
+		{MLKEM768, 2, 256},
+		{0, 2, 3},
+	} {
+		if _, err := Split(tc.kem, seed, tc.threshold, tc.n); !errors.Is(err, ErrInvalidParameters) {
+			t.Fatalf("%d-of-%d for KEM %d: got %v, want ErrInvalidParameters", tc.threshold, tc.n, tc.kem, err)
+		}
+	}
+	if _, err := Split(MLKEM768, seed, 255, 255); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package sshkex
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// Handshake holds the handshake values that precede the key exchange
+// in the exchange hash.
+type Handshake struct {
+	// ClientVersion and ServerVersion are the identification strings,
+	// without the trailing CR LF.
+	ClientVersion []byte
+	ServerVersion []byte
+
+	// ClientKexInit and ServerKexInit are the SSH_MSG_KEXINIT payloads.
+	ClientKexInit []byte
+	ServerKexInit []byte
+}
+
+// ExchangeHash returns the exchange hash H over the handshake, the
+// server host key blob K_S, C_INIT, S_REPLY and the shared secret K,
+// every field encoded as an SSH string.
+func ExchangeHash(hs *Handshake, hostKey, clientInit, serverReply, k []byte) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{
+		hs.ClientVersion, hs.ServerVersion, hs.ClientKexInit, hs.ServerKexInit,
+		hostKey, clientInit, serverReply, k,
+	} {
+		writeString(h, field)
+	}
+	return h.Sum(nil)
+}
+
+// DeriveKey derives n bytes of the key identified by letter ('A'
+// through 'F') as in RFC 4253, Section 7.2, with K encoded as an SSH
+// string. sessionID is the exchange hash of the first key exchange.
+func DeriveKey(k, h, sessionID []byte, letter byte, n int) []byte {
+	d := sha256.New()
+	writeString(d, k)
+	_, _ = d.Write(h)
+	_, _ = d.Write([]byte{letter})
+	_, _ = d.Write(sessionID)
+	out := d.Sum(nil)
+	for len(out) < n {
+		d.Reset()
+		writeString(d, k)
+		_, _ = d.Write(h)
+		_, _ = d.Write(out)
+		out = d.Sum(out)
+	}
+	return out[:n]
+}
+
+// MarshalInit returns the SSH_MSG_KEX_HYBRID_INIT payload carrying C_INIT.
+func MarshalInit(clientInit []byte) []byte {
+	return appendString([]byte{MsgKexHybridInit}, clientInit)
+}
+
+// ParseInit returns C_INIT from an SSH_MSG_KEX_HYBRID_INIT payload.
+func ParseInit(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || payload[0] != MsgKexHybridInit {
+		return nil, ErrMalformedMessage
+	}
+	clientInit, rest, ok := parseString(payload[1:])
+	if !ok || len(rest) != 0 {
+		return nil, ErrMalformedMessage
+	}
+	return clientInit, nil
+}
+
+// Reply is the content of SSH_MSG_KEX_HYBRID_REPLY.
+type Reply struct {
+	HostKey     []byte
+	ServerReply []byte
+	Signature   []byte
+}
+
+// Marshal returns the SSH_MSG_KEX_HYBRID_REPLY payload.
+func (r *Reply) Marshal() []byte {
+	b := []byte{MsgKexHybridReply}
+	b = appendString(b, r.HostKey)
+	b = appendString(b, r.ServerReply)
+	return appendString(b, r.Signature)
+}
+
+// ParseReply parses an SSH_MSG_KEX_HYBRID_REPLY payload.
+func ParseReply(payload []byte) (*Reply, error) {
+	if len(payload) == 0 || payload[0] != MsgKexHybridReply {
+		return nil, ErrMalformedMessage
+	}
+	r := new(Reply)
+	rest := payload[1:]
+	for _, field := range []*[]byte{&r.HostKey, &r.ServerReply, &r.Signature} {
+		var ok bool
+		if *field, rest, ok = parseString(rest); !ok {
+			return nil, ErrMalformedMessage
+		}
+	}
+	if len(rest) != 0 {
+		return nil, ErrMalformedMessage
+	}
+	return r, nil
+}
+
+// writeString writes s to h as an SSH string.
+func writeString(h hash.Hash, s []byte) {
+	_, _ = h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(s))))
+	_, _ = h.Write(s)
+}
+
+// appendString appends s to b as an SSH string.
+func appendString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// parseString splits an SSH string off the front of b.
+func parseString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
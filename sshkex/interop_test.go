@@ -0,0 +1,218 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package sshkex
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// These tests run the key exchange against golang.org/x/crypto/ssh over
+// a loopback connection, with just enough of the SSH transport to get
+// through SSH_MSG_NEWKEYS. A peer only sends NEWKEYS after it has
+// verified the host key signature over the exchange hash, so receiving
+// it confirms that both sides agree on H and K.
+
+const (
+	msgKexInit = 20
+	msgNewKeys = 21
+)
+
+type testTransport struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	version []byte
+}
+
+func newTestTransport(t *testing.T, conn net.Conn) *testTransport {
+	t.Helper()
+	tr := &testTransport{conn: conn, r: bufio.NewReader(conn)}
+	if _, err := io.WriteString(conn, "SSH-2.0-KyberK2SO_Test\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := tr.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.version = []byte(strings.TrimRight(line, "\r\n"))
+	return tr
+}
+
+func (tr *testTransport) writePacket(t *testing.T, payload []byte) {
+	t.Helper()
+	padding := 8 - (5+len(payload))%8
+	if padding < 4 {
+		padding += 8
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padding))
+	b = append(b, byte(padding))
+	b = append(b, payload...)
+	b = append(b, make([]byte, padding)...)
+	if _, err := tr.conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (tr *testTransport) readPacket(t *testing.T) []byte {
+	t.Helper()
+	var hdr [5]byte
+	if _, err := io.ReadFull(tr.r, hdr[:]); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, binary.BigEndian.Uint32(hdr[:4])-1)
+	if _, err := io.ReadFull(tr.r, b); err != nil {
+		t.Fatal(err)
+	}
+	return b[:len(b)-int(hdr[4])]
+}
+
+func kexInitPayload() []byte {
+	b := []byte{msgKexInit}
+	b = append(b, make([]byte, 16)...)
+	for _, list := range []string{
+		Name, ssh.KeyAlgoED25519, "aes128-ctr", "aes128-ctr", "hmac-sha2-256", "hmac-sha2-256",
+		"none", "none", "", "",
+	} {
+		b = appendString(b, []byte(list))
+	}
+	return append(b, 0, 0, 0, 0, 0)
+}
+
+func listen(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("loopback networking unavailable:", err)
+	}
+	return l, l.Addr().String()
+}
+
+func hostSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// TestServerInterop answers an x/crypto/ssh client.
+func TestServerInterop(t *testing.T) {
+	l, addr := listen(t)
+	defer l.Close()
+	signer := hostSigner(t)
+	go func() {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, _, _ = ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+			User:            "test",
+			HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+			Config:          ssh.Config{KeyExchanges: []string{Name}},
+		})
+	}()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tr := newTestTransport(t, conn)
+	serverKexInit := kexInitPayload()
+	tr.writePacket(t, serverKexInit)
+	clientKexInit := tr.readPacket(t)
+	if clientKexInit[0] != msgKexInit {
+		t.Fatalf("unexpected message %d", clientKexInit[0])
+	}
+	clientInit, err := ParseInit(tr.readPacket(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, k, err := ServerReply(clientInit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := &Handshake{tr.version, []byte("SSH-2.0-KyberK2SO_Test"), clientKexInit, serverKexInit}
+	hostKey := signer.PublicKey().Marshal()
+	h := ExchangeHash(hs, hostKey, clientInit, reply, k)
+	sig, err := signer.Sign(rand.Reader, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.writePacket(t, (&Reply{hostKey, reply, ssh.Marshal(sig)}).Marshal())
+	tr.writePacket(t, []byte{msgNewKeys})
+	if p := tr.readPacket(t); p[0] != msgNewKeys {
+		t.Fatalf("client rejected the key exchange with message %d", p[0])
+	}
+}
+
+// TestClientInterop runs the client side against an x/crypto/ssh server.
+func TestClientInterop(t *testing.T) {
+	l, addr := listen(t)
+	defer l.Close()
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostSigner(t))
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, _, _ = ssh.NewServerConn(conn, config)
+	}()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tr := newTestTransport(t, conn)
+	clientKexInit := kexInitPayload()
+	tr.writePacket(t, clientKexInit)
+	serverKexInit := tr.readPacket(t)
+	if serverKexInit[0] != msgKexInit {
+		t.Fatalf("unexpected message %d", serverKexInit[0])
+	}
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.writePacket(t, MarshalInit(c.Init()))
+	reply, err := ParseReply(tr.readPacket(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := c.Finish(reply.ServerReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := &Handshake{[]byte("SSH-2.0-KyberK2SO_Test"), tr.version, clientKexInit, serverKexInit}
+	h := ExchangeHash(hs, reply.HostKey, c.Init(), reply.ServerReply, k)
+	hostKey, err := ssh.ParsePublicKey(reply.HostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := new(ssh.Signature)
+	if err := ssh.Unmarshal(reply.Signature, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := hostKey.Verify(h, sig); err != nil {
+		t.Fatalf("host key signature over the exchange hash: %v", err)
+	}
+	if p := tr.readPacket(t); p[0] != msgNewKeys {
+		t.Fatalf("unexpected message %d", p[0])
+	}
+}
@@ -0,0 +1,172 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package sshkex implements the mlkem768x25519-sha256 hybrid key
+// exchange for SSH (draft-ietf-sshm-mlkem-hybrid-kex) as a standalone
+// component for SSH transports that run their own handshake.
+//
+// The client sends C_INIT, an ML-KEM-768 encapsulation key followed by
+// an X25519 public key. The server replies with S_REPLY, an ML-KEM-768
+// ciphertext followed by its own X25519 public key. Both sides derive
+// the shared secret K = SHA-256(K_PQ || K_CL), which enters the
+// exchange hash and the key derivation encoded as an SSH string
+// rather than as an mpint.
+//
+// golang.org/x/crypto/ssh ships this algorithm without exposing an
+// extension point for key exchanges, so this package is interoperable
+// with it but does not plug into it.
+package sshkex
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Name is the SSH key exchange method name.
+const Name = "mlkem768x25519-sha256"
+
+// Message numbers of the hybrid key exchange messages, shared with the
+// ECDH key exchange of RFC 5656.
+const (
+	MsgKexHybridInit  = 30
+	MsgKexHybridReply = 31
+)
+
+const (
+	// ClientInitSize is the size of C_INIT.
+	ClientInitSize = kyberk2so.Kyber768PKBytes + curve25519.PointSize
+
+	// ServerReplySize is the size of S_REPLY.
+	ServerReplySize = kyberk2so.Kyber768CTBytes + curve25519.PointSize
+)
+
+var (
+	// ErrInvalidClientInit is returned when C_INIT has the wrong length
+	// or carries an invalid encapsulation key or X25519 public key.
+	ErrInvalidClientInit = errors.New("sshkex: invalid client init")
+
+	// ErrInvalidServerReply is returned when S_REPLY has the wrong length
+	// or carries an invalid X25519 public key.
+	ErrInvalidServerReply = errors.New("sshkex: invalid server reply")
+
+	// ErrMalformedMessage is returned when a key exchange message cannot
+	// be parsed.
+	ErrMalformedMessage = errors.New("sshkex: malformed message")
+)
+
+// Client holds the client's ephemeral secrets for one key exchange.
+type Client struct {
+	dk     [kyberk2so.Kyber768SKBytes]byte
+	scalar [curve25519.ScalarSize]byte
+	init   []byte
+}
+
+// NewClient generates the client's ephemeral ML-KEM-768 and X25519
+// key pairs.
+func NewClient() (*Client, error) {
+	var seed [64]byte
+	var scalar [curve25519.ScalarSize]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(seed[:])
+	if _, err := rand.Read(scalar[:]); err != nil {
+		return nil, err
+	}
+	return newClient(seed, scalar)
+}
+
+// newClient derives the client state from the ML-KEM seed and the
+// X25519 scalar.
+func newClient(seed [64]byte, scalar [curve25519.ScalarSize]byte) (*Client, error) {
+	dk, ek, err := kyberk2so.KemKeypairDerand768(seed)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := curve25519.X25519(scalar[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{dk: dk, scalar: scalar}
+	c.init = append(ek[:], pub...)
+	return c, nil
+}
+
+// Init returns C_INIT, to be sent in SSH_MSG_KEX_HYBRID_INIT.
+func (c *Client) Init() []byte {
+	return append([]byte(nil), c.init...)
+}
+
+// Finish processes S_REPLY from SSH_MSG_KEX_HYBRID_REPLY and returns
+// the 32-byte shared secret K. The client's secrets are erased, so
+// Finish may only be called once.
+func (c *Client) Finish(reply []byte) ([]byte, error) {
+	defer mlkem.ZeroBytes(c.dk[:])
+	defer mlkem.ZeroBytes(c.scalar[:])
+	if len(reply) != ServerReplySize {
+		return nil, ErrInvalidServerReply
+	}
+	kPQ, err := kyberk2so.KemDecrypt768([kyberk2so.Kyber768CTBytes]byte(reply[:kyberk2so.Kyber768CTBytes]), c.dk)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(kPQ[:])
+	kCL, err := curve25519.X25519(c.scalar[:], reply[kyberk2so.Kyber768CTBytes:])
+	if err != nil {
+		return nil, ErrInvalidServerReply
+	}
+	defer mlkem.ZeroBytes(kCL)
+	return combine(kPQ[:], kCL), nil
+}
+
+// ServerReply processes C_INIT and returns S_REPLY together with the
+// 32-byte shared secret K.
+func ServerReply(init []byte) ([]byte, []byte, error) {
+	var m [32]byte
+	var scalar [curve25519.ScalarSize]byte
+	if _, err := rand.Read(m[:]); err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(m[:])
+	if _, err := rand.Read(scalar[:]); err != nil {
+		return nil, nil, err
+	}
+	defer mlkem.ZeroBytes(scalar[:])
+	return serverReply(init, m, scalar)
+}
+
+// serverReply computes S_REPLY and K from the encapsulation randomness
+// m and the server's X25519 scalar.
+func serverReply(init []byte, m [32]byte, scalar [curve25519.ScalarSize]byte) ([]byte, []byte, error) {
+	if len(init) != ClientInitSize {
+		return nil, nil, ErrInvalidClientInit
+	}
+	ct, kPQ, err := kyberk2so.KemEncryptDerand768([kyberk2so.Kyber768PKBytes]byte(init[:kyberk2so.Kyber768PKBytes]), m)
+	if err != nil {
+		return nil, nil, ErrInvalidClientInit
+	}
+	defer mlkem.ZeroBytes(kPQ[:])
+	kCL, err := curve25519.X25519(scalar[:], init[kyberk2so.Kyber768PKBytes:])
+	if err != nil {
+		return nil, nil, ErrInvalidClientInit
+	}
+	defer mlkem.ZeroBytes(kCL)
+	pub, err := curve25519.X25519(scalar[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(ct[:], pub...), combine(kPQ[:], kCL), nil
+}
+
+// combine returns K = SHA-256(K_PQ || K_CL).
+func combine(kPQ, kCL []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write(kPQ)
+	_, _ = h.Write(kCL)
+	return h.Sum(nil)
+}
@@ -0,0 +1,164 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package sshkex
+
+import (
+	"bytes"
+	"crypto/mlkem"
+	"crypto/mlkem/mlkemtest"
+	"crypto/sha256"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := c.Init()
+	if len(init) != ClientInitSize {
+		t.Fatalf("unexpected C_INIT length %d", len(init))
+	}
+	reply, kServer, err := ServerReply(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply) != ServerReplySize {
+		t.Fatalf("unexpected S_REPLY length %d", len(reply))
+	}
+	kClient, err := c.Finish(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(kClient, kServer) {
+		t.Fatal("shared secret mismatch")
+	}
+}
+
+// TestStandardLibrary recomputes a fixed exchange with crypto/mlkem
+// and curve25519 independently of this package.
+func TestStandardLibrary(t *testing.T) {
+	var seed [64]byte
+	var clientScalar, serverScalar [32]byte
+	var m [32]byte
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	for i := range m {
+		m[i] = byte(0x40 + i)
+		clientScalar[i] = byte(0x80 + i)
+		serverScalar[i] = byte(0xc0 + i)
+	}
+	c, err := newClient(seed, clientScalar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, k, err := serverReply(c.Init(), m, serverScalar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dk, err := mlkem.NewDecapsulationKey768(seed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, _ := curve25519.X25519(clientScalar[:], curve25519.Basepoint)
+	if !bytes.Equal(c.Init(), append(dk.EncapsulationKey().Bytes(), clientPub...)) {
+		t.Fatal("C_INIT mismatch")
+	}
+	kPQ, ct, err := mlkemtest.Encapsulate768(dk.EncapsulationKey(), m[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverPub, _ := curve25519.X25519(serverScalar[:], curve25519.Basepoint)
+	if !bytes.Equal(reply, append(ct, serverPub...)) {
+		t.Fatal("S_REPLY mismatch")
+	}
+	kCL, _ := curve25519.X25519(serverScalar[:], clientPub)
+	want := sha256.Sum256(append(kPQ, kCL...))
+	if !bytes.Equal(k, want[:]) {
+		t.Fatal("shared secret mismatch")
+	}
+	got, err := c.Finish(reply)
+	if err != nil || !bytes.Equal(got, want[:]) {
+		t.Fatalf("client shared secret mismatch: %v", err)
+	}
+}
+
+func TestInvalidInputs(t *testing.T) {
+	c, _ := NewClient()
+	init := c.Init()
+	if _, _, err := ServerReply(init[:ClientInitSize-1]); err != ErrInvalidClientInit {
+		t.Fatalf("expected ErrInvalidClientInit, got %v", err)
+	}
+	// A low-order X25519 share yields an all-zero secret.
+	lowOrder := bytes.Clone(init)
+	clear(lowOrder[ClientInitSize-curve25519.PointSize:])
+	if _, _, err := ServerReply(lowOrder); err != ErrInvalidClientInit {
+		t.Fatalf("expected ErrInvalidClientInit, got %v", err)
+	}
+	// Coefficients must be reduced modulo q.
+	unreduced := bytes.Clone(init)
+	unreduced[0], unreduced[1] = 0xff, 0x0f
+	if _, _, err := ServerReply(unreduced); err != ErrInvalidClientInit {
+		t.Fatalf("expected ErrInvalidClientInit, got %v", err)
+	}
+	reply, _, _ := ServerReply(init)
+	if _, err := c.Finish(reply[1:]); err != ErrInvalidServerReply {
+		t.Fatalf("expected ErrInvalidServerReply, got %v", err)
+	}
+	c, _ = NewClient()
+	reply, _, _ = ServerReply(c.Init())
+	clear(reply[ServerReplySize-curve25519.PointSize:])
+	if _, err := c.Finish(reply); err != ErrInvalidServerReply {
+		t.Fatalf("expected ErrInvalidServerReply, got %v", err)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	init := bytes.Repeat([]byte{1}, ClientInitSize)
+	got, err := ParseInit(MarshalInit(init))
+	if err != nil || !bytes.Equal(got, init) {
+		t.Fatalf("init round trip failed: %v", err)
+	}
+	r := &Reply{HostKey: []byte("key"), ServerReply: []byte("reply"), Signature: []byte("sig")}
+	parsed, err := ParseReply(r.Marshal())
+	if err != nil || string(parsed.HostKey) != "key" || string(parsed.ServerReply) != "reply" ||
+		string(parsed.Signature) != "sig" {
+		t.Fatalf("reply round trip failed: %v", err)
+	}
+	for _, bad := range [][]byte{
+		nil,
+		{MsgKexHybridReply, 0, 0, 0, 1},
+		append(MarshalInit(init), 0),
+		MarshalInit(init)[:10],
+	} {
+		if _, err := ParseInit(bad); err != ErrMalformedMessage {
+			t.Fatalf("%x: expected ErrMalformedMessage, got %v", bad, err)
+		}
+	}
+	if _, err := ParseReply(r.Marshal()[:12]); err != ErrMalformedMessage {
+		t.Fatalf("expected ErrMalformedMessage, got %v", err)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	k, h := []byte("k"), []byte("h")
+	long := DeriveKey(k, h, h, 'C', 80)
+	if !bytes.Equal(long[:32], DeriveKey(k, h, h, 'C', 32)) {
+		t.Fatal("derived key prefix mismatch")
+	}
+	d := sha256.New()
+	writeString(d, k)
+	d.Write(h)
+	d.Write(long[:32])
+	if !bytes.Equal(long[32:64], d.Sum(nil)) {
+		t.Fatal("key extension mismatch")
+	}
+	if bytes.Equal(long[:32], DeriveKey(k, h, h, 'D', 32)) {
+		t.Fatal("letters are not separated")
+	}
+}
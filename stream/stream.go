@@ -0,0 +1,367 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+// Package stream implements streaming file encryption to an ML-KEM-1024
+// key, for payloads too large to hold in memory.
+//
+// A stream is a header followed by a body:
+//
+//	header = version (0x01) || ML-KEM-1024 ciphertext
+//	body   = chunk_0 || chunk_1 || ... || chunk_n
+//
+// The payload key is derived with HKDF-SHA256 from the shared secret of
+// the KemEncrypt1024 ciphertext, with the header as info. The body uses
+// the STREAM construction: the plaintext is split into ChunkSize
+// chunks, the last of which may be shorter and is only empty for an
+// empty payload, and each chunk is sealed with ChaCha20-Poly1305 under
+// a nonce made of an 11-byte big-endian chunk counter and a final-chunk
+// flag byte. Reordered, duplicated or modified chunks fail to open, a
+// stream truncated at a chunk boundary lacks its final chunk, and data
+// after the final chunk is rejected.
+//
+// Since chunks have a fixed size, any range of the plaintext can be
+// decrypted from a seekable source without reading the chunks before
+// it; see NewDecryptingReaderAt.
+package stream
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+	"github.com/symbolicsoft/kyber-k2so/internal/mlkem"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	// ErrInvalidHeader is returned when a stream is too short to hold a
+	// header, or has an unknown version.
+	ErrInvalidHeader = errors.New("stream: invalid header")
+
+	// ErrMalformed is returned when a chunk fails to open, or a stream is
+	// truncated or has data after its final chunk.
+	ErrMalformed = errors.New("stream: malformed or truncated stream")
+
+	// ErrClosed is returned when writing to a closed stream.
+	ErrClosed = errors.New("stream: writer is closed")
+)
+
+// Version is the version byte of streams.
+const Version = 0x01
+
+// HeaderSize is the size of the stream header.
+const HeaderSize = 1 + kyberk2so.Kyber1024CTBytes
+
+// ChunkSize is the plaintext size of every chunk but the last.
+const ChunkSize = 64 * 1024
+
+const (
+	encryptedChunkSize = ChunkSize + chacha20poly1305.Overhead
+	lastChunkFlag      = 0x01
+)
+
+// payloadAEAD derives the payload AEAD from the header and the shared
+// secret.
+func payloadAEAD(header []byte, ss [kyberk2so.KyberSSBytes]byte) (cipher.AEAD, error) {
+	defer mlkem.ZeroBytes(ss[:])
+	key, err := hkdf.Key(sha256.New, ss[:], nil, "kyberk2so stream v1"+string(header), chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer mlkem.ZeroBytes(key)
+	return chacha20poly1305.New(key)
+}
+
+// openHeader checks the version of a header and decapsulates its
+// ciphertext.
+func openHeader(header []byte, dk [kyberk2so.Kyber1024SKBytes]byte) (cipher.AEAD, error) {
+	if header[0] != Version {
+		return nil, ErrInvalidHeader
+	}
+	ss, err := kyberk2so.KemDecrypt1024([kyberk2so.Kyber1024CTBytes]byte(header[1:]), dk)
+	if err != nil {
+		return nil, err
+	}
+	return payloadAEAD(header, ss)
+}
+
+// chunkNonce returns the nonce of chunk i.
+func chunkNonce(i uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], i)
+	if last {
+		nonce[11] = lastChunkFlag
+	}
+	return nonce
+}
+
+// encryptingWriter seals the chunks of a stream.
+type encryptingWriter struct {
+	aead  cipher.AEAD
+	dst   io.Writer
+	buf   []byte
+	chunk uint64
+	err   error
+}
+
+// NewEncryptingWriter writes a stream header to w and returns a
+// WriteCloser that encrypts to w under a fresh key encapsulated to ek.
+// Close must be called to write the final chunk; it does not close w.
+func NewEncryptingWriter(w io.Writer, ek [kyberk2so.Kyber1024PKBytes]byte) (io.WriteCloser, error) {
+	ct, ss, err := kyberk2so.KemEncrypt1024(ek)
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte{Version}, ct[:]...)
+	aead, err := payloadAEAD(header, ss)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{aead: aead, dst: w, buf: make([]byte, 0, encryptedChunkSize)}, nil
+}
+
+// Write buffers p, flushing full chunks only once more data follows
+// them, so that the final chunk is never empty unless the whole
+// payload is.
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	total := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == ChunkSize {
+			if err := w.flushChunk(false); err != nil {
+				w.err = err
+				return total - len(p), err
+			}
+		}
+		n := min(ChunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// Close writes the final chunk.
+func (w *encryptingWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.flushChunk(true)
+	w.err = ErrClosed
+	return err
+}
+
+// flushChunk seals and writes the buffered chunk.
+func (w *encryptingWriter) flushChunk(last bool) error {
+	w.buf = w.aead.Seal(w.buf[:0], chunkNonce(w.chunk, last), w.buf, nil)
+	_, err := w.dst.Write(w.buf)
+	w.buf = w.buf[:0]
+	w.chunk++
+	return err
+}
+
+// decryptingReader opens the chunks of a stream in order.
+type decryptingReader struct {
+	aead   cipher.AEAD
+	src    io.Reader
+	buf    []byte
+	plain  []byte
+	unread []byte
+	chunk  uint64
+	err    error
+}
+
+// NewDecryptingReader reads a stream header from r and returns a Reader
+// that decrypts the rest of r with dk. Truncation, reordering and
+// duplication of chunks, missing final chunks and trailing data are
+// reported as ErrMalformed; plaintext is only returned once its chunk
+// has been authenticated.
+func NewDecryptingReader(r io.Reader, dk [kyberk2so.Kyber1024SKBytes]byte) (io.Reader, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrInvalidHeader
+		}
+		return nil, err
+	}
+	aead, err := openHeader(header, dk)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		aead:  aead,
+		src:   r,
+		buf:   make([]byte, encryptedChunkSize),
+		plain: make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// Read implements io.Reader.
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if len(r.unread) > 0 {
+		n := copy(p, r.unread)
+		r.unread = r.unread[n:]
+		return n, nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	last, err := r.readChunk()
+	if err != nil {
+		r.err = err
+		return 0, err
+	}
+	if last {
+		var extra [1]byte
+		switch _, err := io.ReadFull(r.src, extra[:]); err {
+		case io.EOF:
+			r.err = io.EOF
+		case nil:
+			r.unread = nil
+			r.err = ErrMalformed
+			return 0, r.err
+		default:
+			r.err = err
+		}
+	}
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk. A short chunk must be the
+// last one; a full chunk is tried as a regular chunk first and as the
+// last chunk otherwise.
+func (r *decryptingReader) readChunk() (bool, error) {
+	in := r.buf
+	n, err := io.ReadFull(r.src, in)
+	last := false
+	switch err {
+	case nil:
+	case io.EOF:
+		return false, ErrMalformed
+	case io.ErrUnexpectedEOF:
+		if n == chacha20poly1305.Overhead && r.chunk != 0 {
+			// Only an empty payload may end with an empty chunk.
+			return false, ErrMalformed
+		}
+		in, last = in[:n], true
+	default:
+		return false, err
+	}
+	out, err := r.aead.Open(r.plain[:0], chunkNonce(r.chunk, last), in, nil)
+	if err != nil && !last {
+		last = true
+		out, err = r.aead.Open(r.plain[:0], chunkNonce(r.chunk, last), in, nil)
+	}
+	if err != nil {
+		return false, ErrMalformed
+	}
+	r.unread = out
+	r.chunk++
+	return last, nil
+}
+
+// ReaderAt decrypts arbitrary ranges of a stream from a seekable source.
+// It is safe for concurrent use if the source is.
+type ReaderAt struct {
+	aead    cipher.AEAD
+	src     io.ReaderAt
+	chunks  int64
+	lastLen int64
+	size    int64
+}
+
+// NewDecryptingReaderAt returns a ReaderAt that decrypts the stream of
+// the given size in r with dk. It authenticates the final chunk, and
+// with it the length of the stream, before returning; other chunks are
+// authenticated as they are read.
+func NewDecryptingReaderAt(r io.ReaderAt, size int64, dk [kyberk2so.Kyber1024SKBytes]byte) (*ReaderAt, error) {
+	if size < int64(HeaderSize) {
+		return nil, ErrInvalidHeader
+	}
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	aead, err := openHeader(header, dk)
+	if err != nil {
+		return nil, err
+	}
+	body := size - int64(HeaderSize)
+	if body < chacha20poly1305.Overhead {
+		return nil, ErrMalformed
+	}
+	chunks := (body + encryptedChunkSize - 1) / encryptedChunkSize
+	lastLen := body - (chunks-1)*encryptedChunkSize
+	if chunks > 1 && lastLen == chacha20poly1305.Overhead {
+		return nil, ErrMalformed
+	}
+	ra := &ReaderAt{
+		aead:    aead,
+		src:     r,
+		chunks:  chunks,
+		lastLen: lastLen,
+		size:    body - chunks*chacha20poly1305.Overhead,
+	}
+	if _, err := ra.readChunk(chunks-1, make([]byte, encryptedChunkSize)); err != nil {
+		return nil, err
+	}
+	return ra, nil
+}
+
+// Size returns the size of the plaintext.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt, decrypting only the chunks that hold
+// the plaintext range [off, off+len(p)).
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("stream: negative offset")
+	}
+	buf := make([]byte, encryptedChunkSize)
+	n := 0
+	for n < len(p) && off < r.size {
+		plain, err := r.readChunk(off/ChunkSize, buf)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], plain[off%ChunkSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readChunk reads and opens chunk i into buf.
+func (r *ReaderAt) readChunk(i int64, buf []byte) ([]byte, error) {
+	last := i == r.chunks-1
+	in := buf[:encryptedChunkSize]
+	if last {
+		in = buf[:r.lastLen]
+	}
+	if _, err := r.src.ReadAt(in, int64(HeaderSize)+i*encryptedChunkSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	out, err := r.aead.Open(in[:0], chunkNonce(uint64(i), last), in, nil)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return out, nil
+}
@@ -0,0 +1,297 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	kyberk2so "github.com/symbolicsoft/kyber-k2so"
+)
+
+func keyPair(tb testing.TB) ([kyberk2so.Kyber1024SKBytes]byte, [kyberk2so.Kyber1024PKBytes]byte) {
+	tb.Helper()
+	var coins [64]byte
+	dk, ek, err := kyberk2so.KemKeypairDerand1024(coins)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return dk, ek
+}
+
+func payload(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	return p
+}
+
+func encrypt(tb testing.TB, ek [kyberk2so.Kyber1024PKBytes]byte, plaintext []byte, writeSize int) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	w, err := NewEncryptingWriter(&buf, ek)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for p := plaintext; len(p) > 0; {
+		n := min(writeSize, len(p))
+		if _, err := w.Write(p[:n]); err != nil {
+			tb.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(dk [kyberk2so.Kyber1024SKBytes]byte, stream []byte) ([]byte, error) {
+	r, err := NewDecryptingReader(bytes.NewReader(stream), dk)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	dk, ek := keyPair(t)
+	for _, n := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize, 3*ChunkSize + 100} {
+		plaintext := payload(n)
+		stream := encrypt(t, ek, plaintext, 1000)
+		chunks := max(1, (n+ChunkSize-1)/ChunkSize)
+		if len(stream) != HeaderSize+n+chunks*16 {
+			t.Fatalf("%d bytes: stream is %d bytes", n, len(stream))
+		}
+		got, err := decrypt(dk, stream)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("%d bytes: plaintext differs", n)
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	dk, ek := keyPair(t)
+	stream := encrypt(t, ek, payload(3*ChunkSize+100), ChunkSize)
+	chunk := func(i int) []byte {
+		start := HeaderSize + i*encryptedChunkSize
+		return stream[start:min(start+encryptedChunkSize, len(stream))]
+	}
+	header := stream[:HeaderSize]
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(append([][]byte{header}, parts...), nil)
+	}
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+	}{
+		{"truncated at a chunk boundary", join(chunk(0), chunk(1), chunk(2))},
+		{"truncated within a chunk", stream[:len(stream)-1]},
+		{"reordered", join(chunk(1), chunk(0), chunk(2), chunk(3))},
+		{"duplicated", join(chunk(0), chunk(0), chunk(1), chunk(2), chunk(3))},
+		{"missing chunk", join(chunk(0), chunk(2), chunk(3))},
+		{"trailing data", append(bytes.Clone(stream), 0)},
+		{"appended chunk", join(chunk(0), chunk(1), chunk(2), chunk(3), chunk(3))},
+		{"empty body", header},
+	} {
+		if _, err := decrypt(dk, tc.stream); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %v, want ErrMalformed", tc.name, err)
+		}
+	}
+	for _, offset := range []int{1, HeaderSize - 1, HeaderSize, HeaderSize + encryptedChunkSize + 5, len(stream) - 1} {
+		tampered := bytes.Clone(stream)
+		tampered[offset] ^= 1
+		if _, err := decrypt(dk, tampered); !errors.Is(err, ErrMalformed) {
+			t.Errorf("byte %d: got %v, want ErrMalformed", offset, err)
+		}
+	}
+	tampered := bytes.Clone(stream)
+	tampered[0] = Version + 1
+	if _, err := decrypt(dk, tampered); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("version: got %v, want ErrInvalidHeader", err)
+	}
+	if _, err := decrypt(dk, stream[:HeaderSize-1]); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("short header: got %v, want ErrInvalidHeader", err)
+	}
+	otherDK, _, err := kyberk2so.KemKeypair1024()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(otherDK, stream); !errors.Is(err, ErrMalformed) {
+		t.Errorf("wrong key: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	dk, ek := keyPair(t)
+	plaintext := payload(3*ChunkSize + 100)
+	stream := encrypt(t, ek, plaintext, 4096)
+	r, err := NewDecryptingReaderAt(bytes.NewReader(stream), int64(len(stream)), dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(plaintext)) {
+		t.Fatalf("size %d, want %d", r.Size(), len(plaintext))
+	}
+	for _, tc := range []struct{ off, n int }{
+		{0, 10},
+		{ChunkSize - 5, 10},
+		{2*ChunkSize + 17, ChunkSize + 50},
+		{0, len(plaintext)},
+		{len(plaintext) - 3, 3},
+	} {
+		p := make([]byte, tc.n)
+		if n, err := r.ReadAt(p, int64(tc.off)); err != nil || n != tc.n {
+			t.Fatalf("range %d+%d: read %d, %v", tc.off, tc.n, n, err)
+		}
+		if !bytes.Equal(p, plaintext[tc.off:tc.off+tc.n]) {
+			t.Fatalf("range %d+%d: plaintext differs", tc.off, tc.n)
+		}
+	}
+	p := make([]byte, 10)
+	if n, err := r.ReadAt(p, int64(len(plaintext)-4)); n != 4 || err != io.EOF {
+		t.Fatalf("read past the end: %d, %v", n, err)
+	}
+	sr := io.NewSectionReader(r, ChunkSize, 2*ChunkSize)
+	if got, err := io.ReadAll(sr); err != nil || !bytes.Equal(got, plaintext[ChunkSize:3*ChunkSize]) {
+		t.Fatal("section does not match")
+	}
+	// A stream truncated at a chunk boundary is rejected up front.
+	truncated := stream[:HeaderSize+3*encryptedChunkSize]
+	if _, err := NewDecryptingReaderAt(bytes.NewReader(truncated), int64(len(truncated)), dk); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("truncated: got %v, want ErrMalformed", err)
+	}
+	// A modified chunk only fails the ranges that cover it.
+	tampered := bytes.Clone(stream)
+	tampered[HeaderSize+encryptedChunkSize+1] ^= 1
+	r, err = NewDecryptingReaderAt(bytes.NewReader(tampered), int64(len(tampered)), dk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(p, ChunkSize); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("tampered chunk: got %v, want ErrMalformed", err)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	_, ek := keyPair(t)
+	w, err := NewEncryptingWriter(io.Discard, ek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
+
+// FuzzDecryptingReader checks that modified bodies never panic and
+// never yield plaintext other than the original.
+func FuzzDecryptingReader(f *testing.F) {
+	dk, ek := keyPair(f)
+	plaintext := payload(40)
+	stream := encrypt(f, ek, plaintext, len(plaintext))
+	f.Add(stream[HeaderSize:])
+	f.Add(stream[HeaderSize : len(stream)-1])
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, body []byte) {
+		checkModified(t, dk, stream, append(bytes.Clone(stream[:HeaderSize]), body...), plaintext)
+	})
+}
+
+// FuzzFraming rebuilds a multi-chunk stream from a program of chunk
+// operations, covering reordering, duplication, truncation and
+// modification of chunks without fuzzing megabytes of input.
+func FuzzFraming(f *testing.F) {
+	dk, ek := keyPair(f)
+	plaintext := payload(3*ChunkSize + 100)
+	stream := encrypt(f, ek, plaintext, ChunkSize)
+	var chunks [][]byte
+	for b := stream[HeaderSize:]; len(b) > 0; {
+		n := min(encryptedChunkSize, len(b))
+		chunks, b = append(chunks, b[:n]), b[n:]
+	}
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3})
+	f.Add([]byte{0, 0, 0, 1, 0, 2})
+	f.Add([]byte{0, 1, 0, 0, 0, 2, 0, 3})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3, 0, 3})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 1, 3})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3, 2, 0})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3, 3, 7})
+	f.Fuzz(func(t *testing.T, program []byte) {
+		modified := bytes.Clone(stream[:HeaderSize])
+		for i := 0; i+1 < len(program); i += 2 {
+			arg := int(program[i+1])
+			switch program[i] % 4 {
+			case 0:
+				modified = append(modified, chunks[arg%len(chunks)]...)
+			case 1:
+				c := chunks[arg%len(chunks)]
+				modified = append(modified, c[:len(c)-1-arg%len(c)]...)
+			case 2:
+				modified = append(modified, byte(arg))
+			case 3:
+				modified[HeaderSize+arg%(len(modified)-HeaderSize+1)-1] ^= 1
+			}
+		}
+		checkModified(t, dk, stream, modified, plaintext)
+	})
+}
+
+// checkModified checks that a stream other than the original fails to
+// decrypt, and that any range of it that does decrypt is original
+// plaintext.
+func checkModified(t *testing.T, dk [kyberk2so.Kyber1024SKBytes]byte, stream, modified, plaintext []byte) {
+	got, err := decrypt(dk, modified)
+	if err == nil && (!bytes.Equal(modified, stream) || !bytes.Equal(got, plaintext)) {
+		t.Fatal("modified stream was accepted")
+	}
+	r, err := NewDecryptingReaderAt(bytes.NewReader(modified), int64(len(modified)), dk)
+	if err != nil {
+		return
+	}
+	if r.Size() != int64(len(plaintext)) {
+		t.Fatal("modified stream has another length")
+	}
+	p := make([]byte, 100)
+	for off := int64(0); off < r.Size(); off += ChunkSize / 2 {
+		n, err := r.ReadAt(p, off)
+		if (err == nil || err == io.EOF) && !bytes.Equal(p[:n], plaintext[off:off+int64(n)]) {
+			t.Fatalf("range at %d decrypted to other plaintext", off)
+		}
+	}
+}
+
+// FuzzRoundTrip checks the framing of payloads of any length written in
+// writes of any size.
+func FuzzRoundTrip(f *testing.F) {
+	dk, ek := keyPair(f)
+	f.Add(uint32(0), uint16(1))
+	f.Add(uint32(ChunkSize), uint16(ChunkSize-1))
+	f.Add(uint32(2*ChunkSize+1), uint16(7))
+	f.Fuzz(func(t *testing.T, n uint32, writeSize uint16) {
+		n %= 4 * ChunkSize
+		plaintext := payload(int(n))
+		stream := encrypt(t, ek, plaintext, max(1, int(writeSize)))
+		got, err := decrypt(dk, stream)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("%d bytes in writes of %d: round trip failed", n, writeSize)
+		}
+		r, err := NewDecryptingReaderAt(bytes.NewReader(stream), int64(len(stream)), dk)
+		if err != nil || r.Size() != int64(n) {
+			t.Fatalf("%d bytes: seekable reader failed", n)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import "crypto/subtle"
+
+// kemTrialSelect folds the result of one trial decapsulation into the
+// shared secret: the first key's result is the default, so that a
+// ciphertext no key accepts yields its implicit rejection value, and
+// the first accepting key's secret replaces it. found records in
+// constant time whether a key has accepted.
+func kemTrialSelect(sharedSecret, candidate *[KyberSSBytes]byte, i, accept int, found *int) {
+	take := accept &^ *found
+	if i == 0 {
+		take = 1
+	}
+	subtle.ConstantTimeCopy(take, sharedSecret[:], candidate[:])
+	*found |= accept
+	byteopsZeroBytes(candidate[:])
+}
+
+// KemDecryptTrial512 takes a ciphertext (from KemEncrypt512) and
+// several private keys (from KemKeypair512) and returns the shared secret
+// of the key that accepts the ciphertext, for ciphertexts that do not
+// name their recipient key. Every key is tried, and the selection runs in
+// constant time; if no key accepts, the implicit rejection value of the
+// first key is returned, exactly as KemDecrypt512 with that key would.
+// An accompanying error is returned if no keys are given or a private
+// key is invalid.
+func KemDecryptTrial512(
+	ciphertext [Kyber512CTBytes]byte,
+	privateKeys [][Kyber512SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	var sharedSecret [KyberSSBytes]byte
+	if len(privateKeys) == 0 {
+		return sharedSecret, ErrInvalidDecapsulationKey
+	}
+	found := 0
+	for i := range privateKeys {
+		candidate, accept, err := kemDecrypt512(ciphertext, privateKeys[i])
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return sharedSecret, err
+		}
+		kemTrialSelect(&sharedSecret, &candidate, i, accept, &found)
+	}
+	return sharedSecret, nil
+}
+
+// KemDecryptTrial768 takes a ciphertext (from KemEncrypt768) and
+// several private keys (from KemKeypair768) and returns the shared secret
+// of the key that accepts the ciphertext, for ciphertexts that do not
+// name their recipient key. Every key is tried, and the selection runs in
+// constant time; if no key accepts, the implicit rejection value of the
+// first key is returned, exactly as KemDecrypt768 with that key would.
+// An accompanying error is returned if no keys are given or a private
+// key is invalid.
+func KemDecryptTrial768(
+	ciphertext [Kyber768CTBytes]byte,
+	privateKeys [][Kyber768SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	var sharedSecret [KyberSSBytes]byte
+	if len(privateKeys) == 0 {
+		return sharedSecret, ErrInvalidDecapsulationKey
+	}
+	found := 0
+	for i := range privateKeys {
+		candidate, accept, err := kemDecrypt768(ciphertext, privateKeys[i])
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return sharedSecret, err
+		}
+		kemTrialSelect(&sharedSecret, &candidate, i, accept, &found)
+	}
+	return sharedSecret, nil
+}
+
+// KemDecryptTrial1024 takes a ciphertext (from KemEncrypt1024) and
+// several private keys (from KemKeypair1024) and returns the shared secret
+// of the key that accepts the ciphertext, for ciphertexts that do not
+// name their recipient key. Every key is tried, and the selection runs in
+// constant time; if no key accepts, the implicit rejection value of the
+// first key is returned, exactly as KemDecrypt1024 with that key would.
+// An accompanying error is returned if no keys are given or a private
+// key is invalid.
+func KemDecryptTrial1024(
+	ciphertext [Kyber1024CTBytes]byte,
+	privateKeys [][Kyber1024SKBytes]byte,
+) ([KyberSSBytes]byte, error) {
+	var sharedSecret [KyberSSBytes]byte
+	if len(privateKeys) == 0 {
+		return sharedSecret, ErrInvalidDecapsulationKey
+	}
+	found := 0
+	for i := range privateKeys {
+		candidate, accept, err := kemDecrypt1024(ciphertext, privateKeys[i])
+		if err != nil {
+			byteopsZeroBytes(sharedSecret[:])
+			return sharedSecret, err
+		}
+		kemTrialSelect(&sharedSecret, &candidate, i, accept, &found)
+	}
+	return sharedSecret, nil
+}
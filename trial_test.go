@@ -0,0 +1,64 @@
+/* SPDX-FileCopyrightText: © 2020-2026 Nadim Kobeissi <nadim@symbolic.software>
+ * SPDX-License-Identifier: MIT */
+
+package kyberk2so
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKemDecryptTrial768(t *testing.T) {
+	privateKeys := make([][Kyber768SKBytes]byte, 3)
+	publicKeys := make([][Kyber768PKBytes]byte, 3)
+	for i := range privateKeys {
+		privateKeys[i], publicKeys[i], _ = KemKeypair768()
+	}
+	for i := range publicKeys {
+		ciphertext, ssA, err := KemEncrypt768(publicKeys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ssB, err := KemDecryptTrial768(ciphertext, privateKeys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ssA != ssB {
+			t.Fatalf("key %d: shared secrets differ", i)
+		}
+	}
+	// A ciphertext no key accepts yields the first key's implicit
+	// rejection value.
+	other, otherPK, _ := KemKeypair768()
+	ciphertext, _, _ := KemEncrypt768(otherPK)
+	ssA, _ := KemDecrypt768(ciphertext, privateKeys[0])
+	ssB, err := KemDecryptTrial768(ciphertext, privateKeys)
+	if err != nil || ssA != ssB {
+		t.Fatal("rejection does not match KemDecrypt768 with the first key")
+	}
+	if ss, _ := KemDecryptTrial768(ciphertext, append(privateKeys, other)); ss == ssA {
+		t.Fatal("accepting key was not selected")
+	}
+	if _, err := KemDecryptTrial768(ciphertext, nil); !errors.Is(err, ErrInvalidDecapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidDecapsulationKey", err)
+	}
+	privateKeys[1][Kyber768SKBytes-40] ^= 1
+	if _, err := KemDecryptTrial768(ciphertext, privateKeys); !errors.Is(err, ErrInvalidDecapsulationKey) {
+		t.Fatalf("got %v, want ErrInvalidDecapsulationKey", err)
+	}
+}
+
+func TestKemDecryptTrial512And1024(t *testing.T) {
+	sk512a, _, _ := KemKeypair512()
+	sk512b, pk512b, _ := KemKeypair512()
+	ct512, ss512, _ := KemEncrypt512(pk512b)
+	if ss, err := KemDecryptTrial512(ct512, [][Kyber512SKBytes]byte{sk512a, sk512b}); err != nil || ss != ss512 {
+		t.Fatal("ML-KEM-512 trial decapsulation failed")
+	}
+	sk1024a, _, _ := KemKeypair1024()
+	sk1024b, pk1024b, _ := KemKeypair1024()
+	ct1024, ss1024, _ := KemEncrypt1024(pk1024b)
+	if ss, err := KemDecryptTrial1024(ct1024, [][Kyber1024SKBytes]byte{sk1024a, sk1024b}); err != nil || ss != ss1024 {
+		t.Fatal("ML-KEM-1024 trial decapsulation failed")
+	}
+}